@@ -0,0 +1,79 @@
+// Package audit records who did what to which PLC, one JSON object per
+// line, for operations that change more than tag values.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/auth"
+)
+
+// Entry is one audited call.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// User and Role are the caller, empty for anonymous calls.
+	User   string `json:"user,omitempty"`
+	Role   string `json:"role,omitempty"`
+	Action string `json:"action"`
+	Plc    string `json:"plc,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Result is "ok", "confirm" when a confirmation token was issued,
+	// "denied", "alert" for a change goplc detected, or the error.
+	Result string `json:"result"`
+}
+
+// Log writes entries to a writer. A nil Log records nothing.
+type Log struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func New(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// Open appends to the file at path, creating it if needed.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return New(f), nil
+}
+
+// Record writes e, with the time and the caller of ctx filled in.
+func (l *Log) Record(ctx context.Context, e Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if c, ok := auth.FromContext(ctx); ok {
+		e.User, e.Role = c.Name, c.Role.String()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// Close closes the underlying writer if it is a closer.
+func (l *Log) Close() error {
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/thinkontrolsy/goplc/auth"
+)
+
+func TestRecord(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b)
+	ctx := auth.NewContext(context.Background(), auth.Caller{Name: "eng", Role: auth.Engineer})
+	l.Record(ctx, Entry{Action: "StopCpu", Plc: "s7 10.0.0.1", Result: "ok"})
+	l.Record(context.Background(), Entry{Action: "GetCpuState", Result: "denied"})
+
+	dec := json.NewDecoder(&b)
+	var e Entry
+	if err := dec.Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.User != "eng" || e.Role != "engineer" || e.Action != "StopCpu" || e.Result != "ok" || e.Time.IsZero() {
+		t.Fatalf("first entry %+v", e)
+	}
+	e = Entry{}
+	if err := dec.Decode(&e); err != nil || e.User != "" || e.Result != "denied" {
+		t.Fatalf("second entry %+v, %v", e, err)
+	}
+
+	// a nil log records nothing
+	var nilLog *Log
+	nilLog.Record(ctx, Entry{Action: "StopCpu"})
+}
@@ -0,0 +1,98 @@
+// Package auth holds the users of the HTTP services of goplc and the roles
+// that decide what they may do.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+)
+
+// Role is what a user may do. Every role may do what the roles before it
+// may.
+type Role int
+
+const (
+	// Viewer may read tags and CPU information.
+	Viewer Role = iota + 1
+	// Operator may also write tags.
+	Operator
+	// Engineer may also change the operating state of CPUs.
+	Engineer
+)
+
+var roleNames = map[Role]string{
+	Viewer:   "viewer",
+	Operator: "operator",
+	Engineer: "engineer",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// Allows reports whether r may do what required may.
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *Role) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	for role, name := range roleNames {
+		if name == s {
+			*r = role
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown role %q", s)
+}
+
+type User struct {
+	Password string `json:"password"`
+	Role     Role   `json:"role"`
+}
+
+// Users maps user names to users.
+type Users map[string]User
+
+// Authenticate returns the role of name if password is right.
+func (u Users) Authenticate(name, password string) (Role, bool) {
+	user, ok := u[name]
+	if !ok {
+		// compare anyway, so that unknown names take as long
+		user.Password = "\x00" + password
+	}
+	if subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 || !ok {
+		return 0, false
+	}
+	return user.Role, true
+}
+
+type contextKey struct{}
+
+// Caller is an authenticated user.
+type Caller struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
+
+func NewContext(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the caller stored in ctx.
+func FromContext(ctx context.Context) (Caller, bool) {
+	c, ok := ctx.Value(contextKey{}).(Caller)
+	return c, ok
+}
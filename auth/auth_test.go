@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUsers(t *testing.T) {
+	var users Users
+	if err := json.Unmarshal([]byte(`{"tech":{"password":"look","role":"viewer"},"op":{"password":"turn","role":"operator"},"eng":{"password":"fix","role":"engineer"}}`), &users); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name, password string
+		role           Role
+		ok             bool
+	}{
+		{"tech", "look", Viewer, true},
+		{"op", "turn", Operator, true},
+		{"eng", "fix", Engineer, true},
+		{"op", "look", 0, false},
+		{"nobody", "", 0, false},
+	} {
+		if role, ok := users.Authenticate(c.name, c.password); role != c.role || ok != c.ok {
+			t.Errorf("Authenticate(%q, %q) = %v, %v", c.name, c.password, role, ok)
+		}
+	}
+	if !Operator.Allows(Viewer) || Viewer.Allows(Operator) || !Engineer.Allows(Operator) || Operator.Allows(Engineer) {
+		t.Error("every role must allow what the roles before it may do, but not the reverse")
+	}
+	if err := json.Unmarshal([]byte(`{"x":{"role":"admin"}}`), &users); err == nil {
+		t.Error("unknown role accepted")
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	users := Users{"eng": {Password: "fix", Role: Engineer}}
+	intercept := users.UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		c, _ := FromContext(ctx)
+		return c, nil
+	}
+	call := func(credentials string) (interface{}, error) {
+		ctx := context.Background()
+		if credentials != "" {
+			md := metadata.Pairs("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+			ctx = metadata.NewIncomingContext(ctx, md)
+		}
+		return intercept(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	}
+	c, err := call("eng:fix")
+	if err != nil || c != (Caller{Name: "eng", Role: Engineer}) {
+		t.Fatalf("caller %v, %v", c, err)
+	}
+	for _, credentials := range []string{"", "eng:broken", "eng"} {
+		if _, err := call(credentials); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%q: %v", credentials, err)
+		}
+	}
+}
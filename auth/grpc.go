@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate reads HTTP basic credentials from the authorization
+// metadata of a gRPC call and stores the caller in the context.
+func (u Users) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if !strings.HasPrefix(v, "Basic ") {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, "Basic "))
+		if err != nil {
+			continue
+		}
+		i := strings.IndexByte(string(b), ':')
+		if i < 0 {
+			continue
+		}
+		name, password := string(b[:i]), string(b[i+1:])
+		if role, ok := u.Authenticate(name, password); ok {
+			return NewContext(ctx, Caller{Name: name, Role: role}), nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+// UnaryServerInterceptor authenticates every unary call with the basic
+// credentials in its authorization metadata, as the HTTP gateway does.
+func (u Users) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := u.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func (u Users) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := u.authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
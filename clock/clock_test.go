@@ -0,0 +1,103 @@
+package clock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/integrity"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+var errUnreachable = errors.New("unreachable")
+
+type clocks map[string]time.Duration
+
+func (c clocks) PlcClockDrift(ctx context.Context, plc *pb.Plc) (time.Duration, error) {
+	drift, ok := c[plc.GetHost()]
+	if !ok {
+		return 0, errUnreachable
+	}
+	return drift, nil
+}
+
+func (c clocks) SyncPlcClock(ctx context.Context, plc *pb.Plc) (time.Duration, error) {
+	drift := c[plc.GetHost()]
+	c[plc.GetHost()] = 0
+	return drift, nil
+}
+
+func TestMonitor(t *testing.T) {
+	var log bytes.Buffer
+	c := clocks{"10.0.0.1": 1500 * time.Millisecond, "10.0.0.3": -3 * time.Second}
+	plcs := config.Plcs{
+		{Name: "press", Host: "10.0.0.1"},
+		{Name: "robot", Host: "10.0.0.2", Protocol: "modbus"},
+		{Name: "oven", Host: "10.0.0.3"},
+	}
+	if _, err := NewMonitor(Config{Plcs: []string{"kiln"}}, plcs, c, nil); err == nil {
+		t.Fatal("monitor of an unknown PLC")
+	}
+	m, err := NewMonitor(Config{Correct: config.Duration(2 * time.Second)}, plcs, c, audit.New(&log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.config) != 2 || m.config[0].Name != "press" || m.config[1].Name != "oven" {
+		t.Fatalf("watching %v", m.config)
+	}
+	for _, p := range m.config {
+		if _, err := m.Check(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the press is within the threshold, the oven was corrected
+	if c["10.0.0.1"] != 1500*time.Millisecond || c["10.0.0.3"] != 0 {
+		t.Fatalf("clocks %v", c)
+	}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	var got []string
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		`goplc_plc_clock_drift_seconds{plc="oven"} 0`,
+		`goplc_plc_clock_drift_seconds{plc="press"} 1.5`,
+		`goplc_plc_clock_corrections_total{plc="press"} 0`,
+		`goplc_plc_clock_corrections_total{plc="oven"} 1`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("metrics %q, want %q", got, want)
+	}
+
+	var e audit.Entry
+	if err := json.NewDecoder(&log).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Action != "clock_corrected" || e.Plc != integrity.Key(plcs[2].Pb()) || e.Detail != "drift was -3s" || e.Result != "ok" {
+		t.Fatalf("audit %+v", e)
+	}
+
+	// a clock that cannot be read is no entry of the audit log
+	m, err = NewMonitor(Config{Interval: config.Duration(time.Millisecond), Plcs: []string{"kiln"}}, append(plcs, config.Plc{Name: "kiln", Host: "10.0.0.4"}), c, audit.New(&log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	m.Run(ctx)
+	if log.Len() != 0 {
+		t.Fatalf("audit of a failed read %s", log.String())
+	}
+}
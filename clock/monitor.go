@@ -0,0 +1,155 @@
+// Package clock watches the clocks of S7 CPUs: it samples how far each
+// drifts from the clock of goplc, exports the drift as a metric and
+// corrects clocks that drift too far.
+package clock
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/integrity"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const DefaultInterval = time.Minute
+
+// Clocks reads and sets the clocks of CPUs; PlcServer does.
+type Clocks interface {
+	// PlcClockDrift is how far the clock of plc is ahead.
+	PlcClockDrift(ctx context.Context, plc *pb.Plc) (time.Duration, error)
+	// SyncPlcClock sets the clock of plc and returns the drift before.
+	SyncPlcClock(ctx context.Context, plc *pb.Plc) (time.Duration, error)
+}
+
+type Config struct {
+	Interval config.Duration `json:"interval,omitempty"`
+	// Plcs names the PLCs whose clocks are sampled, by default those of
+	// every S7 PLC.
+	Plcs []string `json:"plcs,omitempty"`
+	// Correct is the drift either way beyond which a clock is set to that
+	// of goplc; clocks are left alone when 0.
+	Correct config.Duration `json:"correct,omitempty"`
+}
+
+func (c Config) interval() time.Duration {
+	if c.Interval > 0 {
+		return time.Duration(c.Interval)
+	}
+	return DefaultInterval
+}
+
+// Monitor samples the drift of the clocks of PLCs every interval, and
+// serves the drifts in the Prometheus text format. Corrections are
+// recorded in the audit log as clock_corrected.
+type Monitor struct {
+	config  config.Plcs
+	clocks  Clocks
+	audit   *audit.Log
+	every   time.Duration
+	correct time.Duration
+
+	mu          sync.Mutex
+	drift       map[string]time.Duration
+	corrections map[string]int
+}
+
+func NewMonitor(c Config, plcs config.Plcs, clocks Clocks, log *audit.Log) (*Monitor, error) {
+	selected, err := plcs.Select(c.Plcs)
+	if err != nil {
+		return nil, fmt.Errorf("clock: %v", err)
+	}
+	return &Monitor{
+		config:      selected,
+		clocks:      clocks,
+		audit:       log,
+		every:       c.interval(),
+		correct:     time.Duration(c.Correct),
+		drift:       make(map[string]time.Duration),
+		corrections: make(map[string]int),
+	}, nil
+}
+
+// Run samples the clocks right away and then every interval until ctx is
+// done. A clock that cannot be read is logged, not audited, and drops out
+// of the metrics until it can.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.every)
+	defer ticker.Stop()
+	for {
+		for _, p := range m.config {
+			if _, err := m.Check(ctx, p); err != nil && ctx.Err() == nil {
+				log.Printf("clock: %s: %v", p.GetName(), err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check samples the drift of the clock of p and corrects it if it is
+// beyond the threshold. It returns the drift sampled.
+func (m *Monitor) Check(ctx context.Context, p config.Plc) (time.Duration, error) {
+	name := p.GetName()
+	drift, err := m.clocks.PlcClockDrift(ctx, p.Pb())
+	if err != nil {
+		m.mu.Lock()
+		delete(m.drift, name)
+		m.mu.Unlock()
+		return 0, err
+	}
+	m.mu.Lock()
+	m.drift[name] = drift
+	m.mu.Unlock()
+	if m.correct <= 0 || (drift < m.correct && drift > -m.correct) {
+		return drift, nil
+	}
+	drift, err = m.clocks.SyncPlcClock(ctx, p.Pb())
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	} else {
+		m.mu.Lock()
+		m.corrections[name]++
+		m.drift[name] = 0
+		m.mu.Unlock()
+	}
+	m.audit.Record(ctx, audit.Entry{Action: "clock_corrected", Plc: integrity.Key(p.Pb()), Detail: fmt.Sprintf("drift was %v", drift), Result: result})
+	return drift, err
+}
+
+// ServeHTTP serves the drift of every clock checked successfully last
+// time, and the number of corrections, as Prometheus metrics.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP goplc_plc_clock_drift_seconds How far the clock of the PLC is ahead of that of goplc.")
+	fmt.Fprintln(w, "# TYPE goplc_plc_clock_drift_seconds gauge")
+	for _, name := range sortedNames(m.drift) {
+		fmt.Fprintf(w, "goplc_plc_clock_drift_seconds{plc=%q} %g\n", name, m.drift[name].Seconds())
+	}
+	fmt.Fprintln(w, "# HELP goplc_plc_clock_corrections_total Clock corrections by goplc.")
+	fmt.Fprintln(w, "# TYPE goplc_plc_clock_corrections_total counter")
+	for _, p := range m.config {
+		fmt.Fprintf(w, "goplc_plc_clock_corrections_total{plc=%q} %d\n", p.GetName(), m.corrections[p.GetName()])
+	}
+}
+
+func sortedNames(drift map[string]time.Duration) []string {
+	names := make([]string, 0, len(drift))
+	for name := range drift {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,227 @@
+// Command plcsnap takes snapshots of DBs and M memory of S7 CPUs through
+// goplc, restores them and compares them:
+//
+//	plcsnap take -plc 10.0.0.230 -o before.json DB5 DB6 M:0-127
+//	plcsnap restore -reason "undo commissioning" before.json DB5:10-29
+//	plcsnap diff -layout recipe.json before.json
+//
+// A layout file holds the tags and udts of a DiffSnapshotReq, as in
+//
+//	{"tags": [{"address": "DB5P12", "dt": "Real"}],
+//	 "udts": [{"name": "valve", "address": "DB6P0",
+//	           "fields": [{"name": "open", "dt": "Bool"}, {"name": "state", "dt": "Int", "offset": 2}]}]}
+//
+// The credentials are -user and the GOPLC_PASSWORD environment variable.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	"github.com/thinkontrolsy/goplc/snapshot"
+)
+
+const usage = `usage: plcsnap [-server address] [-user name] command ...
+
+  take -plc host [-rack n] [-slot n] [-port n] -o file area...
+	saves areas, as DB5, DB5:10-29 or M:0-127, to file
+  restore [-plc host] -reason text [-yes] file [range...]
+	writes back the ranges of file, all of it without any, to the
+	CPU the snapshot was taken of or -plc
+  diff [-plc host] [-layout file] base [current]
+	compares snapshot base to current, or to the CPU now, interpreting
+	the changes by the tags and UDTs of the layout file
+`
+
+func main() {
+	log.SetFlags(0)
+	server := flag.String("server", "localhost:50051", "goplc gRPC address")
+	user := flag.String("user", "", "goplc user, with the password in GOPLC_PASSWORD")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := grpc.Dial(*server, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if *user != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(*user + ":" + os.Getenv("GOPLC_PASSWORD")))
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+credentials)
+	}
+	client := pb.NewPlcRWClient(conn)
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "take":
+		err = take(ctx, client, args)
+	case "restore":
+		err = restore(ctx, client, args)
+	case "diff":
+		err = diff(ctx, client, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// ranges parses the ranges in args.
+func ranges(args []string) ([]*pb.AreaRange, error) {
+	var rs []*pb.AreaRange
+	for _, a := range args {
+		r, err := snapshot.ParseRange(a)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func take(ctx context.Context, client pb.PlcRWClient, args []string) error {
+	fs := flag.NewFlagSet("take", flag.ExitOnError)
+	plc := &pb.Plc{}
+	fs.StringVar(&plc.Host, "plc", "", "CPU host")
+	rack := fs.Uint("rack", 0, "CPU rack")
+	slot := fs.Uint("slot", 2, "CPU slot")
+	port := fs.Uint("port", 0, "ISO-on-TCP port, 102 by default")
+	out := fs.String("o", "", "snapshot file")
+	fs.Parse(args)
+	plc.Rack, plc.Slot, plc.Port = uint32(*rack), uint32(*slot), uint32(*port)
+	if plc.Host == "" || *out == "" || fs.NArg() == 0 {
+		return fmt.Errorf("take needs -plc, -o and areas")
+	}
+	areas, err := ranges(fs.Args())
+	if err != nil {
+		return err
+	}
+	snap, err := client.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: plc, Areas: areas})
+	if err != nil {
+		return err
+	}
+	return snapshot.Save(*out, snap)
+}
+
+func restore(ctx context.Context, client pb.PlcRWClient, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	host := fs.String("plc", "", "CPU host, the one of the snapshot by default")
+	reason := fs.String("reason", "", "why, for the audit log")
+	yes := fs.Bool("yes", false, "do not ask to confirm")
+	fs.Parse(args)
+	if *reason == "" || fs.NArg() == 0 {
+		return fmt.Errorf("restore needs -reason and a snapshot file")
+	}
+	snap, err := snapshot.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	rs, err := ranges(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	req := &pb.RestoreReq{Plc: snap.GetPlc(), Snapshot: snap, Ranges: rs, Reason: *reason}
+	if *host != "" {
+		req.Plc = &pb.Plc{Host: *host, Rack: snap.GetPlc().GetRack(), Slot: snap.GetPlc().GetSlot(), Port: snap.GetPlc().GetPort()}
+	}
+	res, err := client.RestoreSnapshot(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		taken := time.Unix(snap.GetTaken().GetSeconds(), 0)
+		fmt.Fprintf(os.Stderr, "restore %s of %s, taken %s, to %s? [y/N] ", describe(rs), snap.GetDevice().GetName(), taken.Format(time.RFC3339), req.GetPlc().GetHost())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.HasPrefix(strings.ToLower(answer), "y") {
+			return fmt.Errorf("not restored")
+		}
+	}
+	req.Confirm = res.GetConfirm()
+	if res, err = client.RestoreSnapshot(ctx, req); err != nil {
+		return err
+	}
+	fmt.Println("restored", describe(res.GetWritten()))
+	return nil
+}
+
+func diff(ctx context.Context, client pb.PlcRWClient, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	host := fs.String("plc", "", "CPU host, the one of the snapshot by default")
+	layout := fs.String("layout", "", "file of tags and UDTs")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("diff needs one or two snapshot files")
+	}
+	req := &pb.DiffSnapshotReq{}
+	if *layout != "" {
+		f, err := os.Open(*layout)
+		if err != nil {
+			return err
+		}
+		err = jsonpb.Unmarshal(f, req)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", *layout, err)
+		}
+	}
+	var err error
+	if req.Base, err = snapshot.Load(fs.Arg(0)); err != nil {
+		return err
+	}
+	var d *pb.SnapshotDiff
+	if fs.NArg() == 2 {
+		if req.Current, err = snapshot.Load(fs.Arg(1)); err != nil {
+			return err
+		}
+		d, err = snapshot.Diff(req.GetBase(), req.GetCurrent(), req.GetTags(), req.GetUdts())
+	} else {
+		if *host != "" {
+			p := req.GetBase().GetPlc()
+			req.Plc = &pb.Plc{Host: *host, Rack: p.GetRack(), Slot: p.GetSlot(), Port: p.GetPort()}
+		}
+		d, err = client.DiffSnapshot(ctx, req)
+	}
+	if err != nil {
+		return err
+	}
+	for _, c := range d.GetBytes() {
+		fmt.Println(snapshot.FormatByteChange(c))
+	}
+	for _, c := range d.GetTags() {
+		fmt.Println(snapshot.FormatTagChange(c))
+	}
+	for _, a := range d.GetUnmatched() {
+		fmt.Println(a, "is in one snapshot only")
+	}
+	return nil
+}
+
+func describe(rs []*pb.AreaRange) string {
+	if len(rs) == 0 {
+		return "everything"
+	}
+	names := make([]string, len(rs))
+	for i, r := range rs {
+		names[i] = snapshot.FormatRange(r)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,172 @@
+// Package config holds the PLC and tag definitions shared by the long
+// running parts of goplc, such as the bridges that poll tags in the
+// background.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const DefaultInterval = time.Second
+
+// Duration is a time.Duration written as a Go duration string ("500ms").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type Tag struct {
+	Name     string `json:"name,omitempty"`
+	Address  string `json:"address"`
+	Dt       string `json:"dt"`
+	Writable bool   `json:"writable,omitempty"`
+}
+
+// GetName returns the tag name, falling back to its address.
+func (t Tag) GetName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Address
+}
+
+func (t Tag) Pb() *pb.Tag {
+	return &pb.Tag{Address: t.Address, Dt: t.Dt}
+}
+
+type Plc struct {
+	Name string `json:"name"`
+	// Protocol selects the driver, "s7" when empty.
+	Protocol string   `json:"protocol,omitempty"`
+	Host     string   `json:"host"`
+	Rack     uint32   `json:"rack"`
+	Slot     uint32   `json:"slot"`
+	Port     uint32   `json:"port,omitempty"`
+	Interval Duration `json:"interval,omitempty"`
+	// Options are protocol specific settings; see the driver.
+	Options map[string]string `json:"options,omitempty"`
+	// PasswordRef names the password of a protected S7 CPU among the
+	// passwords of the configuration.
+	PasswordRef string `json:"password_ref,omitempty"`
+	Tags        []Tag  `json:"tags,omitempty"`
+}
+
+// GetName returns the PLC name, falling back to its host.
+func (p Plc) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Host
+}
+
+// GetInterval returns the poll interval, DefaultInterval when unset.
+func (p Plc) GetInterval() time.Duration {
+	if p.Interval > 0 {
+		return time.Duration(p.Interval)
+	}
+	return DefaultInterval
+}
+
+func (p Plc) Pb() *pb.Plc {
+	return &pb.Plc{Host: p.Host, Rack: p.Rack, Slot: p.Slot, Port: p.Port, Protocol: p.Protocol, Options: p.Options, PasswordRef: p.PasswordRef}
+}
+
+// PbTags returns fresh proto tags for every configured tag, in order.
+func (p Plc) PbTags() []*pb.Tag {
+	tags := make([]*pb.Tag, len(p.Tags))
+	for i, t := range p.Tags {
+		tags[i] = t.Pb()
+	}
+	return tags
+}
+
+// Tag looks a tag up by name.
+func (p Plc) Tag(name string) (Tag, bool) {
+	for _, t := range p.Tags {
+		if t.GetName() == name {
+			return t, true
+		}
+	}
+	return Tag{}, false
+}
+
+type Plcs []Plc
+
+// Plc looks a PLC up by name.
+func (ps Plcs) Plc(name string) (Plc, bool) {
+	for _, p := range ps {
+		if p.GetName() == name {
+			return p, true
+		}
+	}
+	return Plc{}, false
+}
+
+// Select returns the PLCs of names, in their order, or every S7 PLC when
+// names is empty.
+func (ps Plcs) Select(names []string) (Plcs, error) {
+	var selected Plcs
+	if len(names) == 0 {
+		for _, p := range ps {
+			if p.Protocol == "" || p.Protocol == "s7" {
+				selected = append(selected, p)
+			}
+		}
+		return selected, nil
+	}
+	for _, name := range names {
+		p, ok := ps.Plc(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown PLC %q", name)
+		}
+		selected = append(selected, p)
+	}
+	return selected, nil
+}
+
+// AllowWrite implements a write policy that only lets configured tags
+// marked writable be written.
+func (ps Plcs) AllowWrite(plc *pb.Plc, tag *pb.Tag) error {
+	for _, p := range ps {
+		if p.Host != plc.GetHost() || p.Rack != plc.GetRack() || p.Slot != plc.GetSlot() {
+			continue
+		}
+		for _, t := range p.Tags {
+			if t.Address == tag.GetAddress() && t.Writable {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s on %s is not writable", tag.GetAddress(), plc.GetHost())
+}
+
+// Load decodes the JSON file at path into v.
+func Load(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
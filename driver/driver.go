@@ -0,0 +1,132 @@
+// Package driver is the boundary between the protocol neutral parts of
+// goplc, such as PlcServer and the bridges, and the protocols spoken to
+// PLCs. A protocol implements Driver and registers it under the name that
+// Plc.Protocol selects, the way database/sql drivers do.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// DefaultProtocol is used for PLCs without a protocol.
+const DefaultProtocol = "s7"
+
+// ErrUnsupported is returned for operations a protocol does not have.
+var ErrUnsupported = errors.New("not supported by the protocol")
+
+var stringReg = regexp.MustCompile(pb.DT_REG)
+
+// Capabilities describes what a driver can do beyond reading tags.
+type Capabilities struct {
+	// Write is set when tags can be written.
+	Write bool
+	// DeviceInfo is set when Conn.DeviceInfo is supported.
+	DeviceInfo bool
+	// CpuControl is set when connections implement CpuControl.
+	CpuControl bool
+	// Datatypes lists the supported tag datatypes; String stands for
+	// String[n] too.
+	Datatypes []string
+}
+
+// Supports reports whether dt is one of the supported datatypes.
+func (c Capabilities) Supports(dt string) bool {
+	if stringReg.MatchString(dt) {
+		dt = "String"
+	}
+	for _, d := range c.Datatypes {
+		if d == dt {
+			return true
+		}
+	}
+	return false
+}
+
+type Driver interface {
+	// Connect opens a connection to plc; ctx bounds the attempt.
+	Connect(ctx context.Context, plc *pb.Plc) (Conn, error)
+	// Validate checks that the address and datatype of tag make sense
+	// to the protocol, without talking to a PLC.
+	Validate(tag *pb.Tag) error
+	Capabilities() Capabilities
+}
+
+// Conn is a connection to one PLC. It is used by one goroutine at a time.
+type Conn interface {
+	// ReadTags sets the value of every tag. An error fails the whole
+	// read; a tag that failed on its own has Err set instead.
+	ReadTags(ctx context.Context, tags []*pb.Tag) error
+	WriteTags(ctx context.Context, tags []*pb.Tag) error
+	DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error)
+	Close() error
+}
+
+// CpuControl is implemented by connections that can report and change the
+// operating state of the CPU.
+type CpuControl interface {
+	CpuState(ctx context.Context) (pb.CpuState_State, error)
+	// StartCpu restarts a stopped CPU, with a cold restart when cold is
+	// set. Starting a running CPU is not an error.
+	StartCpu(ctx context.Context, cold bool) error
+	// StopCpu stops the CPU. Stopping a stopped CPU is not an error.
+	StopCpu(ctx context.Context) error
+}
+
+var (
+	mu      sync.RWMutex
+	drivers = make(map[string]Driver)
+)
+
+// Register makes a driver available under name. It panics when name is
+// taken, since that is a programming error.
+func Register(name string, d Driver) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := drivers[name]; ok {
+		panic("driver: Register called twice for " + name)
+	}
+	drivers[name] = d
+}
+
+// Lookup returns the driver registered under name, DefaultProtocol if name
+// is empty.
+func Lookup(name string) (Driver, error) {
+	if name == "" {
+		name = DefaultProtocol
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	d, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown protocol %q", name)
+	}
+	return d, nil
+}
+
+// Protocols returns the names of the registered drivers, sorted.
+func Protocols() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks tag with the driver of plc.
+func Validate(plc *pb.Plc, tag *pb.Tag) error {
+	d, err := Lookup(plc.GetProtocol())
+	if err != nil {
+		return err
+	}
+	return d.Validate(tag)
+}
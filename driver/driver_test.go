@@ -0,0 +1,17 @@
+package driver
+
+import (
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	c := Capabilities{Datatypes: []string{"Int", "String"}}
+	for dt, want := range map[string]bool{"Int": true, "String[12]": true, "Real": false, "String[x]": false} {
+		if got := c.Supports(dt); got != want {
+			t.Errorf("Supports(%q) = %v, want %v", dt, got, want)
+		}
+	}
+	if _, err := Lookup("nothing"); err == nil {
+		t.Error("Lookup of an unregistered protocol succeeded")
+	}
+}
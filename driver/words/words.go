@@ -0,0 +1,114 @@
+// Package words is what the drivers of word oriented PLCs share: laying
+// tag values out in 16 bit words in the byte order of the PLC, and
+// merging the words or bits tags take into as few reads as a request
+// allows.
+package words
+
+import (
+	"sort"
+	"strings"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// Order turns a value from the byte order of FillBuffer, most significant
+// byte first, into that of the PLC. Applied twice it gives the value back.
+type Order func(b []byte) []byte
+
+// Reverse is the order of PLCs that keep the least significant byte and
+// word first.
+func Reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
+
+// SwapWords is the order of PLCs that keep each word most significant
+// byte first but the least significant word first.
+func SwapWords(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := 0; i+1 < len(b); i += 2 {
+		copy(r[len(b)-2-i:], b[i:i+2])
+	}
+	return r
+}
+
+func isString(dt string) bool {
+	return strings.HasPrefix(dt, "String")
+}
+
+// Pack lays a tag value out in count words in order: one byte values in
+// the low byte of a word and strings as their characters, NUL padded.
+func Pack(tag *pb.Tag, count int, order Order) []byte {
+	words := make([]byte, count*2)
+	switch {
+	case tag.GetDt() == "Bool":
+		if tag.GetValueBool() {
+			copy(words, order([]byte{0, 1}))
+		}
+	case tag.GetLength() == 1:
+		copy(words, order([]byte{0, tag.FillBuffer(0)[0]}))
+	case isString(tag.GetDt()):
+		v := tag.GetValueString()
+		copy(words, v[:pb.Min(len(v), tag.GetLength()-2)])
+	default:
+		copy(words, order(tag.FillBuffer(0)))
+	}
+	return words
+}
+
+// Unpack is the inverse of Pack.
+func Unpack(tag *pb.Tag, words []byte, order Order) {
+	switch {
+	case tag.GetDt() == "Bool":
+		tag.Value = &pb.Tag_ValueBool{ValueBool: words[0]|words[1] != 0}
+	case tag.GetLength() == 1:
+		tag.SetTagValue(order(words[:2])[1:])
+	case isString(tag.GetDt()):
+		s := words[:pb.Min(len(words), tag.GetLength()-2)]
+		if i := strings.IndexByte(string(s), 0); i >= 0 {
+			s = s[:i]
+		}
+		tag.Value = &pb.Tag_ValueString{ValueString: string(s)}
+	default:
+		tag.SetTagValue(order(words[:tag.GetLength()]))
+	}
+}
+
+// Span is one read from Start to End covering Items, the indexes of the
+// items grouped.
+type Span struct {
+	Start, End int
+	Items      []int
+}
+
+// Group merges n items of one area, item i taking size words or bits
+// from start on as extent returns them, into as few spans as it can: in
+// order of start, none longer than max and none reaching across more than
+// maxGap unused words or bits.
+func Group(n int, extent func(i int) (start, size int), max, maxGap int) []Span {
+	order := make([]int, n)
+	starts := make([]int, n)
+	for i := range order {
+		order[i] = i
+		starts[i], _ = extent(i)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return starts[order[i]] < starts[order[j]] })
+	var spans []Span
+	for _, i := range order {
+		start, size := extent(i)
+		end := start + size
+		if last := len(spans) - 1; last >= 0 && start <= spans[last].End+maxGap && end-spans[last].Start <= max {
+			s := &spans[last]
+			if end > s.End {
+				s.End = end
+			}
+			s.Items = append(s.Items, i)
+			continue
+		}
+		spans = append(spans, Span{Start: start, End: end, Items: []int{i}})
+	}
+	return spans
+}
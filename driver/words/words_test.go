@@ -0,0 +1,59 @@
+package words
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+func TestPack(t *testing.T) {
+	for _, c := range []struct {
+		tag     *pb.Tag
+		count   int
+		reverse []byte
+		swapped []byte
+	}{
+		{&pb.Tag{Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}}, 1, []byte{1, 0}, []byte{0, 1}},
+		{&pb.Tag{Dt: "Byte", Value: &pb.Tag_ValueBytes{ValueBytes: []byte{0xAB}}}, 1, []byte{0xAB, 0}, []byte{0, 0xAB}},
+		{&pb.Tag{Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: -2}}, 1, []byte{0xFE, 0xFF}, []byte{0xFF, 0xFE}},
+		{&pb.Tag{Dt: "DInt", Value: &pb.Tag_ValueInteger{ValueInteger: 0x10002}}, 2, []byte{2, 0, 1, 0}, []byte{0, 2, 0, 1}},
+		{&pb.Tag{Dt: "String[5]", Value: &pb.Tag_ValueString{ValueString: "abcdefg"}}, 3, []byte("abcde\x00"), []byte("abcde\x00")},
+	} {
+		for _, o := range []struct {
+			order Order
+			want  []byte
+		}{{Reverse, c.reverse}, {SwapWords, c.swapped}} {
+			b := Pack(c.tag, c.count, o.order)
+			if !bytes.Equal(b, o.want) {
+				t.Errorf("%s %v: % x, want % x", c.tag.GetDt(), c.tag.GetValue(), b, o.want)
+				continue
+			}
+			back := &pb.Tag{Dt: c.tag.GetDt()}
+			Unpack(back, b, o.order)
+			want := c.tag.GetValue()
+			if s := c.tag.GetValueString(); s != "" {
+				want = &pb.Tag_ValueString{ValueString: s[:5]}
+			}
+			if !reflect.DeepEqual(back.GetValue(), want) {
+				t.Errorf("%s unpacked %v, want %v", c.tag.GetDt(), back.GetValue(), want)
+			}
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	// start and size of each item, out of order
+	items := [][2]int{{100, 2}, {0, 1}, {10, 4}, {20, 1}, {37, 1}, {101, 1}}
+	got := Group(len(items), func(i int) (int, int) { return items[i][0], items[i][1] }, 30, 16)
+	want := []Span{
+		{Start: 0, End: 21, Items: []int{1, 2, 3}},
+		// 37 is within the gap but beyond the 30 words of a span from 0
+		{Start: 37, End: 38, Items: []int{4}},
+		{Start: 100, End: 102, Items: []int{0, 5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("spans %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,361 @@
+package fins
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/driver/words"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultPort = 9600
+	Vendor      = "OMRON"
+
+	timeout = 5 * time.Second
+	// maxGap is the number of unused words a read may span to merge two
+	// tags into one request.
+	maxGap = 16
+)
+
+func init() {
+	driver.Register("fins", Driver{})
+	driver.Register("fins-udp", UDPDriver{})
+}
+
+// Area is a memory area with its codes for word and bit access.
+type Area struct {
+	Name      string
+	Word, Bit byte
+}
+
+var areas = map[string]Area{
+	"CIO": {"CIO", 0xB0, 0x30},
+	"W":   {"W", 0xB1, 0x31},
+	"H":   {"H", 0xB2, 0x32},
+	"D":   {"D", 0x82, 0x02},
+}
+
+// emArea is bank n of the extended data memory.
+func emArea(n int) Area {
+	return Area{fmt.Sprintf("E%X", n), 0xA0 + byte(n), 0x20 + byte(n)}
+}
+
+var addressReg = regexp.MustCompile(`^(CIO|DM|D|W|H|E([0-9A-C])_)(\d+)(?:\.(\d{1,2}))?$`)
+
+// address is a parsed tag address: an area, the first word and, for Bool
+// tags, a bit.
+type address struct {
+	area Area
+	word int
+	bit  int
+}
+
+func parseAddress(tag *pb.Tag) (address, error) {
+	match := addressReg.FindStringSubmatch(tag.GetAddress())
+	if match == nil {
+		return address{}, fmt.Errorf("FINS address %q is not CIO, D, W, H or En_ followed by a word", tag.GetAddress())
+	}
+	a := address{bit: -1}
+	switch {
+	case match[2] != "":
+		n, _ := strconv.ParseUint(match[2], 16, 8)
+		a.area = emArea(int(n))
+	case match[1] == "DM":
+		a.area = areas["D"]
+	default:
+		a.area = areas[match[1]]
+	}
+	a.word, _ = strconv.Atoi(match[3])
+	if match[4] != "" {
+		a.bit, _ = strconv.Atoi(match[4])
+		if tag.GetDt() != "Bool" || a.bit > 15 {
+			return address{}, fmt.Errorf("only Bool tags take a bit 0-15")
+		}
+	}
+	if tag.GetLength() == 0 {
+		return address{}, fmt.Errorf("Datatype illegal")
+	}
+	if a.word+a.count(tag) > 0x10000 {
+		return address{}, fmt.Errorf("%s ends beyond word 65535", tag.GetAddress())
+	}
+	return a, nil
+}
+
+// count is the number of words the tag takes.
+func (a address) count(tag *pb.Tag) int {
+	switch {
+	case tag.GetDt() == "Bool":
+		return 1
+	case isString(tag.GetDt()):
+		return (tag.GetLength() - 1) / 2
+	}
+	return (tag.GetLength() + 1) / 2
+}
+
+func isString(dt string) bool {
+	return strings.HasPrefix(dt, "String")
+}
+
+// options are the FINS settings of Plc.Options.
+type options struct {
+	dst Node
+	// node and localNode are set when given
+	node, localNode int
+}
+
+// parseOptions reads "network", "node" and "unit" of the PLC and the
+// "local_node" of goplc.
+func parseOptions(plc *pb.Plc) (options, error) {
+	o := options{node: -1, localNode: -1}
+	var network, unit int
+	for _, opt := range []struct {
+		name string
+		v    *int
+	}{{"network", &network}, {"node", &o.node}, {"unit", &unit}, {"local_node", &o.localNode}} {
+		s, ok := plc.GetOptions()[opt.name]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return o, fmt.Errorf("%s: bad value %q", opt.name, s)
+		}
+		*opt.v = int(v)
+	}
+	o.dst = Node{Network: byte(network), Unit: byte(unit)}
+	return o, nil
+}
+
+func dialTimeout(ctx context.Context) time.Duration {
+	d := timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		d = time.Until(deadline)
+	}
+	return d
+}
+
+func hostPort(plc *pb.Plc) string {
+	port := int(plc.GetPort())
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(plc.GetHost(), strconv.Itoa(port))
+}
+
+// lastOctet is the last byte of an IPv4 address, which FINS uses as node
+// number by default.
+func lastOctet(addr net.Addr) (int, error) {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return 0, err
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("no FINS node for %s, set it in the options", host)
+	}
+	return int(ip[3]), nil
+}
+
+// Driver reads and writes Omron PLCs with FINS/TCP. Tags are addressed by
+// area and word, such as D100, CIO10, W3, H5 or E1_200 for bank 1 of EM;
+// Bool tags may name a bit, as in CIO10.05. Values wider than a word span
+// consecutive words, least significant first. Node addresses are
+// negotiated on connect; Plc.Options may set the destination "network",
+// "node" and "unit".
+type Driver struct{}
+
+func (Driver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	o, err := parseOptions(plc)
+	if err != nil {
+		return nil, err
+	}
+	t, client, server, err := dialTCP(hostPort(plc), dialTimeout(ctx))
+	if err != nil {
+		return nil, err
+	}
+	dst := o.dst
+	dst.Node = server
+	if o.node >= 0 {
+		dst.Node = byte(o.node)
+	}
+	return &conn{client: &Client{t: t, src: Node{Node: client}, dst: dst}, protocol: "fins"}, nil
+}
+
+func (Driver) Validate(tag *pb.Tag) error {
+	_, err := parseAddress(tag)
+	return err
+}
+
+func (Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Write:      true,
+		DeviceInfo: true,
+		Datatypes: []string{
+			"Bool", "Byte", "Char", "Word", "DWord", "LWord",
+			"SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "ULInt",
+			"Real", "LReal", "String",
+		},
+	}
+}
+
+// UDPDriver reads and writes Omron PLCs with FINS/UDP, with the tag
+// addresses and options of Driver. Without negotiation the nodes default
+// to the last byte of the IP addresses of the PLC and of goplc; the
+// "local_node" option overrides the latter.
+type UDPDriver struct{}
+
+func (UDPDriver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	o, err := parseOptions(plc)
+	if err != nil {
+		return nil, err
+	}
+	t, err := dialUDP(hostPort(plc), dialTimeout(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if o.node < 0 {
+		o.node, err = lastOctet(t.conn.RemoteAddr())
+	}
+	if err == nil && o.localNode < 0 {
+		o.localNode, err = lastOctet(t.conn.LocalAddr())
+	}
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	dst := o.dst
+	dst.Node = byte(o.node)
+	return &conn{client: &Client{t: t, src: Node{Node: byte(o.localNode)}, dst: dst}, protocol: "fins-udp"}, nil
+}
+
+func (UDPDriver) Validate(tag *pb.Tag) error {
+	return Driver{}.Validate(tag)
+}
+
+func (UDPDriver) Capabilities() driver.Capabilities {
+	return Driver{}.Capabilities()
+}
+
+type conn struct {
+	client   *Client
+	protocol string
+}
+
+func (c *conn) Close() error {
+	return c.client.Close()
+}
+
+func (c *conn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	u, err := c.client.CpuUnitData()
+	if err == EndCode(0x0401) {
+		return nil, driver.ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeviceInfo{
+		Protocol: c.protocol,
+		Vendor:   Vendor,
+		Model:    u.Model,
+		Version:  u.Version,
+		Details: map[string]string{
+			"dm_words": strconv.Itoa(u.DMWords),
+			"em_banks": strconv.Itoa(u.EMBanks),
+			"node":     strconv.Itoa(int(c.client.dst.Node)),
+		},
+	}, nil
+}
+
+type item struct {
+	tag *pb.Tag
+	address
+	size int
+}
+
+// span is one read request covering the items of an area.
+type span struct {
+	area       Area
+	start, end int
+	items      []item
+}
+
+// spans merges the tags into as few reads as the request limits allow.
+func spans(tags []*pb.Tag) ([]*span, error) {
+	byArea := make(map[Area][]item)
+	for _, tag := range tags {
+		a, err := parseAddress(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+		byArea[a.area] = append(byArea[a.area], item{tag: tag, address: a, size: a.count(tag)})
+	}
+	var spans []*span
+	for area, items := range byArea {
+		for _, g := range words.Group(len(items), func(i int) (int, int) { return items[i].word, items[i].size }, MaxWords, maxGap) {
+			s := &span{area: area, start: g.Start, end: g.End}
+			for _, i := range g.Items {
+				s.items = append(s.items, items[i])
+			}
+			spans = append(spans, s)
+		}
+	}
+	return spans, nil
+}
+
+// ReadTags reads every span in words. An end code, such as an address out
+// of range, only fails the tags of its span.
+func (c *conn) ReadTags(ctx context.Context, tags []*pb.Tag) error {
+	spans, err := spans(tags)
+	if err != nil {
+		return err
+	}
+	for _, s := range spans {
+		data, err := c.client.ReadWords(s.area.Word, s.start, s.end-s.start)
+		if e, ok := err.(EndCode); ok {
+			for _, it := range s.items {
+				it.tag.Err = e.Error()
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, it := range s.items {
+			b := data[(it.word-s.start)*2 : (it.word-s.start+it.size)*2]
+			if it.bit >= 0 {
+				v := binary.BigEndian.Uint16(b)&(1<<uint(it.bit)) != 0
+				it.tag.Value = &pb.Tag_ValueBool{ValueBool: v}
+				continue
+			}
+			words.Unpack(it.tag, b, words.SwapWords)
+		}
+	}
+	return nil
+}
+
+// WriteTags writes one tag per request, in order. Bits are written on
+// their own, without touching the rest of the word.
+func (c *conn) WriteTags(ctx context.Context, tags []*pb.Tag) error {
+	for _, tag := range tags {
+		a, err := parseAddress(tag)
+		if err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+		if a.bit >= 0 {
+			err = c.client.WriteBits(a.area.Bit, a.word, a.bit, []bool{tag.GetValueBool()})
+		} else {
+			err = c.client.WriteWords(a.area.Word, a.word, words.Pack(tag, a.count(tag), words.SwapWords))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package fins
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// simulator is a FINS node with 1000 words of every area. Node 10 is
+// assigned to TCP clients. It counts the commands it answers.
+type simulator struct {
+	mu       sync.Mutex
+	words    map[byte]*[1000]uint16
+	commands int
+}
+
+func newSimulator() *simulator {
+	s := &simulator{words: make(map[byte]*[1000]uint16)}
+	for _, a := range areas {
+		s.words[a.Word] = new([1000]uint16)
+	}
+	s.words[emArea(2).Word] = new([1000]uint16)
+	return s
+}
+
+// count returns the number of commands answered since the last count and
+// starts counting again.
+func (s *simulator) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.commands
+	s.commands = 0
+	return n
+}
+
+// word returns word i of the area of code.
+func (s *simulator) word(code byte, i int) uint16 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.words[code][i]
+}
+
+func (s *simulator) serveTCP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				header := make([]byte, tcpHeaderLength)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				data := make([]byte, binary.BigEndian.Uint32(header[4:])-8)
+				if _, err := io.ReadFull(conn, data); err != nil {
+					return
+				}
+				command := binary.BigEndian.Uint32(header[8:])
+				var resp []byte
+				if command == tcpNodeRequest {
+					command = tcpNodeResponse
+					resp = []byte{0, 0, 0, 10, 0, 0, 0, 1}
+				} else {
+					resp = s.handle(data)
+				}
+				msg := make([]byte, tcpHeaderLength)
+				copy(msg, "FINS")
+				binary.BigEndian.PutUint32(msg[4:], uint32(8+len(resp)))
+				binary.BigEndian.PutUint32(msg[8:], command)
+				conn.Write(append(msg, resp...))
+			}
+		}()
+	}
+}
+
+func (s *simulator) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(s.handle(buf[:n]), addr)
+	}
+}
+
+// handle answers a command frame.
+func (s *simulator) handle(frame []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands++
+	resp := make([]byte, headerLength+4, 2048)
+	resp[0] = 0xC0
+	resp[2] = gatewayCount
+	copy(resp[3:6], frame[6:9])
+	copy(resp[6:9], frame[3:6])
+	resp[9] = frame[9]
+	copy(resp[headerLength:], frame[headerLength:headerLength+2])
+	params := frame[headerLength+2:]
+	reply := func(code uint16, data []byte) []byte {
+		binary.BigEndian.PutUint16(resp[headerLength+2:], code)
+		return append(resp, data...)
+	}
+	switch binary.BigEndian.Uint16(frame[headerLength:]) {
+	case cmdCpuUnitDataRead:
+		data := make([]byte, 92)
+		copy(data, "CJ2M-CPU31")
+		copy(data[20:], "02.01")
+		binary.BigEndian.PutUint16(data[83:], 32768)
+		data[86] = 4
+		return reply(0, data)
+	case cmdMemoryAreaRead, cmdMemoryAreaWrite:
+	default:
+		return reply(0x0401, nil)
+	}
+	code := params[0]
+	word := int(binary.BigEndian.Uint16(params[1:]))
+	bit := int(params[3])
+	n := int(binary.BigEndian.Uint16(params[4:]))
+	values := params[6:]
+	isBit := code < 0x80
+	words := s.words[code|0x80]
+	if words == nil {
+		return reply(0x1101, nil)
+	}
+	if n > MaxWords || word+n > len(words) {
+		return reply(0x1104, nil)
+	}
+	if binary.BigEndian.Uint16(frame[headerLength:]) == cmdMemoryAreaRead {
+		var data []byte
+		for _, v := range words[word : word+n] {
+			data = append(data, byte(v>>8), byte(v))
+		}
+		return reply(0, data)
+	}
+	for i := 0; i < n; i++ {
+		if isBit {
+			mask := uint16(1) << uint(bit+i)
+			if values[i] != 0 {
+				words[word] |= mask
+			} else {
+				words[word] &^= mask
+			}
+			continue
+		}
+		words[word+i] = binary.BigEndian.Uint16(values[2*i:])
+	}
+	// CPU error flag with a normal completion
+	return reply(0x0040, nil)
+}
+
+func TestDriver(t *testing.T) {
+	s := newSimulator()
+	d := s.words[areas["D"].Word]
+	d[0] = 0xFFFE // -2
+	d[1], d[2] = 0x0002, 0x0001
+	r := math.Float32bits(12.5)
+	d[10], d[11] = uint16(r), uint16(r>>16)
+	d[100], d[101], d[102] = 'h'<<8|'e', 'l'<<8|'l', 'o'<<8
+	s.words[areas["CIO"].Word][10] = 1 << 5
+	s.words[areas["H"].Word][5] = 300
+	s.words[emArea(2).Word][200] = 7
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.serveTCP(l)
+	udp, err := net.ListenPacket("udp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	go s.serveUDP(udp)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	for _, protocol := range []string{"fins", "fins-udp"} {
+		s.count()
+		drv, err := driver.Lookup(protocol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := drv.Connect(context.Background(), &pb.Plc{Protocol: protocol, Host: host, Port: uint32(p)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		tags := []*pb.Tag{
+			{Address: "D0", Dt: "Int"},
+			{Address: "DM1", Dt: "DInt"},
+			{Address: "D10", Dt: "Real"},
+			{Address: "D100", Dt: "String[5]"},
+			{Address: "CIO10.05", Dt: "Bool"},
+			{Address: "H5", Dt: "UInt"},
+			{Address: "E2_200", Dt: "Word"},
+			{Address: "D998", Dt: "LInt"},
+		}
+		if err := conn.ReadTags(context.Background(), tags); err != nil {
+			t.Fatal(err)
+		}
+		if tags[0].GetValueInteger() != -2 || tags[1].GetValueInteger() != 0x10002 || tags[2].GetValueDouble() != 12.5 ||
+			tags[3].GetValueString() != "hello" || !tags[4].GetValueBool() || tags[5].GetValueInteger() != 300 ||
+			string(tags[6].GetValueBytes()) != "\x00\x07" {
+			t.Fatalf("%s read %v", protocol, tags)
+		}
+		// D998 runs past the end of D and only fails itself
+		if tags[7].GetErr() != EndCode(0x1104).Error() {
+			t.Fatalf("%s D998 err %q", protocol, tags[7].GetErr())
+		}
+		// D0 to D11, D100, D998, CIO, H and EM
+		if n := s.count(); n != 6 {
+			t.Fatalf("%s %d read commands, want 6", protocol, n)
+		}
+
+		err = conn.WriteTags(context.Background(), []*pb.Tag{
+			{Address: "W3.15", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+			{Address: "D30", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: -1.5}},
+			{Address: "D40", Dt: "String[3]", Value: &pb.Tag_ValueString{ValueString: "abc"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := math.Float32bits(-1.5)
+		w3, dm := s.word(areas["W"].Word, 3), func(i int) uint16 { return s.word(areas["D"].Word, i) }
+		if w3 != 1<<15 || dm(30) != uint16(w) || dm(31) != uint16(w>>16) || dm(40) != 'a'<<8|'b' || dm(41) != 'c'<<8 {
+			t.Fatalf("%s after write: W3 %#x, D30 %#x %#x, D40 %#x %#x", protocol, w3, dm(30), dm(31), dm(40), dm(41))
+		}
+
+		info, err := conn.DeviceInfo(context.Background())
+		if err != nil || info.GetVendor() != Vendor || info.GetModel() != "CJ2M-CPU31" || info.GetVersion() != "02.01" || info.GetDetails()["em_banks"] != "4" {
+			t.Fatalf("%s device info %v %v", protocol, info, err)
+		}
+		// the simulator assigns node 1 to itself over TCP, UDP defaults to
+		// the last byte of 127.0.0.1
+		if info.GetDetails()["node"] != "1" {
+			t.Fatalf("%s node %s", protocol, info.GetDetails()["node"])
+		}
+	}
+
+	drv, _ := driver.Lookup("fins")
+	for _, tag := range []*pb.Tag{
+		{Address: "DB1P0", Dt: "Int"},
+		{Address: "D1.16", Dt: "Bool"},
+		{Address: "D1.2", Dt: "Int"},
+		{Address: "ED_1", Dt: "Int"},
+		{Address: "D65535", Dt: "DInt"},
+	} {
+		if err := drv.Validate(tag); err == nil {
+			t.Errorf("%s %s accepted", tag.Address, tag.Dt)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Package fins is a driver for Omron CS, CJ, CP and NJ PLCs speaking FINS
+// over TCP or UDP.
+package fins
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	cmdMemoryAreaRead  = 0x0101
+	cmdMemoryAreaWrite = 0x0102
+	cmdCpuUnitDataRead = 0x0501
+
+	// MaxWords is the most words a memory area read or write moves.
+	MaxWords = 999
+
+	headerLength = 10
+	icfCommand   = 0x80
+	icfResponse  = 0x40
+	gatewayCount = 0x02
+
+	// FINS/TCP commands of the framing header
+	tcpNodeRequest  = 0
+	tcpNodeResponse = 1
+	tcpFrame        = 2
+	tcpHeaderLength = 16
+)
+
+// EndCode is a failed completion code of a response, without the relay
+// and CPU error flags, returned as an error.
+type EndCode uint16
+
+func (e EndCode) Error() string {
+	switch e {
+	case 0x0401:
+		return "FINS end code 0x0401: undefined command"
+	case 0x1101:
+		return "FINS end code 0x1101: no such area"
+	case 0x1103:
+		return "FINS end code 0x1103: address out of range"
+	case 0x1104:
+		return "FINS end code 0x1104: address range exceeded"
+	case 0x2101:
+		return "FINS end code 0x2101: area is read only"
+	case 0x2102:
+		return "FINS end code 0x2102: area is write protected"
+	case 0x2108:
+		return "FINS end code 0x2108: not possible in the current mode"
+	}
+	return fmt.Sprintf("FINS end code %#04X", uint16(e))
+}
+
+// Node is a FINS node address: network, node and unit.
+type Node struct {
+	Network byte
+	Node    byte
+	Unit    byte
+}
+
+// transport carries one FINS frame to the PLC and returns the response
+// frame.
+type transport interface {
+	send(frame []byte) ([]byte, error)
+	Close() error
+}
+
+// tcpTransport wraps frames in the FINS/TCP header.
+type tcpTransport struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+var errShortResponse = errors.New("short FINS response")
+
+// dialTCP connects and negotiates node addresses: the PLC assigns one to
+// the client and tells its own.
+func dialTCP(address string, timeout time.Duration) (t *tcpTransport, client, server byte, err error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	t = &tcpTransport{conn: conn, timeout: timeout}
+	// node 0 asks for automatic assignment
+	resp, err := t.exchange(tcpNodeRequest, []byte{0, 0, 0, 0})
+	if err == nil && len(resp) < 8 {
+		err = errShortResponse
+	}
+	if err != nil {
+		conn.Close()
+		return nil, 0, 0, fmt.Errorf("FINS/TCP node address: %v", err)
+	}
+	return t, resp[3], resp[7], nil
+}
+
+// exchange sends a FINS/TCP message and returns the data of the answer.
+func (t *tcpTransport) exchange(command uint32, data []byte) ([]byte, error) {
+	msg := make([]byte, tcpHeaderLength+len(data))
+	copy(msg, "FINS")
+	binary.BigEndian.PutUint32(msg[4:], uint32(8+len(data)))
+	binary.BigEndian.PutUint32(msg[8:], command)
+	copy(msg[tcpHeaderLength:], data)
+	t.conn.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := t.conn.Write(msg); err != nil {
+		return nil, err
+	}
+	header := make([]byte, tcpHeaderLength)
+	if _, err := io.ReadFull(t.conn, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(header[4:]))
+	if string(header[:4]) != "FINS" || length < 8 || length > 8+2048 {
+		return nil, fmt.Errorf("bad FINS/TCP header % x", header)
+	}
+	resp := make([]byte, length-8)
+	if _, err := io.ReadFull(t.conn, resp); err != nil {
+		return nil, err
+	}
+	if code := binary.BigEndian.Uint32(header[12:]); code != 0 {
+		return nil, fmt.Errorf("FINS/TCP error code %#x", code)
+	}
+	want := uint32(tcpNodeResponse)
+	if command == tcpFrame {
+		want = tcpFrame
+	}
+	if got := binary.BigEndian.Uint32(header[8:]); got != want {
+		return nil, fmt.Errorf("FINS/TCP command %d, want %d", got, want)
+	}
+	return resp, nil
+}
+
+func (t *tcpTransport) send(frame []byte) ([]byte, error) {
+	return t.exchange(tcpFrame, frame)
+}
+
+func (t *tcpTransport) Close() error {
+	return t.conn.Close()
+}
+
+// udpTransport sends one frame per datagram.
+type udpTransport struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func dialUDP(address string, timeout time.Duration) (*udpTransport, error) {
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &udpTransport{conn: conn, timeout: timeout}, nil
+}
+
+func (t *udpTransport) send(frame []byte) ([]byte, error) {
+	t.conn.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := t.conn.Write(frame); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2048+headerLength)
+	for {
+		n, err := t.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// a late answer to an earlier request that timed out is skipped
+		if n <= headerLength || buf[9] == frame[9] {
+			return buf[:n], nil
+		}
+	}
+}
+
+func (t *udpTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Client issues FINS commands from one node to another.
+type Client struct {
+	t        transport
+	src, dst Node
+
+	mu  sync.Mutex
+	sid byte
+}
+
+// call sends a command and returns the response data after the end code.
+func (c *Client) call(command uint16, params []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sid++
+	frame := make([]byte, headerLength+2+len(params))
+	frame[0] = icfCommand
+	frame[2] = gatewayCount
+	frame[3], frame[4], frame[5] = c.dst.Network, c.dst.Node, c.dst.Unit
+	frame[6], frame[7], frame[8] = c.src.Network, c.src.Node, c.src.Unit
+	frame[9] = c.sid
+	binary.BigEndian.PutUint16(frame[headerLength:], command)
+	copy(frame[headerLength+2:], params)
+	resp, err := c.t.send(frame)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < headerLength+4 || resp[0]&icfResponse == 0 {
+		return nil, errShortResponse
+	}
+	if resp[9] != c.sid {
+		return nil, fmt.Errorf("FINS response to SID %d, want %d", resp[9], c.sid)
+	}
+	if got := binary.BigEndian.Uint16(resp[headerLength:]); got != command {
+		return nil, fmt.Errorf("FINS response to command %#04x, want %#04x", got, command)
+	}
+	// the top bits flag relay and CPU errors that do not fail the command
+	if code := binary.BigEndian.Uint16(resp[headerLength+2:]) & 0x7F3F; code != 0 {
+		return nil, EndCode(code)
+	}
+	return resp[headerLength+4:], nil
+}
+
+func areaParams(area byte, word, bit, count int) []byte {
+	return []byte{area, byte(word >> 8), byte(word), byte(bit), byte(count >> 8), byte(count)}
+}
+
+// ReadWords reads count words of a word area from word and returns them
+// as sent, two bytes each.
+func (c *Client) ReadWords(area byte, word, count int) ([]byte, error) {
+	data, err := c.call(cmdMemoryAreaRead, areaParams(area, word, 0, count))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != count*2 {
+		return nil, errShortResponse
+	}
+	return data, nil
+}
+
+// WriteWords writes words, two bytes each, to a word area from word.
+func (c *Client) WriteWords(area byte, word int, words []byte) error {
+	_, err := c.call(cmdMemoryAreaWrite, append(areaParams(area, word, 0, len(words)/2), words...))
+	return err
+}
+
+// WriteBits writes bits of a bit area from the bit of word.
+func (c *Client) WriteBits(area byte, word, bit int, bits []bool) error {
+	params := areaParams(area, word, bit, len(bits))
+	for _, v := range bits {
+		if v {
+			params = append(params, 1)
+		} else {
+			params = append(params, 0)
+		}
+	}
+	_, err := c.call(cmdMemoryAreaWrite, params)
+	return err
+}
+
+// CpuUnit is the CPU unit data of a PLC.
+type CpuUnit struct {
+	Model   string
+	Version string
+	// DMWords is the size of DM; EMBanks the number of EM banks.
+	DMWords int
+	EMBanks int
+}
+
+// CpuUnitData reads the model, version and memory sizes of the CPU unit.
+func (c *Client) CpuUnitData() (*CpuUnit, error) {
+	data, err := c.call(cmdCpuUnitDataRead, []byte{0})
+	if err != nil {
+		return nil, err
+	}
+	// model and version, 20 bytes each, 40 reserved bytes, then the area
+	// data: program size, IOM size, DM words, timer/counter size, EM banks
+	if len(data) < 40 {
+		return nil, errShortResponse
+	}
+	u := &CpuUnit{Model: trim(data[:20]), Version: trim(data[20:40])}
+	if len(data) >= 87 {
+		u.DMWords = int(binary.BigEndian.Uint16(data[83:]))
+		u.EMBanks = int(data[86])
+	}
+	return u, nil
+}
+
+// trim drops the space or NUL padding of a text field.
+func trim(b []byte) string {
+	for len(b) > 0 && (b[len(b)-1] == ' ' || b[len(b)-1] == 0) {
+		b = b[:len(b)-1]
+	}
+	return string(b)
+}
+
+func (c *Client) Close() error {
+	return c.t.Close()
+}
@@ -0,0 +1,237 @@
+// Package integrity detects changes to the programs of S7 CPUs: it keeps a
+// baseline of the checksums, sizes and dates of their blocks and compares
+// the blocks on the CPUs to it.
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// BlockReader reads the info of every block of a CPU; PlcServer does.
+type BlockReader interface {
+	BlockInfos(ctx context.Context, plc *pb.Plc) ([]*pb.BlockInfo, error)
+}
+
+// Block is what the baseline keeps of a block.
+type Block struct {
+	Type          string    `json:"type"`
+	Number        uint32    `json:"number"`
+	Checksum      uint32    `json:"checksum"`
+	Mc7Size       uint32    `json:"mc7_size"`
+	LoadSize      uint32    `json:"load_size"`
+	CodeDate      time.Time `json:"code_date"`
+	InterfaceDate time.Time `json:"interface_date"`
+}
+
+func (b Block) String() string {
+	return b.Type + strconv.Itoa(int(b.Number))
+}
+
+// Pb returns the block info the block was taken from, as far as the
+// baseline keeps it.
+func (b Block) Pb() *pb.BlockInfo {
+	codeDate, _ := ptypes.TimestampProto(b.CodeDate)
+	intfDate, _ := ptypes.TimestampProto(b.InterfaceDate)
+	return &pb.BlockInfo{
+		Type:          b.Type,
+		Number:        b.Number,
+		Checksum:      b.Checksum,
+		Mc7Size:       b.Mc7Size,
+		LoadSize:      b.LoadSize,
+		CodeDate:      codeDate,
+		InterfaceDate: intfDate,
+	}
+}
+
+func block(info *pb.BlockInfo) Block {
+	codeDate, _ := ptypes.Timestamp(info.GetCodeDate())
+	intfDate, _ := ptypes.Timestamp(info.GetInterfaceDate())
+	return Block{
+		Type:          info.GetType(),
+		Number:        info.GetNumber(),
+		Checksum:      info.GetChecksum(),
+		Mc7Size:       info.GetMc7Size(),
+		LoadSize:      info.GetLoadSize(),
+		CodeDate:      codeDate,
+		InterfaceDate: intfDate,
+	}
+}
+
+// Baseline is the blocks of a CPU when it was taken.
+type Baseline struct {
+	Taken  time.Time `json:"taken"`
+	Blocks []Block   `json:"blocks"`
+}
+
+// Collect takes the blocks on plc now.
+func Collect(ctx context.Context, r BlockReader, plc *pb.Plc) (*Baseline, error) {
+	infos, err := r.BlockInfos(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{Taken: time.Now().UTC(), Blocks: make([]Block, len(infos))}
+	for i, info := range infos {
+		b.Blocks[i] = block(info)
+	}
+	return b, nil
+}
+
+// Kinds of changes.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is a block added, removed or changed since the baseline. Fields
+// names what changed of a changed block.
+type Change struct {
+	Kind     string
+	Block    string
+	Fields   []string
+	Baseline *Block
+	Current  *Block
+}
+
+func (c Change) String() string {
+	if c.Kind == Changed {
+		return fmt.Sprintf("%s %s: %v", c.Block, c.Kind, c.Fields)
+	}
+	return c.Block + " " + c.Kind
+}
+
+// Compare returns the changes from base to current: the blocks added and
+// changed in the order of current, then the blocks removed.
+func Compare(base, current *Baseline) []Change {
+	before := make(map[string]*Block, len(base.Blocks))
+	for i := range base.Blocks {
+		before[base.Blocks[i].String()] = &base.Blocks[i]
+	}
+	var changes []Change
+	seen := make(map[string]bool, len(current.Blocks))
+	for i := range current.Blocks {
+		cur := &current.Blocks[i]
+		name := cur.String()
+		seen[name] = true
+		old, ok := before[name]
+		if !ok {
+			changes = append(changes, Change{Kind: Added, Block: name, Current: cur})
+			continue
+		}
+		var fields []string
+		for _, f := range []struct {
+			name    string
+			changed bool
+		}{
+			{"checksum", old.Checksum != cur.Checksum},
+			{"mc7_size", old.Mc7Size != cur.Mc7Size},
+			{"load_size", old.LoadSize != cur.LoadSize},
+			{"code_date", !old.CodeDate.Equal(cur.CodeDate)},
+			{"interface_date", !old.InterfaceDate.Equal(cur.InterfaceDate)},
+		} {
+			if f.changed {
+				fields = append(fields, f.name)
+			}
+		}
+		if fields != nil {
+			changes = append(changes, Change{Kind: Changed, Block: name, Fields: fields, Baseline: old, Current: cur})
+		}
+	}
+	for i := range base.Blocks {
+		if old := &base.Blocks[i]; !seen[old.String()] {
+			changes = append(changes, Change{Kind: Removed, Block: old.String(), Baseline: old})
+		}
+	}
+	return changes
+}
+
+// Key identifies plc in a store.
+func Key(plc *pb.Plc) string {
+	protocol := plc.GetProtocol()
+	if protocol == "" {
+		protocol = "s7"
+	}
+	host := plc.GetHost()
+	if plc.GetPort() != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(int(plc.GetPort())))
+	}
+	return fmt.Sprintf("%s://%s/%d/%d", protocol, host, plc.GetRack(), plc.GetSlot())
+}
+
+// Store keeps the baselines of CPUs in a JSON file, rewritten whole on
+// every change.
+type Store struct {
+	path string
+
+	mu        sync.Mutex
+	baselines map[string]*Baseline
+}
+
+// OpenStore reads the baselines in the file at path, which need not exist
+// yet.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, baselines: make(map[string]*Baseline)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.baselines); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the baseline of plc.
+func (s *Store) Get(plc *pb.Plc) (*Baseline, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.baselines[Key(plc)]
+	return b, ok
+}
+
+// Put makes b the baseline of plc.
+func (s *Store) Put(plc *pb.Plc, b *Baseline) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := Key(plc)
+	old, had := s.baselines[key]
+	s.baselines[key] = b
+	if err := s.save(); err != nil {
+		if had {
+			s.baselines[key] = old
+		} else {
+			delete(s.baselines, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes the file by renaming a new one over it, so that it is never
+// half written.
+func (s *Store) save() error {
+	b, err := json.MarshalIndent(s.baselines, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,147 @@
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/config"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+type blocks []*pb.BlockInfo
+
+func (b *blocks) BlockInfos(ctx context.Context, plc *pb.Plc) ([]*pb.BlockInfo, error) {
+	return *b, nil
+}
+
+func info(typ string, number, checksum uint32, date time.Time) *pb.BlockInfo {
+	ts, _ := ptypes.TimestampProto(date)
+	return &pb.BlockInfo{Type: typ, Number: number, Checksum: checksum, Mc7Size: 100, LoadSize: 200, CodeDate: ts, InterfaceDate: ts}
+}
+
+func TestCompare(t *testing.T) {
+	t0 := time.Date(2020, 3, 2, 14, 30, 0, 0, time.UTC)
+	r := &blocks{info("OB", 1, 1, t0), info("FC", 12, 2, t0), info("DB", 1, 3, t0)}
+	base, err := Collect(context.Background(), r, &pb.Plc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Compare(base, base); changes != nil {
+		t.Fatalf("changes from itself %v", changes)
+	}
+	*r = blocks{info("OB", 1, 1, t0), info("FC", 12, 4, t0.Add(time.Hour)), info("FB", 1, 5, t0)}
+	current, _ := Collect(context.Background(), r, &pb.Plc{})
+	var got []string
+	for _, c := range Compare(base, current) {
+		got = append(got, c.String())
+	}
+	want := []string{"FC12 changed: [checksum code_date interface_date]", "FB1 added", "DB1 removed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes %q, want %q", got, want)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "integrity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baselines.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plc := &pb.Plc{Host: "10.0.0.1", Slot: 2}
+	if _, ok := s.Get(plc); ok {
+		t.Fatal("baseline in a new store")
+	}
+	b := &Baseline{Taken: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), Blocks: []Block{{Type: "OB", Number: 1, Checksum: 7}}}
+	if err := s.Put(plc, b); err != nil {
+		t.Fatal(err)
+	}
+	s, err = OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := s.Get(plc)
+	if !ok || !reflect.DeepEqual(got, b) {
+		t.Fatalf("reopened baseline %+v", got)
+	}
+	if _, ok := s.Get(&pb.Plc{Host: "10.0.0.1", Slot: 3}); ok {
+		t.Fatal("baseline of another slot")
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "integrity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, _ := OpenStore(filepath.Join(dir, "baselines.json"))
+	var log bytes.Buffer
+	t0 := time.Date(2020, 3, 2, 14, 30, 0, 0, time.UTC)
+	r := &blocks{info("OB", 1, 1, t0), info("FC", 12, 2, t0)}
+	plcs := config.Plcs{{Name: "press", Host: "10.0.0.1"}, {Name: "robot", Host: "10.0.0.2", Protocol: "modbus"}}
+	if _, err := NewMonitor(Config{Plcs: []string{"oven"}}, plcs, r, store, nil); err == nil {
+		t.Fatal("monitor of an unknown PLC")
+	}
+	m, err := NewMonitor(Config{}, plcs, r, store, audit.New(&log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.config) != 1 || m.config[0].Name != "press" {
+		t.Fatalf("watching %v", m.config)
+	}
+	check := func() []Change {
+		t.Helper()
+		changes, err := m.Check(context.Background(), plcs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+
+	// the first check takes the baseline, the next finds nothing
+	check()
+	if changes := check(); changes != nil {
+		t.Fatalf("changes %v", changes)
+	}
+	*r = blocks{info("OB", 1, 1, t0), info("FC", 12, 3, t0)}
+	check()
+	// still changed, but already recorded
+	if changes := check(); len(changes) != 1 {
+		t.Fatalf("changes %v", changes)
+	}
+	*r = blocks{info("OB", 1, 1, t0)}
+	check()
+
+	var got []string
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Action+" "+e.Detail+" "+e.Result)
+	}
+	want := []string{
+		"integrity_baseline 2 blocks ok",
+		"block_changed FC12 checksum alert",
+		"block_removed FC12 alert",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit %q, want %q", got, want)
+	}
+}
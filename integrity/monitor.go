@@ -0,0 +1,110 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/config"
+)
+
+const DefaultInterval = 15 * time.Minute
+
+type Config struct {
+	// Baselines is the file the baselines are kept in.
+	Baselines string          `json:"baselines"`
+	Interval  config.Duration `json:"interval,omitempty"`
+	// Plcs names the PLCs to watch, every S7 PLC when empty.
+	Plcs []string `json:"plcs,omitempty"`
+}
+
+func (c Config) interval() time.Duration {
+	if c.Interval > 0 {
+		return time.Duration(c.Interval)
+	}
+	return DefaultInterval
+}
+
+// Monitor compares the blocks of PLCs to their baselines every interval
+// and records every change in the audit log as it appears, as
+// block_added, block_removed or block_changed with the result "alert". A
+// PLC without a baseline gets the blocks it has the first time.
+type Monitor struct {
+	config config.Plcs
+	r      BlockReader
+	store  *Store
+	audit  *audit.Log
+	every  time.Duration
+
+	// reported are the changes of each PLC already recorded
+	reported map[string]map[string]bool
+}
+
+func NewMonitor(c Config, plcs config.Plcs, r BlockReader, store *Store, log *audit.Log) (*Monitor, error) {
+	selected, err := plcs.Select(c.Plcs)
+	if err != nil {
+		return nil, fmt.Errorf("integrity: %v", err)
+	}
+	return &Monitor{config: selected, r: r, store: store, audit: log, every: c.interval(), reported: make(map[string]map[string]bool)}, nil
+}
+
+// Run checks every PLC, then again every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.every)
+	defer ticker.Stop()
+	for {
+		for _, p := range m.config {
+			if _, err := m.Check(ctx, p); err != nil && ctx.Err() == nil {
+				m.audit.Record(ctx, audit.Entry{Action: "integrity_check", Plc: Key(p.Pb()), Result: err.Error()})
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check compares the blocks of p to its baseline, records the changes not
+// recorded before and returns all of them.
+func (m *Monitor) Check(ctx context.Context, p config.Plc) ([]Change, error) {
+	plc := p.Pb()
+	key := Key(plc)
+	current, err := Collect(ctx, m.r, plc)
+	if err != nil {
+		return nil, err
+	}
+	base, ok := m.store.Get(plc)
+	if !ok {
+		err := m.store.Put(plc, current)
+		result := "ok"
+		if err != nil {
+			result = err.Error()
+		}
+		m.audit.Record(ctx, audit.Entry{Action: "integrity_baseline", Plc: key, Detail: fmt.Sprintf("%d blocks", len(current.Blocks)), Result: result})
+		return nil, err
+	}
+	changes := Compare(base, current)
+	reported := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		// a change is new as long as the block differs in another way
+		id := c.String()
+		if c.Current != nil {
+			id += fmt.Sprintf(" %#04x %v", c.Current.Checksum, c.Current.CodeDate)
+		}
+		reported[id] = true
+		if m.reported[key][id] {
+			continue
+		}
+		detail := c.Block
+		if len(c.Fields) > 0 {
+			detail += " " + strings.Join(c.Fields, ", ")
+		}
+		m.audit.Record(ctx, audit.Entry{Action: "block_" + c.Kind, Plc: key, Detail: detail, Result: "alert"})
+	}
+	m.reported[key] = reported
+	return changes, nil
+}
@@ -0,0 +1,189 @@
+// Package mqtttest provides an in-process MQTT broker for tests.
+package mqtttest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// Broker is a minimal MQTT 3.1.1 broker: QoS 0 and 1, wildcard
+// subscriptions, retained messages and wills, enough for the bridges.
+type Broker struct {
+	l net.Listener
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	subs     map[net.Conn][]string
+	retained map[string]*packets.PublishPacket
+	wills    map[net.Conn]*packets.PublishPacket
+	clients  map[net.Conn]string
+}
+
+func NewBroker(t *testing.T) *Broker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Broker{
+		l:        l,
+		subs:     make(map[net.Conn][]string),
+		retained: make(map[string]*packets.PublishPacket),
+		wills:    make(map[net.Conn]*packets.PublishPacket),
+		clients:  make(map[net.Conn]string),
+	}
+	go b.serve()
+	return b
+}
+
+func (b *Broker) URL() string {
+	return "tcp://" + b.l.Addr().String()
+}
+
+func (b *Broker) Close() {
+	b.l.Close()
+}
+
+// Drop closes the connections of the client clientID as a network failure
+// would, so that their wills are sent.
+func (b *Broker) Drop(clientID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn, id := range b.clients {
+		if id == clientID {
+			conn.Close()
+		}
+	}
+}
+
+func (b *Broker) serve() {
+	for {
+		conn, err := b.l.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *Broker) handle(conn net.Conn) {
+	graceful := false
+	defer func() {
+		b.mu.Lock()
+		will := b.wills[conn]
+		delete(b.subs, conn)
+		delete(b.wills, conn)
+		delete(b.clients, conn)
+		b.mu.Unlock()
+		if will != nil && !graceful {
+			b.route(will)
+		}
+		conn.Close()
+	}()
+	for {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			b.mu.Lock()
+			b.clients[conn] = p.ClientIdentifier
+			b.mu.Unlock()
+			if p.WillFlag {
+				will := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+				will.TopicName = p.WillTopic
+				will.Payload = p.WillMessage
+				will.Retain = p.WillRetain
+				b.mu.Lock()
+				b.wills[conn] = will
+				b.mu.Unlock()
+			}
+			ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			b.send(conn, ack)
+		case *packets.SubscribePacket:
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			b.mu.Lock()
+			b.subs[conn] = append(b.subs[conn], p.Topics...)
+			var retained []*packets.PublishPacket
+			for topic, msg := range b.retained {
+				for _, filter := range p.Topics {
+					if topicMatches(filter, topic) {
+						retained = append(retained, msg)
+					}
+				}
+			}
+			b.mu.Unlock()
+			b.send(conn, ack)
+			for _, msg := range retained {
+				b.deliver(conn, msg)
+			}
+		case *packets.PublishPacket:
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				b.send(conn, ack)
+			}
+			b.route(p)
+		case *packets.PingreqPacket:
+			b.send(conn, packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			graceful = true
+			return
+		}
+	}
+}
+
+func (b *Broker) route(p *packets.PublishPacket) {
+	b.mu.Lock()
+	if p.Retain {
+		b.retained[p.TopicName] = p
+	}
+	var conns []net.Conn
+	for conn, filters := range b.subs {
+		for _, filter := range filters {
+			if topicMatches(filter, p.TopicName) {
+				conns = append(conns, conn)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, conn := range conns {
+		b.deliver(conn, p)
+	}
+}
+
+func (b *Broker) deliver(conn net.Conn, p *packets.PublishPacket) {
+	msg := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	msg.TopicName = p.TopicName
+	msg.Payload = p.Payload
+	msg.Retain = p.Retain
+	b.send(conn, msg)
+}
+
+func (b *Broker) send(conn net.Conn, cp packets.ControlPacket) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	cp.Write(conn)
+}
+
+func topicMatches(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
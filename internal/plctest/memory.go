@@ -0,0 +1,151 @@
+// Package plctest provides PLC stand-ins for tests.
+package plctest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+var ErrOffline = errors.New("PLC offline")
+
+// MemoryPlc serves ReadTags and WriteTags from tag values keyed by
+// address. Tags it has no value for read back with their zero value.
+type MemoryPlc struct {
+	pb.UnimplementedPlcRWServer
+
+	mu      sync.Mutex
+	values  map[string]*pb.Tag
+	offline bool
+	state   pb.CpuState_State
+}
+
+func NewMemoryPlc(tags ...*pb.Tag) *MemoryPlc {
+	m := &MemoryPlc{values: make(map[string]*pb.Tag), state: pb.CpuState_RUN}
+	for _, tag := range tags {
+		m.values[tag.GetAddress()] = tag
+	}
+	return m
+}
+
+// SetOffline makes every following request fail with ErrOffline.
+func (m *MemoryPlc) SetOffline(offline bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.offline = offline
+}
+
+// Set stores a tag value as if the PLC program had written it.
+func (m *MemoryPlc) Set(tag *pb.Tag) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[tag.GetAddress()] = proto.Clone(tag).(*pb.Tag)
+}
+
+// Get returns the stored value of address, nil if there is none.
+func (m *MemoryPlc) Get(address string) *pb.Tag {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if v, ok := m.values[address]; ok {
+		return proto.Clone(v).(*pb.Tag)
+	}
+	return nil
+}
+
+func (m *MemoryPlc) ReadTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.offline {
+		return nil, ErrOffline
+	}
+	for _, tag := range req.GetTags() {
+		if v, ok := m.values[tag.GetAddress()]; ok {
+			tag.Value = proto.Clone(v).(*pb.Tag).Value
+		}
+	}
+	return &pb.RWResult{Tags: req.GetTags()}, nil
+}
+
+func (m *MemoryPlc) WriteTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.offline {
+		return nil, ErrOffline
+	}
+	for _, tag := range req.GetTags() {
+		m.values[tag.GetAddress()] = proto.Clone(tag).(*pb.Tag)
+	}
+	return &pb.RWResult{Tags: req.GetTags()}, nil
+}
+
+// GetCpuInfo describes the memory PLC as an S7-1500.
+func (m *MemoryPlc) GetCpuInfo(ctx context.Context, req *pb.Plc) (*pb.S7CpuInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.offline {
+		return nil, ErrOffline
+	}
+	return &pb.S7CpuInfo{
+		ModuleTypeName: "CPU 1511-1 PN",
+		SerialNumber:   "S C-MEMORY",
+		AsName:         "memory",
+		ModuleName:     req.GetHost(),
+	}, nil
+}
+
+func (m *MemoryPlc) GetDeviceInfo(ctx context.Context, req *pb.Plc) (*pb.DeviceInfo, error) {
+	info, err := m.GetCpuInfo(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeviceInfo{
+		Protocol:     "s7",
+		Vendor:       "Siemens",
+		Model:        info.GetModuleTypeName(),
+		SerialNumber: info.GetSerialNumber(),
+		Name:         info.GetModuleName(),
+		Details:      map[string]string{"as_name": info.GetAsName()},
+	}, nil
+}
+
+func (m *MemoryPlc) GetCpuState(ctx context.Context, req *pb.Plc) (*pb.CpuState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.offline {
+		return nil, ErrOffline
+	}
+	return &pb.CpuState{State: m.state}, nil
+}
+
+// StartCpu asks to confirm with the token "confirm", as PlcServer would
+// with a random one.
+func (m *MemoryPlc) StartCpu(ctx context.Context, req *pb.CpuControlReq) (*pb.CpuControlResult, error) {
+	return m.control(req, pb.CpuState_RUN)
+}
+
+func (m *MemoryPlc) StopCpu(ctx context.Context, req *pb.CpuControlReq) (*pb.CpuControlResult, error) {
+	return m.control(req, pb.CpuState_STOP)
+}
+
+func (m *MemoryPlc) control(req *pb.CpuControlReq, state pb.CpuState_State) (*pb.CpuControlResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.offline {
+		return nil, ErrOffline
+	}
+	switch req.GetConfirm() {
+	case "":
+		return &pb.CpuControlResult{Confirm: "confirm", ConfirmExpires: ptypes.TimestampNow()}, nil
+	case "confirm":
+		m.state = state
+		return &pb.CpuControlResult{State: &pb.CpuState{State: state}}, nil
+	}
+	return nil, status.Error(codes.FailedPrecondition, "confirmation token invalid or expired")
+}
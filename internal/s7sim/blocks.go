@@ -0,0 +1,305 @@
+package s7sim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Block types as the CPU codes them.
+const (
+	BlockOB  byte = 0x38
+	BlockDB  byte = 0x41
+	BlockFC  byte = 0x43
+	BlockSFC byte = 0x44
+	BlockFB  byte = 0x45
+	BlockSFB byte = 0x46
+)
+
+// headerTypes are the codes of the block types in the header of a block in
+// load memory.
+var headerTypes = map[byte]byte{
+	BlockOB:  0x08,
+	BlockDB:  0x0A,
+	BlockFC:  0x0C,
+	BlockSFC: 0x0D,
+	BlockFB:  0x0E,
+	BlockSFB: 0x0F,
+}
+
+// Block is a block in the load memory of the CPU.
+type Block struct {
+	// Language is the code of the language, 1 for STL, 5 for a DB.
+	Language byte
+	Author   string
+	Family   string
+	Name     string
+	// Version is the major version in the high nibble, the minor in the
+	// low.
+	Version  byte
+	Checksum uint16
+	// Date is when the code and the interface changed last.
+	Date time.Time
+	// MC7 is the code, or the values of a DB.
+	MC7 []byte
+	// Interface follows the code in load memory.
+	Interface []byte
+}
+
+type blockKey struct {
+	typ    byte
+	number uint16
+}
+
+// SetBlock loads a block.
+func (c *CPU) SetBlock(typ byte, number uint16, b Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks[blockKey{typ, number}] = b
+}
+
+// RemoveBlock deletes a block.
+func (c *CPU) RemoveBlock(typ byte, number uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.blocks, blockKey{typ, number})
+}
+
+// Data is the block as an upload returns it: a 36 byte header, the MC7
+// code and the interface.
+func (b Block) Data(typ byte, number uint16) []byte {
+	h := make([]byte, 36)
+	h[0], h[1] = 0x70, 0x70
+	h[4], h[5] = b.Language, headerTypes[typ]
+	binary.BigEndian.PutUint16(h[6:], number)
+	binary.BigEndian.PutUint32(h[8:], uint32(36+len(b.MC7)+len(b.Interface)))
+	binary.BigEndian.PutUint16(h[34:], uint16(len(b.MC7)))
+	return append(append(h, b.MC7...), b.Interface...)
+}
+
+// siemensTime returns the days since 1984 and milliseconds since midnight
+// of t.
+func siemensTime(t time.Time) (uint16, uint32) {
+	d := t.Sub(time.Date(1984, 1, 1, 0, 0, 0, 0, time.UTC))
+	days := d / (24 * time.Hour)
+	return uint16(days), uint32((d - days*24*time.Hour) / time.Millisecond)
+}
+
+// info is the block info response of a block.
+func (b Block) info(typ byte, number uint16) []byte {
+	r := make([]byte, 78)
+	r[1] = typ
+	r[9], r[10], r[11] = 0x01, b.Language, typ
+	binary.BigEndian.PutUint16(r[12:], number)
+	binary.BigEndian.PutUint32(r[14:], uint32(36+len(b.MC7)+len(b.Interface)))
+	days, ms := siemensTime(b.Date)
+	binary.BigEndian.PutUint32(r[22:], ms)
+	binary.BigEndian.PutUint16(r[26:], days)
+	binary.BigEndian.PutUint32(r[28:], ms)
+	binary.BigEndian.PutUint16(r[32:], days)
+	binary.BigEndian.PutUint16(r[40:], uint16(len(b.MC7)))
+	copy(r[42:50], b.Author)
+	copy(r[50:58], b.Family)
+	copy(r[58:66], b.Name)
+	r[66] = b.Version
+	binary.BigEndian.PutUint16(r[68:], b.Checksum)
+	return r
+}
+
+// blockFunction answers the userdata block functions: listing all blocks,
+// the blocks of a type and the info of a block.
+func (c *CPU) blockFunction(sub byte, data []byte) (byte, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch sub {
+	case 0x01:
+		counts := make(map[byte]int)
+		for k := range c.blocks {
+			counts[k.typ]++
+		}
+		var res []byte
+		for _, typ := range []byte{BlockOB, BlockFB, BlockFC, BlockDB, BlockSFB, BlockSFC} {
+			res = append(res, 0x30, typ, byte(counts[typ]>>8), byte(counts[typ]))
+		}
+		return 0xFF, res
+	case 0x02:
+		if len(data) < 6 {
+			return 0x0A, nil
+		}
+		var numbers []int
+		for k := range c.blocks {
+			if k.typ == data[5] {
+				numbers = append(numbers, int(k.number))
+			}
+		}
+		sort.Ints(numbers)
+		var res []byte
+		for _, n := range numbers {
+			res = append(res, byte(n>>8), byte(n), 0x22, c.blocks[blockKey{data[5], uint16(n)}].Language)
+		}
+		return 0xFF, res
+	case 0x03:
+		k, ok := parseFileName(data[4:])
+		b, found := c.blocks[k]
+		if !ok || !found {
+			return 0x0A, nil
+		}
+		return 0xFF, b.info(k.typ, k.number)
+	}
+	return 0x0A, nil
+}
+
+// parseFileName parses the name of a block: 0, the type, five digits of
+// number and the file system.
+func parseFileName(name []byte) (blockKey, bool) {
+	if len(name) < 8 || name[0] != '0' {
+		return blockKey{}, false
+	}
+	n, err := strconv.Atoi(string(name[2:7]))
+	if err != nil {
+		return blockKey{}, false
+	}
+	return blockKey{name[1], uint16(n)}, true
+}
+
+// upload answers start upload, upload and end upload, an upload at a time
+// chunk bytes.
+func (c *CPU) upload(ref uint16, params []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if params[0] == 0x1D {
+		if len(params) < 18 || params[9] != '_' {
+			return ackData(ref, 0x8104, nil, nil)
+		}
+		k, ok := parseFileName(params[10:])
+		b, found := c.blocks[k]
+		if !ok || !found {
+			// item not available
+			return ackData(ref, 0xD209, nil, nil)
+		}
+		data := b.Data(k.typ, k.number)
+		c.uploadID++
+		c.uploads[c.uploadID] = data
+		res := []byte{0x1D, 0, 1, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(res[4:], c.uploadID)
+		return ackData(ref, 0, append(append(res, 7), fmt.Sprintf("%07d", len(data))...), nil)
+	}
+	if len(params) < 8 {
+		return ackData(ref, 0x8104, nil, nil)
+	}
+	id := binary.BigEndian.Uint32(params[4:])
+	data, ok := c.uploads[id]
+	if !ok {
+		return ackData(ref, 0xD20B, nil, nil)
+	}
+	if params[0] == 0x1F {
+		delete(c.uploads, id)
+		return ackData(ref, 0, []byte{0x1F}, nil)
+	}
+	more := byte(0)
+	if len(data) > chunk {
+		data, more = data[:chunk], 1
+	}
+	c.uploads[id] = c.uploads[id][len(data):]
+	return ackData(ref, 0, []byte{0x1E, more}, append([]byte{byte(len(data) >> 8), byte(len(data)), 0, 0xFB}, data...))
+}
+
+// download is a download in progress on a connection.
+type download struct {
+	name blockKey
+	// file is the name the CPU asks for the block with
+	file []byte
+	size int
+	data []byte
+}
+
+// protection is the protection level in effect, from SZL 0x0232.
+func (c *CPU) protection() int {
+	s := c.szl[[2]uint16{0x0232, 4}]
+	if len(s.Records) < 8 {
+		return 1
+	}
+	return int(binary.BigEndian.Uint16(s.Records[6:]))
+}
+
+// startDownload answers a request to download a block and asks for the
+// first part of it.
+func (c *CPU) startDownload(frame []byte, dl *download, legitimated bool) []byte {
+	params := frame[17 : 17+binary.BigEndian.Uint16(frame[13:])]
+	ref := binary.BigEndian.Uint16(frame[11:])
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(params) < 32 || params[9] != '_' {
+		return ackData(ref, 0x8104, nil, nil)
+	}
+	name, ok := parseFileName(params[10:18])
+	size, err := strconv.Atoi(string(params[20:26]))
+	if !ok || err != nil {
+		return ackData(ref, 0x8104, nil, nil)
+	}
+	if c.protection() > 1 && !legitimated {
+		return ackData(ref, 0xD241, nil, nil)
+	}
+	*dl = download{name: name, file: append([]byte(nil), params[9:18]...), size: size}
+	return append(ackData(ref, 0, []byte{0x1A}, nil), request(1, append([]byte{0x1B, 0, 0, 0, 0, 0, 0, 0, 9}, dl.file...))...)
+}
+
+// downloadAck takes a part of the block downloaded and asks for the next,
+// or ends the download.
+func (c *CPU) downloadAck(frame []byte, dl *download) []byte {
+	params := frame[19 : 19+binary.BigEndian.Uint16(frame[13:])]
+	data := frame[19+len(params):]
+	switch {
+	case len(params) == 2 && params[0] == 0x1B && len(data) >= 4:
+		n := int(binary.BigEndian.Uint16(data))
+		dl.data = append(dl.data, data[4:4+n]...)
+		if params[1]&0x01 != 0 {
+			return request(1, append([]byte{0x1B, 0, 0, 0, 0, 0, 0, 0, 9}, dl.file...))
+		}
+		return request(1, append([]byte{0x1C, 0, 0, 0, 0, 0, 0, 0, 9}, dl.file...))
+	case len(params) == 1 && params[0] == 0x1C && len(dl.data) == dl.size:
+		c.mu.Lock()
+		c.passive[dl.name] = dl.data
+		c.mu.Unlock()
+	}
+	*dl = download{}
+	return nil
+}
+
+// blockService answers the PI services inserting a downloaded block and
+// deleting a block.
+func (c *CPU) blockService(ref uint16, params []byte, legitimated bool) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name, ok := parseFileName(params[12:20])
+	if !ok {
+		return ackData(ref, 0x8104, nil, nil)
+	}
+	switch string(params[len(params)-5:]) {
+	case "_INSE":
+		data, ok := c.passive[name]
+		if !ok {
+			return ackData(ref, 0xD209, nil, nil)
+		}
+		delete(c.passive, name)
+		mc7 := 36 + int(binary.BigEndian.Uint16(data[34:]))
+		b := Block{Language: data[4], MC7: data[36:mc7], Interface: data[mc7:], Date: time.Now().UTC().Truncate(time.Millisecond)}
+		for _, v := range b.MC7 {
+			b.Checksum += uint16(v)
+		}
+		c.blocks[name] = b
+	case "_DELE":
+		if c.protection() > 1 && !legitimated {
+			return ackData(ref, 0xD241, nil, nil)
+		}
+		if _, ok := c.blocks[name]; !ok {
+			return ackData(ref, 0xD209, nil, nil)
+		}
+		delete(c.blocks, name)
+	default:
+		return ackData(ref, 0x8104, nil, nil)
+	}
+	return ackData(ref, 0, []byte{0x28}, nil)
+}
@@ -0,0 +1,45 @@
+package s7sim
+
+import "time"
+
+func bcd(v int) byte {
+	return byte(v/10<<4 | v%10)
+}
+
+func fromBcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
+
+// Clock is the time on the clock of the CPU, which runs in UTC.
+func (c *CPU) Clock() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().UTC().Add(c.clock)
+}
+
+// SetClock sets the clock of the CPU.
+func (c *CPU) SetClock(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = time.Until(t)
+}
+
+// clockFunction answers reading and setting the clock.
+func (c *CPU) clockFunction(sub byte, data []byte) (byte, []byte) {
+	switch sub {
+	case 0x01:
+		t := c.Clock()
+		ms := t.Nanosecond() / int(time.Millisecond)
+		return 0xFF, []byte{0, bcd(t.Year() / 100), bcd(t.Year() % 100), bcd(int(t.Month())), bcd(t.Day()),
+			bcd(t.Hour()), bcd(t.Minute()), bcd(t.Second()), bcd(ms / 10), byte(ms%10)<<4 | byte(t.Weekday()+1)}
+	case 0x02:
+		if len(data) >= 14 {
+			b := data[4:]
+			ms := fromBcd(b[8])*10 + int(b[9]>>4)
+			c.SetClock(time.Date(fromBcd(b[1])*100+fromBcd(b[2]), time.Month(fromBcd(b[3])), fromBcd(b[4]),
+				fromBcd(b[5]), fromBcd(b[6]), fromBcd(b[7]), ms*int(time.Millisecond), time.UTC))
+		}
+	}
+	// no data
+	return 0x0A, nil
+}
@@ -0,0 +1,409 @@
+// Package s7sim provides a stand-in S7 CPU for tests.
+package s7sim
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// Operating states as SZL 0x0424 reports them.
+const (
+	StateStop byte = 0x04
+	StateRun  byte = 0x08
+)
+
+// chunk is the most SZL data a response carries, small enough that long
+// lists take several.
+const chunk = 200
+
+// SZL is a system status list extract.
+type SZL struct {
+	// Size is the length of a record, Records the records one after
+	// another.
+	Size    int
+	Records []byte
+}
+
+// CPU answers ISO-on-TCP connections the way an S7 CPU does, for the
+// functions goplc sends as raw telegrams: SZL reads, starting and stopping
+// the CPU, listing, uploading, downloading and deleting blocks, reading and
+// setting the clock and sending the password, as well as to reads and
+// writes of M, DBs, timers and counters. It starts in RUN.
+type CPU struct {
+	l net.Listener
+
+	mu         sync.Mutex
+	state      byte
+	warmStarts int
+	coldStarts int
+	szl        map[[2]uint16]SZL
+	blocks     map[blockKey]Block
+	uploads    map[uint32][]byte
+	uploadID   uint32
+	// passive holds the blocks downloaded and not yet inserted
+	passive map[blockKey][]byte
+	m       []byte
+	// timers and counters, a word each
+	timers   []byte
+	counters []byte
+	// clock is how far the clock is ahead
+	clock time.Duration
+	// password is the one connections send to lift the protection level
+	password string
+	tamper   func(resp []byte) []byte
+}
+
+func NewCPU(t *testing.T) *CPU {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &CPU{
+		l:        l,
+		state:    StateRun,
+		szl:      make(map[[2]uint16]SZL),
+		blocks:   make(map[blockKey]Block),
+		uploads:  make(map[uint32][]byte),
+		passive:  make(map[blockKey][]byte),
+		m:        make([]byte, MSize),
+		timers:   make([]byte, 2*Timers),
+		counters: make([]byte, 2*Counters),
+	}
+	c.identify()
+	go c.serve()
+	return c
+}
+
+// Identity of the CPU.
+const (
+	ModuleTypeName  = "CPU 315-2 PN/DP"
+	SerialNumber    = "S C-X4U421302009"
+	OrderCode       = "6ES7 315-2EH14-0AB0"
+	FirmwareVersion = "V3.2.8"
+	PduSize         = 240
+	MaxConnections  = 16
+)
+
+// record makes an SZL record of size bytes starting with index and b.
+func record(size int, index uint16, b ...byte) []byte {
+	r := make([]byte, size)
+	binary.BigEndian.PutUint16(r, index)
+	copy(r[2:], b)
+	return r
+}
+
+func words(v ...uint16) []byte {
+	b := make([]byte, 2*len(v))
+	for i, w := range v {
+		binary.BigEndian.PutUint16(b[2*i:], w)
+	}
+	return b
+}
+
+// identify sets the SZLs identifying the CPU as a CPU 315-2 PN/DP, with a
+// protection level of 2 and the mode selector at RUN-P.
+func (c *CPU) identify() {
+	var ids []byte
+	for _, r := range []struct {
+		index uint16
+		text  string
+	}{
+		{1, "goplc sim"},
+		{2, ModuleTypeName},
+		{3, ""},
+		{4, "Original Siemens Equipment"},
+		{5, SerialNumber},
+		{7, ModuleTypeName},
+	} {
+		ids = append(ids, record(34, r.index, []byte(r.text)...)...)
+	}
+	c.szl[[2]uint16{0x001C, 0}] = SZL{Size: 34, Records: ids}
+
+	order := []byte(OrderCode + " ")
+	var modules []byte
+	modules = append(modules, record(28, 1, append(order, 0, 0, 0, 4, 0, 4)...)...)
+	modules = append(modules, record(28, 6, append(order, 0, 0, 0, 4, 0, 4)...)...)
+	modules = append(modules, record(28, 7, append([]byte("                    "), 0, 0, 'V', 3, 2, 8)...)...)
+	c.szl[[2]uint16{0x0011, 0}] = SZL{Size: 28, Records: modules}
+
+	// selector and parameterized levels, level in effect, selector RUN-P
+	c.szl[[2]uint16{0x0232, 4}] = SZL{Size: 40, Records: record(40, 4, words(2, 0, 2, 2)...)}
+	// max PDU and connections
+	c.szl[[2]uint16{0x0131, 1}] = SZL{Size: 40, Records: record(40, 1, words(PduSize, MaxConnections)...)}
+	// inputs, outputs and M in bytes, timers and counters
+	var areas []byte
+	for i, n := range []uint16{2048, 2048, MSize, Timers, Counters} {
+		areas = append(areas, record(8, uint16(i+1), words(0, n)...)...)
+	}
+	c.szl[[2]uint16{0x0014, 0}] = SZL{Size: 8, Records: areas}
+}
+
+// Plc addresses the CPU.
+func (c *CPU) Plc() *pb.Plc {
+	host, port, _ := net.SplitHostPort(c.l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &pb.Plc{Host: host, Port: uint32(p), Slot: 1}
+}
+
+func (c *CPU) Close() {
+	c.l.Close()
+}
+
+func (c *CPU) State() byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *CPU) SetState(state byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+}
+
+// Starts returns the number of warm and cold restarts.
+func (c *CPU) Starts() (warm, cold int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.warmStarts, c.coldStarts
+}
+
+// Tamper makes the CPU pass every response through f before sending it,
+// for tests of malformed or unexpected responses; nil stops it.
+func (c *CPU) Tamper(f func(resp []byte) []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tamper = f
+}
+
+// SetSZL sets the answer to reading index of SZL id.
+func (c *CPU) SetSZL(id, index uint16, s SZL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.szl[[2]uint16{id, index}] = s
+}
+
+// RemoveSZL makes reading index of SZL id fail as for an SZL the CPU does
+// not have.
+func (c *CPU) RemoveSZL(id, index uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.szl, [2]uint16{id, index})
+}
+
+func (c *CPU) serve() {
+	for {
+		conn, err := c.l.Accept()
+		if err != nil {
+			return
+		}
+		go c.handle(conn)
+	}
+}
+
+func (c *CPU) handle(conn net.Conn) {
+	defer conn.Close()
+	// the rest of an SZL that did not fit its first response
+	var pending []byte
+	var seq byte
+	var dl download
+	// legitimated is whether the connection sent the password
+	var legitimated bool
+	for {
+		tpkt := make([]byte, 4)
+		if _, err := io.ReadFull(conn, tpkt); err != nil {
+			return
+		}
+		frame := make([]byte, binary.BigEndian.Uint16(tpkt[2:]))
+		copy(frame, tpkt)
+		if _, err := io.ReadFull(conn, frame[4:]); err != nil {
+			return
+		}
+		var resp []byte
+		switch {
+		case frame[5] == 0xE0:
+			// connection request, confirmed
+			resp = append([]byte(nil), frame...)
+			resp[5] = 0xD0
+		case len(frame) < 17 || frame[7] != 0x32:
+			return
+		case frame[8] == 0x01 && len(frame) > 17 && frame[17] == 0x1A:
+			resp = c.startDownload(frame, &dl, legitimated)
+		case frame[8] == 0x01:
+			resp = c.job(frame, legitimated)
+		case frame[8] == 0x03:
+			resp = c.downloadAck(frame, &dl)
+		case frame[8] == 0x07:
+			params := frame[17 : 17+binary.BigEndian.Uint16(frame[13:])]
+			data := frame[17+len(params):]
+			group, sub := params[5]&0x0F, params[6]
+			if params[4] == 0x11 {
+				seq++
+				var code byte
+				var refused uint16
+				switch group {
+				case 0x04:
+					code, pending = c.readSzl(binary.BigEndian.Uint16(data[4:]), binary.BigEndian.Uint16(data[6:]))
+				case 0x03:
+					code, pending = c.blockFunction(sub, data)
+				case 0x07:
+					code, pending = c.clockFunction(sub, data)
+				case 0x05:
+					code = 0x0A
+					legitimated, refused = c.security(sub, data)
+				}
+				if refused != 0 {
+					resp = userDataError(group, sub, seq, refused)
+					break
+				}
+				if code != 0xFF {
+					resp = userData(group, sub, seq, false, []byte{code, 0x09, 0, 0})
+					break
+				}
+			}
+			payload := pending
+			pending = nil
+			if len(payload) > chunk {
+				payload, pending = payload[:chunk], payload[chunk:]
+			}
+			resp = userData(group, sub, seq, len(pending) > 0, append([]byte{0xFF, 0x09, byte(len(payload) >> 8), byte(len(payload))}, payload...))
+		default:
+			return
+		}
+		if resp == nil {
+			continue
+		}
+		c.mu.Lock()
+		tamper := c.tamper
+		c.mu.Unlock()
+		if tamper != nil {
+			resp = tamper(resp)
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// readSzl returns the return code of reading index of SZL id and the
+// header and records of the SZL.
+func (c *CPU) readSzl(id, index uint16) (byte, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.szl[[2]uint16{id, index}]
+	if id == 0x0424 {
+		s, ok = SZL{Size: 20, Records: make([]byte, 20)}, true
+		s.Records[3] = c.state
+	}
+	if !ok {
+		// object does not exist
+		return 0x0A, nil
+	}
+	b := make([]byte, 8, 8+len(s.Records))
+	binary.BigEndian.PutUint16(b, id)
+	binary.BigEndian.PutUint16(b[2:], index)
+	binary.BigEndian.PutUint16(b[4:], uint16(s.Size))
+	if s.Size > 0 {
+		binary.BigEndian.PutUint16(b[6:], uint16(len(s.Records)/s.Size))
+	}
+	return 0xFF, append(b, s.Records...)
+}
+
+// job answers a job request.
+func (c *CPU) job(frame []byte, legitimated bool) []byte {
+	params := frame[17 : 17+binary.BigEndian.Uint16(frame[13:])]
+	ref := binary.BigEndian.Uint16(frame[11:])
+	switch params[0] {
+	case 0xF0:
+		// setup communication, granting at most PduSize
+		granted := append([]byte(nil), params...)
+		if binary.BigEndian.Uint16(granted[6:]) > PduSize {
+			binary.BigEndian.PutUint16(granted[6:], PduSize)
+		}
+		return ackData(ref, 0, granted, nil)
+	case 0x28, 0x29:
+		if len(params) > 20 && params[0] == 0x28 && params[len(params)-6] == 5 {
+			return c.blockService(ref, params, legitimated)
+		}
+		if len(params) < 10 || string(params[len(params)-9:]) != "P_PROGRAM" {
+			return ackData(ref, 0x8104, nil, nil)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if params[0] == 0x29 {
+			if c.state == StateStop {
+				return ackData(ref, 0, []byte{0x29, 0x07}, nil)
+			}
+			c.state = StateStop
+			return ackData(ref, 0, []byte{0x29}, nil)
+		}
+		if c.state == StateRun {
+			return ackData(ref, 0, []byte{0x28, 0x02}, nil)
+		}
+		if string(params[len(params)-12:len(params)-10]) == "C " {
+			c.coldStarts++
+		} else {
+			c.warmStarts++
+		}
+		c.state = StateRun
+		return ackData(ref, 0, []byte{0x28}, nil)
+	case 0x1D, 0x1E, 0x1F:
+		return c.upload(ref, params)
+	case 0x04, 0x05:
+		return c.readWriteVar(ref, params, frame[17+len(params):], legitimated)
+	}
+	return ackData(ref, 0x8104, nil, nil)
+}
+
+func header(length int) []byte {
+	b := make([]byte, 7, length)
+	b[0] = 3
+	binary.BigEndian.PutUint16(b[2:], uint16(length))
+	b[4], b[5], b[6] = 2, 0xF0, 0x80
+	return b
+}
+
+// ackData frames a job response with error code code.
+func ackData(ref, code uint16, params, data []byte) []byte {
+	b := header(19 + len(params) + len(data))
+	b = append(b, 0x32, 0x03, 0, 0, byte(ref>>8), byte(ref),
+		byte(len(params)>>8), byte(len(params)), byte(len(data)>>8), byte(len(data)), byte(code>>8), byte(code))
+	return append(append(b, params...), data...)
+}
+
+// request frames a job the CPU sends.
+func request(ref uint16, params []byte) []byte {
+	b := header(17 + len(params))
+	b = append(b, 0x32, 0x01, 0, 0, byte(ref>>8), byte(ref), byte(len(params)>>8), byte(len(params)), 0, 0)
+	return append(b, params...)
+}
+
+// userData frames a response of function group and subfunction sub, more
+// if data units follow.
+func userData(group, sub, seq byte, more bool, data []byte) []byte {
+	last := byte(0)
+	if more {
+		last = 1
+	}
+	return userDataFrame([]byte{0, 1, 0x12, 8, 0x12, 0x80 | group, sub, seq, 0, last, 0, 0}, data)
+}
+
+// userDataError frames a response of function group and subfunction sub
+// with error code code.
+func userDataError(group, sub, seq byte, code uint16) []byte {
+	return userDataFrame([]byte{0, 1, 0x12, 8, 0x12, 0x80 | group, sub, seq, 0, 0, byte(code >> 8), byte(code)}, []byte{0x0A, 0, 0, 0})
+}
+
+func userDataFrame(params, data []byte) []byte {
+	b := header(17 + len(params) + len(data))
+	b = append(b, 0x32, 0x07, 0, 0, 0, 0,
+		byte(len(params)>>8), byte(len(params)), byte(len(data)>>8), byte(len(data)))
+	return append(append(b, params...), data...)
+}
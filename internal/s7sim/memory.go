@@ -0,0 +1,138 @@
+package s7sim
+
+import "encoding/binary"
+
+// Areas as read and write requests code them.
+const (
+	areaCounters = 0x1C
+	areaTimers   = 0x1D
+	areaM        = 0x83
+	areaDB       = 0x84
+)
+
+// MSize is the size of M memory, Timers and Counters the number of timers
+// and counters, as SZL 0x0014 tells them.
+const (
+	MSize    = 2048
+	Timers   = 256
+	Counters = 256
+)
+
+// M returns a copy of M memory.
+func (c *CPU) M() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.m...)
+}
+
+// SetM sets M memory from start to data.
+func (c *CPU) SetM(start int, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	copy(c.m[start:], data)
+}
+
+// Timer returns the word of timer n, its time value in S5Time format.
+func (c *CPU) Timer(n int) uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return binary.BigEndian.Uint16(c.timers[2*n:])
+}
+
+// SetTimer sets the word of timer n.
+func (c *CPU) SetTimer(n int, w uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	binary.BigEndian.PutUint16(c.timers[2*n:], w)
+}
+
+// Counter returns the word of counter n, its value in BCD.
+func (c *CPU) Counter(n int) uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return binary.BigEndian.Uint16(c.counters[2*n:])
+}
+
+// SetCounter sets the word of counter n.
+func (c *CPU) SetCounter(n int, w uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	binary.BigEndian.PutUint16(c.counters[2*n:], w)
+}
+
+// readWriteVar answers reading and writing a range of bytes of M or a DB,
+// whose values are its MC7 code, or of words of timers or counters, to a
+// connection legitimated or not.
+func (c *CPU) readWriteVar(ref uint16, params, data []byte, legitimated bool) []byte {
+	fail := func(code byte) []byte {
+		if params[0] == 0x04 {
+			return ackData(ref, 0, params[:2], []byte{code, 0, 0, 0})
+		}
+		return ackData(ref, 0, params[:2], []byte{code})
+	}
+	if len(params) < 14 || params[1] != 1 {
+		// only an item at a time
+		return fail(0x06)
+	}
+	// timers and counters go by the word and number, the rest by the
+	// byte and bit address
+	numbered := params[10] == areaTimers || params[10] == areaCounters
+	if (numbered && params[5] != params[10]) || (!numbered && params[5] != 0x02) {
+		return fail(0x06)
+	}
+	n := int(binary.BigEndian.Uint16(params[6:]))
+	start := int(uint32(params[11])<<16 | uint32(params[12])<<8 | uint32(params[13]))
+	if numbered {
+		n, start = 2*n, 2*start
+	} else {
+		start >>= 3
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refuses(params[0], legitimated) {
+		// access to the object not allowed
+		return fail(0x03)
+	}
+	var mem []byte
+	key := blockKey{BlockDB, binary.BigEndian.Uint16(params[8:])}
+	switch params[10] {
+	case areaM:
+		mem = c.m
+	case areaTimers:
+		mem = c.timers
+	case areaCounters:
+		mem = c.counters
+	case areaDB:
+		b, ok := c.blocks[key]
+		if !ok {
+			return fail(0x0A)
+		}
+		mem = b.MC7
+	default:
+		return fail(0x0A)
+	}
+	if start+n > len(mem) {
+		// address out of range
+		return fail(0x05)
+	}
+	if params[0] == 0x04 {
+		if numbered {
+			// octets, the length in bytes
+			return ackData(ref, 0, params[:2], append([]byte{0xFF, 0x09, byte(n >> 8), byte(n)}, mem[start:start+n]...))
+		}
+		bits := n << 3
+		return ackData(ref, 0, params[:2], append([]byte{0xFF, 0x04, byte(bits >> 8), byte(bits)}, mem[start:start+n]...))
+	}
+	if len(data) < 4+n {
+		return fail(0x07)
+	}
+	if params[10] == areaDB {
+		// the block may share its code with whoever set it
+		b := c.blocks[key]
+		b.MC7 = append([]byte(nil), b.MC7...)
+		c.blocks[key] = b
+		mem = b.MC7
+	}
+	copy(mem[start:], data[4:4+n])
+	return ackData(ref, 0, params[:2], []byte{0xFF})
+}
@@ -0,0 +1,55 @@
+package s7sim
+
+// Protect sets the protection level in effect in SZL 0x0232 and the
+// password that lifts it for a connection. A CPU with a password refuses
+// writes at level 2 and reads too at level 3; blocks cannot be changed at
+// level 2 or 3 either way.
+func (c *CPU) Protect(level uint16, password string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.password = password
+	c.szl[[2]uint16{0x0232, 4}] = SZL{Size: 40, Records: record(40, 4, words(2, level, level, 2)...)}
+}
+
+// refuses reports whether the protection level refuses function, a read
+// (0x04) or a write (0x05), to a connection.
+func (c *CPU) refuses(function byte, legitimated bool) bool {
+	if legitimated || c.password == "" {
+		return false
+	}
+	if function == 0x05 {
+		return c.protection() >= 2
+	}
+	return c.protection() >= 3
+}
+
+// security answers sending and clearing the password, returning whether
+// the connection is legitimated after and the error code.
+func (c *CPU) security(sub byte, data []byte) (bool, uint16) {
+	if sub != 0x01 {
+		return false, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(data) < 12 || c.password == "" || decodePassword(data[4:12]) != c.password {
+		// invalid password
+		return false, 0xD602
+	}
+	return true, 0
+}
+
+// decodePassword undoes the scrambling of a password and its padding.
+func decodePassword(b []byte) string {
+	p := make([]byte, len(b))
+	for i := range b {
+		p[i] = b[i] ^ 0x55
+		if i >= 2 {
+			p[i] ^= b[i-2]
+		}
+	}
+	n := len(p)
+	for n > 0 && p[n-1] == ' ' {
+		n--
+	}
+	return string(p[:n])
+}
@@ -0,0 +1,299 @@
+package logix
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/driver/words"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultPort = 44818
+	DefaultSlot = 0
+
+	timeout = 5 * time.Second
+	// budget is what a batch of reads may take of an unconnected message,
+	// leaving room for the unconnected send and multiple service headers.
+	budget = MaxMessage - 40
+)
+
+func init() {
+	driver.Register("logix", Driver{})
+}
+
+// atomic are the Logix types of the datatypes.
+var atomic = map[string]byte{
+	"Bool":  0xC1,
+	"SInt":  0xC2,
+	"Int":   0xC3,
+	"DInt":  0xC4,
+	"LInt":  0xC5,
+	"USInt": 0xC6,
+	"UInt":  0xC7,
+	"UDInt": 0xC8,
+	"ULInt": 0xC9,
+	"Real":  0xCA,
+	"LReal": 0xCB,
+	"Byte":  0xD1,
+	"Word":  0xD2,
+	"DWord": 0xD3,
+	"LWord": 0xD4,
+}
+
+var vendors = map[uint16]string{
+	1: "Rockwell Automation/Allen-Bradley",
+}
+
+func isString(dt string) bool {
+	return strings.HasPrefix(dt, "String")
+}
+
+// Driver reads and writes tags of Logix controllers by name, such as
+// Speed, Recipe[2].Temp or Program:Main.Count. Bool, SInt, Int, DInt,
+// LInt, their unsigned forms, Real and LReal map to the atomic Logix types
+// of the same name; String maps to STRING and other string types of
+// LEN and DATA. Plc.Options may set the backplane "slot" of the controller
+// (default 0), or "slot" "none" to talk to the connected device itself.
+type Driver struct{}
+
+func (Driver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	slot := DefaultSlot
+	if s, ok := plc.GetOptions()["slot"]; ok {
+		if s == "none" {
+			slot = -1
+		} else {
+			v, err := strconv.ParseUint(s, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("slot: bad value %q", s)
+			}
+			slot = int(v)
+		}
+	}
+	port := int(plc.GetPort())
+	if port == 0 {
+		port = DefaultPort
+	}
+	d := timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		d = time.Until(deadline)
+	}
+	client, err := Dial(net.JoinHostPort(plc.GetHost(), strconv.Itoa(port)), slot, d)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{client: client}, nil
+}
+
+func (Driver) Validate(tag *pb.Tag) error {
+	if _, err := symbolPath(tag.GetAddress()); err != nil {
+		return err
+	}
+	if _, ok := atomic[tag.GetDt()]; !ok && !isString(tag.GetDt()) {
+		return fmt.Errorf("Datatype illegal")
+	}
+	if tag.GetLength() == 0 {
+		return fmt.Errorf("Datatype illegal")
+	}
+	return nil
+}
+
+func (Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Write:      true,
+		DeviceInfo: true,
+		Datatypes: []string{
+			"Bool", "Byte", "Word", "DWord", "LWord",
+			"SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "ULInt",
+			"Real", "LReal", "String",
+		},
+	}
+}
+
+type conn struct {
+	client *Client
+}
+
+func (c *conn) Close() error {
+	return c.client.Close()
+}
+
+func (c *conn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	id, err := c.client.Identity()
+	if s, ok := err.(Status); ok && s.Code == 0x08 {
+		return nil, driver.ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	vendor, ok := vendors[id.Vendor]
+	if !ok {
+		vendor = fmt.Sprintf("vendor %d", id.Vendor)
+	}
+	return &pb.DeviceInfo{
+		Protocol:     "logix",
+		Vendor:       vendor,
+		Model:        id.ProductName,
+		SerialNumber: fmt.Sprintf("%08X", id.Serial),
+		Version:      fmt.Sprintf("%d.%03d", id.Major, id.Minor),
+		Details: map[string]string{
+			"device_type":  strconv.Itoa(int(id.DeviceType)),
+			"product_code": strconv.Itoa(int(id.ProductCode)),
+		},
+	}, nil
+}
+
+// replySize estimates the reply to reading tag: the reply header, the type
+// and the value.
+func replySize(tag *pb.Tag) int {
+	if isString(tag.GetDt()) {
+		// structure type, LEN and DATA padded to a DINT boundary
+		return 4 + 4 + 4 + (tag.GetLength()-2+3)/4*4
+	}
+	return 4 + 2 + tag.GetLength()
+}
+
+// ReadTags reads the tags in as few Multiple Service Packets as fit in
+// unconnected messages. A failed read, such as of a tag that does not
+// exist, only fails its tag.
+func (c *conn) ReadTags(ctx context.Context, tags []*pb.Tag) error {
+	var batch []*pb.Tag
+	var reqs [][]byte
+	var reqSize, respSize int
+	flush := func() error {
+		if len(reqs) == 0 {
+			return nil
+		}
+		err := c.readBatch(batch, reqs)
+		batch, reqs, reqSize, respSize = nil, nil, 0, 0
+		return err
+	}
+	for _, tag := range tags {
+		path, err := symbolPath(tag.GetAddress())
+		if err != nil {
+			return err
+		}
+		req := readRequest(path)
+		if reqSize+len(req)+2 > budget || respSize+replySize(tag)+2 > budget {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, tag)
+		reqs = append(reqs, req)
+		reqSize += len(req) + 2
+		respSize += replySize(tag) + 2
+	}
+	return flush()
+}
+
+// readBatch sends the read requests of the tags, one alone and more in a
+// Multiple Service Packet.
+func (c *conn) readBatch(tags []*pb.Tag, reqs [][]byte) error {
+	var replies [][]byte
+	if len(reqs) == 1 {
+		reply, err := c.client.send(reqs[0])
+		if err != nil {
+			return err
+		}
+		replies = [][]byte{reply}
+	} else {
+		var err error
+		replies, err = c.client.Multiple(reqs)
+		if s, ok := err.(Status); ok {
+			for _, tag := range tags {
+				tag.Err = s.Error()
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	for i, tag := range tags {
+		data, err := parseReply(replies[i], svcReadTag)
+		if err == nil {
+			err = setValue(tag, data)
+		}
+		if err != nil {
+			tag.Err = err.Error()
+		}
+	}
+	return nil
+}
+
+// setValue sets tag from the data of a Read Tag reply.
+func setValue(tag *pb.Tag, data []byte) error {
+	typ, value, err := parseRead(data)
+	if err != nil {
+		return err
+	}
+	if isString(tag.GetDt()) {
+		if typ[0] != 0xA0 || len(value) < 4 {
+			return fmt.Errorf("type %#02x is not a string", typ[0])
+		}
+		n := int(binary.LittleEndian.Uint32(value))
+		n = pb.Min(pb.Min(n, len(value)-4), tag.GetLength()-2)
+		if n < 0 {
+			n = 0
+		}
+		tag.Value = &pb.Tag_ValueString{ValueString: string(value[4 : 4+n])}
+		return nil
+	}
+	if typ[0] != atomic[tag.GetDt()] {
+		return fmt.Errorf("type %#02x, want %#02x for %s", typ[0], atomic[tag.GetDt()], tag.GetDt())
+	}
+	if len(value) < tag.GetLength() {
+		return errShortReply
+	}
+	if tag.GetDt() == "Bool" {
+		tag.Value = &pb.Tag_ValueBool{ValueBool: value[0] != 0}
+		return nil
+	}
+	tag.SetTagValue(words.Reverse(value[:tag.GetLength()]))
+	return nil
+}
+
+// WriteTags writes one tag per request, in order. Strings are read first
+// to learn their structure type and size.
+func (c *conn) WriteTags(ctx context.Context, tags []*pb.Tag) error {
+	for _, tag := range tags {
+		if err := c.write(tag); err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+	}
+	return nil
+}
+
+func (c *conn) write(tag *pb.Tag) error {
+	switch {
+	case isString(tag.GetDt()):
+		typ, value, err := c.client.ReadTag(tag.GetAddress())
+		if err != nil {
+			return err
+		}
+		if typ[0] != 0xA0 || len(value) < 4 {
+			return fmt.Errorf("type %#02x is not a string", typ[0])
+		}
+		v := tag.GetValueString()
+		if len(v) > len(value)-4 || len(v) > tag.GetLength()-2 {
+			return fmt.Errorf("%d characters do not fit", len(v))
+		}
+		data := make([]byte, len(value))
+		binary.LittleEndian.PutUint32(data, uint32(len(v)))
+		copy(data[4:], v)
+		return c.client.WriteTag(tag.GetAddress(), typ, data)
+	case tag.GetDt() == "Bool":
+		v := byte(0)
+		if tag.GetValueBool() {
+			v = 1
+		}
+		return c.client.WriteTag(tag.GetAddress(), []byte{atomic["Bool"], 0}, []byte{v})
+	}
+	return c.client.WriteTag(tag.GetAddress(), []byte{atomic[tag.GetDt()], 0}, words.Reverse(tag.FillBuffer(0)))
+}
@@ -0,0 +1,289 @@
+package logix
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+type value struct {
+	typ, data []byte
+}
+
+// controller is a CIP responder stand-in holding tags by name. It counts
+// the messages it answers, those routed through the backplane and the
+// largest request.
+type controller struct {
+	mu       sync.Mutex
+	tags     map[string]value
+	messages int
+	routed   int
+	largest  int
+}
+
+func (c *controller) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				header := make([]byte, encapHeaderLength)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				data := make([]byte, binary.LittleEndian.Uint16(header[2:]))
+				if _, err := io.ReadFull(conn, data); err != nil {
+					return
+				}
+				var resp []byte
+				switch binary.LittleEndian.Uint16(header) {
+				case cmdRegisterSession:
+					binary.LittleEndian.PutUint32(header[4:], 0x1234)
+					resp = data
+				case cmdSendRRData:
+					req := data[16 : 16+binary.LittleEndian.Uint16(data[14:])]
+					reply := c.handle(req)
+					resp = append(data[:16:16], reply...)
+					binary.LittleEndian.PutUint16(resp[14:], uint16(len(reply)))
+				default:
+					continue
+				}
+				binary.LittleEndian.PutUint16(header[2:], uint16(len(resp)))
+				conn.Write(append(header, resp...))
+			}
+		}()
+	}
+}
+
+func (c *controller) handle(req []byte) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages++
+	if len(req) > c.largest {
+		c.largest = len(req)
+	}
+	if req[0] == svcUnconnectedSend {
+		c.routed++
+		data := req[2+2*int(req[1]):]
+		n := int(binary.LittleEndian.Uint16(data[2:]))
+		req = data[4 : 4+n]
+	}
+	return c.service(req)
+}
+
+func reply(service, status byte, data []byte) []byte {
+	return append([]byte{service | replyFlag, 0, status, 0}, data...)
+}
+
+// service answers one CIP request.
+func (c *controller) service(req []byte) []byte {
+	service := req[0]
+	path := req[2 : 2+2*int(req[1])]
+	data := req[2+2*int(req[1]):]
+	switch service {
+	case svcGetAttributesAll:
+		id := []byte{1, 0, 14, 0, 0xA6, 0, 32, 11, 0, 0, 0x78, 0x56, 0x34, 0x12}
+		name := "1756-L83E/B"
+		return reply(service, 0, append(append(id, byte(len(name))), name...))
+	case svcMultipleService:
+		n := int(binary.LittleEndian.Uint16(data))
+		resp := make([]byte, 2+2*n)
+		binary.LittleEndian.PutUint16(resp, uint16(n))
+		status := byte(0)
+		for i := 0; i < n; i++ {
+			start := int(binary.LittleEndian.Uint16(data[2+2*i:]))
+			end := len(data)
+			if i+1 < n {
+				end = int(binary.LittleEndian.Uint16(data[4+2*i:]))
+			}
+			binary.LittleEndian.PutUint16(resp[2+2*i:], uint16(len(resp)))
+			r := c.service(data[start:end])
+			if r[2] != 0 {
+				status = 0x1E
+			}
+			resp = append(resp, r...)
+		}
+		return reply(service, status, resp)
+	}
+	name := tagName(path)
+	v, ok := c.tags[name]
+	if !ok {
+		return reply(service, 0x04, nil)
+	}
+	switch service {
+	case svcReadTag:
+		return reply(service, 0, append(append([]byte(nil), v.typ...), v.data...))
+	case svcWriteTag:
+		typ := data[:len(v.typ)]
+		if string(typ) != string(v.typ) || len(data) != len(v.typ)+2+len(v.data) {
+			return append(reply(service, 0xFF, nil)[:3], 1, 0x07, 0x21)
+		}
+		c.tags[name] = value{v.typ, append([]byte(nil), data[len(v.typ)+2:]...)}
+		return reply(service, 0, nil)
+	}
+	return reply(service, 0x08, nil)
+}
+
+// tagName decodes a symbolic path.
+func tagName(path []byte) string {
+	var name strings.Builder
+	for len(path) > 0 {
+		switch path[0] {
+		case 0x91:
+			n := int(path[1])
+			if name.Len() > 0 {
+				name.WriteByte('.')
+			}
+			name.Write(path[2 : 2+n])
+			path = path[2+n+n%2:]
+		case 0x28:
+			fmt.Fprintf(&name, "[%d]", path[1])
+			path = path[2:]
+		default:
+			return ""
+		}
+	}
+	return name.String()
+}
+
+func logixString(s string) []byte {
+	data := make([]byte, 88)
+	binary.LittleEndian.PutUint32(data, uint32(len(s)))
+	copy(data[4:], s)
+	return data
+}
+
+func TestDriver(t *testing.T) {
+	c := &controller{tags: map[string]value{
+		"Speed":              {[]byte{0xCA, 0}, []byte{0, 0, 0x48, 0x41}}, // 12.5
+		"Count":              {[]byte{0xC4, 0}, []byte{0xFE, 0xFF, 0xFF, 0xFF}},
+		"Run":                {[]byte{0xC1, 0}, []byte{0xFF}},
+		"Program:Main.Level": {[]byte{0xC3, 0}, []byte{0x2C, 0x01}},
+		"Recipe[2].Temp":     {[]byte{0xC5, 0}, []byte{1, 0, 0, 0, 1, 0, 0, 0}},
+		"Name":               {[]byte{0xA0, 0x02, 0xCE, 0x0F}, logixString("pump")},
+	}}
+	for i := 0; i < 100; i++ {
+		c.tags["Array["+strconv.Itoa(i)+"]"] = value{[]byte{0xC4, 0}, []byte{byte(i), 0, 0, 0}}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go c.serve(l)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	plc := &pb.Plc{Protocol: "logix", Host: host, Port: uint32(p)}
+
+	drv, err := driver.Lookup("logix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := drv.Connect(context.Background(), plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tags := []*pb.Tag{
+		{Address: "Speed", Dt: "Real"},
+		{Address: "Count", Dt: "DInt"},
+		{Address: "Run", Dt: "Bool"},
+		{Address: "Program:Main.Level", Dt: "Int"},
+		{Address: "Recipe[2].Temp", Dt: "LInt"},
+		{Address: "Name", Dt: "String[82]"},
+		{Address: "Missing", Dt: "DInt"},
+		{Address: "Count", Dt: "Int"},
+	}
+	if err := conn.ReadTags(context.Background(), tags); err != nil {
+		t.Fatal(err)
+	}
+	if tags[0].GetValueDouble() != 12.5 || tags[1].GetValueInteger() != -2 || !tags[2].GetValueBool() ||
+		tags[3].GetValueInteger() != 300 || tags[4].GetValueInteger() != 1<<32|1 || tags[5].GetValueString() != "pump" {
+		t.Fatalf("read %v", tags)
+	}
+	if tags[6].GetErr() != (Status{Code: 0x04}).Error() || tags[7].GetErr() == "" {
+		t.Fatalf("errors %q, %q", tags[6].GetErr(), tags[7].GetErr())
+	}
+	// one multiple service packet, routed to slot 0
+	if c.messages != 1 || c.routed != 1 {
+		t.Fatalf("%d messages, %d routed, want 1", c.messages, c.routed)
+	}
+
+	c.messages = 0
+	tags = nil
+	for i := 0; i < 100; i++ {
+		tags = append(tags, &pb.Tag{Address: "Array[" + strconv.Itoa(i) + "]", Dt: "DInt"})
+	}
+	if err := conn.ReadTags(context.Background(), tags); err != nil {
+		t.Fatal(err)
+	}
+	for i, tag := range tags {
+		if tag.GetValueInteger() != int64(i) {
+			t.Fatalf("%s = %v", tag.GetAddress(), tag)
+		}
+	}
+	if c.messages < 2 || c.largest > MaxMessage {
+		t.Fatalf("%d messages, largest %d bytes", c.messages, c.largest)
+	}
+
+	err = conn.WriteTags(context.Background(), []*pb.Tag{
+		{Address: "Speed", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: -1.5}},
+		{Address: "Run", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: false}},
+		{Address: "Name", Dt: "String[82]", Value: &pb.Tag_ValueString{ValueString: "pump 3"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	speed := math.Float32frombits(binary.LittleEndian.Uint32(c.tags["Speed"].data))
+	if speed != -1.5 || c.tags["Run"].data[0] != 0 || string(c.tags["Name"].data) != string(logixString("pump 3")) {
+		t.Fatalf("after write: Speed %v, Run %v, Name %q", speed, c.tags["Run"].data, c.tags["Name"].data[:10])
+	}
+	if err := conn.WriteTags(context.Background(), []*pb.Tag{{Address: "Count", Dt: "Int"}}); err == nil {
+		t.Fatal("write of the wrong type succeeded")
+	}
+
+	info, err := conn.DeviceInfo(context.Background())
+	if err != nil || info.GetVendor() != vendors[1] || info.GetModel() != "1756-L83E/B" || info.GetVersion() != "32.011" || info.GetSerialNumber() != "12345678" {
+		t.Fatalf("device info %v %v", info, err)
+	}
+
+	// without a route requests go to the connected device
+	plc.Options = map[string]string{"slot": "none"}
+	direct, err := drv.Connect(context.Background(), plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer direct.Close()
+	c.routed = 0
+	if err := direct.ReadTags(context.Background(), []*pb.Tag{{Address: "Count", Dt: "DInt"}}); err != nil || c.routed != 0 {
+		t.Fatalf("direct read: %v, %d routed", err, c.routed)
+	}
+
+	for _, tag := range []*pb.Tag{
+		{Address: "1abc", Dt: "Int"},
+		{Address: "A..B", Dt: "Int"},
+		{Address: "A.Program:B", Dt: "Int"},
+		{Address: "A[x]", Dt: "Int"},
+		{Address: "Count", Dt: "Char"},
+		{Address: "Count", Dt: "DTL"},
+	} {
+		if err := drv.Validate(tag); err == nil {
+			t.Errorf("%s %s accepted", tag.Address, tag.Dt)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Package logix is a driver for Allen-Bradley ControlLogix and
+// CompactLogix controllers, reading and writing tags by name with CIP
+// services over EtherNet/IP.
+package logix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EtherNet/IP encapsulation commands
+	cmdRegisterSession   = 0x0065
+	cmdUnregisterSession = 0x0066
+	cmdSendRRData        = 0x006F
+	encapHeaderLength    = 24
+
+	// common packet format items
+	itemNull        = 0x0000
+	itemUnconnected = 0x00B2
+
+	// CIP services
+	svcGetAttributesAll = 0x01
+	svcMultipleService  = 0x0A
+	svcReadTag          = 0x4C
+	svcWriteTag         = 0x4D
+	svcUnconnectedSend  = 0x52
+	replyFlag           = 0x80
+
+	// MaxMessage bounds the size of an unconnected request or reply.
+	MaxMessage = 504
+)
+
+var (
+	// paths of the message router, the connection manager and the
+	// identity object
+	routerPath     = []byte{0x20, 0x02, 0x24, 0x01}
+	connectionPath = []byte{0x20, 0x06, 0x24, 0x01}
+	identityPath   = []byte{0x20, 0x01, 0x24, 0x01}
+)
+
+// Status is a failed CIP general status with the first extended status
+// word, returned as an error.
+type Status struct {
+	Code     byte
+	Extended uint16
+}
+
+func (s Status) Error() string {
+	var text string
+	switch s.Code {
+	case 0x01:
+		text = "connection failure"
+	case 0x04:
+		text = "path segment error, no such tag"
+	case 0x05:
+		text = "path destination unknown"
+	case 0x06:
+		text = "partial transfer"
+	case 0x08:
+		text = "service not supported"
+	case 0x0F:
+		text = "privilege violation"
+	case 0x13:
+		text = "not enough data"
+	case 0x1E:
+		text = "embedded service error"
+	case 0xFF:
+		if s.Extended == 0x2107 {
+			text = "datatype mismatch"
+		} else {
+			text = "general error"
+		}
+	default:
+		return fmt.Sprintf("CIP status %#02x", s.Code)
+	}
+	return fmt.Sprintf("CIP status %#02x: %s", s.Code, text)
+}
+
+var errShortReply = errors.New("short CIP reply")
+
+// Client sends unconnected CIP requests in an EtherNet/IP session. With a
+// route they go to the controller behind the module it connects to.
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+	route   []byte
+
+	mu      sync.Mutex
+	session uint32
+	context uint64
+}
+
+// Dial connects and registers a session. slot is the backplane slot of
+// the controller, or negative to talk to the connected device itself.
+func Dial(address string, slot int, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, timeout: timeout}
+	if slot >= 0 {
+		// port 1 is the backplane
+		c.route = []byte{0x01, byte(slot)}
+	}
+	// protocol version 1, no options
+	header, _, err := c.exchange(cmdRegisterSession, []byte{1, 0, 0, 0})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("EtherNet/IP register session: %v", err)
+	}
+	c.session = binary.LittleEndian.Uint32(header[4:])
+	return c, nil
+}
+
+// exchange sends an encapsulated command and returns the header and data
+// of the answer.
+func (c *Client) exchange(command uint16, data []byte) (header, resp []byte, err error) {
+	c.context++
+	msg := make([]byte, encapHeaderLength+len(data))
+	binary.LittleEndian.PutUint16(msg, command)
+	binary.LittleEndian.PutUint16(msg[2:], uint16(len(data)))
+	binary.LittleEndian.PutUint32(msg[4:], c.session)
+	binary.LittleEndian.PutUint64(msg[12:], c.context)
+	copy(msg[encapHeaderLength:], data)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, nil, err
+	}
+	if command == cmdUnregisterSession {
+		// not answered
+		return nil, nil, nil
+	}
+	header = make([]byte, encapHeaderLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, nil, err
+	}
+	resp = make([]byte, binary.LittleEndian.Uint16(header[2:]))
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, nil, err
+	}
+	if status := binary.LittleEndian.Uint32(header[8:]); status != 0 {
+		return nil, nil, fmt.Errorf("EtherNet/IP status %#x", status)
+	}
+	if binary.LittleEndian.Uint16(header) != command || binary.LittleEndian.Uint64(header[12:]) != c.context {
+		return nil, nil, fmt.Errorf("EtherNet/IP answer % x does not match the request", header)
+	}
+	return header, resp, nil
+}
+
+// request encodes a CIP request for service at path.
+func request(service byte, path, data []byte) []byte {
+	req := append([]byte{service, byte(len(path) / 2)}, path...)
+	return append(req, data...)
+}
+
+// send delivers a CIP request, wrapped in an unconnected send when there
+// is a route, and returns the raw reply.
+func (c *Client) send(req []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.route != nil {
+		// priority and time tick, timeout ticks, then the embedded request
+		data := []byte{0x0A, 0x0E, byte(len(req)), byte(len(req) >> 8)}
+		data = append(data, req...)
+		if len(req)%2 == 1 {
+			data = append(data, 0)
+		}
+		data = append(data, byte(len(c.route)/2), 0)
+		req = request(svcUnconnectedSend, connectionPath, append(data, c.route...))
+	}
+	// interface handle, timeout, two items: null address and the request
+	data := make([]byte, 16, 16+len(req))
+	binary.LittleEndian.PutUint16(data[6:], 2)
+	binary.LittleEndian.PutUint16(data[8:], itemNull)
+	binary.LittleEndian.PutUint16(data[12:], itemUnconnected)
+	binary.LittleEndian.PutUint16(data[14:], uint16(len(req)))
+	_, resp, err := c.exchange(cmdSendRRData, append(data, req...))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 16 || binary.LittleEndian.Uint16(resp[12:]) != itemUnconnected {
+		return nil, errShortReply
+	}
+	reply := resp[16:]
+	if n := int(binary.LittleEndian.Uint16(resp[14:])); n <= len(reply) {
+		reply = reply[:n]
+	}
+	return reply, nil
+}
+
+// parseReply checks a CIP reply to service and returns its data. A failed
+// unconnected send answers in place of the embedded service.
+func parseReply(reply []byte, service byte) ([]byte, error) {
+	if len(reply) < 4 || len(reply) < 4+2*int(reply[3]) {
+		return nil, errShortReply
+	}
+	if reply[0] != service|replyFlag && reply[0] != svcUnconnectedSend|replyFlag {
+		return nil, fmt.Errorf("CIP reply to service %#02x, want %#02x", reply[0]&^replyFlag, service)
+	}
+	if reply[2] != 0 {
+		s := Status{Code: reply[2]}
+		if reply[3] > 0 {
+			s.Extended = binary.LittleEndian.Uint16(reply[4:])
+		}
+		return reply[4+2*int(reply[3]):], s
+	}
+	return reply[4+2*int(reply[3]):], nil
+}
+
+// call sends a request and returns the data of its reply.
+func (c *Client) call(service byte, path, data []byte) ([]byte, error) {
+	reply, err := c.send(request(service, path, data))
+	if err != nil {
+		return nil, err
+	}
+	return parseReply(reply, service)
+}
+
+var segmentReg = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?::[A-Za-z_][A-Za-z0-9_]*)?)(?:\[(\d+(?:,\d+)*)\])?$`)
+
+// symbolPath encodes a tag name such as Program:Main.Recipe[2].Speed as
+// symbolic and element segments.
+func symbolPath(name string) ([]byte, error) {
+	var path []byte
+	for i, part := range strings.Split(name, ".") {
+		match := segmentReg.FindStringSubmatch(part)
+		if match == nil || (i > 0 && strings.Contains(match[1], ":")) || len(match[1]) > 255 {
+			return nil, fmt.Errorf("Logix tag name %q is not a name with optional members and indices", name)
+		}
+		path = append(path, 0x91, byte(len(match[1])))
+		path = append(path, match[1]...)
+		if len(match[1])%2 == 1 {
+			path = append(path, 0)
+		}
+		if match[2] == "" {
+			continue
+		}
+		for _, s := range strings.Split(match[2], ",") {
+			n, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("index %s: %v", s, err)
+			}
+			switch {
+			case n <= 0xFF:
+				path = append(path, 0x28, byte(n))
+			case n <= 0xFFFF:
+				path = append(path, 0x29, 0, byte(n), byte(n>>8))
+			default:
+				path = append(path, 0x2A, 0, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+			}
+		}
+	}
+	return path, nil
+}
+
+// readRequest is the Read Tag request for one element of a tag.
+func readRequest(path []byte) []byte {
+	return request(svcReadTag, path, []byte{1, 0})
+}
+
+// parseRead splits the data of a Read Tag reply into the type, two bytes
+// or four for structures, and the value.
+func parseRead(data []byte) (typ, value []byte, err error) {
+	if len(data) < 2 {
+		return nil, nil, errShortReply
+	}
+	n := 2
+	if data[0] == 0xA0 {
+		n = 4
+	}
+	if len(data) < n {
+		return nil, nil, errShortReply
+	}
+	return data[:n], data[n:], nil
+}
+
+// ReadTag reads one element of a tag and returns its type and value.
+func (c *Client) ReadTag(name string) (typ, value []byte, err error) {
+	path, err := symbolPath(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	reply, err := c.send(readRequest(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := parseReply(reply, svcReadTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseRead(data)
+}
+
+// WriteTag writes one element of a tag of type typ.
+func (c *Client) WriteTag(name string, typ, value []byte) error {
+	path, err := symbolPath(name)
+	if err != nil {
+		return err
+	}
+	data := append(append([]byte(nil), typ...), 1, 0)
+	_, err = c.call(svcWriteTag, path, append(data, value...))
+	return err
+}
+
+// Multiple sends requests in one Multiple Service Packet and returns the
+// raw reply of each.
+func (c *Client) Multiple(reqs [][]byte) ([][]byte, error) {
+	data := make([]byte, 2+2*len(reqs))
+	binary.LittleEndian.PutUint16(data, uint16(len(reqs)))
+	for i, req := range reqs {
+		binary.LittleEndian.PutUint16(data[2+2*i:], uint16(len(data)))
+		data = append(data, req...)
+	}
+	resp, err := c.call(svcMultipleService, routerPath, data)
+	// an embedded service error only fails some of the replies
+	if s, ok := err.(Status); ok && s.Code == 0x1E {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 || int(binary.LittleEndian.Uint16(resp)) != len(reqs) || len(resp) < 2+2*len(reqs) {
+		return nil, errShortReply
+	}
+	replies := make([][]byte, len(reqs))
+	for i := range reqs {
+		start := int(binary.LittleEndian.Uint16(resp[2+2*i:]))
+		end := len(resp)
+		if i+1 < len(reqs) {
+			end = int(binary.LittleEndian.Uint16(resp[4+2*i:]))
+		}
+		if start > end || end > len(resp) {
+			return nil, errShortReply
+		}
+		replies[i] = resp[start:end]
+	}
+	return replies, nil
+}
+
+// Identity is the identity object of a device.
+type Identity struct {
+	Vendor       uint16
+	DeviceType   uint16
+	ProductCode  uint16
+	Major, Minor byte
+	Serial       uint32
+	ProductName  string
+}
+
+// Identity reads the identity object of the controller.
+func (c *Client) Identity() (*Identity, error) {
+	data, err := c.call(svcGetAttributesAll, identityPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	// vendor, device type, product code, revision, status, serial number
+	// and the product name as a short string
+	if len(data) < 15 || len(data) < 15+int(data[14]) {
+		return nil, errShortReply
+	}
+	return &Identity{
+		Vendor:      binary.LittleEndian.Uint16(data),
+		DeviceType:  binary.LittleEndian.Uint16(data[2:]),
+		ProductCode: binary.LittleEndian.Uint16(data[4:]),
+		Major:       data[6],
+		Minor:       data[7],
+		Serial:      binary.LittleEndian.Uint32(data[10:]),
+		ProductName: string(data[15 : 15+int(data[14])]),
+	}, nil
+}
+
+// Close ends the session and the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.exchange(cmdUnregisterSession, nil)
+	c.mu.Unlock()
+	return c.conn.Close()
+}
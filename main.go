@@ -1,9 +1,175 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"google.golang.org/grpc"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/clock"
+	"github.com/thinkontrolsy/goplc/config"
+	_ "github.com/thinkontrolsy/goplc/fins"
+	"github.com/thinkontrolsy/goplc/integrity"
+	_ "github.com/thinkontrolsy/goplc/logix"
+	_ "github.com/thinkontrolsy/goplc/melsec"
+	"github.com/thinkontrolsy/goplc/modbus"
+	"github.com/thinkontrolsy/goplc/mqtt"
+	"github.com/thinkontrolsy/goplc/opcua"
+	"github.com/thinkontrolsy/goplc/rest"
+	"github.com/thinkontrolsy/goplc/s7"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	"github.com/thinkontrolsy/goplc/sparkplug"
+	"github.com/thinkontrolsy/goplc/web"
 )
 
+type Config struct {
+	Listen string `json:"listen"`
+	// WritePolicy is "any" (default), "configured" to only allow writes to
+	// configured tags marked writable, or "none".
+	WritePolicy string `json:"write_policy,omitempty"`
+	// Users log in to gRPC with basic credentials in the authorization
+	// metadata, and to HTTP unless it has users of its own.
+	Users auth.Users `json:"users,omitempty"`
+	// AuditLog is the file CPU state changes are recorded in; stderr by
+	// default.
+	AuditLog string `json:"audit_log,omitempty"`
+	// Passwords are those of protected S7 CPUs, by the name password_ref
+	// of a PLC gives.
+	Passwords map[string]string    `json:"passwords,omitempty"`
+	Plcs      config.Plcs          `json:"plcs,omitempty"`
+	Mqtt      *mqtt.Config         `json:"mqtt,omitempty"`
+	Sparkplug *sparkplug.Config    `json:"sparkplug,omitempty"`
+	Modbus    *modbus.FacadeConfig `json:"modbus,omitempty"`
+	Opcua     *opcua.Config        `json:"opcua,omitempty"`
+	Http      *rest.Config         `json:"http,omitempty"`
+	Integrity *integrity.Config    `json:"integrity,omitempty"`
+	// Clock watches the drift of the clocks of CPUs, served at /metrics
+	// when HTTP is configured.
+	Clock *clock.Config `json:"clock,omitempty"`
+}
+
 func main() {
-	fmt.Println("")
+	path := flag.String("config", "", "JSON configuration file")
+	listen := flag.String("listen", ":50051", "gRPC listen address")
+	flag.Parse()
+
+	c := Config{Listen: *listen}
+	if *path != "" {
+		if err := config.Load(*path, &c); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := &s7.PlcServer{Audit: audit.New(os.Stderr)}
+	if c.AuditLog != "" {
+		l, err := audit.Open(c.AuditLog)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.Audit = l
+	}
+	server.KeepPasswords(context.Background(), c.Passwords)
+	switch c.WritePolicy {
+	case "", "any":
+	case "configured":
+		server.WritePolicy = c.Plcs
+	case "none":
+		server.WritePolicy = s7.ReadOnly
+	default:
+		log.Fatalf("unknown write policy %q", c.WritePolicy)
+	}
+
+	ctx := context.Background()
+	if c.Integrity != nil {
+		store, err := integrity.OpenStore(c.Integrity.Baselines)
+		if err != nil {
+			log.Fatalf("integrity: %v", err)
+		}
+		server.Baselines = store
+		monitor, err := integrity.NewMonitor(*c.Integrity, c.Plcs, server, store, server.Audit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go monitor.Run(ctx)
+	}
+	var clocks *clock.Monitor
+	if c.Clock != nil {
+		var err error
+		if clocks, err = clock.NewMonitor(*c.Clock, c.Plcs, server, server.Audit); err != nil {
+			log.Fatal(err)
+		}
+		go clocks.Run(ctx)
+	}
+	if c.Mqtt != nil {
+		bridge := mqtt.NewBridge(*c.Mqtt, c.Plcs, server)
+		go func() {
+			if err := bridge.Run(ctx); err != nil {
+				log.Fatalf("mqtt: %v", err)
+			}
+		}()
+	}
+	if c.Sparkplug != nil {
+		node := sparkplug.NewNode(*c.Sparkplug, c.Plcs, server)
+		go func() {
+			if err := node.Run(ctx); err != nil {
+				log.Fatalf("sparkplug: %v", err)
+			}
+		}()
+	}
+	if c.Modbus != nil {
+		facade, err := modbus.NewFacade(*c.Modbus, c.Plcs, server)
+		if err != nil {
+			log.Fatalf("modbus: %v", err)
+		}
+		mb := &modbus.Server{Handler: facade}
+		go func() {
+			log.Fatalf("modbus: %v", mb.ListenAndServe(c.Modbus.Listen))
+		}()
+	}
+
+	if c.Opcua != nil {
+		ua, err := opcua.NewServer(*c.Opcua, c.Plcs, server)
+		if err != nil {
+			log.Fatalf("opcua: %v", err)
+		}
+		go func() {
+			log.Fatalf("opcua: %v", ua.ListenAndServe(c.Opcua.Listen))
+		}()
+	}
+
+	if c.Http != nil {
+		if len(c.Http.Users) == 0 {
+			c.Http.Users = c.Users
+		}
+		gateway := rest.NewGateway(*c.Http, server)
+		gateway.Handle("/feed", rest.NewFeed(server))
+		gateway.Handle("/ui/", http.StripPrefix("/ui", web.Handler()))
+		if clocks != nil {
+			gateway.Handle("/metrics", clocks)
+		}
+		go func() {
+			log.Fatalf("http: %v", http.ListenAndServe(c.Http.Listen, gateway))
+		}()
+	}
+
+	lis, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var opts []grpc.ServerOption
+	if len(c.Users) > 0 {
+		opts = append(opts,
+			grpc.UnaryInterceptor(c.Users.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(c.Users.StreamServerInterceptor()))
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterPlcRWServer(s, server)
+	log.Printf("listening on %s", c.Listen)
+	log.Fatal(s.Serve(lis))
 }
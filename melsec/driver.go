@@ -0,0 +1,401 @@
+package melsec
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/driver/words"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultPort = 5000
+	Vendor      = "Mitsubishi Electric"
+
+	timeout = 5 * time.Second
+	// maxGap is the number of unused words or bits a batch read may span to
+	// merge two tags into one request.
+	maxGap = 16
+)
+
+func init() {
+	driver.Register("melsec", Driver{})
+}
+
+// Device is a device area of the CPU.
+type Device struct {
+	Name string
+	Code byte
+	// Bit is set for bit devices, which are read in bit units.
+	Bit bool
+	// Hex is set for devices numbered in hexadecimal.
+	Hex bool
+}
+
+var devices = map[string]Device{
+	"X":  {"X", 0x9C, true, true},
+	"Y":  {"Y", 0x9D, true, true},
+	"M":  {"M", 0x90, true, false},
+	"L":  {"L", 0x92, true, false},
+	"F":  {"F", 0x93, true, false},
+	"B":  {"B", 0xA0, true, true},
+	"SM": {"SM", 0x91, true, false},
+	"D":  {"D", 0xA8, false, false},
+	"W":  {"W", 0xB4, false, true},
+	"R":  {"R", 0xAF, false, false},
+	"ZR": {"ZR", 0xB0, false, false},
+	"SD": {"SD", 0xA9, false, false},
+}
+
+var addressReg = regexp.MustCompile(`^(SM|SD|ZR|X|Y|M|L|F|B|D|W|R)([0-9A-F]+)(?:\.([0-9A-F]))?$`)
+
+// address is a parsed tag address: a device, the number of the first point
+// and, for Bool tags in word devices, a bit.
+type address struct {
+	device Device
+	number int
+	bit    int
+}
+
+func parseAddress(tag *pb.Tag) (address, error) {
+	match := addressReg.FindStringSubmatch(tag.GetAddress())
+	if match == nil {
+		return address{}, fmt.Errorf("MC address %q is not a device such as D, M, X, Y, W or R followed by a number", tag.GetAddress())
+	}
+	a := address{device: devices[match[1]], bit: -1}
+	base := 10
+	if a.device.Hex {
+		base = 16
+	}
+	number, err := strconv.ParseUint(match[2], base, 24)
+	if err != nil {
+		return address{}, fmt.Errorf("%s takes a base %d number below %d", a.device.Name, base, 1<<24)
+	}
+	a.number = int(number)
+	if match[3] != "" {
+		if a.device.Bit || tag.GetDt() != "Bool" {
+			return address{}, fmt.Errorf("only Bool tags in word devices take a bit 0-F")
+		}
+		bit, _ := strconv.ParseUint(match[3], 16, 8)
+		a.bit = int(bit)
+	}
+	if a.device.Bit && tag.GetDt() != "Bool" {
+		return address{}, fmt.Errorf("only Bool tags fit %s", a.device.Name)
+	}
+	if tag.GetLength() == 0 {
+		return address{}, fmt.Errorf("Datatype illegal")
+	}
+	if a.number+a.count(tag) > 1<<24 {
+		return address{}, fmt.Errorf("%s ends beyond the last device number", tag.GetAddress())
+	}
+	return a, nil
+}
+
+// count is the number of bits or words the tag takes.
+func (a address) count(tag *pb.Tag) int {
+	switch {
+	case a.device.Bit || tag.GetDt() == "Bool":
+		return 1
+	case isString(tag.GetDt()):
+		return (tag.GetLength() - 1) / 2
+	}
+	return (tag.GetLength() + 1) / 2
+}
+
+func isString(dt string) bool {
+	return strings.HasPrefix(dt, "String")
+}
+
+// parseRoute reads the "network" and "station" options of plc, the
+// network and PC numbers of the CPU as seen from the connected module.
+func parseRoute(plc *pb.Plc) (Route, error) {
+	r := LocalRoute
+	for _, o := range []struct {
+		name string
+		v    *byte
+	}{{"network", &r.Network}, {"station", &r.Station}} {
+		s, ok := plc.GetOptions()[o.name]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return r, fmt.Errorf("%s: bad value %q", o.name, s)
+		}
+		*o.v = byte(v)
+	}
+	return r, nil
+}
+
+// Driver reads and writes Mitsubishi CPUs with the MC protocol. Tags are
+// addressed by device and number, such as D100, M20, X1F or W1A; X, Y, B
+// and W are numbered in hexadecimal. Bool tags may name a bit of a word
+// device, as in D10.F. Values wider than a word span consecutive words,
+// least significant first. Plc.Options may route requests with "network"
+// and "station" numbers.
+type Driver struct{}
+
+func (Driver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	route, err := parseRoute(plc)
+	if err != nil {
+		return nil, err
+	}
+	port := int(plc.GetPort())
+	if port == 0 {
+		port = DefaultPort
+	}
+	d := timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		d = time.Until(deadline)
+	}
+	client, err := Dial(net.JoinHostPort(plc.GetHost(), strconv.Itoa(port)), route, d)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{client: client}, nil
+}
+
+func (Driver) Validate(tag *pb.Tag) error {
+	_, err := parseAddress(tag)
+	return err
+}
+
+func (Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Write:      true,
+		DeviceInfo: true,
+		Datatypes: []string{
+			"Bool", "Byte", "Char", "Word", "DWord", "LWord",
+			"SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "ULInt",
+			"Real", "LReal", "String",
+		},
+	}
+}
+
+type conn struct {
+	client *Client
+}
+
+func (c *conn) Close() error {
+	return c.client.Close()
+}
+
+func (c *conn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	model, code, err := c.client.CpuModel()
+	if err == EndCode(0xC059) {
+		return nil, driver.ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeviceInfo{
+		Protocol: "melsec",
+		Vendor:   Vendor,
+		Model:    model,
+		Details:  map[string]string{"model_code": fmt.Sprintf("%04X", code)},
+	}, nil
+}
+
+type item struct {
+	tag *pb.Tag
+	address
+	size int
+}
+
+// span is one batch read covering the items of a device.
+type span struct {
+	device     Device
+	start, end int
+	items      []item
+}
+
+// spans merges the tags into as few batch reads as the request limits
+// allow.
+func spans(tags []*pb.Tag) ([]*span, error) {
+	byDevice := make(map[string][]item)
+	for _, tag := range tags {
+		a, err := parseAddress(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+		byDevice[a.device.Name] = append(byDevice[a.device.Name], item{tag: tag, address: a, size: a.count(tag)})
+	}
+	names := make([]string, 0, len(byDevice))
+	for name := range byDevice {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var spans []*span
+	for _, name := range names {
+		items := byDevice[name]
+		max := MaxWords
+		if devices[name].Bit {
+			max = MaxBits
+		}
+		for _, g := range words.Group(len(items), func(i int) (int, int) { return items[i].number, items[i].size }, max, maxGap) {
+			s := &span{device: devices[name], start: g.Start, end: g.End}
+			for _, i := range g.Items {
+				s.items = append(s.items, items[i])
+			}
+			spans = append(spans, s)
+		}
+	}
+	return spans, nil
+}
+
+// random tells spans of one or two words of word devices, which a random
+// read fetches together, from the rest.
+func random(spans []*span) (small, batch []*span) {
+	for _, s := range spans {
+		if !s.device.Bit && s.end-s.start <= 2 {
+			small = append(small, s)
+		} else {
+			batch = append(batch, s)
+		}
+	}
+	// a random read only pays off for more than one span
+	if len(small) < 2 {
+		return nil, spans
+	}
+	return small, batch
+}
+
+// ReadTags reads the tags with a random read for small scattered word
+// tags and batch reads for everything else. An end code, such as a device
+// out of range, only fails the tags of its request.
+func (c *conn) ReadTags(ctx context.Context, tags []*pb.Tag) error {
+	spans, err := spans(tags)
+	if err != nil {
+		return err
+	}
+	small, batch := random(spans)
+	for len(small) > 0 {
+		n := pb.Min(len(small), MaxRandom)
+		if err := c.readRandom(small[:n]); err != nil {
+			return err
+		}
+		small = small[n:]
+	}
+	for _, s := range batch {
+		if s.device.Bit {
+			bits, err := c.client.ReadBits(s.device, s.start, s.end-s.start)
+			if failed(err, s) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			for _, it := range s.items {
+				it.tag.Value = &pb.Tag_ValueBool{ValueBool: bits[it.number-s.start]}
+			}
+			continue
+		}
+		words, err := c.client.ReadWords(s.device, s.start, s.end-s.start)
+		if failed(err, s) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		s.set(words)
+	}
+	return nil
+}
+
+// readRandom reads spans of one or two words in a single request.
+func (c *conn) readRandom(spans []*span) error {
+	var words, dwords []Point
+	for _, s := range spans {
+		p := Point{Device: s.device, Number: s.start}
+		if s.end-s.start == 1 {
+			words = append(words, p)
+		} else {
+			dwords = append(dwords, p)
+		}
+	}
+	data, err := c.client.RandomRead(words, dwords)
+	if failed(err, spans...) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	w, d := data[:2*len(words)], data[2*len(words):]
+	for _, s := range spans {
+		if s.end-s.start == 1 {
+			s.set(w[:2])
+			w = w[2:]
+		} else {
+			s.set(d[:4])
+			d = d[4:]
+		}
+	}
+	return nil
+}
+
+// set sets the items of a word span from the words read.
+func (s *span) set(data []byte) {
+	for _, it := range s.items {
+		b := data[(it.number-s.start)*2 : (it.number-s.start+it.size)*2]
+		if it.bit >= 0 {
+			v := binary.LittleEndian.Uint16(b)&(1<<uint(it.bit)) != 0
+			it.tag.Value = &pb.Tag_ValueBool{ValueBool: v}
+			continue
+		}
+		words.Unpack(it.tag, b, words.Reverse)
+	}
+}
+
+// failed marks the tags of the spans when err is an end code.
+func failed(err error, spans ...*span) bool {
+	e, ok := err.(EndCode)
+	if !ok {
+		return false
+	}
+	for _, s := range spans {
+		for _, it := range s.items {
+			it.tag.Err = e.Error()
+		}
+	}
+	return true
+}
+
+// WriteTags writes one tag per batch write, in order. A Bool in a word
+// device is read, changed and written back, which races with other writers
+// of the word.
+func (c *conn) WriteTags(ctx context.Context, tags []*pb.Tag) error {
+	for _, tag := range tags {
+		a, err := parseAddress(tag)
+		if err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+		switch {
+		case a.device.Bit:
+			err = c.client.WriteBits(a.device, a.number, []bool{tag.GetValueBool()})
+		case a.bit >= 0:
+			var words []byte
+			words, err = c.client.ReadWords(a.device, a.number, 1)
+			if err == nil {
+				v := binary.LittleEndian.Uint16(words)
+				if tag.GetValueBool() {
+					v |= 1 << uint(a.bit)
+				} else {
+					v &^= 1 << uint(a.bit)
+				}
+				binary.LittleEndian.PutUint16(words, v)
+				err = c.client.WriteWords(a.device, a.number, words)
+			}
+		default:
+			err = c.client.WriteWords(a.device, a.number, words.Pack(tag, a.count(tag), words.Reverse))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package melsec
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// cpu is an SLMP responder with 4096 points of every device. It counts
+// the requests it answers by command.
+type cpu struct {
+	mu       sync.Mutex
+	bits     map[byte]*[4096]bool
+	words    map[byte]*[4096]uint16
+	requests map[uint16]int
+}
+
+func newCpu() *cpu {
+	c := &cpu{bits: make(map[byte]*[4096]bool), words: make(map[byte]*[4096]uint16), requests: make(map[uint16]int)}
+	for _, d := range devices {
+		if d.Bit {
+			c.bits[d.Code] = new([4096]bool)
+		} else {
+			c.words[d.Code] = new([4096]uint16)
+		}
+	}
+	return c
+}
+
+func (c *cpu) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				header := make([]byte, headerLength)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				req := make([]byte, binary.LittleEndian.Uint16(header[7:]))
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				code, data := c.handle(binary.LittleEndian.Uint16(req[2:]), binary.LittleEndian.Uint16(req[4:]), req[6:])
+				resp := make([]byte, headerLength+2, headerLength+2+len(data))
+				copy(resp, header)
+				binary.LittleEndian.PutUint16(resp, subheaderResp)
+				binary.LittleEndian.PutUint16(resp[7:], uint16(2+len(data)))
+				binary.LittleEndian.PutUint16(resp[9:], code)
+				conn.Write(append(resp, data...))
+			}
+		}()
+	}
+}
+
+func (c *cpu) handle(command, subcommand uint16, data []byte) (uint16, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests[command]++
+	if command == cmdReadCpuModel {
+		return 0, append([]byte("Q03UDVCPU       "), 0x66, 0x03)
+	}
+	if command == cmdRandomRead {
+		n := int(data[0]) + int(data[1])
+		if n > MaxRandom {
+			return 0xC054, nil
+		}
+		var resp []byte
+		for i := 0; i < n; i++ {
+			p := data[2+4*i:]
+			number := int(p[0]) | int(p[1])<<8 | int(p[2])<<16
+			words := c.words[p[3]]
+			size := 1
+			if i >= int(data[0]) {
+				size = 2
+			}
+			if number+size > len(words) {
+				return 0xC056, nil
+			}
+			for _, v := range words[number : number+size] {
+				resp = append(resp, byte(v), byte(v>>8))
+			}
+		}
+		return 0, resp
+	}
+	number := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	n := int(binary.LittleEndian.Uint16(data[4:]))
+	values := data[6:]
+	if subcommand == subBit {
+		bits := c.bits[data[3]]
+		if n > MaxBits {
+			return 0xC051, nil
+		}
+		if number+n > len(bits) {
+			return 0xC056, nil
+		}
+		switch command {
+		case cmdBatchRead:
+			resp := make([]byte, (n+1)/2)
+			for i, v := range bits[number : number+n] {
+				if v && i%2 == 0 {
+					resp[i/2] |= 0x10
+				} else if v {
+					resp[i/2] |= 0x01
+				}
+			}
+			return 0, resp
+		case cmdBatchWrite:
+			for i := 0; i < n; i++ {
+				bits[number+i] = values[i/2]&(0x10>>uint(4*(i%2))) != 0
+			}
+			return 0, nil
+		}
+		return 0xC059, nil
+	}
+	words := c.words[data[3]]
+	if n > MaxWords {
+		return 0xC051, nil
+	}
+	if number+n > len(words) {
+		return 0xC056, nil
+	}
+	switch command {
+	case cmdBatchRead:
+		var resp []byte
+		for _, v := range words[number : number+n] {
+			resp = append(resp, byte(v), byte(v>>8))
+		}
+		return 0, resp
+	case cmdBatchWrite:
+		for i := 0; i < n; i++ {
+			words[number+i] = binary.LittleEndian.Uint16(values[2*i:])
+		}
+		return 0, nil
+	}
+	return 0xC059, nil
+}
+
+func TestDriver(t *testing.T) {
+	c := newCpu()
+	d := c.words[devices["D"].Code]
+	d[0] = 0xFFFE // -2
+	d[1], d[2] = 0x0002, 0x0001
+	r := math.Float32bits(12.5)
+	d[10], d[11] = uint16(r), uint16(r>>16)
+	d[100], d[101], d[102] = 'e'<<8|'h', 'l'<<8|'l', 'o'
+	c.words[devices["W"].Code][0x1A] = 1 << 0xF
+	c.words[devices["R"].Code][2000] = 77
+	c.bits[devices["M"].Code][5] = true
+	c.bits[devices["X"].Code][0x1F] = true
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go c.serve(l)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	plc := &pb.Plc{Protocol: "melsec", Host: host, Port: uint32(p)}
+
+	drv, err := driver.Lookup("melsec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := drv.Connect(context.Background(), plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tags := []*pb.Tag{
+		{Address: "D0", Dt: "Int"},
+		{Address: "D1", Dt: "DInt"},
+		{Address: "D10", Dt: "Real"},
+		{Address: "D100", Dt: "String[5]"},
+		{Address: "M5", Dt: "Bool"},
+		{Address: "X1F", Dt: "Bool"},
+		{Address: "W1A.F", Dt: "Bool"},
+		{Address: "R2000", Dt: "UInt"},
+		{Address: "D4090", Dt: "String[20]"},
+	}
+	if err := conn.ReadTags(context.Background(), tags); err != nil {
+		t.Fatal(err)
+	}
+	if tags[0].GetValueInteger() != -2 || tags[1].GetValueInteger() != 0x10002 || tags[2].GetValueDouble() != 12.5 ||
+		tags[3].GetValueString() != "hello" || !tags[4].GetValueBool() || !tags[5].GetValueBool() ||
+		!tags[6].GetValueBool() || tags[7].GetValueInteger() != 77 {
+		t.Fatalf("read %v", tags)
+	}
+	// D4090 runs past the last D and only fails itself
+	if tags[8].GetErr() != EndCode(0xC056).Error() {
+		t.Fatalf("D4090 err %q", tags[8].GetErr())
+	}
+	// W1A and R2000 share a random read; D0 to D11, D100, D4090, M and X
+	// are batch reads
+	if c.requests[cmdRandomRead] != 1 || c.requests[cmdBatchRead] != 5 {
+		t.Fatalf("requests %v", c.requests)
+	}
+
+	err = conn.WriteTags(context.Background(), []*pb.Tag{
+		{Address: "Y3", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+		{Address: "D20.1", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+		{Address: "D30", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: -1.5}},
+		{Address: "ZR40", Dt: "String[3]", Value: &pb.Tag_ValueString{ValueString: "abc"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := math.Float32bits(-1.5)
+	zr := c.words[devices["ZR"].Code]
+	if !c.bits[devices["Y"].Code][3] || d[20] != 2 || d[30] != uint16(w) || d[31] != uint16(w>>16) || zr[40] != 'b'<<8|'a' || zr[41] != 'c' {
+		t.Fatalf("after write: Y3 %v, D20 %#x, D30 %#x %#x, ZR40 %#x %#x", c.bits[devices["Y"].Code][3], d[20], d[30], d[31], zr[40], zr[41])
+	}
+
+	// one tag every 10 words up to D2000 needs three batch reads
+	c.requests = make(map[uint16]int)
+	tags = nil
+	for i := 0; i < 2000; i += 10 {
+		tags = append(tags, &pb.Tag{Address: "D" + strconv.Itoa(i), Dt: "Int"})
+	}
+	if err := conn.ReadTags(context.Background(), tags); err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags {
+		if tag.GetErr() != "" {
+			t.Fatalf("%s: %s", tag.GetAddress(), tag.GetErr())
+		}
+	}
+	if c.requests[cmdBatchRead] != 3 {
+		t.Fatalf("%d batch reads, want 3", c.requests[cmdBatchRead])
+	}
+
+	info, err := conn.DeviceInfo(context.Background())
+	if err != nil || info.GetVendor() != Vendor || info.GetModel() != "Q03UDVCPU" || info.GetDetails()["model_code"] != "0366" {
+		t.Fatalf("device info %v %v", info, err)
+	}
+
+	for _, tag := range []*pb.Tag{
+		{Address: "DB1P0", Dt: "Int"},
+		{Address: "M1F", Dt: "Bool"},
+		{Address: "M1", Dt: "Int"},
+		{Address: "M1.2", Dt: "Bool"},
+		{Address: "D1.2", Dt: "Int"},
+		{Address: "D16777215", Dt: "DInt"},
+	} {
+		if err := drv.Validate(tag); err == nil {
+			t.Errorf("%s %s accepted", tag.Address, tag.Dt)
+		}
+	}
+}
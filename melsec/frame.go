@@ -0,0 +1,231 @@
+// Package melsec is a driver for Mitsubishi Q, L and iQ-R PLCs speaking the
+// MC protocol (SLMP) with binary 3E frames over TCP.
+package melsec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	cmdReadCpuModel  = 0x0101
+	cmdBatchRead     = 0x0401
+	cmdBatchWrite    = 0x1401
+	cmdRandomRead    = 0x0403
+	subWord          = 0x0000
+	subBit           = 0x0001
+	subheaderRequest = 0x0050
+	subheaderResp    = 0x00D0
+
+	// MaxWords is the most words a batch read or write moves.
+	MaxWords = 960
+	// MaxBits is the most bits a batch read or write in bit units moves.
+	MaxBits = 7168
+	// MaxRandom is the most word plus double word points of a random read.
+	MaxRandom = 192
+
+	// monitoringTimer is the time the CPU may take to answer, in 250ms.
+	monitoringTimer = 16
+	headerLength    = 9
+)
+
+// EndCode is a non zero completion code of a response, returned as an
+// error.
+type EndCode uint16
+
+func (e EndCode) Error() string {
+	switch e {
+	case 0xC050:
+		return "MC end code 0xC050: ASCII data in binary mode"
+	case 0xC051, 0xC052, 0xC053, 0xC054:
+		return fmt.Sprintf("MC end code %#04X: too many points", uint16(e))
+	case 0xC056:
+		return "MC end code 0xC056: device out of range"
+	case 0xC059:
+		return "MC end code 0xC059: command not supported"
+	case 0xC05B:
+		return "MC end code 0xC05B: device not accessible"
+	case 0xC061:
+		return "MC end code 0xC061: bad request length"
+	}
+	return fmt.Sprintf("MC end code %#04X", uint16(e))
+}
+
+// Route is the destination of requests: the network and station numbers
+// of the CPU and the module that relays to it.
+type Route struct {
+	Network       byte
+	Station       byte
+	ModuleIO      uint16
+	ModuleStation byte
+}
+
+// LocalRoute addresses the CPU the Ethernet port belongs to.
+var LocalRoute = Route{Network: 0, Station: 0xFF, ModuleIO: 0x03FF}
+
+// Client sends 3E frames on one connection, one request at a time.
+type Client struct {
+	conn    net.Conn
+	route   Route
+	timeout time.Duration
+}
+
+func Dial(address string, route Route, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, route: route, timeout: timeout}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// call sends a command with its data and returns the response data.
+func (c *Client) call(command, subcommand uint16, data []byte) ([]byte, error) {
+	req := make([]byte, headerLength+6+len(data))
+	binary.LittleEndian.PutUint16(req, subheaderRequest)
+	req[2] = c.route.Network
+	req[3] = c.route.Station
+	binary.LittleEndian.PutUint16(req[4:], c.route.ModuleIO)
+	req[6] = c.route.ModuleStation
+	binary.LittleEndian.PutUint16(req[7:], uint16(6+len(data)))
+	binary.LittleEndian.PutUint16(req[9:], monitoringTimer)
+	binary.LittleEndian.PutUint16(req[11:], command)
+	binary.LittleEndian.PutUint16(req[13:], subcommand)
+	copy(req[15:], data)
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.LittleEndian.Uint16(header[7:]))
+	if binary.LittleEndian.Uint16(header) != subheaderResp || length < 2 {
+		return nil, fmt.Errorf("bad MC response header % x", header)
+	}
+	resp := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	if code := binary.LittleEndian.Uint16(resp); code != 0 {
+		return nil, EndCode(code)
+	}
+	return resp[2:], nil
+}
+
+// device appends the head device number and device code.
+func device(b []byte, d Device, number int) []byte {
+	return append(b, byte(number), byte(number>>8), byte(number>>16), d.Code)
+}
+
+// ReadWords reads count words from the device number.
+func (c *Client) ReadWords(d Device, number, count int) ([]byte, error) {
+	data := device(nil, d, number)
+	data = append(data, byte(count), byte(count>>8))
+	resp, err := c.call(cmdBatchRead, subWord, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != count*2 {
+		return nil, fmt.Errorf("MC batch read: %d bytes, want %d", len(resp), count*2)
+	}
+	return resp, nil
+}
+
+// ReadBits reads count bits of a bit device.
+func (c *Client) ReadBits(d Device, number, count int) ([]bool, error) {
+	data := device(nil, d, number)
+	data = append(data, byte(count), byte(count>>8))
+	resp, err := c.call(cmdBatchRead, subBit, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) != (count+1)/2 {
+		return nil, fmt.Errorf("MC batch read: %d bytes, want %d", len(resp), (count+1)/2)
+	}
+	bits := make([]bool, count)
+	for i := range bits {
+		// two points a byte, the first in the high nibble
+		shift := uint(4)
+		if i%2 == 1 {
+			shift = 0
+		}
+		bits[i] = resp[i/2]>>shift&1 != 0
+	}
+	return bits, nil
+}
+
+// WriteWords writes words, two bytes each, from the device number.
+func (c *Client) WriteWords(d Device, number int, words []byte) error {
+	count := len(words) / 2
+	data := device(nil, d, number)
+	data = append(data, byte(count), byte(count>>8))
+	_, err := c.call(cmdBatchWrite, subWord, append(data, words...))
+	return err
+}
+
+// WriteBits writes bits from the device number.
+func (c *Client) WriteBits(d Device, number int, bits []bool) error {
+	data := device(nil, d, number)
+	data = append(data, byte(len(bits)), byte(len(bits)>>8))
+	packed := make([]byte, (len(bits)+1)/2)
+	for i, v := range bits {
+		if !v {
+			continue
+		}
+		if i%2 == 0 {
+			packed[i/2] |= 0x10
+		} else {
+			packed[i/2] |= 0x01
+		}
+	}
+	_, err := c.call(cmdBatchWrite, subBit, append(data, packed...))
+	return err
+}
+
+// Point is a device number of a random read.
+type Point struct {
+	Device Device
+	Number int
+}
+
+// RandomRead reads single words and double words of any devices in one
+// request and returns them in order, two and four bytes each.
+func (c *Client) RandomRead(words, dwords []Point) ([]byte, error) {
+	data := []byte{byte(len(words)), byte(len(dwords))}
+	for _, p := range append(append([]Point(nil), words...), dwords...) {
+		data = device(data, p.Device, p.Number)
+	}
+	resp, err := c.call(cmdRandomRead, subWord, data)
+	if err != nil {
+		return nil, err
+	}
+	if want := 2*len(words) + 4*len(dwords); len(resp) != want {
+		return nil, fmt.Errorf("MC random read: %d bytes, want %d", len(resp), want)
+	}
+	return resp, nil
+}
+
+// CpuModel reads the model name and code of the CPU.
+func (c *Client) CpuModel() (string, uint16, error) {
+	resp, err := c.call(cmdReadCpuModel, 0, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(resp) < 18 {
+		return "", 0, fmt.Errorf("MC read CPU model: %d bytes, want 18", len(resp))
+	}
+	name := string(resp[:16])
+	for len(name) > 0 && (name[len(name)-1] == ' ' || name[len(name)-1] == 0) {
+		name = name[:len(name)-1]
+	}
+	return name, binary.LittleEndian.Uint16(resp[16:]), nil
+}
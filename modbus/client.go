@@ -0,0 +1,201 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	FuncReadDeviceIdentification = 0x2B
+	meiReadDeviceIdentification  = 0x0E
+)
+
+// transport carries one request PDU to unit and returns the response PDU,
+// which may be an exception.
+type transport interface {
+	send(unit byte, pdu []byte) ([]byte, error)
+	Close() error
+}
+
+// tcpTransport frames PDUs with the MBAP header of Modbus TCP.
+type tcpTransport struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	mu sync.Mutex
+	id uint16
+}
+
+func dialTCP(address string, timeout time.Duration) (*tcpTransport, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpTransport{conn: conn, timeout: timeout}, nil
+}
+
+func (t *tcpTransport) send(unit byte, pdu []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.id++
+	adu := make([]byte, mbapHeaderLength+len(pdu))
+	binary.BigEndian.PutUint16(adu, t.id)
+	binary.BigEndian.PutUint16(adu[4:], uint16(len(pdu)+1))
+	adu[6] = unit
+	copy(adu[mbapHeaderLength:], pdu)
+	t.conn.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := t.conn.Write(adu); err != nil {
+		return nil, err
+	}
+	header := make([]byte, mbapHeaderLength)
+	for {
+		if _, err := io.ReadFull(t.conn, header); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			return nil, fmt.Errorf("bad MBAP header % x", header)
+		}
+		resp := make([]byte, length-1)
+		if _, err := io.ReadFull(t.conn, resp); err != nil {
+			return nil, err
+		}
+		// a late answer to an earlier request that timed out is skipped
+		if binary.BigEndian.Uint16(header) == t.id {
+			return resp, nil
+		}
+	}
+}
+
+func (t *tcpTransport) Close() error {
+	return t.conn.Close()
+}
+
+// Client issues Modbus requests to one unit.
+type Client struct {
+	t    transport
+	unit byte
+}
+
+var errShortResponse = errors.New("short Modbus response")
+
+// call sends a request and checks the function code of the response.
+func (c *Client) call(pdu []byte) ([]byte, error) {
+	resp, err := c.t.send(c.unit, pdu)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 {
+		return nil, errShortResponse
+	}
+	if resp[0] == pdu[0]|0x80 {
+		return nil, Exception(resp[1])
+	}
+	if resp[0] != pdu[0] {
+		return nil, fmt.Errorf("Modbus response to function %d, want %d", resp[0], pdu[0])
+	}
+	return resp[1:], nil
+}
+
+func request(function byte, values ...uint16) []byte {
+	pdu := make([]byte, 1+2*len(values))
+	pdu[0] = function
+	for i, v := range values {
+		binary.BigEndian.PutUint16(pdu[1+2*i:], v)
+	}
+	return pdu
+}
+
+// ReadBits reads count coils or discrete inputs from start.
+func (c *Client) ReadBits(table Table, start, count int) ([]bool, error) {
+	function := byte(FuncReadCoils)
+	if table == DiscreteInputs {
+		function = FuncReadDiscreteInputs
+	}
+	data, err := c.call(request(function, uint16(start), uint16(count)))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 || int(data[0]) != (count+7)/8 || len(data) < 1+int(data[0]) {
+		return nil, errShortResponse
+	}
+	return unpackBits(data[1:], count), nil
+}
+
+// ReadRegisters reads count input or holding registers from start and
+// returns their bytes as sent, two per register.
+func (c *Client) ReadRegisters(table Table, start, count int) ([]byte, error) {
+	function := byte(FuncReadHoldingRegisters)
+	if table == InputRegisters {
+		function = FuncReadInputRegisters
+	}
+	data, err := c.call(request(function, uint16(start), uint16(count)))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 || int(data[0]) != count*2 || len(data) < 1+count*2 {
+		return nil, errShortResponse
+	}
+	return data[1 : 1+count*2], nil
+}
+
+// WriteCoils writes bits from start, with a single coil request for one bit.
+func (c *Client) WriteCoils(start int, bits []bool) error {
+	if len(bits) == 1 {
+		v := uint16(0)
+		if bits[0] {
+			v = 0xFF00
+		}
+		_, err := c.call(request(FuncWriteSingleCoil, uint16(start), v))
+		return err
+	}
+	packed := packBits(bits)
+	pdu := append(request(FuncWriteMultipleCoils, uint16(start), uint16(len(bits))), byte(len(packed)))
+	_, err := c.call(append(pdu, packed...))
+	return err
+}
+
+// WriteRegisters writes the registers in regs, two bytes each, from start,
+// with a single register request for one register.
+func (c *Client) WriteRegisters(start int, regs []byte) error {
+	if len(regs) == 2 {
+		_, err := c.call(request(FuncWriteSingleRegister, uint16(start), binary.BigEndian.Uint16(regs)))
+		return err
+	}
+	pdu := append(request(FuncWriteMultipleRegisters, uint16(start), uint16(len(regs)/2)), byte(len(regs)))
+	_, err := c.call(append(pdu, regs...))
+	return err
+}
+
+// DeviceIdentification reads the basic device identification objects:
+// vendor name, product code and revision.
+func (c *Client) DeviceIdentification() (map[byte]string, error) {
+	data, err := c.call([]byte{FuncReadDeviceIdentification, meiReadDeviceIdentification, 0x01, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	// MEI type, read code, conformity level, more follows, next object id,
+	// number of objects, then id, length and value of every object
+	if len(data) < 6 {
+		return nil, errShortResponse
+	}
+	objects := make(map[byte]string)
+	b := data[6:]
+	for i := 0; i < int(data[5]); i++ {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, errShortResponse
+		}
+		objects[b[0]] = string(b[2 : 2+int(b[1])])
+		b = b[2+int(b[1]):]
+	}
+	return objects, nil
+}
+
+func (c *Client) Close() error {
+	return c.t.Close()
+}
@@ -0,0 +1,294 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/driver/words"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultPort = 502
+	DefaultUnit = 1
+
+	timeout = 5 * time.Second
+	// maxGap is the number of unused registers or bits a read may span to
+	// merge two tags into one request.
+	maxGap = 16
+)
+
+func init() {
+	driver.Register("modbus", Driver{})
+}
+
+var addressReg = regexp.MustCompile(`^(C|DI|IR|HR)(\d+)(?:\.(\d+))?$`)
+
+// address is a parsed tag address: a table, the zero based address of the
+// first coil or register and, for Bool tags in registers, a bit.
+type address struct {
+	table Table
+	start int
+	bit   int
+}
+
+func parseAddress(tag *pb.Tag) (address, error) {
+	match := addressReg.FindStringSubmatch(tag.GetAddress())
+	if match == nil {
+		return address{}, fmt.Errorf("Modbus address %q is not C, DI, IR or HR followed by a number", tag.GetAddress())
+	}
+	a := address{bit: -1}
+	a.table, _ = ParseTable(match[1])
+	a.start, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		a.bit, _ = strconv.Atoi(match[3])
+		if a.table.IsBit() || tag.GetDt() != "Bool" || a.bit > 15 {
+			return address{}, fmt.Errorf("only Bool tags in registers take a bit 0-15")
+		}
+	}
+	if a.table.IsBit() && tag.GetDt() != "Bool" {
+		return address{}, fmt.Errorf("only Bool tags fit %s", a.table)
+	}
+	if tag.GetLength() == 0 {
+		return address{}, fmt.Errorf("Datatype illegal")
+	}
+	if a.start+a.count(tag) > 0x10000 {
+		return address{}, fmt.Errorf("%s ends beyond address 65535", tag.GetAddress())
+	}
+	return a, nil
+}
+
+// count is the number of coils or registers the tag takes.
+func (a address) count(tag *pb.Tag) int {
+	if a.table.IsBit() || tag.GetDt() == "Bool" {
+		return 1
+	}
+	return (tag.GetLength() + 1) / 2
+}
+
+// options are the Modbus settings of Plc.Options.
+type options struct {
+	unit  byte
+	order Order
+}
+
+func parseOptions(plc *pb.Plc) (options, error) {
+	o := options{unit: DefaultUnit}
+	if s, ok := plc.GetOptions()["unit"]; ok {
+		unit, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return o, fmt.Errorf("unit: %v", err)
+		}
+		o.unit = byte(unit)
+	}
+	var err error
+	o.order, err = ParseOrder(plc.GetOptions()["word_order"], plc.GetOptions()["byte_order"])
+	return o, err
+}
+
+// Driver reads and writes Modbus TCP devices. Tags are addressed as C12,
+// DI3, IR5 or HR100 with zero based addresses; Bool tags may name a bit of
+// a register, as in HR7.3. Values wider than a register span consecutive
+// registers. Plc.Options may set the unit id ("unit", default 1) and the
+// "word_order" and "byte_order" of such values, "big" (default) or
+// "little".
+type Driver struct{}
+
+func (Driver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	o, err := parseOptions(plc)
+	if err != nil {
+		return nil, err
+	}
+	port := int(plc.GetPort())
+	if port == 0 {
+		port = DefaultPort
+	}
+	d := timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		d = time.Until(deadline)
+	}
+	t, err := dialTCP(net.JoinHostPort(plc.GetHost(), strconv.Itoa(port)), d)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{client: &Client{t: t, unit: o.unit}, order: o.order, protocol: "modbus"}, nil
+}
+
+func (Driver) Validate(tag *pb.Tag) error {
+	_, err := parseAddress(tag)
+	return err
+}
+
+func (Driver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Write:      true,
+		DeviceInfo: true,
+		Datatypes: []string{
+			"Bool", "Byte", "Char", "Word", "DWord", "LWord",
+			"SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "ULInt",
+			"Real", "LReal", "String",
+		},
+	}
+}
+
+type conn struct {
+	client   *Client
+	order    Order
+	protocol string
+}
+
+func (c *conn) Close() error {
+	return c.client.Close()
+}
+
+func (c *conn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	objects, err := c.client.DeviceIdentification()
+	if err == IllegalFunction {
+		return nil, driver.ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DeviceInfo{
+		Protocol: c.protocol,
+		Vendor:   objects[0],
+		Model:    objects[1],
+		Version:  objects[2],
+		Details:  map[string]string{"unit": strconv.Itoa(int(c.client.unit))},
+	}, nil
+}
+
+type item struct {
+	tag *pb.Tag
+	address
+	size int
+}
+
+// span is one read request covering the items of a table.
+type span struct {
+	table      Table
+	start, end int
+	items      []item
+}
+
+// spans merges the tags into as few reads as the request limits allow.
+func spans(tags []*pb.Tag) ([]*span, error) {
+	byTable := make(map[Table][]item)
+	for _, tag := range tags {
+		a, err := parseAddress(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+		byTable[a.table] = append(byTable[a.table], item{tag: tag, address: a, size: a.count(tag)})
+	}
+	var spans []*span
+	for table, items := range byTable {
+		max := MaxReadRegisters
+		if table.IsBit() {
+			max = MaxReadBits
+		}
+		for _, g := range words.Group(len(items), func(i int) (int, int) { return items[i].start, items[i].size }, max, maxGap) {
+			s := &span{table: table, start: g.Start, end: g.End}
+			for _, i := range g.Items {
+				s.items = append(s.items, items[i])
+			}
+			spans = append(spans, s)
+		}
+	}
+	return spans, nil
+}
+
+// ReadTags reads every span. A Modbus exception, such as an illegal data
+// address, only fails the tags of its span.
+func (c *conn) ReadTags(ctx context.Context, tags []*pb.Tag) error {
+	spans, err := spans(tags)
+	if err != nil {
+		return err
+	}
+	for _, s := range spans {
+		if s.table.IsBit() {
+			bits, err := c.client.ReadBits(s.table, s.start, s.end-s.start)
+			if failed(s, err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			for _, it := range s.items {
+				it.tag.Value = &pb.Tag_ValueBool{ValueBool: bits[it.start-s.start]}
+			}
+			continue
+		}
+		regs, err := c.client.ReadRegisters(s.table, s.start, s.end-s.start)
+		if failed(s, err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, it := range s.items {
+			b := regs[(it.start-s.start)*2 : (it.start-s.start+it.size)*2]
+			if it.bit >= 0 {
+				v := binary.BigEndian.Uint16(b)&(1<<uint(it.bit)) != 0
+				it.tag.Value = &pb.Tag_ValueBool{ValueBool: v}
+				continue
+			}
+			fromRegisters(c.order, it.tag, b)
+		}
+	}
+	return nil
+}
+
+// failed marks the tags of s when err is a Modbus exception.
+func failed(s *span, err error) bool {
+	e, ok := err.(Exception)
+	if !ok {
+		return false
+	}
+	for _, it := range s.items {
+		it.tag.Err = e.Error()
+	}
+	return true
+}
+
+// WriteTags writes one tag per request, in order. A Bool in a register is
+// read, changed and written back, which races with other writers of the
+// register.
+func (c *conn) WriteTags(ctx context.Context, tags []*pb.Tag) error {
+	for _, tag := range tags {
+		a, err := parseAddress(tag)
+		if err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+		if !a.table.Writable() {
+			return fmt.Errorf("%s: %s is read only", tag.GetAddress(), a.table)
+		}
+		switch {
+		case a.table == Coils:
+			err = c.client.WriteCoils(a.start, []bool{tag.GetValueBool()})
+		case a.bit >= 0:
+			var regs []byte
+			regs, err = c.client.ReadRegisters(a.table, a.start, 1)
+			if err == nil {
+				v := binary.BigEndian.Uint16(regs)
+				if tag.GetValueBool() {
+					v |= 1 << uint(a.bit)
+				} else {
+					v &^= 1 << uint(a.bit)
+				}
+				binary.BigEndian.PutUint16(regs, v)
+				err = c.client.WriteRegisters(a.start, regs)
+			}
+		default:
+			err = c.client.WriteRegisters(a.start, toRegisters(c.order, tag, a.count(tag)))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+		}
+	}
+	return nil
+}
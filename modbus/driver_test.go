@@ -0,0 +1,163 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// device is a Modbus server stand-in with 100 of every coil, input and
+// register. It counts the requests it answers.
+type device struct {
+	mu       sync.Mutex
+	bits     [2][100]bool
+	regs     [2][100]uint16
+	requests int
+}
+
+func (d *device) ServeModbus(unit byte, pdu []byte) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests++
+	function := pdu[0]
+	if function == FuncReadDeviceIdentification {
+		resp := []byte{function, meiReadDeviceIdentification, 0x01, 0x01, 0, 0, 3}
+		for id, v := range []string{"WAGO", "750-362", "1.4"} {
+			resp = append(resp, byte(id), byte(len(v)))
+			resp = append(resp, v...)
+		}
+		return resp
+	}
+	start := int(binary.BigEndian.Uint16(pdu[1:]))
+	n := int(binary.BigEndian.Uint16(pdu[3:]))
+	switch function {
+	case FuncReadCoils, FuncReadDiscreteInputs:
+		if start+n > 100 {
+			return exceptionPDU(function, IllegalDataAddress)
+		}
+		bits := d.bits[function-FuncReadCoils][start : start+n]
+		packed := packBits(bits)
+		return append([]byte{function, byte(len(packed))}, packed...)
+	case FuncReadHoldingRegisters, FuncReadInputRegisters:
+		if start+n > 100 {
+			return exceptionPDU(function, IllegalDataAddress)
+		}
+		table := 0
+		if function == FuncReadInputRegisters {
+			table = 1
+		}
+		resp := []byte{function, byte(n * 2)}
+		for _, v := range d.regs[table][start : start+n] {
+			resp = append(resp, byte(v>>8), byte(v))
+		}
+		return resp
+	case FuncWriteSingleCoil:
+		d.bits[0][start] = n == 0xFF00
+		return pdu
+	case FuncWriteSingleRegister:
+		d.regs[0][start] = uint16(n)
+		return pdu
+	case FuncWriteMultipleRegisters:
+		for i := 0; i < n; i++ {
+			d.regs[0][start+i] = binary.BigEndian.Uint16(pdu[6+2*i:])
+		}
+		return pdu[:5]
+	}
+	return exceptionPDU(function, IllegalFunction)
+}
+
+func TestDriver(t *testing.T) {
+	d := &device{}
+	d.bits[0][12] = true
+	d.bits[1][3] = true
+	d.regs[1][5] = 0xFFFE // -2
+	d.regs[0][7] = 1 << 3
+	// 12.5 as a Real with the low word first
+	r := math.Float32bits(12.5)
+	d.regs[0][10], d.regs[0][11] = uint16(r), uint16(r>>16)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Handler: d}
+	go srv.Serve(l)
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	plc := &pb.Plc{Protocol: "modbus", Host: host, Port: uint32(p), Options: map[string]string{"word_order": "little"}}
+
+	drv, err := driver.Lookup("modbus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := drv.Connect(context.Background(), plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tags := []*pb.Tag{
+		{Address: "C12", Dt: "Bool"},
+		{Address: "DI3", Dt: "Bool"},
+		{Address: "IR5", Dt: "Int"},
+		{Address: "HR7.3", Dt: "Bool"},
+		{Address: "HR10", Dt: "Real"},
+		{Address: "HR99", Dt: "DInt"},
+	}
+	if err := conn.ReadTags(context.Background(), tags); err != nil {
+		t.Fatal(err)
+	}
+	if !tags[0].GetValueBool() || !tags[1].GetValueBool() || tags[2].GetValueInteger() != -2 || !tags[3].GetValueBool() || tags[4].GetValueDouble() != 12.5 {
+		t.Fatalf("read %v", tags)
+	}
+	// HR99 spills over the end of the device and only fails itself
+	if tags[5].GetErr() != IllegalDataAddress.Error() {
+		t.Fatalf("HR99 err %q", tags[5].GetErr())
+	}
+	// one request per table, HR7 and HR10 share theirs
+	if d.requests != 5 {
+		t.Fatalf("%d read requests, want 5", d.requests)
+	}
+
+	err = conn.WriteTags(context.Background(), []*pb.Tag{
+		{Address: "C1", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+		{Address: "HR7.0", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+		{Address: "HR20", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: -1.5}},
+		{Address: "HR30", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 300}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := math.Float32bits(-1.5)
+	if !d.bits[0][1] || d.regs[0][7] != 1<<3|1 || d.regs[0][20] != uint16(w) || d.regs[0][21] != uint16(w>>16) || d.regs[0][30] != 300 {
+		t.Fatalf("after write: coil 1 %v, HR7 %#x, HR20 %#x %#x, HR30 %d", d.bits[0][1], d.regs[0][7], d.regs[0][20], d.regs[0][21], d.regs[0][30])
+	}
+	if err := conn.WriteTags(context.Background(), []*pb.Tag{{Address: "IR1", Dt: "Int"}}); err == nil {
+		t.Fatal("write to an input register succeeded")
+	}
+
+	info, err := conn.DeviceInfo(context.Background())
+	if err != nil || info.GetVendor() != "WAGO" || info.GetModel() != "750-362" || info.GetVersion() != "1.4" {
+		t.Fatalf("device info %v %v", info, err)
+	}
+
+	for _, tag := range []*pb.Tag{
+		{Address: "DB1P0", Dt: "Int"},
+		{Address: "C1", Dt: "Int"},
+		{Address: "HR1.16", Dt: "Bool"},
+		{Address: "HR1.2", Dt: "Int"},
+		{Address: "HR65535", Dt: "DInt"},
+	} {
+		if err := drv.Validate(tag); err == nil {
+			t.Errorf("%s %s accepted", tag.Address, tag.Dt)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package modbus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// FacadeConfig maps Modbus addresses onto tags of configured PLCs.
+type FacadeConfig struct {
+	Listen string `json:"listen"`
+	// WordOrder and ByteOrder are "big" (default) or "little".
+	WordOrder string    `json:"word_order,omitempty"`
+	ByteOrder string    `json:"byte_order,omitempty"`
+	Map       []Mapping `json:"map"`
+}
+
+// Mapping puts one tag at Address of Table. A register mapping spans as
+// many registers as the tag has bytes, rounded up: DB10P0 Real at HR 100
+// takes HR 100 and 101. Bit tables only take Bool tags.
+type Mapping struct {
+	Table   string     `json:"table"`
+	Address uint16     `json:"address"`
+	Plc     string     `json:"plc"`
+	Tag     config.Tag `json:"tag"`
+}
+
+// PlcRW is the part of PlcServer the facade uses.
+type PlcRW interface {
+	ReadTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error)
+	WriteTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error)
+}
+
+type entry struct {
+	table Table
+	start int
+	count int
+	plc   config.Plc
+	tag   config.Tag
+}
+
+func (e *entry) end() int {
+	return e.start + e.count
+}
+
+// Facade is a Handler serving the mapped tags through PlcRW.
+type Facade struct {
+	order   Order
+	entries []*entry
+	server  PlcRW
+}
+
+func NewFacade(c FacadeConfig, plcs config.Plcs, server PlcRW) (*Facade, error) {
+	order, err := ParseOrder(c.WordOrder, c.ByteOrder)
+	if err != nil {
+		return nil, err
+	}
+	f := &Facade{order: order, server: server}
+	for _, m := range c.Map {
+		table, err := ParseTable(m.Table)
+		if err != nil {
+			return nil, err
+		}
+		plc, ok := plcs.Plc(m.Plc)
+		if !ok {
+			return nil, fmt.Errorf("%s %d: unknown PLC %q", table, m.Address, m.Plc)
+		}
+		tag := m.Tag.Pb()
+		if err := driver.Validate(plc.Pb(), tag); err != nil {
+			return nil, fmt.Errorf("%s %d: %s %s: %v", table, m.Address, m.Tag.Address, m.Tag.Dt, err)
+		}
+		e := &entry{table: table, start: int(m.Address), count: 1, plc: plc, tag: m.Tag}
+		if table.IsBit() {
+			if m.Tag.Dt != "Bool" {
+				return nil, fmt.Errorf("%s %d: only Bool tags fit a bit table", table, m.Address)
+			}
+		} else if m.Tag.Dt != "Bool" {
+			e.count = (tag.GetLength() + 1) / 2
+		}
+		if e.end() > 0x10000 {
+			return nil, fmt.Errorf("%s %d: %s does not fit", table, m.Address, m.Tag.Address)
+		}
+		f.entries = append(f.entries, e)
+	}
+	sort.Slice(f.entries, func(i, j int) bool {
+		if f.entries[i].table != f.entries[j].table {
+			return f.entries[i].table < f.entries[j].table
+		}
+		return f.entries[i].start < f.entries[j].start
+	})
+	for i := 1; i < len(f.entries); i++ {
+		prev, e := f.entries[i-1], f.entries[i]
+		if prev.table == e.table && prev.end() > e.start {
+			return nil, fmt.Errorf("%s %d: %s overlaps %s", e.table, e.start, e.tag.Address, prev.tag.Address)
+		}
+	}
+	return f, nil
+}
+
+func (f *Facade) ServeModbus(unit byte, pdu []byte) []byte {
+	if len(pdu) == 0 {
+		return exceptionPDU(0, IllegalFunction)
+	}
+	resp, err := f.serve(pdu[0], pdu[1:])
+	if err != nil {
+		e, ok := err.(Exception)
+		if !ok {
+			log.Printf("modbus: function %d: %v", pdu[0], err)
+			e = GatewayTargetFailed
+		}
+		return exceptionPDU(pdu[0], e)
+	}
+	return resp
+}
+
+func (f *Facade) serve(function byte, data []byte) ([]byte, error) {
+	var table Table
+	switch function {
+	case FuncReadCoils, FuncWriteSingleCoil, FuncWriteMultipleCoils:
+		table = Coils
+	case FuncReadDiscreteInputs:
+		table = DiscreteInputs
+	case FuncReadInputRegisters:
+		table = InputRegisters
+	case FuncReadHoldingRegisters, FuncWriteSingleRegister, FuncWriteMultipleRegisters:
+		table = HoldingRegisters
+	default:
+		return nil, IllegalFunction
+	}
+	if len(data) < 4 {
+		return nil, IllegalDataValue
+	}
+	start := int(binary.BigEndian.Uint16(data))
+	value := binary.BigEndian.Uint16(data[2:])
+	switch function {
+	case FuncReadCoils, FuncReadDiscreteInputs:
+		{
+			if value < 1 || value > MaxReadBits {
+				return nil, IllegalDataValue
+			}
+			bits, err := f.readBits(table, start, int(value))
+			if err != nil {
+				return nil, err
+			}
+			b := packBits(bits)
+			return append([]byte{function, byte(len(b))}, b...), nil
+		}
+	case FuncReadHoldingRegisters, FuncReadInputRegisters:
+		{
+			if value < 1 || value > MaxReadRegisters {
+				return nil, IllegalDataValue
+			}
+			regs, err := f.readRegisters(table, start, int(value))
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte{function, byte(len(regs))}, regs...), nil
+		}
+	case FuncWriteSingleCoil:
+		{
+			if value != 0xFF00 && value != 0x0000 {
+				return nil, IllegalDataValue
+			}
+			if err := f.writeBits(table, start, []bool{value == 0xFF00}); err != nil {
+				return nil, err
+			}
+			return append([]byte{function}, data[:4]...), nil
+		}
+	case FuncWriteSingleRegister:
+		{
+			if err := f.writeRegisters(table, start, data[2:4]); err != nil {
+				return nil, err
+			}
+			return append([]byte{function}, data[:4]...), nil
+		}
+	case FuncWriteMultipleCoils:
+		{
+			if value < 1 || value > MaxWriteBits || len(data) < 5 || int(data[4]) != (int(value)+7)/8 || len(data) != 5+int(data[4]) {
+				return nil, IllegalDataValue
+			}
+			if err := f.writeBits(table, start, unpackBits(data[5:], int(value))); err != nil {
+				return nil, err
+			}
+			return append([]byte{function}, data[:4]...), nil
+		}
+	case FuncWriteMultipleRegisters:
+		{
+			if value < 1 || value > MaxWriteRegisters || len(data) < 5 || int(data[4]) != int(value)*2 || len(data) != 5+int(data[4]) {
+				return nil, IllegalDataValue
+			}
+			if err := f.writeRegisters(table, start, data[5:]); err != nil {
+				return nil, err
+			}
+			return append([]byte{function}, data[:4]...), nil
+		}
+	}
+	return nil, IllegalFunction
+}
+
+// overlapping returns the entries of table that share an address with
+// [start, start+count). There must be at least one.
+func (f *Facade) overlapping(table Table, start, count int) ([]*entry, error) {
+	if start+count > 0x10000 {
+		return nil, IllegalDataAddress
+	}
+	var entries []*entry
+	for _, e := range f.entries {
+		if e.table == table && e.start < start+count && e.end() > start {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, IllegalDataAddress
+	}
+	return entries, nil
+}
+
+// read reads the tags of entries, one request per PLC.
+func (f *Facade) read(entries []*entry) ([]*pb.Tag, error) {
+	tags := make([]*pb.Tag, len(entries))
+	for i, e := range entries {
+		tags[i] = e.tag.Pb()
+	}
+	for _, group := range groupByPlc(entries) {
+		req := &pb.RWReq{Plc: entries[group[0]].plc.Pb()}
+		for _, i := range group {
+			req.Tags = append(req.Tags, tags[i])
+		}
+		if _, err := f.server.ReadTags(context.Background(), req); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+func (f *Facade) write(entries []*entry, tags []*pb.Tag) error {
+	for _, group := range groupByPlc(entries) {
+		req := &pb.RWReq{Plc: entries[group[0]].plc.Pb()}
+		for _, i := range group {
+			req.Tags = append(req.Tags, tags[i])
+		}
+		if _, err := f.server.WriteTags(context.Background(), req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupByPlc returns the indexes of entries grouped by PLC, in order.
+func groupByPlc(entries []*entry) [][]int {
+	var groups [][]int
+	index := make(map[string]int)
+	for i, e := range entries {
+		name := e.plc.GetName()
+		g, ok := index[name]
+		if !ok {
+			g = len(groups)
+			index[name] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+func (f *Facade) readBits(table Table, start, count int) ([]bool, error) {
+	entries, err := f.overlapping(table, start, count)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := f.read(entries)
+	if err != nil {
+		return nil, err
+	}
+	bits := make([]bool, count)
+	for i, e := range entries {
+		bits[e.start-start] = tags[i].GetValueBool()
+	}
+	return bits, nil
+}
+
+func (f *Facade) writeBits(table Table, start int, bits []bool) error {
+	entries, err := f.overlapping(table, start, len(bits))
+	if err != nil {
+		return err
+	}
+	tags := make([]*pb.Tag, len(entries))
+	for i, e := range entries {
+		if !e.tag.Writable {
+			return IllegalDataAddress
+		}
+		tags[i] = e.tag.Pb()
+		tags[i].Value = &pb.Tag_ValueBool{ValueBool: bits[e.start-start]}
+	}
+	return f.write(entries, tags)
+}
+
+func (f *Facade) readRegisters(table Table, start, count int) ([]byte, error) {
+	entries, err := f.overlapping(table, start, count)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := f.read(entries)
+	if err != nil {
+		return nil, err
+	}
+	regs := make([]byte, count*2)
+	for i, e := range entries {
+		copyRegisters(regs, start, f.registers(e, tags[i]), e.start)
+	}
+	return regs, nil
+}
+
+// writeRegisters writes every entry regs touches. Entries only partly
+// covered by regs are read first so that their other registers keep their
+// value.
+func (f *Facade) writeRegisters(table Table, start int, regs []byte) error {
+	entries, err := f.overlapping(table, start, len(regs)/2)
+	if err != nil {
+		return err
+	}
+	var partial []*entry
+	for _, e := range entries {
+		if !e.tag.Writable {
+			return IllegalDataAddress
+		}
+		if e.start < start || e.end() > start+len(regs)/2 {
+			partial = append(partial, e)
+		}
+	}
+	current := make(map[*entry]*pb.Tag)
+	if len(partial) > 0 {
+		tags, err := f.read(partial)
+		if err != nil {
+			return err
+		}
+		for i, e := range partial {
+			current[e] = tags[i]
+		}
+	}
+	tags := make([]*pb.Tag, len(entries))
+	for i, e := range entries {
+		img := make([]byte, e.count*2)
+		if tag, ok := current[e]; ok {
+			img = f.registers(e, tag)
+		}
+		copyRegisters(img, e.start, regs, start)
+		tags[i] = e.tag.Pb()
+		f.setRegisters(e, tags[i], img)
+	}
+	return f.write(entries, tags)
+}
+
+// copyRegisters copies the registers src, starting at address srcStart,
+// into dst, starting at address dstStart, where the two overlap.
+func copyRegisters(dst []byte, dstStart int, src []byte, srcStart int) {
+	from := srcStart
+	if dstStart > from {
+		from = dstStart
+	}
+	to := srcStart + len(src)/2
+	if end := dstStart + len(dst)/2; end < to {
+		to = end
+	}
+	if from >= to {
+		return
+	}
+	copy(dst[(from-dstStart)*2:(to-dstStart)*2], src[(from-srcStart)*2:(to-srcStart)*2])
+}
+
+func isString(dt string) bool {
+	return strings.HasPrefix(dt, "String")
+}
+
+// registers lays a tag value out in the registers of e.
+func (f *Facade) registers(e *entry, tag *pb.Tag) []byte {
+	return toRegisters(f.order, tag, e.count)
+}
+
+// setRegisters is the inverse of registers.
+func (f *Facade) setRegisters(e *entry, tag *pb.Tag, regs []byte) {
+	fromRegisters(f.order, tag, regs)
+}
+
+// toRegisters lays a tag value out in count registers. Bool and one byte
+// values take the low byte of a single register, strings keep the S7
+// layout (max length, length, characters) and everything else is
+// reordered according to o.
+func toRegisters(o Order, tag *pb.Tag, count int) []byte {
+	regs := make([]byte, count*2)
+	switch {
+	case tag.GetDt() == "Bool":
+		if tag.GetValueBool() {
+			regs[1] = 1
+		}
+	case tag.GetLength() == 1:
+		regs[1] = tag.FillBuffer(0)[0]
+	case isString(tag.GetDt()):
+		copy(regs, tag.FillBuffer(0))
+	default:
+		copy(regs, o.apply(tag.FillBuffer(0)))
+	}
+	return regs
+}
+
+// fromRegisters is the inverse of toRegisters.
+func fromRegisters(o Order, tag *pb.Tag, regs []byte) {
+	switch {
+	case tag.GetDt() == "Bool":
+		tag.Value = &pb.Tag_ValueBool{ValueBool: regs[0]|regs[1] != 0}
+	case tag.GetLength() == 1:
+		tag.SetTagValue(regs[1:2])
+	case isString(tag.GetDt()):
+		l := pb.Min(int(regs[1]), tag.GetLength()-2)
+		tag.Value = &pb.Tag_ValueString{ValueString: string(regs[2 : 2+l])}
+	default:
+		tag.SetTagValue(o.apply(regs))
+	}
+}
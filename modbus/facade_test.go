@@ -0,0 +1,159 @@
+package modbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	_ "github.com/thinkontrolsy/goplc/s7"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+func TestOrder(t *testing.T) {
+	abcd := []byte{0xA, 0xB, 0xC, 0xD}
+	cases := []struct {
+		word, byte string
+		want       []byte
+	}{
+		{"big", "big", []byte{0xA, 0xB, 0xC, 0xD}},
+		{"little", "big", []byte{0xC, 0xD, 0xA, 0xB}},
+		{"big", "little", []byte{0xB, 0xA, 0xD, 0xC}},
+		{"little", "little", []byte{0xD, 0xC, 0xB, 0xA}},
+	}
+	for _, c := range cases {
+		o, err := ParseOrder(c.word, c.byte)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := o.apply(abcd)
+		if !bytes.Equal(got, c.want) {
+			t.Errorf("%s/%s: got % x, want % x", c.word, c.byte, got, c.want)
+		}
+		if back := o.apply(got); !bytes.Equal(back, abcd) {
+			t.Errorf("%s/%s: not its own inverse: % x", c.word, c.byte, back)
+		}
+	}
+}
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	id   uint16
+}
+
+func (c *testClient) call(pdu ...byte) []byte {
+	c.id++
+	adu := make([]byte, mbapHeaderLength, mbapHeaderLength+len(pdu))
+	binary.BigEndian.PutUint16(adu, c.id)
+	binary.BigEndian.PutUint16(adu[4:], uint16(len(pdu)+1))
+	adu[6] = 1
+	if _, err := c.conn.Write(append(adu, pdu...)); err != nil {
+		c.t.Fatal(err)
+	}
+	header := make([]byte, mbapHeaderLength)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		c.t.Fatal(err)
+	}
+	if binary.BigEndian.Uint16(header) != c.id {
+		c.t.Fatalf("transaction id %d, want %d", binary.BigEndian.Uint16(header), c.id)
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		c.t.Fatal(err)
+	}
+	return resp
+}
+
+func TestFacade(t *testing.T) {
+	plc := plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB10P0", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+		&pb.Tag{Address: "DB10P4", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: -2}},
+		&pb.Tag{Address: "DB10P6", Dt: "String[4]", Value: &pb.Tag_ValueString{ValueString: "ab"}},
+		&pb.Tag{Address: "MP0.1", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+	)
+	plcs := config.Plcs{{Name: "line1", Host: "127.0.0.1"}}
+	facade, err := NewFacade(FacadeConfig{
+		WordOrder: "little",
+		Map: []Mapping{
+			{Table: "HR", Address: 100, Plc: "line1", Tag: config.Tag{Address: "DB10P0", Dt: "Real", Writable: true}},
+			{Table: "HR", Address: 102, Plc: "line1", Tag: config.Tag{Address: "DB10P4", Dt: "Int"}},
+			{Table: "IR", Address: 0, Plc: "line1", Tag: config.Tag{Address: "DB10P6", Dt: "String[4]"}},
+			{Table: "C", Address: 5, Plc: "line1", Tag: config.Tag{Address: "MP0.1", Dt: "Bool", Writable: true}},
+		},
+	}, plcs, plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Handler: facade}
+	go server.Serve(l)
+	defer server.Close()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &testClient{t: t, conn: conn}
+
+	real := func(f float32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, math.Float32bits(f))
+		return []byte{b[2], b[3], b[0], b[1]}
+	}
+	check := func(name string, got, want []byte) {
+		t.Helper()
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: got % x, want % x", name, got, want)
+		}
+	}
+
+	check("read HR 100-102", c.call(FuncReadHoldingRegisters, 0, 100, 0, 3),
+		append(append([]byte{FuncReadHoldingRegisters, 6}, real(12.5)...), 0xFF, 0xFE))
+	check("read IR 0-2", c.call(FuncReadInputRegisters, 0, 0, 0, 3),
+		[]byte{FuncReadInputRegisters, 6, 4, 2, 'a', 'b', 0, 0})
+	check("read C 4-6", c.call(FuncReadCoils, 0, 4, 0, 3), []byte{FuncReadCoils, 1, 0x02})
+	check("read unmapped", c.call(FuncReadHoldingRegisters, 0, 0, 0, 1), []byte{0x83, 0x02})
+	check("read DI", c.call(FuncReadDiscreteInputs, 0, 0, 0, 1), []byte{0x82, 0x02})
+
+	v := real(-3.25)
+	check("write HR 100-101", c.call(FuncWriteMultipleRegisters, 0, 100, 0, 2, 4, v[0], v[1], v[2], v[3]),
+		[]byte{FuncWriteMultipleRegisters, 0, 100, 0, 2})
+	if got := plc.Get("DB10P0").GetValueDouble(); got != -3.25 {
+		t.Fatalf("DB10P0 = %v", got)
+	}
+	// with little word order HR 101 holds the high word of the Real
+	check("write HR 101", c.call(FuncWriteSingleRegister, 0, 101, 0x41, 0x48),
+		[]byte{FuncWriteSingleRegister, 0, 101, 0x41, 0x48})
+	if got := plc.Get("DB10P0").GetValueDouble(); got != 12.5 {
+		t.Fatalf("DB10P0 = %v", got)
+	}
+	check("write read only", c.call(FuncWriteSingleRegister, 0, 102, 0, 1), []byte{0x86, 0x02})
+	check("write C 5", c.call(FuncWriteSingleCoil, 0, 5, 0, 0), []byte{FuncWriteSingleCoil, 0, 5, 0, 0})
+	if plc.Get("MP0.1").GetValueBool() {
+		t.Fatal("MP0.1 still set")
+	}
+	check("unknown function", c.call(0x2B, 0x0E, 1, 0), []byte{0xAB, 0x01})
+
+	plc.SetOffline(true)
+	check("PLC offline", c.call(FuncReadHoldingRegisters, 0, 100, 0, 2), []byte{0x83, 0x0B})
+}
+
+func TestFacadeOverlap(t *testing.T) {
+	plcs := config.Plcs{{Name: "line1", Host: "127.0.0.1"}}
+	_, err := NewFacade(FacadeConfig{Map: []Mapping{
+		{Table: "HR", Address: 100, Plc: "line1", Tag: config.Tag{Address: "DB10P0", Dt: "Real"}},
+		{Table: "HR", Address: 101, Plc: "line1", Tag: config.Tag{Address: "DB10P4", Dt: "Int"}},
+	}}, plcs, nil)
+	if err == nil {
+		t.Fatal("overlapping mappings accepted")
+	}
+}
@@ -0,0 +1,171 @@
+// Package modbus implements the Modbus protocol pieces goplc needs: a TCP
+// server facade that maps registers onto PLC tags, and a client driver
+// that reads and writes Modbus devices through the Tag model.
+package modbus
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	FuncReadCoils              = 0x01
+	FuncReadDiscreteInputs     = 0x02
+	FuncReadHoldingRegisters   = 0x03
+	FuncReadInputRegisters     = 0x04
+	FuncWriteSingleCoil        = 0x05
+	FuncWriteSingleRegister    = 0x06
+	FuncWriteMultipleCoils     = 0x0F
+	FuncWriteMultipleRegisters = 0x10
+
+	MaxReadBits       = 2000
+	MaxReadRegisters  = 125
+	MaxWriteBits      = 1968
+	MaxWriteRegisters = 123
+)
+
+// Exception is a Modbus exception code, returned as an error.
+type Exception byte
+
+const (
+	IllegalFunction     Exception = 0x01
+	IllegalDataAddress  Exception = 0x02
+	IllegalDataValue    Exception = 0x03
+	ServerDeviceFailure Exception = 0x04
+	GatewayTargetFailed Exception = 0x0B
+)
+
+func (e Exception) Error() string {
+	switch e {
+	case IllegalFunction:
+		return "Modbus exception 1: illegal function"
+	case IllegalDataAddress:
+		return "Modbus exception 2: illegal data address"
+	case IllegalDataValue:
+		return "Modbus exception 3: illegal data value"
+	case ServerDeviceFailure:
+		return "Modbus exception 4: server device failure"
+	case GatewayTargetFailed:
+		return "Modbus exception 11: gateway target device failed to respond"
+	}
+	return fmt.Sprintf("Modbus exception %d", byte(e))
+}
+
+func exceptionPDU(function byte, e Exception) []byte {
+	return []byte{function | 0x80, byte(e)}
+}
+
+type Table int
+
+const (
+	Coils Table = iota
+	DiscreteInputs
+	InputRegisters
+	HoldingRegisters
+)
+
+func (t Table) String() string {
+	switch t {
+	case Coils:
+		return "C"
+	case DiscreteInputs:
+		return "DI"
+	case InputRegisters:
+		return "IR"
+	case HoldingRegisters:
+		return "HR"
+	}
+	return fmt.Sprintf("Table(%d)", int(t))
+}
+
+// IsBit reports whether the table holds single bits rather than registers.
+func (t Table) IsBit() bool {
+	return t == Coils || t == DiscreteInputs
+}
+
+// Writable reports whether Modbus allows writes to the table.
+func (t Table) Writable() bool {
+	return t == Coils || t == HoldingRegisters
+}
+
+// ParseTable accepts the short names C, DI, IR and HR as well as coil,
+// discrete_input, input_register and holding_register, in any case.
+func ParseTable(s string) (Table, error) {
+	switch strings.ToLower(s) {
+	case "c", "coil", "coils":
+		return Coils, nil
+	case "di", "discrete_input", "discrete_inputs":
+		return DiscreteInputs, nil
+	case "ir", "input_register", "input_registers":
+		return InputRegisters, nil
+	case "hr", "holding_register", "holding_registers":
+		return HoldingRegisters, nil
+	}
+	return 0, fmt.Errorf("unknown Modbus table %q", s)
+}
+
+// Order describes how a big-endian S7 value is laid out in registers.
+// WordSwap puts the least significant register first, ByteSwap puts the
+// low byte of every register first.
+type Order struct {
+	WordSwap bool
+	ByteSwap bool
+}
+
+// ParseOrder reads a word and byte order given as "big" or "little"; an
+// empty string means big.
+func ParseOrder(word, byte string) (Order, error) {
+	var o Order
+	switch strings.ToLower(word) {
+	case "", "big":
+	case "little":
+		o.WordSwap = true
+	default:
+		return o, fmt.Errorf("unknown word order %q", word)
+	}
+	switch strings.ToLower(byte) {
+	case "", "big":
+	case "little":
+		o.ByteSwap = true
+	default:
+		return o, fmt.Errorf("unknown byte order %q", byte)
+	}
+	return o, nil
+}
+
+// apply reorders an even number of bytes between S7 and register layout.
+// It is its own inverse.
+func (o Order) apply(b []byte) []byte {
+	out := make([]byte, len(b))
+	n := len(b) / 2
+	for i := 0; i < n; i++ {
+		j := i
+		if o.WordSwap {
+			j = n - 1 - i
+		}
+		if o.ByteSwap {
+			out[2*j], out[2*j+1] = b[2*i+1], b[2*i]
+		} else {
+			out[2*j], out[2*j+1] = b[2*i], b[2*i+1]
+		}
+	}
+	return out
+}
+
+func packBits(bits []bool) []byte {
+	b := make([]byte, (len(bits)+7)/8)
+	for i, v := range bits {
+		if v {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return b
+}
+
+func unpackBits(b []byte, n int) []bool {
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = b[i/8]&(1<<uint(i%8)) != 0
+	}
+	return bits
+}
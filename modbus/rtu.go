@@ -0,0 +1,346 @@
+package modbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultBaud = 19200
+
+	rtuTimeout = time.Second
+	// readSlice bounds a single read from the port, so that a total
+	// timeout can be checked between reads.
+	readSlice = 100 * time.Millisecond
+)
+
+func init() {
+	driver.Register("modbus-rtu", RTUDriver{})
+}
+
+var errTimeout = errors.New("Modbus RTU: no response")
+
+// crc16 is the CRC of Modbus RTU frames, sent low byte first.
+func crc16(b []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, v := range b {
+		crc ^= uint16(v)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func appendCRC(b []byte) []byte {
+	crc := crc16(b)
+	return append(b, byte(crc), byte(crc>>8))
+}
+
+// LineConfig are the settings of a serial line.
+type LineConfig struct {
+	Device   string
+	Baud     int
+	Parity   serial.Parity
+	StopBits serial.StopBits
+	// FrameDelay is the silence before every request, 3.5 characters by
+	// default.
+	FrameDelay time.Duration
+	// Timeout bounds the wait for a response.
+	Timeout time.Duration
+}
+
+// charTime is the time one 11 bit character takes on the line.
+func (c LineConfig) charTime() time.Duration {
+	return time.Duration(11 * float64(time.Second) / float64(c.Baud))
+}
+
+func (c LineConfig) frameDelay() time.Duration {
+	if c.FrameDelay > 0 {
+		return c.FrameDelay
+	}
+	// the specification fixes 1.75ms above 19200 baud
+	if c.Baud > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return c.charTime() * 7 / 2
+}
+
+// parseLineConfig reads the serial settings of plc: Host is the device,
+// Options may set "baud" (default 19200), "parity" ("even" by default,
+// "odd" or "none"), "stop_bits" (1 or 2), "frame_delay" and "timeout", the
+// last two as Go durations.
+func parseLineConfig(plc *pb.Plc) (LineConfig, error) {
+	c := LineConfig{
+		Device:   plc.GetHost(),
+		Baud:     DefaultBaud,
+		Parity:   serial.ParityEven,
+		StopBits: serial.Stop1,
+		Timeout:  rtuTimeout,
+	}
+	opts := plc.GetOptions()
+	if s, ok := opts["baud"]; ok {
+		baud, err := strconv.Atoi(s)
+		if err != nil || baud <= 0 {
+			return c, fmt.Errorf("baud: bad value %q", s)
+		}
+		c.Baud = baud
+	}
+	switch strings.ToLower(opts["parity"]) {
+	case "", "even", "e":
+	case "odd", "o":
+		c.Parity = serial.ParityOdd
+	case "none", "n":
+		c.Parity = serial.ParityNone
+	default:
+		return c, fmt.Errorf("parity: bad value %q", opts["parity"])
+	}
+	switch opts["stop_bits"] {
+	case "", "1":
+	case "2":
+		c.StopBits = serial.Stop2
+	default:
+		return c, fmt.Errorf("stop_bits: bad value %q", opts["stop_bits"])
+	}
+	for _, d := range []struct {
+		name string
+		v    *time.Duration
+	}{{"frame_delay", &c.FrameDelay}, {"timeout", &c.Timeout}} {
+		s, ok := opts[d.name]
+		if !ok {
+			continue
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil || v <= 0 {
+			return c, fmt.Errorf("%s: bad value %q", d.name, s)
+		}
+		*d.v = v
+	}
+	return c, nil
+}
+
+// line is an open serial port shared by every unit on it. The mutex is
+// the bus arbitration: one request and its response at a time.
+type line struct {
+	config LineConfig
+	refs   int
+
+	mu   sync.Mutex
+	port *serial.Port
+	// last is the end of the last frame on the line.
+	last time.Time
+}
+
+var (
+	linesMu sync.Mutex
+	lines   = make(map[string]*line)
+)
+
+// openLine returns the open line of c.Device, opening it on first use. Units
+// on one line must agree on its settings.
+func openLine(c LineConfig) (*line, error) {
+	linesMu.Lock()
+	defer linesMu.Unlock()
+	if l, ok := lines[c.Device]; ok {
+		if l.config != c {
+			return nil, fmt.Errorf("%s is open with other settings", c.Device)
+		}
+		l.refs++
+		return l, nil
+	}
+	port, err := serial.OpenPort(&serial.Config{
+		Name:        c.Device,
+		Baud:        c.Baud,
+		Parity:      c.Parity,
+		StopBits:    c.StopBits,
+		ReadTimeout: readSlice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	l := &line{config: c, refs: 1, port: port}
+	lines[c.Device] = l
+	return l, nil
+}
+
+func (l *line) release() error {
+	linesMu.Lock()
+	defer linesMu.Unlock()
+	l.refs--
+	if l.refs > 0 {
+		return nil
+	}
+	delete(lines, l.config.Device)
+	return l.port.Close()
+}
+
+// rtuTransport is the view of one unit on a shared line.
+type rtuTransport struct {
+	line *line
+	once sync.Once
+}
+
+func (t *rtuTransport) send(unit byte, pdu []byte) ([]byte, error) {
+	l := t.line
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if wait := time.Until(l.last.Add(l.config.frameDelay())); wait > 0 {
+		time.Sleep(wait)
+	}
+	// drop the rest of a broken earlier response
+	l.port.Flush()
+	adu := appendCRC(append([]byte{unit}, pdu...))
+	_, err := l.port.Write(adu)
+	// the frame is on the line once the port has sent it
+	l.last = time.Now().Add(time.Duration(len(adu)) * l.config.charTime())
+	if err != nil {
+		return nil, err
+	}
+	if unit == 0 {
+		// broadcasts are not answered
+		return pdu, nil
+	}
+	resp, err := readFrame(&deadlineReader{r: l.port, deadline: time.Now().Add(l.config.Timeout)})
+	l.last = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	if resp[0] != unit {
+		return nil, fmt.Errorf("Modbus RTU: response from unit %d, want %d", resp[0], unit)
+	}
+	return resp[1:], nil
+}
+
+func (t *rtuTransport) Close() error {
+	var err error
+	t.once.Do(func() { err = t.line.release() })
+	return err
+}
+
+// deadlineReader reads from a port whose reads return nothing after a
+// short timeout, until deadline.
+type deadlineReader struct {
+	r        io.Reader
+	deadline time.Time
+}
+
+func (d *deadlineReader) Read(b []byte) (int, error) {
+	for {
+		n, err := d.r.Read(b)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if time.Now().After(d.deadline) {
+			return 0, errTimeout
+		}
+	}
+}
+
+// readFrame reads one response frame, unit and PDU, and checks its CRC.
+// RTU frames carry no length, so it follows from the function code.
+func readFrame(r io.Reader) ([]byte, error) {
+	frame := make([]byte, 0, 256)
+	need := func(n int) error {
+		if len(frame)+n > 256 {
+			return fmt.Errorf("Modbus RTU: frame too long")
+		}
+		b := frame[len(frame) : len(frame)+n]
+		if _, err := io.ReadFull(r, b); err != nil {
+			return err
+		}
+		frame = frame[:len(frame)+n]
+		return nil
+	}
+	if err := need(2); err != nil {
+		return nil, err
+	}
+	function := frame[1]
+	switch {
+	case function&0x80 != 0:
+		if err := need(1); err != nil {
+			return nil, err
+		}
+	case function >= FuncReadCoils && function <= FuncReadInputRegisters:
+		if err := need(1); err != nil {
+			return nil, err
+		}
+		if err := need(int(frame[2])); err != nil {
+			return nil, err
+		}
+	case function == FuncWriteSingleCoil || function == FuncWriteSingleRegister ||
+		function == FuncWriteMultipleCoils || function == FuncWriteMultipleRegisters:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+	case function == FuncReadDeviceIdentification:
+		if err := need(6); err != nil {
+			return nil, err
+		}
+		for i := 0; i < int(frame[7]); i++ {
+			if err := need(2); err != nil {
+				return nil, err
+			}
+			if err := need(int(frame[len(frame)-1])); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("Modbus RTU: response with unknown function %d", function)
+	}
+	if err := need(2); err != nil {
+		return nil, err
+	}
+	n := len(frame) - 2
+	if crc := crc16(frame[:n]); frame[n] != byte(crc) || frame[n+1] != byte(crc>>8) {
+		return nil, fmt.Errorf("Modbus RTU: CRC error")
+	}
+	return frame[:n], nil
+}
+
+// RTUDriver reads and writes Modbus RTU units on serial lines, with the
+// tag addresses and options of Driver. Plc.Host is the serial device, such
+// as /dev/ttyUSB0; the line settings are options, see parseLineConfig.
+// Units sharing a device share one open line and take turns on it.
+type RTUDriver struct{}
+
+func (RTUDriver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	o, err := parseOptions(plc)
+	if err != nil {
+		return nil, err
+	}
+	c, err := parseLineConfig(plc)
+	if err != nil {
+		return nil, err
+	}
+	l, err := openLine(c)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{client: &Client{t: &rtuTransport{line: l}, unit: o.unit}, order: o.order, protocol: "modbus-rtu"}, nil
+}
+
+func (RTUDriver) Validate(tag *pb.Tag) error {
+	return Driver{}.Validate(tag)
+}
+
+func (RTUDriver) Capabilities() driver.Capabilities {
+	return Driver{}.Capabilities()
+}
@@ -0,0 +1,169 @@
+//go:build linux
+// +build linux
+
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// openPty returns the master and the slave of a new pseudo-terminal pair
+// and the name of the slave, which stands in for a serial port.
+func openPty(t *testing.T) (*os.File, *os.File, string) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	fd := int(m.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatal(err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	// reads from the master fail while no slave is open, which happens
+	// between connections of the driver
+	s, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, s, name
+}
+
+// serveRTU answers the requests to the units it knows on a serial line,
+// as slaves do. Other units stay silent.
+func serveRTU(rw io.ReadWriter, units map[byte]*device) {
+	for {
+		frame := make([]byte, 2, 256)
+		if _, err := io.ReadFull(rw, frame); err != nil {
+			return
+		}
+		var rest int
+		switch frame[1] {
+		case FuncWriteMultipleCoils, FuncWriteMultipleRegisters:
+			head := make([]byte, 5)
+			if _, err := io.ReadFull(rw, head); err != nil {
+				return
+			}
+			frame = append(frame, head...)
+			rest = int(head[4]) + 2
+		case FuncReadDeviceIdentification:
+			rest = 3 + 2
+		default:
+			rest = 4 + 2
+		}
+		tail := make([]byte, rest)
+		if _, err := io.ReadFull(rw, tail); err != nil {
+			return
+		}
+		frame = append(frame, tail...)
+		n := len(frame) - 2
+		if crc := crc16(frame[:n]); frame[n] != byte(crc) || frame[n+1] != byte(crc>>8) {
+			panic("CRC error in request")
+		}
+		d, ok := units[frame[0]]
+		if !ok {
+			continue
+		}
+		resp := d.ServeModbus(frame[0], frame[1:n])
+		rw.Write(appendCRC(append([]byte{frame[0]}, resp...)))
+	}
+}
+
+func TestRTUDriver(t *testing.T) {
+	master, slave, device1 := openPty(t)
+	defer master.Close()
+	defer slave.Close()
+	meter, drive := &device{}, &device{}
+	meter.regs[1][0] = 230
+	drive.regs[0][0] = 1500
+	go serveRTU(master, map[byte]*device{1: meter, 2: drive})
+
+	drv, err := driver.Lookup("modbus-rtu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plc := func(unit string) *pb.Plc {
+		return &pb.Plc{Protocol: "modbus-rtu", Host: device1, Options: map[string]string{
+			"unit": unit, "baud": "115200", "parity": "none", "timeout": "200ms",
+		}}
+	}
+
+	// both units poll the line at once and take turns
+	var wg sync.WaitGroup
+	for _, c := range []struct {
+		unit string
+		tag  *pb.Tag
+		want int64
+	}{
+		{"1", &pb.Tag{Address: "IR0", Dt: "Int"}, 230},
+		{"2", &pb.Tag{Address: "HR0", Dt: "Int"}, 1500},
+	} {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := drv.Connect(context.Background(), plc(c.unit))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			for i := 0; i < 20; i++ {
+				if err := conn.ReadTags(context.Background(), []*pb.Tag{c.tag}); err != nil {
+					t.Errorf("unit %s: %v", c.unit, err)
+					return
+				}
+				if v := c.tag.GetValueInteger(); v != c.want {
+					t.Errorf("unit %s: %d, want %d", c.unit, v, c.want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	conn, err := drv.Connect(context.Background(), plc("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteTags(context.Background(), []*pb.Tag{{Address: "HR1", Dt: "DInt", Value: &pb.Tag_ValueInteger{ValueInteger: 70000}}}); err != nil {
+		t.Fatal(err)
+	}
+	drive.mu.Lock()
+	hr1, hr2 := drive.regs[0][1], drive.regs[0][2]
+	drive.mu.Unlock()
+	if hr1 != 1 || hr2 != 70000-65536 {
+		t.Fatalf("HR1 %d HR2 %d", hr1, hr2)
+	}
+	info, err := conn.DeviceInfo(context.Background())
+	if err != nil || info.GetProtocol() != "modbus-rtu" || info.GetVendor() != "WAGO" {
+		t.Fatalf("device info %v %v", info, err)
+	}
+	conn.Close()
+
+	if _, err := drv.Connect(context.Background(), &pb.Plc{Host: device1, Options: map[string]string{"parity": "mark"}}); err == nil {
+		t.Fatal("mark parity accepted")
+	}
+
+	silent, err := drv.Connect(context.Background(), plc("9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	if err := silent.ReadTags(context.Background(), []*pb.Tag{{Address: "HR0", Dt: "Int"}}); err != errTimeout {
+		t.Fatalf("read from an absent unit: %v", err)
+	}
+}
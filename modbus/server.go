@@ -0,0 +1,105 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+const mbapHeaderLength = 7
+
+// Handler answers one request PDU (function code and data) addressed to
+// unit with a response PDU, which may be an exception.
+type Handler interface {
+	ServeModbus(unit byte, pdu []byte) []byte
+}
+
+type HandlerFunc func(unit byte, pdu []byte) []byte
+
+func (f HandlerFunc) ServeModbus(unit byte, pdu []byte) []byte {
+	return f(unit, pdu)
+}
+
+// Server is a Modbus TCP server. Requests on one connection are answered
+// in order.
+type Server struct {
+	Handler Handler
+
+	mu    sync.Mutex
+	l     net.Listener
+	conns map[net.Conn]struct{}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.l = l
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the listener and every open connection.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	if s.l == nil {
+		return nil
+	}
+	return s.l.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	header := make([]byte, mbapHeaderLength)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			log.Printf("modbus: %v: bad MBAP header % x", conn.RemoteAddr(), header)
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+		resp := s.Handler.ServeModbus(header[6], pdu)
+		adu := make([]byte, mbapHeaderLength+len(resp))
+		copy(adu, header[:4])
+		binary.BigEndian.PutUint16(adu[4:], uint16(len(resp)+1))
+		adu[6] = header[6]
+		copy(adu[mbapHeaderLength:], resp)
+		if _, err := conn.Write(adu); err != nil {
+			return
+		}
+	}
+}
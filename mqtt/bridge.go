@@ -0,0 +1,226 @@
+// Package mqtt publishes polled tag values to an MQTT broker and, when a
+// write topic is configured, writes values received on it back to the PLC.
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/poll"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultTopic = "plant/{plc}/{tag}"
+
+	publishTimeout = 5 * time.Second
+)
+
+type Config struct {
+	Broker   string `json:"broker"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Topic is the template values are published on; {plc} and {tag}
+	// are replaced by the configured names.
+	Topic string `json:"topic,omitempty"`
+	// WriteTopic, when set, is subscribed to and every message on it is
+	// written to the tag named by its {plc} and {tag} levels.
+	WriteTopic string `json:"write_topic,omitempty"`
+	QoS        byte   `json:"qos"`
+	Retain     bool   `json:"retain"`
+}
+
+func (c Config) topic() string {
+	if c.Topic != "" {
+		return c.Topic
+	}
+	return DefaultTopic
+}
+
+// Payload is the JSON document published for every changed tag.
+type Payload struct {
+	Value     interface{} `json:"value"`
+	Datatype  string      `json:"datatype"`
+	Timestamp time.Time   `json:"timestamp"`
+	Quality   string      `json:"quality"`
+}
+
+// PlcRW is the part of PlcServer the bridge uses.
+type PlcRW interface {
+	poll.Reader
+	WriteTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error)
+}
+
+type Bridge struct {
+	config Config
+	plcs   config.Plcs
+	server PlcRW
+	client paho.Client
+
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func NewBridge(c Config, plcs config.Plcs, server PlcRW) *Bridge {
+	b := &Bridge{config: c, plcs: plcs, server: server}
+	opts := paho.NewClientOptions().
+		AddBroker(c.Broker).
+		SetClientID(c.ClientID).
+		SetUsername(c.Username).
+		SetPassword(c.Password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(b.onConnect)
+	b.client = paho.NewClient(opts)
+	return b
+}
+
+// Run connects to the broker and polls every configured PLC until ctx is
+// done.
+func (b *Bridge) Run(ctx context.Context) error {
+	b.ctx = ctx
+	if token := b.client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	for _, plc := range b.plcs {
+		b.wg.Add(1)
+		go func(plc config.Plc) {
+			defer b.wg.Done()
+			g := poll.Group{Plc: plc.Pb(), Tags: plc.PbTags(), Interval: plc.GetInterval()}
+			poll.Watch(ctx, b.server, g, func(samples []poll.Sample) {
+				b.publish(plc, samples)
+			})
+		}(plc)
+	}
+	<-ctx.Done()
+	b.wg.Wait()
+	b.client.Disconnect(250)
+	return nil
+}
+
+func (b *Bridge) onConnect(client paho.Client) {
+	if b.config.WriteTopic == "" {
+		return
+	}
+	filter := expandTopic(b.config.WriteTopic, "+", "+")
+	if token := client.Subscribe(filter, b.config.QoS, b.onWrite); token.Wait() && token.Error() != nil {
+		log.Printf("mqtt: subscribe %s: %v", filter, token.Error())
+	}
+}
+
+func (b *Bridge) publish(plc config.Plc, samples []poll.Sample) {
+	for _, s := range samples {
+		payload := Payload{
+			Datatype:  s.Tag.GetDt(),
+			Timestamp: s.Time.UTC(),
+			Quality:   s.Quality,
+		}
+		if s.Tag.GetValue() != nil {
+			payload.Value = s.Tag.GetJSONValue()
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("mqtt: %s: %v", s.Tag.GetAddress(), err)
+			continue
+		}
+		topic := expandTopic(b.config.topic(), plc.GetName(), plc.Tags[s.Index].GetName())
+		token := b.client.Publish(topic, b.config.QoS, b.config.Retain, data)
+		if !token.WaitTimeout(publishTimeout) {
+			log.Printf("mqtt: publish %s: timeout", topic)
+		} else if token.Error() != nil {
+			log.Printf("mqtt: publish %s: %v", topic, token.Error())
+		}
+	}
+}
+
+func (b *Bridge) onWrite(client paho.Client, msg paho.Message) {
+	plcName, tagName, ok := matchTopic(b.config.WriteTopic, msg.Topic())
+	if !ok {
+		return
+	}
+	if err := b.write(plcName, tagName, msg.Payload()); err != nil {
+		log.Printf("mqtt: write %s: %v", msg.Topic(), err)
+	}
+}
+
+func (b *Bridge) write(plcName, tagName string, payload []byte) error {
+	plc, ok := b.plcs.Plc(plcName)
+	if !ok {
+		return fmt.Errorf("unknown PLC %q", plcName)
+	}
+	t, ok := plc.Tag(tagName)
+	if !ok {
+		return fmt.Errorf("unknown tag %q", tagName)
+	}
+	if !t.Writable {
+		return fmt.Errorf("tag %q is not writable", tagName)
+	}
+	v, err := decodeValue(payload)
+	if err != nil {
+		return err
+	}
+	tag := t.Pb()
+	if err := tag.SetJSONValue(v); err != nil {
+		return err
+	}
+	ctx := b.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, err = b.server.WriteTags(ctx, &pb.RWReq{Plc: plc.Pb(), Tags: []*pb.Tag{tag}})
+	return err
+}
+
+// decodeValue accepts either a Payload-like object or a bare JSON value.
+func decodeValue(payload []byte) (interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		value, ok := m["value"]
+		if !ok {
+			return nil, fmt.Errorf("payload has no value")
+		}
+		return value, nil
+	}
+	return v, nil
+}
+
+func expandTopic(template, plc, tag string) string {
+	return strings.NewReplacer("{plc}", plc, "{tag}", tag).Replace(template)
+}
+
+// matchTopic matches topic against a template whose {plc} and {tag}
+// placeholders each take a whole topic level.
+func matchTopic(template, topic string) (plc, tag string, ok bool) {
+	levels := strings.Split(template, "/")
+	parts := strings.Split(topic, "/")
+	if len(levels) != len(parts) {
+		return "", "", false
+	}
+	for i, level := range levels {
+		switch level {
+		case "{plc}":
+			plc = parts[i]
+		case "{tag}":
+			tag = parts[i]
+		default:
+			if level != parts[i] {
+				return "", "", false
+			}
+		}
+	}
+	return plc, tag, true
+}
@@ -0,0 +1,107 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/internal/mqtttest"
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+func TestMatchTopic(t *testing.T) {
+	plc, tag, ok := matchTopic("plant/{plc}/{tag}/set", "plant/line1/speed/set")
+	if !ok || plc != "line1" || tag != "speed" {
+		t.Fatalf("got %q %q %v", plc, tag, ok)
+	}
+	if _, _, ok := matchTopic("plant/{plc}/{tag}/set", "plant/line1/speed"); ok {
+		t.Fatal("matched a topic with fewer levels")
+	}
+	if got := expandTopic(DefaultTopic, "line1", "speed"); got != "plant/line1/speed" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	defer broker.Close()
+
+	plc := plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB2P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 42}},
+		&pb.Tag{Address: "DB2P4", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+	)
+	plcs := config.Plcs{{
+		Name:     "line1",
+		Host:     "127.0.0.1",
+		Interval: config.Duration(20 * time.Millisecond),
+		Tags: []config.Tag{
+			{Name: "speed", Address: "DB2P0", Dt: "Int", Writable: true},
+			{Address: "DB2P4", Dt: "Real"},
+		},
+	}}
+
+	received := make(chan paho.Message, 16)
+	sub := paho.NewClient(paho.NewClientOptions().AddBroker(broker.URL()).SetClientID("sub"))
+	if token := sub.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer sub.Disconnect(0)
+	if token := sub.Subscribe("plant/line1/+", 1, func(c paho.Client, m paho.Message) { received <- m }); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+
+	bridge := NewBridge(Config{
+		Broker:     broker.URL(),
+		ClientID:   "goplc",
+		WriteTopic: "plant/{plc}/{tag}/set",
+		QoS:        1,
+	}, plcs, plc)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- bridge.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	next := func() (string, Payload) {
+		select {
+		case m := <-received:
+			var p Payload
+			if err := json.Unmarshal(m.Payload(), &p); err != nil {
+				t.Fatal(err)
+			}
+			return m.Topic(), p
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for publish")
+		}
+		return "", Payload{}
+	}
+
+	got := make(map[string]Payload)
+	for len(got) < 2 {
+		topic, p := next()
+		got[topic] = p
+	}
+	if p := got["plant/line1/speed"]; p.Value != float64(42) || p.Datatype != "Int" || p.Quality != "good" {
+		t.Fatalf("speed: %+v", p)
+	}
+	if p := got["plant/line1/DB2P4"]; p.Value != 12.5 || p.Datatype != "Real" {
+		t.Fatalf("DB2P4: %+v", p)
+	}
+
+	sub.Publish("plant/line1/DB2P4/set", 1, false, `{"value": 1}`).Wait()
+	sub.Publish("plant/line1/speed/set", 1, false, `{"value": 7}`).Wait()
+	topic, p := next()
+	if topic != "plant/line1/speed" || p.Value != float64(7) {
+		t.Fatalf("after write: %s %+v", topic, p)
+	}
+	if v := plc.Get("DB2P4").GetValueDouble(); v != 12.5 {
+		t.Fatalf("read only tag written: %v", v)
+	}
+}
@@ -0,0 +1,383 @@
+package opcua
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/driver"
+)
+
+// NamespaceUri is the namespace of the PLC nodes, index 1.
+const NamespaceUri = "urn:goplc:plc"
+
+const (
+	NodeClassObject   = 1
+	NodeClassVariable = 2
+)
+
+// Attribute ids.
+const (
+	AttributeNodeId                  = 1
+	AttributeNodeClass               = 2
+	AttributeBrowseName              = 3
+	AttributeDisplayName             = 4
+	AttributeDescription             = 5
+	AttributeWriteMask               = 6
+	AttributeUserWriteMask           = 7
+	AttributeEventNotifier           = 12
+	AttributeValue                   = 13
+	AttributeDataType                = 14
+	AttributeValueRank               = 15
+	AttributeArrayDimensions         = 16
+	AttributeAccessLevel             = 17
+	AttributeUserAccessLevel         = 18
+	AttributeMinimumSamplingInterval = 19
+	AttributeHistorizing             = 20
+)
+
+const (
+	accessRead  = 0x01
+	accessWrite = 0x02
+
+	valueRankScalar = -1
+	valueRankArray  = 1
+)
+
+// Standard nodes of namespace 0.
+const (
+	idRootFolder     = 84
+	idObjectsFolder  = 85
+	idTypesFolder    = 86
+	idViewsFolder    = 87
+	idServer         = 2253
+	idServerArray    = 2254
+	idNamespaceArray = 2255
+	idServerStatus   = 2256
+	idStartTime      = 2257
+	idCurrentTime    = 2258
+	idState          = 2259
+
+	idBaseObjectType       = 58
+	idFolderType           = 61
+	idBaseDataVariableType = 63
+	idPropertyType         = 68
+	idServerType           = 2004
+	idServerStatusType     = 2138
+)
+
+// Reference type ids.
+const (
+	refReferences        = 31
+	refHierarchical      = 33
+	refHasChild          = 34
+	refOrganizes         = 35
+	refHasTypeDefinition = 40
+	refAggregates        = 44
+	refHasProperty       = 46
+	refHasComponent      = 47
+)
+
+// subtypes lists the reference types below each abstract reference type
+// the address space uses.
+var subtypes = map[uint32][]uint32{
+	refHierarchical: {refHasChild, refOrganizes, refAggregates, refHasProperty, refHasComponent},
+	refHasChild:     {refAggregates, refHasProperty, refHasComponent},
+	refAggregates:   {refHasProperty, refHasComponent},
+}
+
+// matchesReference reports whether a reference of type ref passes a browse
+// filter for filter, possibly including its subtypes.
+func matchesReference(ref uint32, filter NodeId, includeSubtypes bool) bool {
+	if filter.IsNull() {
+		return true
+	}
+	if filter.Namespace != 0 || filter.Type != 0 {
+		return false
+	}
+	if filter.Numeric == ref {
+		return true
+	}
+	if !includeSubtypes {
+		return false
+	}
+	if filter.Numeric == refReferences {
+		return true
+	}
+	for _, sub := range subtypes[filter.Numeric] {
+		if sub == ref {
+			return true
+		}
+	}
+	return false
+}
+
+type reference struct {
+	typeId  uint32
+	forward bool
+	target  *node
+}
+
+type node struct {
+	id        NodeId
+	class     int32
+	name      string
+	typeDef   uint32
+	dataType  uint32
+	valueRank int32
+	refs      []reference
+
+	// variables are either backed by a PLC tag or computed by value
+	plc   config.Plc
+	tag   config.Tag
+	value func() interface{}
+}
+
+func (n *node) isTag() bool {
+	return n.value == nil && n.class == NodeClassVariable
+}
+
+func (n *node) browseName() QualifiedName {
+	return QualifiedName{NamespaceIndex: n.id.Namespace, Name: n.name}
+}
+
+func (n *node) accessLevel() uint8 {
+	if n.isTag() && n.tag.Writable {
+		return accessRead | accessWrite
+	}
+	return accessRead
+}
+
+type addressSpace struct {
+	nodes map[string]*node
+}
+
+func (a *addressSpace) node(id NodeId) (*node, bool) {
+	n, ok := a.nodes[id.Key()]
+	return n, ok
+}
+
+func (a *addressSpace) add(parent *node, refType uint32, n *node) *node {
+	a.nodes[n.id.Key()] = n
+	if parent != nil {
+		parent.refs = append(parent.refs, reference{typeId: refType, forward: true, target: n})
+		n.refs = append(n.refs, reference{typeId: refType, forward: false, target: parent})
+	}
+	return n
+}
+
+func folder(id NodeId, name string) *node {
+	return &node{id: id, class: NodeClassObject, name: name, typeDef: idFolderType}
+}
+
+func variable(id NodeId, name string, dataType uint32, value func() interface{}) *node {
+	return &node{
+		id:        id,
+		class:     NodeClassVariable,
+		name:      name,
+		typeDef:   idBaseDataVariableType,
+		dataType:  dataType,
+		valueRank: valueRankScalar,
+		value:     value,
+	}
+}
+
+func std(id uint32) NodeId {
+	return NewNumericNodeId(0, id)
+}
+
+// newAddressSpace builds the standard Server object and, below Objects, a
+// folder per PLC holding a folder per memory area (DB10, M, I, Q) with a
+// variable per configured tag.
+func newAddressSpace(plcs config.Plcs, status func() ServerStatusDataType) (*addressSpace, error) {
+	a := &addressSpace{nodes: make(map[string]*node)}
+	root := a.add(nil, 0, folder(std(idRootFolder), "Root"))
+	objects := a.add(root, refOrganizes, folder(std(idObjectsFolder), "Objects"))
+	a.add(root, refOrganizes, folder(std(idTypesFolder), "Types"))
+	a.add(root, refOrganizes, folder(std(idViewsFolder), "Views"))
+
+	server := a.add(objects, refOrganizes, &node{id: std(idServer), class: NodeClassObject, name: "Server", typeDef: idServerType})
+	property := func(id uint32, name string, dataType uint32, value func() interface{}) {
+		n := variable(std(id), name, dataType, value)
+		n.typeDef = idPropertyType
+		n.valueRank = valueRankArray
+		a.add(server, refHasProperty, n)
+	}
+	property(idNamespaceArray, "NamespaceArray", DataTypeString, func() interface{} {
+		return []string{"http://opcfoundation.org/UA/", NamespaceUri}
+	})
+	property(idServerArray, "ServerArray", DataTypeString, func() interface{} {
+		return []string{applicationUri}
+	})
+	st := variable(std(idServerStatus), "ServerStatus", dataTypeServerStatus, func() interface{} {
+		return NewExtensionObject(status())
+	})
+	st.typeDef = idServerStatusType
+	a.add(server, refHasComponent, st)
+	a.add(st, refHasComponent, variable(std(idStartTime), "StartTime", dataTypeUtcTime, func() interface{} {
+		return status().StartTime
+	}))
+	a.add(st, refHasComponent, variable(std(idCurrentTime), "CurrentTime", dataTypeUtcTime, func() interface{} {
+		return status().CurrentTime
+	}))
+	a.add(st, refHasComponent, variable(std(idState), "State", dataTypeServerState, func() interface{} {
+		return status().State
+	}))
+
+	for _, plc := range plcs {
+		plcNode := a.add(objects, refOrganizes, folder(NewStringNodeId(1, plc.GetName()), plc.GetName()))
+		areas := make(map[string]*node)
+		for _, tag := range plc.Tags {
+			dataType, err := DataType(tag.Dt)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", plc.GetName(), tag.GetName(), err)
+			}
+			if err := driver.Validate(plc.Pb(), tag.Pb()); err != nil {
+				return nil, fmt.Errorf("%s %s: %v", plc.GetName(), tag.GetName(), err)
+			}
+			// S7 tags are grouped by memory area, tags of other
+			// protocols sit right below their PLC
+			parent, prefix := plcNode, plc.GetName()+"."
+			if plc.Protocol == "" || plc.Protocol == driver.DefaultProtocol {
+				address, _ := tag.Pb().GetArea()
+				area := address.Area
+				areaNode, ok := areas[area]
+				if !ok {
+					areaNode = a.add(plcNode, refOrganizes, folder(NewStringNodeId(1, plc.GetName()+"."+area), area))
+					areas[area] = areaNode
+				}
+				parent, prefix = areaNode, prefix+area+"."
+			}
+			id := NewStringNodeId(1, prefix+tag.GetName())
+			if _, ok := a.node(id); ok {
+				return nil, fmt.Errorf("%s %s: duplicate tag name", plc.GetName(), tag.GetName())
+			}
+			n := variable(id, tag.GetName(), dataType, nil)
+			n.plc, n.tag = plc, tag
+			a.add(parent, refOrganizes, n)
+		}
+	}
+	return a, nil
+}
+
+// browse returns the references of n that pass the filter of desc.
+func (a *addressSpace) browse(n *node, desc *BrowseDescription) []ReferenceDescription {
+	var refs []ReferenceDescription
+	for _, r := range n.refs {
+		switch desc.BrowseDirection {
+		case BrowseDirectionForward:
+			if !r.forward {
+				continue
+			}
+		case BrowseDirectionInverse:
+			if r.forward {
+				continue
+			}
+		}
+		if !matchesReference(r.typeId, desc.ReferenceTypeId, desc.IncludeSubtypes) {
+			continue
+		}
+		if desc.NodeClassMask != 0 && desc.NodeClassMask&uint32(r.target.class) == 0 {
+			continue
+		}
+		refs = append(refs, ReferenceDescription{
+			ReferenceTypeId: std(r.typeId),
+			IsForward:       r.forward,
+			NodeId:          ExpandedNodeId{NodeId: r.target.id},
+			BrowseName:      r.target.browseName(),
+			DisplayName:     LocalizedText{Text: r.target.name},
+			NodeClass:       r.target.class,
+			TypeDefinition:  ExpandedNodeId{NodeId: std(r.target.typeDef)},
+		})
+	}
+	if desc.BrowseDirection != BrowseDirectionInverse && matchesReference(refHasTypeDefinition, desc.ReferenceTypeId, desc.IncludeSubtypes) {
+		class := int32(8) // ObjectType
+		if n.class == NodeClassVariable {
+			class = 16 // VariableType
+		}
+		if desc.NodeClassMask == 0 || desc.NodeClassMask&uint32(class) != 0 {
+			refs = append(refs, ReferenceDescription{
+				ReferenceTypeId: std(refHasTypeDefinition),
+				IsForward:       true,
+				NodeId:          ExpandedNodeId{NodeId: std(n.typeDef)},
+				BrowseName:      QualifiedName{Name: typeNames[n.typeDef]},
+				DisplayName:     LocalizedText{Text: typeNames[n.typeDef]},
+				NodeClass:       class,
+			})
+		}
+	}
+	return refs
+}
+
+var typeNames = map[uint32]string{
+	idBaseObjectType:       "BaseObjectType",
+	idFolderType:           "FolderType",
+	idBaseDataVariableType: "BaseDataVariableType",
+	idPropertyType:         "PropertyType",
+	idServerType:           "ServerType",
+	idServerStatusType:     "ServerStatusType",
+}
+
+// follow resolves one relative path element from n.
+func (a *addressSpace) follow(n *node, el *RelativePathElement) []*node {
+	var targets []*node
+	for _, r := range n.refs {
+		if r.forward == el.IsInverse || !matchesReference(r.typeId, el.ReferenceTypeId, el.IncludeSubtypes) {
+			continue
+		}
+		if r.target.browseName() == el.TargetName {
+			targets = append(targets, r.target)
+		}
+	}
+	return targets
+}
+
+// attribute returns every attribute of n except the value of a variable.
+func (n *node) attribute(id uint32, userAccess uint8) (interface{}, StatusCode) {
+	switch id {
+	case AttributeNodeId:
+		return n.id, Good
+	case AttributeNodeClass:
+		return n.class, Good
+	case AttributeBrowseName:
+		return n.browseName(), Good
+	case AttributeDisplayName:
+		return LocalizedText{Text: n.name}, Good
+	case AttributeDescription:
+		if n.isTag() {
+			return LocalizedText{Text: n.tag.Address + " " + n.tag.Dt}, Good
+		}
+		return LocalizedText{}, Good
+	case AttributeWriteMask, AttributeUserWriteMask:
+		return uint32(0), Good
+	}
+	if n.class == NodeClassObject {
+		if id == AttributeEventNotifier {
+			return uint8(0), Good
+		}
+		return nil, BadAttributeIdInvalid
+	}
+	switch id {
+	case AttributeDataType:
+		return std(n.dataType), Good
+	case AttributeValueRank:
+		return n.valueRank, Good
+	case AttributeArrayDimensions:
+		if n.valueRank == valueRankArray {
+			return []uint32{0}, Good
+		}
+		return []uint32{}, Good
+	case AttributeAccessLevel:
+		return n.accessLevel(), Good
+	case AttributeUserAccessLevel:
+		return n.accessLevel() & userAccess, Good
+	case AttributeMinimumSamplingInterval:
+		if n.isTag() {
+			return float64(minSamplingInterval / time.Millisecond), Good
+		}
+		return float64(0), Good
+	case AttributeHistorizing:
+		return false, Good
+	}
+	return nil, BadAttributeIdInvalid
+}
@@ -0,0 +1,195 @@
+package opcua
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+
+	ptypes "github.com/golang/protobuf/ptypes"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// Node ids of the built-in data types.
+const (
+	DataTypeBoolean  = 1
+	DataTypeSByte    = 2
+	DataTypeByte     = 3
+	DataTypeInt16    = 4
+	DataTypeUInt16   = 5
+	DataTypeInt32    = 6
+	DataTypeUInt32   = 7
+	DataTypeInt64    = 8
+	DataTypeUInt64   = 9
+	DataTypeFloat    = 10
+	DataTypeDouble   = 11
+	DataTypeString   = 12
+	DataTypeDateTime = 13
+	DataTypeDuration = 290
+
+	dataTypeServerState  = 852
+	dataTypeServerStatus = 862
+	dataTypeUtcTime      = 294
+)
+
+// DataTypes maps every name in pb.DT to the data type of its variable. Bit
+// strings become unsigned integers of the same width, dates and times of
+// day become DateTime and durations become Duration, a Double holding
+// milliseconds.
+var DataTypes = map[string]uint32{
+	"Bool": DataTypeBoolean,
+	"Byte": DataTypeByte,
+	"Char": DataTypeString,
+
+	"Word":  DataTypeUInt16,
+	"DWord": DataTypeUInt32,
+	"LWord": DataTypeUInt64,
+
+	"SInt":  DataTypeSByte,
+	"USInt": DataTypeByte,
+
+	"Int":  DataTypeInt16,
+	"UInt": DataTypeUInt16,
+
+	"DInt":  DataTypeInt32,
+	"UDInt": DataTypeUInt32,
+
+	"LInt":  DataTypeInt64,
+	"ULInt": DataTypeUInt64,
+
+	"Real":  DataTypeFloat,
+	"LReal": DataTypeDouble,
+
+	"DTL": DataTypeDateTime,
+
+	"Date":          DataTypeDateTime,
+	"Date_And_Time": DataTypeDateTime,
+	"LDT":           DataTypeDateTime,
+
+	"LTime":        DataTypeDuration,
+	"LTime_Of_Day": DataTypeDateTime,
+
+	"S5Time":      DataTypeDuration,
+	"Time":        DataTypeDuration,
+	"Time_Of_Day": DataTypeDateTime,
+
+	"Counter": DataTypeUInt16,
+
+	"String": DataTypeString,
+}
+
+var stringReg = regexp.MustCompile(pb.DT_REG)
+
+// DataType returns the data type of a tag datatype, including String[n].
+func DataType(dt string) (uint32, error) {
+	if t, ok := DataTypes[dt]; ok {
+		return t, nil
+	}
+	if stringReg.MatchString(dt) {
+		return DataTypeString, nil
+	}
+	return 0, fmt.Errorf("unknown datatype %q", dt)
+}
+
+// variantType returns the variant type that carries values of a data type.
+func variantType(dataType uint32) byte {
+	if dataType == DataTypeDuration {
+		return TypeDouble
+	}
+	return byte(dataType)
+}
+
+// variantValue converts a tag value into the Go type of its variant.
+func variantValue(tag *pb.Tag) interface{} {
+	dataType, err := DataType(tag.GetDt())
+	if err != nil {
+		return nil
+	}
+	switch dataType {
+	case DataTypeBoolean:
+		return tag.GetValueBool()
+	case DataTypeSByte:
+		return int8(tag.GetValueInteger())
+	case DataTypeInt16:
+		return int16(tag.GetValueInteger())
+	case DataTypeInt32:
+		return int32(tag.GetValueInteger())
+	case DataTypeInt64:
+		return tag.GetValueInteger()
+	case DataTypeFloat:
+		return float32(tag.GetValueDouble())
+	case DataTypeDouble:
+		return tag.GetValueDouble()
+	case DataTypeDateTime:
+		t, _ := ptypes.Timestamp(tag.GetValueTimestamp())
+		return t.UTC()
+	case DataTypeDuration:
+		d, _ := ptypes.Duration(tag.GetValueDuration())
+		return float64(d) / float64(time.Millisecond)
+	case DataTypeString:
+		return tag.GetValueString()
+	}
+	// unsigned integers and bit strings
+	var n uint64
+	switch tag.GetDt() {
+	case "Byte", "Word", "DWord", "LWord":
+		n = tag.GetBitString()
+	case "ULInt":
+		n = tag.GetValueUinteger()
+	default:
+		n = uint64(tag.GetValueInteger())
+	}
+	switch dataType {
+	case DataTypeByte:
+		return uint8(n)
+	case DataTypeUInt16:
+		return uint16(n)
+	case DataTypeUInt32:
+		return uint32(n)
+	}
+	return n
+}
+
+// setVariantValue stores a written variant in tag. The variant must have
+// exactly the type of the variable.
+func setVariantValue(tag *pb.Tag, v interface{}) error {
+	dataType, err := DataType(tag.GetDt())
+	if err != nil {
+		return err
+	}
+	if v == nil || reflect.TypeOf(v) != variantTypes[variantType(dataType)] {
+		return BadTypeMismatch
+	}
+	var value interface{}
+	switch x := v.(type) {
+	case bool, string:
+		value = x
+	case int8:
+		value = int64(x)
+	case int16:
+		value = int64(x)
+	case int32:
+		value = int64(x)
+	case int64:
+		value = x
+	case uint8:
+		value = uint64(x)
+	case uint16:
+		value = uint64(x)
+	case uint32:
+		value = uint64(x)
+	case uint64:
+		value = x
+	case float32:
+		value = float64(x)
+	case float64:
+		value = x
+		if dataType == DataTypeDuration {
+			value = time.Duration(x * float64(time.Millisecond)).String()
+		}
+	case time.Time:
+		value = x.UTC().Format(time.RFC3339Nano)
+	}
+	return tag.SetJSONValue(value)
+}
@@ -0,0 +1,303 @@
+package opcua
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+var errDecoding = errors.New("opcua: decoding error")
+
+// binaryEncoder and binaryDecoder are implemented by the built-in types
+// whose encoding is not simply their fields in order.
+type binaryEncoder interface {
+	encode(e *encoder)
+}
+
+type binaryDecoder interface {
+	decode(d *decoder)
+}
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) uint8(v uint8)   { e.WriteByte(v) }
+func (e *encoder) uint16(v uint16) { binary.Write(e, binary.LittleEndian, v) }
+func (e *encoder) uint32(v uint32) { binary.Write(e, binary.LittleEndian, v) }
+func (e *encoder) int32(v int32)   { binary.Write(e, binary.LittleEndian, v) }
+func (e *encoder) int64(v int64)   { binary.Write(e, binary.LittleEndian, v) }
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.WriteByte(1)
+	} else {
+		e.WriteByte(0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	if s == "" {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) byteString(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.Write(b)
+}
+
+// DateTime is the number of 100 ns intervals since 1601-01-01 UTC; the zero
+// time.Time encodes as 0.
+var epoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (e *encoder) time(t time.Time) {
+	if t.IsZero() || t.Before(epoch) {
+		e.int64(0)
+		return
+	}
+	// time.Duration overflows after 292 years, so split at the Unix epoch
+	e.int64(t.Unix()*10000000 + int64(t.Nanosecond()/100) + 116444736000000000)
+}
+
+func (e *encoder) encode(v interface{}) {
+	e.value(reflect.ValueOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (e *encoder) value(v reflect.Value) {
+	if v.CanInterface() {
+		if be, ok := v.Interface().(binaryEncoder); ok {
+			be.encode(e)
+			return
+		}
+	}
+	if v.CanAddr() {
+		if be, ok := v.Addr().Interface().(binaryEncoder); ok {
+			be.encode(e)
+			return
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			e.value(reflect.New(v.Type().Elem()).Elem())
+		} else {
+			e.value(v.Elem())
+		}
+	case reflect.Bool:
+		e.bool(v.Bool())
+	case reflect.Int8:
+		e.uint8(uint8(v.Int()))
+	case reflect.Uint8:
+		e.uint8(uint8(v.Uint()))
+	case reflect.Int16:
+		e.uint16(uint16(v.Int()))
+	case reflect.Uint16:
+		e.uint16(uint16(v.Uint()))
+	case reflect.Int32:
+		e.int32(int32(v.Int()))
+	case reflect.Uint32:
+		e.uint32(uint32(v.Uint()))
+	case reflect.Int64:
+		e.int64(v.Int())
+	case reflect.Uint64:
+		binary.Write(e, binary.LittleEndian, v.Uint())
+	case reflect.Float32:
+		e.uint32(math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		binary.Write(e, binary.LittleEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.byteString(v.Bytes())
+			return
+		}
+		if v.IsNil() {
+			e.int32(-1)
+			return
+		}
+		e.int32(int32(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			e.value(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			e.time(v.Interface().(time.Time))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			e.value(v.Field(i))
+		}
+	default:
+		panic(fmt.Sprintf("opcua: cannot encode %v", v.Type()))
+	}
+}
+
+// decoder reads from a byte slice; the first error sticks and every later
+// read returns zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = errDecoding
+		return make([]byte, n)
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *decoder) uint8() uint8   { return d.read(1)[0] }
+func (d *decoder) uint16() uint16 { return binary.LittleEndian.Uint16(d.read(2)) }
+func (d *decoder) uint32() uint32 { return binary.LittleEndian.Uint32(d.read(4)) }
+func (d *decoder) int32() int32   { return int32(d.uint32()) }
+func (d *decoder) uint64() uint64 { return binary.LittleEndian.Uint64(d.read(8)) }
+func (d *decoder) int64() int64   { return int64(d.uint64()) }
+func (d *decoder) bool() bool     { return d.uint8() != 0 }
+
+func (d *decoder) length() int {
+	n := d.int32()
+	if n > int32(len(d.b)) {
+		d.err = errDecoding
+		return -1
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if n <= 0 {
+		return ""
+	}
+	return string(d.read(n))
+}
+
+func (d *decoder) byteString() []byte {
+	n := d.length()
+	if n < 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.read(n))
+	return b
+}
+
+func (d *decoder) time() time.Time {
+	v := d.int64()
+	if v <= 0 {
+		return time.Time{}
+	}
+	v -= 116444736000000000
+	return time.Unix(v/10000000, v%10000000*100).UTC()
+}
+
+func (d *decoder) decode(v interface{}) error {
+	d.value(reflect.ValueOf(v).Elem())
+	return d.err
+}
+
+func (d *decoder) value(v reflect.Value) {
+	if d.err != nil {
+		return
+	}
+	if v.CanAddr() {
+		if bd, ok := v.Addr().Interface().(binaryDecoder); ok {
+			bd.decode(d)
+			return
+		}
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.value(v.Elem())
+	case reflect.Bool:
+		v.SetBool(d.bool())
+	case reflect.Int8:
+		v.SetInt(int64(int8(d.uint8())))
+	case reflect.Uint8:
+		v.SetUint(uint64(d.uint8()))
+	case reflect.Int16:
+		v.SetInt(int64(int16(d.uint16())))
+	case reflect.Uint16:
+		v.SetUint(uint64(d.uint16()))
+	case reflect.Int32:
+		v.SetInt(int64(d.int32()))
+	case reflect.Uint32:
+		v.SetUint(uint64(d.uint32()))
+	case reflect.Int64:
+		v.SetInt(d.int64())
+	case reflect.Uint64:
+		v.SetUint(d.uint64())
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(d.uint32())))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(d.uint64()))
+	case reflect.String:
+		v.SetString(d.string())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(d.byteString())
+			return
+		}
+		n := d.length()
+		if n < 0 {
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			d.value(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(d.time()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			d.value(v.Field(i))
+		}
+	default:
+		d.err = fmt.Errorf("opcua: cannot decode %v", v.Type())
+	}
+}
+
+func encode(v interface{}) []byte {
+	var e encoder
+	e.encode(v)
+	return e.Bytes()
+}
+
+func decode(b []byte, v interface{}) error {
+	d := decoder{b: b}
+	return d.decode(v)
+}
+
+// readFull reads exactly n bytes.
+func readFull(r io.Reader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
@@ -0,0 +1,773 @@
+// Package opcua serves the configured PLC tags as an OPC UA server over the
+// binary TCP protocol with security policy None. Reads and writes go
+// through the regular ReadTags and WriteTags path and monitored items are
+// sampled by a shared poller.
+package opcua
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/poll"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultListen = ":4840"
+
+	applicationUri = "urn:goplc:server"
+	productUri     = "https://github.com/thinkontrolsy/goplc"
+
+	policyAnonymous = "anonymous"
+	policyUserName  = "username"
+
+	maxSessionTimeout = time.Hour
+
+	channelLifetime = time.Hour
+)
+
+// minSessionTimeout is the shortest session timeout granted; tests shorten
+// it.
+var minSessionTimeout = 10 * time.Second
+
+type Config struct {
+	Listen string `json:"listen"`
+	// EndpointURL is the URL advertised to clients, by default
+	// opc.tcp://<hostname> and the listen port.
+	EndpointURL string `json:"endpoint_url,omitempty"`
+	// Anonymous allows sessions without a user name. They may only read.
+	Anonymous bool `json:"anonymous,omitempty"`
+	// Users maps user names to passwords. With security policy None the
+	// passwords cross the network in clear text.
+	Users map[string]string `json:"users,omitempty"`
+}
+
+// PlcRW is the part of PlcServer the OPC UA server uses.
+type PlcRW interface {
+	poll.Reader
+	WriteTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error)
+}
+
+type Server struct {
+	config Config
+	server PlcRW
+	poller *poll.Shared
+	space  *addressSpace
+	start  time.Time
+
+	lastId uint32
+
+	mu       sync.Mutex
+	l        net.Listener
+	conns    map[net.Conn]struct{}
+	sessions map[string]*session
+	endpoint string
+}
+
+func NewServer(c Config, plcs config.Plcs, server PlcRW) (*Server, error) {
+	s := &Server{
+		config:   c,
+		server:   server,
+		poller:   poll.NewShared(server),
+		start:    time.Now(),
+		conns:    make(map[net.Conn]struct{}),
+		sessions: make(map[string]*session),
+	}
+	space, err := newAddressSpace(plcs, s.status)
+	if err != nil {
+		return nil, err
+	}
+	s.space = space
+	return s, nil
+}
+
+func (s *Server) status() ServerStatusDataType {
+	return ServerStatusDataType{
+		StartTime:   s.start,
+		CurrentTime: time.Now(),
+		BuildInfo: BuildInfo{
+			ProductUri:       productUri,
+			ManufacturerName: "goplc",
+			ProductName:      "goplc",
+		},
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	if addr == "" {
+		addr = DefaultListen
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.l = l
+	s.endpoint = s.config.EndpointURL
+	if s.endpoint == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "localhost"
+		}
+		_, port, _ := net.SplitHostPort(l.Addr().String())
+		s.endpoint = "opc.tcp://" + net.JoinHostPort(host, port)
+	}
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops the listener, every open connection and every subscription.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	for key, sess := range s.sessions {
+		sess.close()
+		delete(s.sessions, key)
+	}
+	if s.l == nil {
+		return nil
+	}
+	return s.l.Close()
+}
+
+// nextId hands out channel, session, subscription and item ids. It may be
+// called with a session mutex held.
+func (s *Server) nextId() uint32 {
+	return atomic.AddUint32(&s.lastId, 1)
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	ch := &channel{conn: conn, partial: make(map[uint32][]byte)}
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		// sessions do not outlive their channel
+		for key, sess := range s.sessions {
+			if sess.on(ch) {
+				sess.close()
+				delete(s.sessions, key)
+			}
+		}
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	if err := ch.handshake(); err != nil {
+		return
+	}
+	for {
+		msg, err := ch.read()
+		if err != nil {
+			return
+		}
+		switch msg.typ {
+		case msgOpen:
+			if err := s.openChannel(ch, msg); err != nil {
+				log.Printf("opcua: %v: %v", conn.RemoteAddr(), err)
+				return
+			}
+		case msgClose:
+			return
+		case msgMessage:
+			var resp response
+			req, err := decodeMessage(msg.body)
+			if err != nil {
+				resp = fault(nil, statusOf(err))
+			} else {
+				resp = s.serve(ch, msg.requestId, req)
+			}
+			if resp == nil {
+				continue
+			}
+			if err := ch.send(msgMessage, msg.requestId, encodeMessage(resp)); err != nil {
+				log.Printf("opcua: %v: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) openChannel(ch *channel, msg *message) error {
+	v, err := decodeMessage(msg.body)
+	if err != nil {
+		return err
+	}
+	req, ok := v.(*OpenSecureChannelRequest)
+	if !ok {
+		return BadTcpMessageTypeInvalid
+	}
+	if req.SecurityMode != MessageSecurityModeNone {
+		ch.fail(BadSecurityPolicyRejected, "only security mode None is supported")
+		return BadSecurityPolicyRejected
+	}
+	id := s.nextId()
+	ch.mu.Lock()
+	if req.RequestType == SecurityTokenIssue {
+		ch.id = id
+	}
+	ch.tokenId = id
+	token := ChannelSecurityToken{
+		ChannelId:       ch.id,
+		TokenId:         ch.tokenId,
+		CreatedAt:       time.Now(),
+		RevisedLifetime: uint32(channelLifetime / time.Millisecond),
+	}
+	ch.mu.Unlock()
+	resp := &OpenSecureChannelResponse{
+		ResponseHeader: ResponseHeader{Timestamp: time.Now(), RequestHandle: req.RequestHeader.RequestHandle},
+		SecurityToken:  token,
+	}
+	return ch.send(msgOpen, msg.requestId, encodeMessage(resp))
+}
+
+// statusOf turns a service error into a status code.
+func statusOf(err error) StatusCode {
+	if code, ok := err.(StatusCode); ok {
+		return code
+	}
+	if err == errDecoding {
+		return BadDecodingError
+	}
+	return BadInternalError
+}
+
+func fault(h *RequestHeader, code StatusCode) response {
+	f := &ServiceFault{ResponseHeader: ResponseHeader{Timestamp: time.Now(), ServiceResult: code}}
+	if h != nil {
+		f.ResponseHeader.RequestHandle = h.RequestHandle
+	}
+	return f
+}
+
+// serve answers one service request. It returns nil for Publish requests,
+// which are answered later by a subscription.
+func (s *Server) serve(ch *channel, requestId uint32, v interface{}) response {
+	req, ok := v.(request)
+	if !ok {
+		return fault(nil, BadServiceUnsupported)
+	}
+	h := req.header()
+	var resp response
+	var err error
+	switch req := req.(type) {
+	case *GetEndpointsRequest:
+		resp = &GetEndpointsResponse{Endpoints: s.endpoints()}
+	case *FindServersRequest:
+		resp = &FindServersResponse{Servers: []ApplicationDescription{s.application()}}
+	case *CreateSessionRequest:
+		resp, err = s.createSession(ch, req)
+	case *ActivateSessionRequest:
+		resp, err = s.activateSession(ch, req)
+	default:
+		var sess *session
+		sess, err = s.session(ch, h.AuthenticationToken)
+		if err != nil {
+			break
+		}
+		switch req := req.(type) {
+		case *CloseSessionRequest:
+			resp, err = s.closeSession(sess, req)
+		case *BrowseRequest:
+			resp, err = s.browse(req)
+		case *BrowseNextRequest:
+			resp, err = s.browseNext(req)
+		case *TranslateBrowsePathsToNodeIdsRequest:
+			resp, err = s.translate(req)
+		case *ReadRequest:
+			resp, err = s.read(sess, req)
+		case *WriteRequest:
+			resp, err = s.write(sess, req)
+		case *CreateSubscriptionRequest:
+			resp, err = s.createSubscription(sess, req)
+		case *ModifySubscriptionRequest:
+			resp, err = sess.modifySubscription(req)
+		case *SetPublishingModeRequest:
+			resp, err = sess.setPublishingMode(req)
+		case *DeleteSubscriptionsRequest:
+			resp, err = sess.deleteSubscriptions(req)
+		case *CreateMonitoredItemsRequest:
+			resp, err = s.createMonitoredItems(sess, req)
+		case *ModifyMonitoredItemsRequest:
+			resp, err = sess.modifyMonitoredItems(req)
+		case *SetMonitoringModeRequest:
+			resp, err = sess.setMonitoringMode(req)
+		case *DeleteMonitoredItemsRequest:
+			resp, err = sess.deleteMonitoredItems(req)
+		case *PublishRequest:
+			err = sess.publish(ch, requestId, req)
+			if err == nil {
+				return nil
+			}
+		case *RepublishRequest:
+			err = BadMessageNotAvailable
+		default:
+			err = BadServiceUnsupported
+		}
+	}
+	if err != nil {
+		return fault(h, statusOf(err))
+	}
+	rh := resp.header()
+	rh.Timestamp = time.Now()
+	rh.RequestHandle = h.RequestHandle
+	return resp
+}
+
+func (s *Server) application() ApplicationDescription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ApplicationDescription{
+		ApplicationUri:  applicationUri,
+		ProductUri:      productUri,
+		ApplicationName: LocalizedText{Text: "goplc"},
+		ApplicationType: ApplicationTypeServer,
+		DiscoveryUrls:   []string{s.endpoint},
+	}
+}
+
+func (s *Server) endpoints() []EndpointDescription {
+	var policies []UserTokenPolicy
+	if s.config.Anonymous || len(s.config.Users) == 0 {
+		policies = append(policies, UserTokenPolicy{PolicyId: policyAnonymous, TokenType: UserTokenAnonymous})
+	}
+	if len(s.config.Users) > 0 {
+		policies = append(policies, UserTokenPolicy{PolicyId: policyUserName, TokenType: UserTokenUserName})
+	}
+	app := s.application()
+	return []EndpointDescription{{
+		EndpointUrl:         app.DiscoveryUrls[0],
+		Server:              app,
+		SecurityMode:        MessageSecurityModeNone,
+		SecurityPolicyUri:   SecurityPolicyNone,
+		UserIdentityTokens:  policies,
+		TransportProfileUri: TransportProfile,
+	}}
+}
+
+type session struct {
+	id      NodeId
+	token   NodeId
+	channel *channel
+	server  *Server
+
+	mu        sync.Mutex
+	activated bool
+	user      string
+	subs      map[uint32]*subscription
+	publishQ  []*publishRequest
+	closed    bool
+	// timeout is the revised session timeout; timer expires the session
+	// once no request came for that long, the last at last
+	timeout time.Duration
+	timer   *time.Timer
+	last    time.Time
+}
+
+// access is the access level mask of the session user; anonymous
+// sessions may only read.
+func (sess *session) access() uint8 {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.user == "" {
+		return accessRead
+	}
+	return accessRead | accessWrite
+}
+
+func (sess *session) on(ch *channel) bool {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.channel == ch
+}
+
+// touch holds off the expiry of the session for another timeout. The
+// session mutex must be held.
+func (sess *session) touch() {
+	sess.last = time.Now()
+	sess.timer.Reset(sess.timeout)
+}
+
+func (sess *session) close() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.closed = true
+	sess.timer.Stop()
+	for id, sub := range sess.subs {
+		sub.stop()
+		delete(sess.subs, id)
+	}
+	sess.publishQ = nil
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func (s *Server) createSession(ch *channel, req *CreateSessionRequest) (*CreateSessionResponse, error) {
+	timeout := time.Duration(req.RequestedSessionTimeout * float64(time.Millisecond))
+	if timeout < minSessionTimeout {
+		timeout = minSessionTimeout
+	}
+	if timeout > maxSessionTimeout {
+		timeout = maxSessionTimeout
+	}
+	sess := &session{
+		id:      NewNumericNodeId(1, s.nextId()),
+		token:   NodeId{Namespace: 1, Opaque: randomBytes(32), Type: nodeIdByteString},
+		channel: ch,
+		server:  s,
+		subs:    make(map[uint32]*subscription),
+		timeout: timeout,
+		last:    time.Now(),
+	}
+	s.mu.Lock()
+	s.sessions[sess.token.Key()] = sess
+	sess.timer = time.AfterFunc(timeout, func() { s.expire(sess) })
+	s.mu.Unlock()
+	return &CreateSessionResponse{
+		SessionId:             sess.id,
+		AuthenticationToken:   sess.token,
+		RevisedSessionTimeout: float64(timeout / time.Millisecond),
+		ServerNonce:           randomBytes(32),
+		ServerEndpoints:       s.endpoints(),
+		MaxRequestMessageSize: maxMessageSize,
+	}, nil
+}
+
+// expire closes sess if no request came on it for its timeout.
+func (s *Server) expire(sess *session) {
+	s.mu.Lock()
+	sess.mu.Lock()
+	idle := time.Since(sess.last) >= sess.timeout
+	sess.mu.Unlock()
+	if !idle || s.sessions[sess.token.Key()] != sess {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.sessions, sess.token.Key())
+	s.mu.Unlock()
+	sess.close()
+}
+
+func (s *Server) lookup(token NodeId) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token.Key()]
+	if !ok {
+		return nil, BadSessionIdInvalid
+	}
+	return sess, nil
+}
+
+// session returns the activated session of token, which must belong to
+// the channel the request came on.
+func (s *Server) session(ch *channel, token NodeId) (*session, error) {
+	sess, err := s.lookup(token)
+	if err != nil {
+		return nil, err
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.channel != ch {
+		return nil, BadSecureChannelIdInvalid
+	}
+	if !sess.activated {
+		return nil, BadSessionNotActivated
+	}
+	sess.touch()
+	return sess, nil
+}
+
+func (s *Server) activateSession(ch *channel, req *ActivateSessionRequest) (*ActivateSessionResponse, error) {
+	sess, err := s.lookup(req.RequestHeader.AuthenticationToken)
+	if err != nil {
+		return nil, err
+	}
+	var user string
+	switch token := req.UserIdentityToken.Value.(type) {
+	case nil, *AnonymousIdentityToken:
+		if !s.config.Anonymous && len(s.config.Users) > 0 {
+			return nil, BadIdentityTokenRejected
+		}
+	case *UserNameIdentityToken:
+		if token.EncryptionAlgorithm != "" {
+			return nil, BadIdentityTokenInvalid
+		}
+		password, ok := s.config.Users[token.UserName]
+		if !ok || subtle.ConstantTimeCompare([]byte(password), token.Password) != 1 {
+			return nil, BadUserAccessDenied
+		}
+		user = token.UserName
+	default:
+		return nil, BadIdentityTokenInvalid
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.activated && sess.channel != ch {
+		return nil, BadSecureChannelIdInvalid
+	}
+	sess.channel = ch
+	sess.activated = true
+	sess.user = user
+	sess.touch()
+	return &ActivateSessionResponse{ServerNonce: randomBytes(32)}, nil
+}
+
+func (s *Server) closeSession(sess *session, req *CloseSessionRequest) (*CloseSessionResponse, error) {
+	s.mu.Lock()
+	delete(s.sessions, sess.token.Key())
+	s.mu.Unlock()
+	sess.close()
+	return &CloseSessionResponse{}, nil
+}
+
+func (s *Server) browse(req *BrowseRequest) (*BrowseResponse, error) {
+	if len(req.NodesToBrowse) == 0 {
+		return nil, BadNothingToDo
+	}
+	resp := &BrowseResponse{Results: make([]BrowseResult, len(req.NodesToBrowse))}
+	for i := range req.NodesToBrowse {
+		desc := &req.NodesToBrowse[i]
+		n, ok := s.space.node(desc.NodeId)
+		switch {
+		case !ok:
+			resp.Results[i].StatusCode = BadNodeIdUnknown
+		case desc.BrowseDirection < BrowseDirectionForward || desc.BrowseDirection > BrowseDirectionBoth:
+			resp.Results[i].StatusCode = BadBrowseDirectionInvalid
+		default:
+			// the address space is small enough to never need
+			// continuation points
+			resp.Results[i].References = s.space.browse(n, desc)
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) browseNext(req *BrowseNextRequest) (*BrowseNextResponse, error) {
+	if len(req.ContinuationPoints) == 0 {
+		return nil, BadNothingToDo
+	}
+	resp := &BrowseNextResponse{Results: make([]BrowseResult, len(req.ContinuationPoints))}
+	for i := range resp.Results {
+		resp.Results[i].StatusCode = BadContinuationPointInvalid
+	}
+	return resp, nil
+}
+
+func (s *Server) translate(req *TranslateBrowsePathsToNodeIdsRequest) (*TranslateBrowsePathsToNodeIdsResponse, error) {
+	if len(req.BrowsePaths) == 0 {
+		return nil, BadNothingToDo
+	}
+	resp := &TranslateBrowsePathsToNodeIdsResponse{Results: make([]BrowsePathResult, len(req.BrowsePaths))}
+	for i, path := range req.BrowsePaths {
+		start, ok := s.space.node(path.StartingNode)
+		if !ok {
+			resp.Results[i].StatusCode = BadNodeIdUnknown
+			continue
+		}
+		if len(path.RelativePath.Elements) == 0 {
+			resp.Results[i].StatusCode = BadNothingToDo
+			continue
+		}
+		nodes := []*node{start}
+		for j := range path.RelativePath.Elements {
+			var next []*node
+			for _, n := range nodes {
+				next = append(next, s.space.follow(n, &path.RelativePath.Elements[j])...)
+			}
+			nodes = next
+		}
+		if len(nodes) == 0 {
+			resp.Results[i].StatusCode = BadNoMatch
+		}
+		for _, n := range nodes {
+			resp.Results[i].Targets = append(resp.Results[i].Targets, BrowsePathTarget{
+				TargetId:           ExpandedNodeId{NodeId: n.id},
+				RemainingPathIndex: 0xFFFFFFFF,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// readTags reads the values of tag variables, one request per PLC.
+func (s *Server) readTags(nodes []*node) []DataValue {
+	values := make([]DataValue, len(nodes))
+	for _, group := range groupByPlc(nodes) {
+		req := &pb.RWReq{Plc: nodes[group[0]].plc.Pb()}
+		for _, i := range group {
+			req.Tags = append(req.Tags, nodes[i].tag.Pb())
+		}
+		now := time.Now()
+		_, err := s.server.ReadTags(context.Background(), req)
+		for j, i := range group {
+			values[i] = tagValue(req.Tags[j], now, err)
+		}
+	}
+	return values
+}
+
+// tagValue turns a tag read at t into a data value.
+func tagValue(tag *pb.Tag, t time.Time, err error) DataValue {
+	if err != nil || tag.GetErr() != "" {
+		return DataValue{Status: BadCommunicationError, SourceTimestamp: t}
+	}
+	return DataValue{Value: Variant{Value: variantValue(tag)}, SourceTimestamp: t}
+}
+
+// groupByPlc returns the indexes of nodes grouped by PLC, in order.
+func groupByPlc(nodes []*node) [][]int {
+	var groups [][]int
+	index := make(map[string]int)
+	for i, n := range nodes {
+		name := n.plc.GetName()
+		g, ok := index[name]
+		if !ok {
+			g = len(groups)
+			index[name] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// timestamps keeps the timestamps asked for.
+func timestamps(dv DataValue, which int32, now time.Time) DataValue {
+	switch which {
+	case TimestampsSource:
+		dv.ServerTimestamp = time.Time{}
+	case TimestampsServer:
+		dv.SourceTimestamp = time.Time{}
+		dv.ServerTimestamp = now
+	case TimestampsBoth:
+		dv.ServerTimestamp = now
+	case TimestampsNeither:
+		dv.SourceTimestamp = time.Time{}
+		dv.ServerTimestamp = time.Time{}
+	}
+	return dv
+}
+
+func (s *Server) read(sess *session, req *ReadRequest) (*ReadResponse, error) {
+	if len(req.NodesToRead) == 0 {
+		return nil, BadNothingToDo
+	}
+	if req.TimestampsToReturn < TimestampsSource || req.TimestampsToReturn > TimestampsNeither {
+		return nil, BadTimestampsToReturnInvalid
+	}
+	now := time.Now()
+	access := sess.access()
+	resp := &ReadResponse{Results: make([]DataValue, len(req.NodesToRead))}
+	var tagNodes []*node
+	var tagIndexes []int
+	for i, rv := range req.NodesToRead {
+		n, ok := s.space.node(rv.NodeId)
+		switch {
+		case !ok:
+			resp.Results[i] = DataValue{Status: BadNodeIdUnknown}
+		case rv.IndexRange != "":
+			resp.Results[i] = DataValue{Status: BadIndexRangeInvalid}
+		case rv.AttributeId == AttributeValue && n.class == NodeClassVariable:
+			if n.isTag() {
+				tagNodes = append(tagNodes, n)
+				tagIndexes = append(tagIndexes, i)
+				continue
+			}
+			resp.Results[i] = timestamps(DataValue{Value: Variant{Value: n.value()}, SourceTimestamp: now}, req.TimestampsToReturn, now)
+		default:
+			v, code := n.attribute(rv.AttributeId, access)
+			resp.Results[i] = DataValue{Value: Variant{Value: v}, Status: code}
+		}
+	}
+	for j, dv := range s.readTags(tagNodes) {
+		resp.Results[tagIndexes[j]] = timestamps(dv, req.TimestampsToReturn, now)
+	}
+	return resp, nil
+}
+
+func (s *Server) write(sess *session, req *WriteRequest) (*WriteResponse, error) {
+	if len(req.NodesToWrite) == 0 {
+		return nil, BadNothingToDo
+	}
+	access := sess.access()
+	resp := &WriteResponse{Results: make([]StatusCode, len(req.NodesToWrite))}
+	var nodes []*node
+	var tags []*pb.Tag
+	var indexes []int
+	for i, wv := range req.NodesToWrite {
+		n, ok := s.space.node(wv.NodeId)
+		switch {
+		case !ok:
+			resp.Results[i] = BadNodeIdUnknown
+			continue
+		case wv.AttributeId != AttributeValue || !n.isTag() || !n.tag.Writable:
+			resp.Results[i] = BadNotWritable
+			continue
+		case access&accessWrite == 0:
+			resp.Results[i] = BadUserAccessDenied
+			continue
+		case wv.IndexRange != "":
+			resp.Results[i] = BadIndexRangeInvalid
+			continue
+		}
+		tag := n.tag.Pb()
+		if err := setVariantValue(tag, wv.Value.Value.Value); err != nil {
+			if code, ok := err.(StatusCode); ok {
+				resp.Results[i] = code
+			} else {
+				resp.Results[i] = BadOutOfRange
+			}
+			continue
+		}
+		nodes = append(nodes, n)
+		tags = append(tags, tag)
+		indexes = append(indexes, i)
+	}
+	for _, group := range groupByPlc(nodes) {
+		req := &pb.RWReq{Plc: nodes[group[0]].plc.Pb()}
+		for _, i := range group {
+			req.Tags = append(req.Tags, tags[i])
+		}
+		code := Good
+		if _, err := s.server.WriteTags(context.Background(), req); err != nil {
+			code = BadCommunicationError
+			if status.Code(err) == codes.PermissionDenied {
+				code = BadUserAccessDenied
+			}
+		}
+		for _, i := range group {
+			resp.Results[indexes[i]] = code
+		}
+	}
+	return resp, nil
+}
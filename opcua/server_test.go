@@ -0,0 +1,303 @@
+package opcua
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	_ "github.com/thinkontrolsy/goplc/s7"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+func TestCodec(t *testing.T) {
+	now := time.Date(2020, 4, 1, 12, 30, 0, 500, time.UTC).Truncate(100)
+	in := &ReadResponse{
+		ResponseHeader: ResponseHeader{Timestamp: now, RequestHandle: 7, StringTable: []string{"a"}},
+		Results: []DataValue{
+			{Value: Variant{Value: float32(12.5)}, SourceTimestamp: now, HasValue: true},
+			{Value: Variant{Value: []string{"x", "y"}}, HasValue: true},
+			{Value: Variant{Value: NewStringNodeId(1, "line1.DB10.speed")}, HasValue: true},
+			{Value: Variant{Value: LocalizedText{Text: "speed"}}, HasValue: true},
+			{Status: BadNodeIdUnknown},
+		},
+	}
+	out, err := decodeMessage(encodeMessage(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("round trip:\n got %+v\nwant %+v", out, in)
+	}
+}
+
+type testClient struct {
+	t       *testing.T
+	conn    net.Conn
+	channel uint32
+	token   uint32
+	seq     uint32
+	request uint32
+	auth    NodeId
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testClient{t: t, conn: conn}
+	writeChunk(conn, msgHello, chunkFinal, encode(&hello{ReceiveBufferSize: 8192, SendBufferSize: 8192, EndpointUrl: "opc.tcp://" + addr}))
+	if ch, err := readChunk(conn); err != nil || ch.typ != msgAcknowledge {
+		t.Fatalf("hello: %v %v", ch, err)
+	}
+	resp := c.send(msgOpen, &OpenSecureChannelRequest{SecurityMode: MessageSecurityModeNone}).(*OpenSecureChannelResponse)
+	c.channel, c.token = resp.SecurityToken.ChannelId, resp.SecurityToken.TokenId
+	return c
+}
+
+func (c *testClient) send(typ string, req interface{}) interface{} {
+	c.t.Helper()
+	c.seq++
+	c.request++
+	var security []byte
+	if typ == msgOpen {
+		security = encode(&asymmetricHeader{SecurityPolicyUri: SecurityPolicyNone})
+	} else {
+		security = make([]byte, 4)
+		binary.LittleEndian.PutUint32(security, c.token)
+	}
+	channelId := make([]byte, 4)
+	binary.LittleEndian.PutUint32(channelId, c.channel)
+	seq := encode(&sequenceHeader{SequenceNumber: c.seq, RequestId: c.request})
+	if err := writeChunk(c.conn, typ, chunkFinal, channelId, security, seq, encodeMessage(req)); err != nil {
+		c.t.Fatal(err)
+	}
+	var body []byte
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		ch, err := readChunk(c.conn)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if ch.typ != typ {
+			c.t.Fatalf("got %s message, want %s", ch.typ, typ)
+		}
+		d := decoder{b: ch.body[4:]}
+		if typ == msgOpen {
+			d.decode(&asymmetricHeader{})
+		} else {
+			d.uint32()
+		}
+		var sh sequenceHeader
+		d.decode(&sh)
+		if sh.RequestId != c.request {
+			c.t.Fatalf("response to request %d, want %d", sh.RequestId, c.request)
+		}
+		body = append(body, d.b...)
+		if ch.chunkType == chunkFinal {
+			break
+		}
+	}
+	resp, err := decodeMessage(body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp
+}
+
+func (c *testClient) call(req request) interface{} {
+	c.t.Helper()
+	req.header().AuthenticationToken = c.auth
+	return c.send(msgMessage, req)
+}
+
+func (c *testClient) session(identity interface{}) StatusCode {
+	c.t.Helper()
+	resp := c.call(&CreateSessionRequest{SessionName: "test"}).(*CreateSessionResponse)
+	c.auth = resp.AuthenticationToken
+	var token ExtensionObject
+	if identity != nil {
+		token = NewExtensionObject(identity)
+	}
+	switch resp := c.call(&ActivateSessionRequest{UserIdentityToken: token}).(type) {
+	case *ServiceFault:
+		return resp.ResponseHeader.ServiceResult
+	case *ActivateSessionResponse:
+		return Good
+	}
+	return BadUnexpectedError
+}
+
+func (c *testClient) read(id NodeId, attribute uint32) DataValue {
+	c.t.Helper()
+	resp, ok := c.call(&ReadRequest{NodesToRead: []ReadValueId{{NodeId: id, AttributeId: attribute}}}).(*ReadResponse)
+	if !ok {
+		c.t.Fatal("read failed")
+	}
+	return resp.Results[0]
+}
+
+func (c *testClient) write(id NodeId, v interface{}) StatusCode {
+	c.t.Helper()
+	resp, ok := c.call(&WriteRequest{NodesToWrite: []WriteValue{{
+		NodeId:      id,
+		AttributeId: AttributeValue,
+		Value:       DataValue{Value: Variant{Value: v}},
+	}}}).(*WriteResponse)
+	if !ok {
+		c.t.Fatal("write failed")
+	}
+	return resp.Results[0]
+}
+
+func (c *testClient) publish() []MonitoredItemNotification {
+	c.t.Helper()
+	for {
+		resp, ok := c.call(&PublishRequest{}).(*PublishResponse)
+		if !ok {
+			c.t.Fatal("publish failed")
+		}
+		if data := resp.NotificationMessage.NotificationData; len(data) > 0 {
+			return data[0].Value.(*DataChangeNotification).MonitoredItems
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	plc := plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB10P0", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+		&pb.Tag{Address: "DB10P4", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: -2}},
+		&pb.Tag{Address: "MP0.1", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+	)
+	plcs := config.Plcs{{Name: "line1", Host: "127.0.0.1", Tags: []config.Tag{
+		{Name: "speed", Address: "DB10P0", Dt: "Real"},
+		{Name: "setpoint", Address: "DB10P4", Dt: "Int", Writable: true},
+		{Name: "running", Address: "MP0.1", Dt: "Bool"},
+	}}}
+	server, err := NewServer(Config{Anonymous: true, Users: map[string]string{"mes": "secret"}}, plcs, plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	speed := NewStringNodeId(1, "line1.DB10.speed")
+	setpoint := NewStringNodeId(1, "line1.DB10.setpoint")
+
+	anonymous := dial(t, l.Addr().String())
+	if code := anonymous.session(nil); code != Good {
+		t.Fatalf("anonymous session: %v", code)
+	}
+	browse := anonymous.call(&BrowseRequest{NodesToBrowse: []BrowseDescription{
+		{NodeId: NewStringNodeId(1, "line1"), BrowseDirection: BrowseDirectionForward, ReferenceTypeId: std(refHierarchical), IncludeSubtypes: true},
+	}}).(*BrowseResponse)
+	var folders []string
+	for _, ref := range browse.Results[0].References {
+		folders = append(folders, ref.BrowseName.Name)
+	}
+	if !reflect.DeepEqual(folders, []string{"DB10", "M"}) {
+		t.Fatalf("line1 folders: %v", folders)
+	}
+	if v := anonymous.read(speed, AttributeValue); v.Status != Good || v.Value.Value != float32(12.5) {
+		t.Fatalf("read speed: %+v", v)
+	}
+	if v := anonymous.read(setpoint, AttributeDataType); !reflect.DeepEqual(v.Value.Value, std(DataTypeInt16)) {
+		t.Fatalf("setpoint data type: %+v", v)
+	}
+	if code := anonymous.write(setpoint, int16(7)); code != BadUserAccessDenied {
+		t.Fatalf("anonymous write: %v", code)
+	}
+
+	if code := dial(t, l.Addr().String()).session(&UserNameIdentityToken{UserName: "mes", Password: []byte("wrong")}); code != BadUserAccessDenied {
+		t.Fatalf("wrong password: %v", code)
+	}
+	mes := dial(t, l.Addr().String())
+	if code := mes.session(&UserNameIdentityToken{UserName: "mes", Password: []byte("secret")}); code != Good {
+		t.Fatalf("user session: %v", code)
+	}
+	if code := mes.write(setpoint, int16(7)); code != Good {
+		t.Fatalf("write setpoint: %v", code)
+	}
+	if got := plc.Get("DB10P4").GetValueInteger(); got != 7 {
+		t.Fatalf("DB10P4 = %d", got)
+	}
+	if code := mes.write(setpoint, int32(7)); code != BadTypeMismatch {
+		t.Fatalf("write Int32 to Int: %v", code)
+	}
+	if code := mes.write(speed, float32(1)); code != BadNotWritable {
+		t.Fatalf("write read only tag: %v", code)
+	}
+
+	sub := mes.call(&CreateSubscriptionRequest{RequestedPublishingInterval: 100, PublishingEnabled: true}).(*CreateSubscriptionResponse)
+	items := mes.call(&CreateMonitoredItemsRequest{
+		SubscriptionId: sub.SubscriptionId,
+		ItemsToCreate: []MonitoredItemCreateRequest{{
+			ItemToMonitor:       ReadValueId{NodeId: speed, AttributeId: AttributeValue},
+			MonitoringMode:      MonitoringModeReporting,
+			RequestedParameters: MonitoringParameters{ClientHandle: 42, SamplingInterval: 100},
+		}},
+	}).(*CreateMonitoredItemsResponse)
+	if items.Results[0].StatusCode != Good {
+		t.Fatalf("create monitored item: %v", items.Results[0].StatusCode)
+	}
+	n := mes.publish()
+	if len(n) != 1 || n[0].ClientHandle != 42 || n[0].Value.Value.Value != float32(12.5) {
+		t.Fatalf("first notification: %+v", n)
+	}
+	plc.Set(&pb.Tag{Address: "DB10P0", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 20}})
+	if n := mes.publish(); n[0].Value.Value.Value != float32(20) {
+		t.Fatalf("change notification: %+v", n)
+	}
+	plc.SetOffline(true)
+	if n := mes.publish(); n[0].Value.Status != BadCommunicationError {
+		t.Fatalf("offline notification: %+v", n)
+	}
+}
+
+func TestSessionTimeout(t *testing.T) {
+	defer func(d time.Duration) { minSessionTimeout = d }(minSessionTimeout)
+	minSessionTimeout = 200 * time.Millisecond
+	plc := plctest.NewMemoryPlc(&pb.Tag{Address: "DB10P0", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}})
+	plcs := config.Plcs{{Name: "line1", Host: "127.0.0.1", Tags: []config.Tag{{Name: "speed", Address: "DB10P0", Dt: "Real"}}}}
+	server, err := NewServer(Config{Anonymous: true}, plcs, plc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	c := dial(t, l.Addr().String())
+	resp := c.call(&CreateSessionRequest{SessionName: "test", RequestedSessionTimeout: 1}).(*CreateSessionResponse)
+	if resp.RevisedSessionTimeout != 200 {
+		t.Fatalf("revised session timeout %v", resp.RevisedSessionTimeout)
+	}
+	c.auth = resp.AuthenticationToken
+	if _, ok := c.call(&ActivateSessionRequest{}).(*ActivateSessionResponse); !ok {
+		t.Fatal("activate failed")
+	}
+	speed := NewStringNodeId(1, "line1.DB10.speed")
+	// requests keep the session open past its timeout
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if v := c.read(speed, AttributeValue); v.Status != Good {
+			t.Fatalf("read %d: %+v", i, v)
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+	f, ok := c.call(&ReadRequest{NodesToRead: []ReadValueId{{NodeId: speed, AttributeId: AttributeValue}}}).(*ServiceFault)
+	if !ok || f.ResponseHeader.ServiceResult != BadSessionIdInvalid {
+		t.Fatalf("read on an idle session: %+v", f)
+	}
+}
@@ -0,0 +1,701 @@
+package opcua
+
+import (
+	"reflect"
+	"time"
+)
+
+// The structures below follow OPC UA Part 4 field by field; the binary
+// codec encodes them in declaration order.
+
+type RequestHeader struct {
+	AuthenticationToken NodeId
+	Timestamp           time.Time
+	RequestHandle       uint32
+	ReturnDiagnostics   uint32
+	AuditEntryId        string
+	TimeoutHint         uint32
+	AdditionalHeader    ExtensionObject
+}
+
+type ResponseHeader struct {
+	Timestamp          time.Time
+	RequestHandle      uint32
+	ServiceResult      StatusCode
+	ServiceDiagnostics DiagnosticInfo
+	StringTable        []string
+	AdditionalHeader   ExtensionObject
+}
+
+// request and response give the dispatcher access to the headers.
+type request interface {
+	header() *RequestHeader
+}
+
+type response interface {
+	header() *ResponseHeader
+}
+
+type ServiceFault struct {
+	ResponseHeader ResponseHeader
+}
+
+const (
+	SecurityTokenIssue = 0
+	SecurityTokenRenew = 1
+
+	MessageSecurityModeNone = 1
+
+	ApplicationTypeServer = 0
+
+	UserTokenAnonymous = 0
+	UserTokenUserName  = 1
+
+	SecurityPolicyNone = "http://opcfoundation.org/UA/SecurityPolicy#None"
+	TransportProfile   = "http://opcfoundation.org/UA-Profile/Transport/uatcp-uasc-uabinary"
+)
+
+type ChannelSecurityToken struct {
+	ChannelId       uint32
+	TokenId         uint32
+	CreatedAt       time.Time
+	RevisedLifetime uint32
+}
+
+type OpenSecureChannelRequest struct {
+	RequestHeader         RequestHeader
+	ClientProtocolVersion uint32
+	RequestType           int32
+	SecurityMode          int32
+	ClientNonce           []byte
+	RequestedLifetime     uint32
+}
+
+type OpenSecureChannelResponse struct {
+	ResponseHeader        ResponseHeader
+	ServerProtocolVersion uint32
+	SecurityToken         ChannelSecurityToken
+	ServerNonce           []byte
+}
+
+type CloseSecureChannelRequest struct {
+	RequestHeader RequestHeader
+}
+
+type ApplicationDescription struct {
+	ApplicationUri      string
+	ProductUri          string
+	ApplicationName     LocalizedText
+	ApplicationType     int32
+	GatewayServerUri    string
+	DiscoveryProfileUri string
+	DiscoveryUrls       []string
+}
+
+type UserTokenPolicy struct {
+	PolicyId          string
+	TokenType         int32
+	IssuedTokenType   string
+	IssuerEndpointUrl string
+	SecurityPolicyUri string
+}
+
+type EndpointDescription struct {
+	EndpointUrl         string
+	Server              ApplicationDescription
+	ServerCertificate   []byte
+	SecurityMode        int32
+	SecurityPolicyUri   string
+	UserIdentityTokens  []UserTokenPolicy
+	TransportProfileUri string
+	SecurityLevel       uint8
+}
+
+type GetEndpointsRequest struct {
+	RequestHeader RequestHeader
+	EndpointUrl   string
+	LocaleIds     []string
+	ProfileUris   []string
+}
+
+type GetEndpointsResponse struct {
+	ResponseHeader ResponseHeader
+	Endpoints      []EndpointDescription
+}
+
+type FindServersRequest struct {
+	RequestHeader RequestHeader
+	EndpointUrl   string
+	LocaleIds     []string
+	ServerUris    []string
+}
+
+type FindServersResponse struct {
+	ResponseHeader ResponseHeader
+	Servers        []ApplicationDescription
+}
+
+type SignedSoftwareCertificate struct {
+	CertificateData []byte
+	Signature       []byte
+}
+
+type SignatureData struct {
+	Algorithm string
+	Signature []byte
+}
+
+type CreateSessionRequest struct {
+	RequestHeader           RequestHeader
+	ClientDescription       ApplicationDescription
+	ServerUri               string
+	EndpointUrl             string
+	SessionName             string
+	ClientNonce             []byte
+	ClientCertificate       []byte
+	RequestedSessionTimeout float64
+	MaxResponseMessageSize  uint32
+}
+
+type CreateSessionResponse struct {
+	ResponseHeader             ResponseHeader
+	SessionId                  NodeId
+	AuthenticationToken        NodeId
+	RevisedSessionTimeout      float64
+	ServerNonce                []byte
+	ServerCertificate          []byte
+	ServerEndpoints            []EndpointDescription
+	ServerSoftwareCertificates []SignedSoftwareCertificate
+	ServerSignature            SignatureData
+	MaxRequestMessageSize      uint32
+}
+
+type AnonymousIdentityToken struct {
+	PolicyId string
+}
+
+type UserNameIdentityToken struct {
+	PolicyId            string
+	UserName            string
+	Password            []byte
+	EncryptionAlgorithm string
+}
+
+type ActivateSessionRequest struct {
+	RequestHeader              RequestHeader
+	ClientSignature            SignatureData
+	ClientSoftwareCertificates []SignedSoftwareCertificate
+	LocaleIds                  []string
+	UserIdentityToken          ExtensionObject
+	UserTokenSignature         SignatureData
+}
+
+type ActivateSessionResponse struct {
+	ResponseHeader  ResponseHeader
+	ServerNonce     []byte
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type CloseSessionRequest struct {
+	RequestHeader       RequestHeader
+	DeleteSubscriptions bool
+}
+
+type CloseSessionResponse struct {
+	ResponseHeader ResponseHeader
+}
+
+const (
+	BrowseDirectionForward = 0
+	BrowseDirectionInverse = 1
+	BrowseDirectionBoth    = 2
+)
+
+type ViewDescription struct {
+	ViewId      NodeId
+	Timestamp   time.Time
+	ViewVersion uint32
+}
+
+type BrowseDescription struct {
+	NodeId          NodeId
+	BrowseDirection int32
+	ReferenceTypeId NodeId
+	IncludeSubtypes bool
+	NodeClassMask   uint32
+	ResultMask      uint32
+}
+
+type ReferenceDescription struct {
+	ReferenceTypeId NodeId
+	IsForward       bool
+	NodeId          ExpandedNodeId
+	BrowseName      QualifiedName
+	DisplayName     LocalizedText
+	NodeClass       int32
+	TypeDefinition  ExpandedNodeId
+}
+
+type BrowseResult struct {
+	StatusCode        StatusCode
+	ContinuationPoint []byte
+	References        []ReferenceDescription
+}
+
+type BrowseRequest struct {
+	RequestHeader                 RequestHeader
+	View                          ViewDescription
+	RequestedMaxReferencesPerNode uint32
+	NodesToBrowse                 []BrowseDescription
+}
+
+type BrowseResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []BrowseResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type BrowseNextRequest struct {
+	RequestHeader             RequestHeader
+	ReleaseContinuationPoints bool
+	ContinuationPoints        [][]byte
+}
+
+type BrowseNextResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []BrowseResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type RelativePathElement struct {
+	ReferenceTypeId NodeId
+	IsInverse       bool
+	IncludeSubtypes bool
+	TargetName      QualifiedName
+}
+
+type RelativePath struct {
+	Elements []RelativePathElement
+}
+
+type BrowsePath struct {
+	StartingNode NodeId
+	RelativePath RelativePath
+}
+
+type BrowsePathTarget struct {
+	TargetId           ExpandedNodeId
+	RemainingPathIndex uint32
+}
+
+type BrowsePathResult struct {
+	StatusCode StatusCode
+	Targets    []BrowsePathTarget
+}
+
+type TranslateBrowsePathsToNodeIdsRequest struct {
+	RequestHeader RequestHeader
+	BrowsePaths   []BrowsePath
+}
+
+type TranslateBrowsePathsToNodeIdsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []BrowsePathResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+const (
+	TimestampsSource  = 0
+	TimestampsServer  = 1
+	TimestampsBoth    = 2
+	TimestampsNeither = 3
+)
+
+type ReadValueId struct {
+	NodeId       NodeId
+	AttributeId  uint32
+	IndexRange   string
+	DataEncoding QualifiedName
+}
+
+type ReadRequest struct {
+	RequestHeader      RequestHeader
+	MaxAge             float64
+	TimestampsToReturn int32
+	NodesToRead        []ReadValueId
+}
+
+type ReadResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []DataValue
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type WriteValue struct {
+	NodeId      NodeId
+	AttributeId uint32
+	IndexRange  string
+	Value       DataValue
+}
+
+type WriteRequest struct {
+	RequestHeader RequestHeader
+	NodesToWrite  []WriteValue
+}
+
+type WriteResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type CreateSubscriptionRequest struct {
+	RequestHeader               RequestHeader
+	RequestedPublishingInterval float64
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	PublishingEnabled           bool
+	Priority                    uint8
+}
+
+type CreateSubscriptionResponse struct {
+	ResponseHeader            ResponseHeader
+	SubscriptionId            uint32
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+type ModifySubscriptionRequest struct {
+	RequestHeader               RequestHeader
+	SubscriptionId              uint32
+	RequestedPublishingInterval float64
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	Priority                    uint8
+}
+
+type ModifySubscriptionResponse struct {
+	ResponseHeader            ResponseHeader
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+type SetPublishingModeRequest struct {
+	RequestHeader     RequestHeader
+	PublishingEnabled bool
+	SubscriptionIds   []uint32
+}
+
+type SetPublishingModeResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type DeleteSubscriptionsRequest struct {
+	RequestHeader   RequestHeader
+	SubscriptionIds []uint32
+}
+
+type DeleteSubscriptionsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+const (
+	MonitoringModeDisabled  = 0
+	MonitoringModeSampling  = 1
+	MonitoringModeReporting = 2
+)
+
+type MonitoringParameters struct {
+	ClientHandle     uint32
+	SamplingInterval float64
+	Filter           ExtensionObject
+	QueueSize        uint32
+	DiscardOldest    bool
+}
+
+type MonitoredItemCreateRequest struct {
+	ItemToMonitor       ReadValueId
+	MonitoringMode      int32
+	RequestedParameters MonitoringParameters
+}
+
+type MonitoredItemCreateResult struct {
+	StatusCode              StatusCode
+	MonitoredItemId         uint32
+	RevisedSamplingInterval float64
+	RevisedQueueSize        uint32
+	FilterResult            ExtensionObject
+}
+
+type CreateMonitoredItemsRequest struct {
+	RequestHeader      RequestHeader
+	SubscriptionId     uint32
+	TimestampsToReturn int32
+	ItemsToCreate      []MonitoredItemCreateRequest
+}
+
+type CreateMonitoredItemsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []MonitoredItemCreateResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type MonitoredItemModifyRequest struct {
+	MonitoredItemId     uint32
+	RequestedParameters MonitoringParameters
+}
+
+type MonitoredItemModifyResult struct {
+	StatusCode              StatusCode
+	RevisedSamplingInterval float64
+	RevisedQueueSize        uint32
+	FilterResult            ExtensionObject
+}
+
+type ModifyMonitoredItemsRequest struct {
+	RequestHeader      RequestHeader
+	SubscriptionId     uint32
+	TimestampsToReturn int32
+	ItemsToModify      []MonitoredItemModifyRequest
+}
+
+type ModifyMonitoredItemsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []MonitoredItemModifyResult
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type SetMonitoringModeRequest struct {
+	RequestHeader    RequestHeader
+	SubscriptionId   uint32
+	MonitoringMode   int32
+	MonitoredItemIds []uint32
+}
+
+type SetMonitoringModeResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type DeleteMonitoredItemsRequest struct {
+	RequestHeader    RequestHeader
+	SubscriptionId   uint32
+	MonitoredItemIds []uint32
+}
+
+type DeleteMonitoredItemsResponse struct {
+	ResponseHeader  ResponseHeader
+	Results         []StatusCode
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type SubscriptionAcknowledgement struct {
+	SubscriptionId uint32
+	SequenceNumber uint32
+}
+
+type NotificationMessage struct {
+	SequenceNumber   uint32
+	PublishTime      time.Time
+	NotificationData []ExtensionObject
+}
+
+type MonitoredItemNotification struct {
+	ClientHandle uint32
+	Value        DataValue
+}
+
+type DataChangeNotification struct {
+	MonitoredItems  []MonitoredItemNotification
+	DiagnosticInfos []DiagnosticInfo
+}
+
+type PublishRequest struct {
+	RequestHeader                RequestHeader
+	SubscriptionAcknowledgements []SubscriptionAcknowledgement
+}
+
+type PublishResponse struct {
+	ResponseHeader           ResponseHeader
+	SubscriptionId           uint32
+	AvailableSequenceNumbers []uint32
+	MoreNotifications        bool
+	NotificationMessage      NotificationMessage
+	Results                  []StatusCode
+	DiagnosticInfos          []DiagnosticInfo
+}
+
+type RepublishRequest struct {
+	RequestHeader            RequestHeader
+	SubscriptionId           uint32
+	RetransmitSequenceNumber uint32
+}
+
+type RepublishResponse struct {
+	ResponseHeader      ResponseHeader
+	NotificationMessage NotificationMessage
+}
+
+type BuildInfo struct {
+	ProductUri       string
+	ManufacturerName string
+	ProductName      string
+	SoftwareVersion  string
+	BuildNumber      string
+	BuildDate        time.Time
+}
+
+type ServerStatusDataType struct {
+	StartTime           time.Time
+	CurrentTime         time.Time
+	State               int32
+	BuildInfo           BuildInfo
+	SecondsTillShutdown uint32
+	ShutdownReason      LocalizedText
+}
+
+// encodingIds maps every structure that travels in an ExtensionObject or a
+// message body to the numeric id of its DefaultBinary encoding node.
+var encodingIds = map[reflect.Type]uint32{
+	reflect.TypeOf(AnonymousIdentityToken{}):                321,
+	reflect.TypeOf(UserNameIdentityToken{}):                 324,
+	reflect.TypeOf(ServiceFault{}):                          397,
+	reflect.TypeOf(FindServersRequest{}):                    422,
+	reflect.TypeOf(FindServersResponse{}):                   425,
+	reflect.TypeOf(GetEndpointsRequest{}):                   428,
+	reflect.TypeOf(GetEndpointsResponse{}):                  431,
+	reflect.TypeOf(OpenSecureChannelRequest{}):              446,
+	reflect.TypeOf(OpenSecureChannelResponse{}):             449,
+	reflect.TypeOf(CloseSecureChannelRequest{}):             452,
+	reflect.TypeOf(CreateSessionRequest{}):                  461,
+	reflect.TypeOf(CreateSessionResponse{}):                 464,
+	reflect.TypeOf(ActivateSessionRequest{}):                467,
+	reflect.TypeOf(ActivateSessionResponse{}):               470,
+	reflect.TypeOf(CloseSessionRequest{}):                   473,
+	reflect.TypeOf(CloseSessionResponse{}):                  476,
+	reflect.TypeOf(BrowseRequest{}):                         527,
+	reflect.TypeOf(BrowseResponse{}):                        530,
+	reflect.TypeOf(BrowseNextRequest{}):                     533,
+	reflect.TypeOf(BrowseNextResponse{}):                    536,
+	reflect.TypeOf(TranslateBrowsePathsToNodeIdsRequest{}):  554,
+	reflect.TypeOf(TranslateBrowsePathsToNodeIdsResponse{}): 557,
+	reflect.TypeOf(ReadRequest{}):                           631,
+	reflect.TypeOf(ReadResponse{}):                          634,
+	reflect.TypeOf(WriteRequest{}):                          673,
+	reflect.TypeOf(WriteResponse{}):                         676,
+	reflect.TypeOf(CreateMonitoredItemsRequest{}):           751,
+	reflect.TypeOf(CreateMonitoredItemsResponse{}):          754,
+	reflect.TypeOf(ModifyMonitoredItemsRequest{}):           763,
+	reflect.TypeOf(ModifyMonitoredItemsResponse{}):          766,
+	reflect.TypeOf(SetMonitoringModeRequest{}):              769,
+	reflect.TypeOf(SetMonitoringModeResponse{}):             772,
+	reflect.TypeOf(DeleteMonitoredItemsRequest{}):           781,
+	reflect.TypeOf(DeleteMonitoredItemsResponse{}):          784,
+	reflect.TypeOf(CreateSubscriptionRequest{}):             787,
+	reflect.TypeOf(CreateSubscriptionResponse{}):            790,
+	reflect.TypeOf(ModifySubscriptionRequest{}):             793,
+	reflect.TypeOf(ModifySubscriptionResponse{}):            796,
+	reflect.TypeOf(SetPublishingModeRequest{}):              799,
+	reflect.TypeOf(SetPublishingModeResponse{}):             802,
+	reflect.TypeOf(DataChangeNotification{}):                811,
+	reflect.TypeOf(PublishRequest{}):                        826,
+	reflect.TypeOf(PublishResponse{}):                       829,
+	reflect.TypeOf(RepublishRequest{}):                      832,
+	reflect.TypeOf(RepublishResponse{}):                     835,
+	reflect.TypeOf(DeleteSubscriptionsRequest{}):            847,
+	reflect.TypeOf(DeleteSubscriptionsResponse{}):           850,
+	reflect.TypeOf(ServerStatusDataType{}):                  864,
+}
+
+var encodingTypes = make(map[uint32]reflect.Type)
+
+func init() {
+	for t, id := range encodingIds {
+		encodingTypes[id] = t
+	}
+}
+
+// encodeMessage encodes a service request or response preceded by the node
+// id of its encoding.
+func encodeMessage(v interface{}) []byte {
+	var e encoder
+	NewExtensionObject(v).TypeId.encode(&e)
+	e.encode(v)
+	return e.Bytes()
+}
+
+// decodeMessage decodes a service request or response body into a pointer
+// to a newly allocated structure.
+func decodeMessage(b []byte) (interface{}, error) {
+	d := decoder{b: b}
+	var id NodeId
+	id.decode(&d)
+	if d.err != nil {
+		return nil, d.err
+	}
+	t, ok := encodingTypes[id.Numeric]
+	if !ok || id.Namespace != 0 {
+		return nil, BadServiceUnsupported
+	}
+	v := reflect.New(t)
+	if err := d.decode(v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func (r *OpenSecureChannelRequest) header() *RequestHeader             { return &r.RequestHeader }
+func (r *CloseSecureChannelRequest) header() *RequestHeader            { return &r.RequestHeader }
+func (r *GetEndpointsRequest) header() *RequestHeader                  { return &r.RequestHeader }
+func (r *FindServersRequest) header() *RequestHeader                   { return &r.RequestHeader }
+func (r *CreateSessionRequest) header() *RequestHeader                 { return &r.RequestHeader }
+func (r *ActivateSessionRequest) header() *RequestHeader               { return &r.RequestHeader }
+func (r *CloseSessionRequest) header() *RequestHeader                  { return &r.RequestHeader }
+func (r *BrowseRequest) header() *RequestHeader                        { return &r.RequestHeader }
+func (r *BrowseNextRequest) header() *RequestHeader                    { return &r.RequestHeader }
+func (r *TranslateBrowsePathsToNodeIdsRequest) header() *RequestHeader { return &r.RequestHeader }
+func (r *ReadRequest) header() *RequestHeader                          { return &r.RequestHeader }
+func (r *WriteRequest) header() *RequestHeader                         { return &r.RequestHeader }
+func (r *CreateSubscriptionRequest) header() *RequestHeader            { return &r.RequestHeader }
+func (r *ModifySubscriptionRequest) header() *RequestHeader            { return &r.RequestHeader }
+func (r *SetPublishingModeRequest) header() *RequestHeader             { return &r.RequestHeader }
+func (r *DeleteSubscriptionsRequest) header() *RequestHeader           { return &r.RequestHeader }
+func (r *CreateMonitoredItemsRequest) header() *RequestHeader          { return &r.RequestHeader }
+func (r *ModifyMonitoredItemsRequest) header() *RequestHeader          { return &r.RequestHeader }
+func (r *SetMonitoringModeRequest) header() *RequestHeader             { return &r.RequestHeader }
+func (r *DeleteMonitoredItemsRequest) header() *RequestHeader          { return &r.RequestHeader }
+func (r *PublishRequest) header() *RequestHeader                       { return &r.RequestHeader }
+func (r *RepublishRequest) header() *RequestHeader                     { return &r.RequestHeader }
+
+func (r *ServiceFault) header() *ResponseHeader                          { return &r.ResponseHeader }
+func (r *OpenSecureChannelResponse) header() *ResponseHeader             { return &r.ResponseHeader }
+func (r *GetEndpointsResponse) header() *ResponseHeader                  { return &r.ResponseHeader }
+func (r *FindServersResponse) header() *ResponseHeader                   { return &r.ResponseHeader }
+func (r *CreateSessionResponse) header() *ResponseHeader                 { return &r.ResponseHeader }
+func (r *ActivateSessionResponse) header() *ResponseHeader               { return &r.ResponseHeader }
+func (r *CloseSessionResponse) header() *ResponseHeader                  { return &r.ResponseHeader }
+func (r *BrowseResponse) header() *ResponseHeader                        { return &r.ResponseHeader }
+func (r *BrowseNextResponse) header() *ResponseHeader                    { return &r.ResponseHeader }
+func (r *TranslateBrowsePathsToNodeIdsResponse) header() *ResponseHeader { return &r.ResponseHeader }
+func (r *ReadResponse) header() *ResponseHeader                          { return &r.ResponseHeader }
+func (r *WriteResponse) header() *ResponseHeader                         { return &r.ResponseHeader }
+func (r *CreateSubscriptionResponse) header() *ResponseHeader            { return &r.ResponseHeader }
+func (r *ModifySubscriptionResponse) header() *ResponseHeader            { return &r.ResponseHeader }
+func (r *SetPublishingModeResponse) header() *ResponseHeader             { return &r.ResponseHeader }
+func (r *DeleteSubscriptionsResponse) header() *ResponseHeader           { return &r.ResponseHeader }
+func (r *CreateMonitoredItemsResponse) header() *ResponseHeader          { return &r.ResponseHeader }
+func (r *ModifyMonitoredItemsResponse) header() *ResponseHeader          { return &r.ResponseHeader }
+func (r *SetMonitoringModeResponse) header() *ResponseHeader             { return &r.ResponseHeader }
+func (r *DeleteMonitoredItemsResponse) header() *ResponseHeader          { return &r.ResponseHeader }
+func (r *PublishResponse) header() *ResponseHeader                       { return &r.ResponseHeader }
+func (r *RepublishResponse) header() *ResponseHeader                     { return &r.ResponseHeader }
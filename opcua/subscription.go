@@ -0,0 +1,540 @@
+package opcua
+
+import (
+	"sort"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/poll"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	minPublishingInterval = 100 * time.Millisecond
+	minSamplingInterval   = 100 * time.Millisecond
+	defaultKeepAliveCount = 10
+	maxQueueSize          = 100
+	maxPublishRequests    = 10
+	maxRetained           = 100
+)
+
+type publishRequest struct {
+	ch        *channel
+	requestId uint32
+	handle    uint32
+	results   []StatusCode
+}
+
+type monitoredItem struct {
+	id         uint32
+	handle     uint32
+	node       *node
+	attribute  uint32
+	mode       int32
+	interval   time.Duration
+	queueSize  uint32
+	discard    bool
+	timestamps int32
+
+	queue  []MonitoredItemNotification
+	cancel func()
+}
+
+// subscription sends the notifications of its monitored items every
+// publishing interval, or a keep-alive when there were none for keepAlive
+// intervals. All fields are guarded by the session mutex.
+type subscription struct {
+	id        uint32
+	sess      *session
+	interval  time.Duration
+	lifetime  uint32
+	keepAlive uint32
+	maxNotify uint32
+	enabled   bool
+	items     map[uint32]*monitoredItem
+
+	seq uint32
+	// sent holds the sequence numbers not yet acknowledged
+	sent  map[uint32]struct{}
+	idle  uint32
+	starv uint32
+	done  chan struct{}
+}
+
+func (sub *subscription) revise(interval float64, lifetime, keepAlive uint32) {
+	sub.interval = time.Duration(interval * float64(time.Millisecond))
+	if sub.interval < minPublishingInterval {
+		sub.interval = minPublishingInterval
+	}
+	sub.keepAlive = keepAlive
+	if sub.keepAlive == 0 {
+		sub.keepAlive = defaultKeepAliveCount
+	}
+	sub.lifetime = lifetime
+	if sub.lifetime < 3*sub.keepAlive {
+		sub.lifetime = 3 * sub.keepAlive
+	}
+}
+
+func (s *Server) createSubscription(sess *session, req *CreateSubscriptionRequest) (*CreateSubscriptionResponse, error) {
+	sub := &subscription{
+		id:        s.nextId(),
+		sess:      sess,
+		maxNotify: req.MaxNotificationsPerPublish,
+		enabled:   req.PublishingEnabled,
+		items:     make(map[uint32]*monitoredItem),
+		sent:      make(map[uint32]struct{}),
+		done:      make(chan struct{}),
+	}
+	sub.revise(req.RequestedPublishingInterval, req.RequestedLifetimeCount, req.RequestedMaxKeepAliveCount)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return nil, BadSessionClosed
+	}
+	sess.subs[sub.id] = sub
+	go sub.run()
+	return &CreateSubscriptionResponse{
+		SubscriptionId:            sub.id,
+		RevisedPublishingInterval: float64(sub.interval / time.Millisecond),
+		RevisedLifetimeCount:      sub.lifetime,
+		RevisedMaxKeepAliveCount:  sub.keepAlive,
+	}, nil
+}
+
+func (sess *session) modifySubscription(req *ModifySubscriptionRequest) (*ModifySubscriptionResponse, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub, ok := sess.subs[req.SubscriptionId]
+	if !ok {
+		return nil, BadSubscriptionIdInvalid
+	}
+	sub.revise(req.RequestedPublishingInterval, req.RequestedLifetimeCount, req.RequestedMaxKeepAliveCount)
+	sub.maxNotify = req.MaxNotificationsPerPublish
+	return &ModifySubscriptionResponse{
+		RevisedPublishingInterval: float64(sub.interval / time.Millisecond),
+		RevisedLifetimeCount:      sub.lifetime,
+		RevisedMaxKeepAliveCount:  sub.keepAlive,
+	}, nil
+}
+
+func (sess *session) setPublishingMode(req *SetPublishingModeRequest) (*SetPublishingModeResponse, error) {
+	if len(req.SubscriptionIds) == 0 {
+		return nil, BadNothingToDo
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	resp := &SetPublishingModeResponse{Results: make([]StatusCode, len(req.SubscriptionIds))}
+	for i, id := range req.SubscriptionIds {
+		sub, ok := sess.subs[id]
+		if !ok {
+			resp.Results[i] = BadSubscriptionIdInvalid
+			continue
+		}
+		sub.enabled = req.PublishingEnabled
+	}
+	return resp, nil
+}
+
+func (sess *session) deleteSubscriptions(req *DeleteSubscriptionsRequest) (*DeleteSubscriptionsResponse, error) {
+	if len(req.SubscriptionIds) == 0 {
+		return nil, BadNothingToDo
+	}
+	sess.mu.Lock()
+	resp := &DeleteSubscriptionsResponse{Results: make([]StatusCode, len(req.SubscriptionIds))}
+	for i, id := range req.SubscriptionIds {
+		sub, ok := sess.subs[id]
+		if !ok {
+			resp.Results[i] = BadSubscriptionIdInvalid
+			continue
+		}
+		sub.stop()
+		delete(sess.subs, id)
+	}
+	// queued Publish requests can no longer be answered
+	var orphans []*publishRequest
+	if len(sess.subs) == 0 {
+		orphans = sess.publishQ
+		sess.publishQ = nil
+	}
+	sess.mu.Unlock()
+	for _, pr := range orphans {
+		pr.fail(BadNoSubscription)
+	}
+	return resp, nil
+}
+
+// stop ends the subscription and the sampling of its items; the session
+// mutex must be held.
+func (sub *subscription) stop() {
+	for _, item := range sub.items {
+		item.stop()
+	}
+	close(sub.done)
+}
+
+func (item *monitoredItem) stop() {
+	if item.cancel != nil {
+		item.cancel()
+		item.cancel = nil
+	}
+}
+
+func (s *Server) createMonitoredItems(sess *session, req *CreateMonitoredItemsRequest) (*CreateMonitoredItemsResponse, error) {
+	if len(req.ItemsToCreate) == 0 {
+		return nil, BadNothingToDo
+	}
+	if req.TimestampsToReturn < TimestampsSource || req.TimestampsToReturn > TimestampsNeither {
+		return nil, BadTimestampsToReturnInvalid
+	}
+	access := sess.access()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub, ok := sess.subs[req.SubscriptionId]
+	if !ok {
+		return nil, BadSubscriptionIdInvalid
+	}
+	resp := &CreateMonitoredItemsResponse{Results: make([]MonitoredItemCreateResult, len(req.ItemsToCreate))}
+	for i, create := range req.ItemsToCreate {
+		result := &resp.Results[i]
+		rv := create.ItemToMonitor
+		n, ok := s.space.node(rv.NodeId)
+		if !ok {
+			result.StatusCode = BadNodeIdUnknown
+			continue
+		}
+		if create.MonitoringMode < MonitoringModeDisabled || create.MonitoringMode > MonitoringModeReporting {
+			result.StatusCode = BadMonitoringModeInvalid
+			continue
+		}
+		if rv.AttributeId != AttributeValue || n.class != NodeClassVariable {
+			if _, code := n.attribute(rv.AttributeId, access); code != Good {
+				result.StatusCode = code
+				continue
+			}
+		}
+		item := &monitoredItem{
+			id:         s.nextId(),
+			node:       n,
+			attribute:  rv.AttributeId,
+			mode:       create.MonitoringMode,
+			timestamps: req.TimestampsToReturn,
+		}
+		item.revise(create.RequestedParameters, sub.interval)
+		sub.items[item.id] = item
+		s.sample(sess, item, access)
+		*result = MonitoredItemCreateResult{
+			MonitoredItemId:         item.id,
+			RevisedSamplingInterval: float64(item.interval / time.Millisecond),
+			RevisedQueueSize:        item.queueSize,
+		}
+	}
+	return resp, nil
+}
+
+func (item *monitoredItem) revise(p MonitoringParameters, publishing time.Duration) {
+	item.handle = p.ClientHandle
+	item.discard = p.DiscardOldest
+	item.interval = time.Duration(p.SamplingInterval * float64(time.Millisecond))
+	if p.SamplingInterval < 0 {
+		item.interval = publishing
+	}
+	if item.interval < minSamplingInterval {
+		item.interval = minSamplingInterval
+	}
+	item.queueSize = p.QueueSize
+	if item.queueSize == 0 {
+		item.queueSize = 1
+	}
+	if item.queueSize > maxQueueSize {
+		item.queueSize = maxQueueSize
+	}
+}
+
+// sample starts sampling item unless it is disabled. Tag values come from
+// the shared poller; every other attribute is constant and only sampled
+// once. The session mutex must be held.
+func (s *Server) sample(sess *session, item *monitoredItem, access uint8) {
+	item.stop()
+	if item.mode == MonitoringModeDisabled {
+		return
+	}
+	n := item.node
+	if item.attribute != AttributeValue || !n.isTag() {
+		var dv DataValue
+		if item.attribute == AttributeValue && n.class == NodeClassVariable {
+			dv = DataValue{Value: Variant{Value: n.value()}, SourceTimestamp: time.Now()}
+		} else {
+			v, code := n.attribute(item.attribute, access)
+			dv = DataValue{Value: Variant{Value: v}, Status: code}
+		}
+		item.push(timestamps(dv, item.timestamps, time.Now()))
+		return
+	}
+	item.cancel = s.poller.Subscribe(n.plc.Pb(), []*pb.Tag{n.tag.Pb()}, item.interval, func(samples []poll.Sample) {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		for _, sample := range samples {
+			var err error
+			if sample.Quality != poll.Good {
+				err = sample.Err
+			}
+			item.push(timestamps(tagValue(sample.Tag, sample.Time, err), item.timestamps, time.Now()))
+		}
+	})
+}
+
+// push queues a notification, dropping one when the queue is full.
+func (item *monitoredItem) push(dv DataValue) {
+	notification := MonitoredItemNotification{ClientHandle: item.handle, Value: dv}
+	if uint32(len(item.queue)) < item.queueSize {
+		item.queue = append(item.queue, notification)
+		return
+	}
+	if item.discard {
+		item.queue = append(item.queue[1:], notification)
+	} else {
+		item.queue[len(item.queue)-1] = notification
+	}
+}
+
+func (sess *session) modifyMonitoredItems(req *ModifyMonitoredItemsRequest) (*ModifyMonitoredItemsResponse, error) {
+	if len(req.ItemsToModify) == 0 {
+		return nil, BadNothingToDo
+	}
+	access := sess.access()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub, ok := sess.subs[req.SubscriptionId]
+	if !ok {
+		return nil, BadSubscriptionIdInvalid
+	}
+	resp := &ModifyMonitoredItemsResponse{Results: make([]MonitoredItemModifyResult, len(req.ItemsToModify))}
+	for i, modify := range req.ItemsToModify {
+		item, ok := sub.items[modify.MonitoredItemId]
+		if !ok {
+			resp.Results[i].StatusCode = BadMonitoredItemIdInvalid
+			continue
+		}
+		interval := item.interval
+		item.revise(modify.RequestedParameters, sub.interval)
+		item.timestamps = req.TimestampsToReturn
+		if item.interval != interval {
+			sess.server.sample(sess, item, access)
+		}
+		resp.Results[i] = MonitoredItemModifyResult{
+			RevisedSamplingInterval: float64(item.interval / time.Millisecond),
+			RevisedQueueSize:        item.queueSize,
+		}
+	}
+	return resp, nil
+}
+
+func (sess *session) setMonitoringMode(req *SetMonitoringModeRequest) (*SetMonitoringModeResponse, error) {
+	if len(req.MonitoredItemIds) == 0 {
+		return nil, BadNothingToDo
+	}
+	if req.MonitoringMode < MonitoringModeDisabled || req.MonitoringMode > MonitoringModeReporting {
+		return nil, BadMonitoringModeInvalid
+	}
+	access := sess.access()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub, ok := sess.subs[req.SubscriptionId]
+	if !ok {
+		return nil, BadSubscriptionIdInvalid
+	}
+	resp := &SetMonitoringModeResponse{Results: make([]StatusCode, len(req.MonitoredItemIds))}
+	for i, id := range req.MonitoredItemIds {
+		item, ok := sub.items[id]
+		if !ok {
+			resp.Results[i] = BadMonitoredItemIdInvalid
+			continue
+		}
+		was := item.mode
+		item.mode = req.MonitoringMode
+		switch {
+		case item.mode == MonitoringModeDisabled:
+			item.stop()
+			item.queue = nil
+		case was == MonitoringModeDisabled:
+			sess.server.sample(sess, item, access)
+		}
+	}
+	return resp, nil
+}
+
+func (sess *session) deleteMonitoredItems(req *DeleteMonitoredItemsRequest) (*DeleteMonitoredItemsResponse, error) {
+	if len(req.MonitoredItemIds) == 0 {
+		return nil, BadNothingToDo
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub, ok := sess.subs[req.SubscriptionId]
+	if !ok {
+		return nil, BadSubscriptionIdInvalid
+	}
+	resp := &DeleteMonitoredItemsResponse{Results: make([]StatusCode, len(req.MonitoredItemIds))}
+	for i, id := range req.MonitoredItemIds {
+		item, ok := sub.items[id]
+		if !ok {
+			resp.Results[i] = BadMonitoredItemIdInvalid
+			continue
+		}
+		item.stop()
+		delete(sub.items, id)
+	}
+	return resp, nil
+}
+
+// publish queues a Publish request for the next subscription that has
+// something to send.
+func (sess *session) publish(ch *channel, requestId uint32, req *PublishRequest) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if len(sess.subs) == 0 {
+		return BadNoSubscription
+	}
+	if len(sess.publishQ) >= maxPublishRequests {
+		return BadTooManyPublishRequests
+	}
+	results := make([]StatusCode, len(req.SubscriptionAcknowledgements))
+	for i, ack := range req.SubscriptionAcknowledgements {
+		sub, ok := sess.subs[ack.SubscriptionId]
+		if !ok {
+			results[i] = BadSubscriptionIdInvalid
+			continue
+		}
+		if _, ok := sub.sent[ack.SequenceNumber]; !ok {
+			results[i] = BadSequenceNumberUnknown
+			continue
+		}
+		delete(sub.sent, ack.SequenceNumber)
+	}
+	sess.publishQ = append(sess.publishQ, &publishRequest{
+		ch:        ch,
+		requestId: requestId,
+		handle:    req.RequestHeader.RequestHandle,
+		results:   results,
+	})
+	return nil
+}
+
+func (pr *publishRequest) send(resp response) {
+	h := resp.header()
+	h.Timestamp = time.Now()
+	h.RequestHandle = pr.handle
+	pr.ch.send(msgMessage, pr.requestId, encodeMessage(resp))
+}
+
+func (pr *publishRequest) fail(code StatusCode) {
+	pr.send(fault(&RequestHeader{RequestHandle: pr.handle}, code))
+}
+
+func (sub *subscription) run() {
+	for {
+		sub.sess.mu.Lock()
+		interval := sub.interval
+		sub.sess.mu.Unlock()
+		select {
+		case <-sub.done:
+			return
+		case <-time.After(interval):
+		}
+		sub.sess.mu.Lock()
+		pr, resp := sub.tick()
+		sub.sess.mu.Unlock()
+		if pr != nil {
+			pr.send(resp)
+		}
+	}
+}
+
+// tick runs once per publishing interval with the session mutex held. It
+// returns the Publish request to answer, if any, and its response.
+func (sub *subscription) tick() (*publishRequest, *PublishResponse) {
+	sess := sub.sess
+	select {
+	case <-sub.done:
+		return nil, nil
+	default:
+	}
+	pending := false
+	if sub.enabled {
+		for _, item := range sub.items {
+			if item.mode == MonitoringModeReporting && len(item.queue) > 0 {
+				pending = true
+				break
+			}
+		}
+	}
+	if !pending {
+		sub.idle++
+		if sub.idle < sub.keepAlive {
+			return nil, nil
+		}
+	}
+	if len(sess.publishQ) == 0 {
+		// nobody to send to; give up on the client after lifetime
+		// intervals
+		sub.starv++
+		if sub.starv >= sub.lifetime {
+			sub.stop()
+			delete(sess.subs, sub.id)
+		}
+		return nil, nil
+	}
+	pr := sess.publishQ[0]
+	sess.publishQ = sess.publishQ[1:]
+	sub.idle = 0
+	sub.starv = 0
+
+	resp := &PublishResponse{
+		SubscriptionId: sub.id,
+		Results:        pr.results,
+		NotificationMessage: NotificationMessage{
+			SequenceNumber: sub.seq + 1,
+			PublishTime:    time.Now(),
+		},
+	}
+	if pending {
+		var notifications []MonitoredItemNotification
+		for _, id := range sub.itemIds() {
+			item := sub.items[id]
+			if item.mode != MonitoringModeReporting {
+				continue
+			}
+			for len(item.queue) > 0 && (sub.maxNotify == 0 || uint32(len(notifications)) < sub.maxNotify) {
+				notifications = append(notifications, item.queue[0])
+				item.queue = item.queue[1:]
+			}
+			if len(item.queue) > 0 {
+				resp.MoreNotifications = true
+			}
+		}
+		sub.seq++
+		sub.sent[sub.seq] = struct{}{}
+		if len(sub.sent) > maxRetained {
+			delete(sub.sent, sub.seq-maxRetained)
+		}
+		resp.NotificationMessage.NotificationData = []ExtensionObject{
+			NewExtensionObject(&DataChangeNotification{MonitoredItems: notifications}),
+		}
+	}
+	for seq := range sub.sent {
+		resp.AvailableSequenceNumbers = append(resp.AvailableSequenceNumbers, seq)
+	}
+	sort.Slice(resp.AvailableSequenceNumbers, func(i, j int) bool {
+		return resp.AvailableSequenceNumbers[i] < resp.AvailableSequenceNumbers[j]
+	})
+	return pr, resp
+}
+
+// itemIds returns the ids of the monitored items in creation order.
+func (sub *subscription) itemIds() []uint32 {
+	ids := make([]uint32, 0, len(sub.items))
+	for id := range sub.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
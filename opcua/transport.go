@@ -0,0 +1,256 @@
+package opcua
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// OPC UA Part 6 TCP transport: every message starts with a three letter
+// type, a chunk type and the message size including this header.
+const (
+	headerLength = 8
+
+	msgHello       = "HEL"
+	msgAcknowledge = "ACK"
+	msgError       = "ERR"
+	msgOpen        = "OPN"
+	msgMessage     = "MSG"
+	msgClose       = "CLO"
+
+	chunkFinal        = 'F'
+	chunkIntermediate = 'C'
+	chunkAbort        = 'A'
+
+	protocolVersion = 0
+	bufferSize      = 65536
+	maxMessageSize  = 16 << 20
+	maxChunkCount   = 512
+)
+
+type hello struct {
+	ProtocolVersion   uint32
+	ReceiveBufferSize uint32
+	SendBufferSize    uint32
+	MaxMessageSize    uint32
+	MaxChunkCount     uint32
+	EndpointUrl       string
+}
+
+type acknowledge struct {
+	ProtocolVersion   uint32
+	ReceiveBufferSize uint32
+	SendBufferSize    uint32
+	MaxMessageSize    uint32
+	MaxChunkCount     uint32
+}
+
+type tcpError struct {
+	Error  StatusCode
+	Reason string
+}
+
+type asymmetricHeader struct {
+	SecurityPolicyUri             string
+	SenderCertificate             []byte
+	ReceiverCertificateThumbprint []byte
+}
+
+type sequenceHeader struct {
+	SequenceNumber uint32
+	RequestId      uint32
+}
+
+// chunk is one transport message after its header.
+type chunk struct {
+	typ       string
+	chunkType byte
+	body      []byte
+}
+
+func readChunk(r io.Reader) (*chunk, error) {
+	header, err := readFull(r, headerLength)
+	if err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[4:])
+	if size < headerLength || size > bufferSize {
+		return nil, BadTcpMessageTypeInvalid
+	}
+	body, err := readFull(r, int(size-headerLength))
+	if err != nil {
+		return nil, err
+	}
+	return &chunk{typ: string(header[:3]), chunkType: header[3], body: body}, nil
+}
+
+func writeChunk(w io.Writer, typ string, chunkType byte, parts ...[]byte) error {
+	size := headerLength
+	for _, p := range parts {
+		size += len(p)
+	}
+	b := make([]byte, headerLength, size)
+	copy(b, typ)
+	b[3] = chunkType
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// channel is a secure channel with security policy None over one TCP
+// connection.
+type channel struct {
+	conn      net.Conn
+	id        uint32
+	tokenId   uint32
+	chunkSize int
+	maxChunks int
+
+	// partial holds the chunks of messages not yet complete, by request id
+	partial map[uint32][]byte
+
+	mu  sync.Mutex
+	seq uint32
+}
+
+// handshake answers the Hello message that opens every connection.
+func (ch *channel) handshake() error {
+	ch.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer ch.conn.SetReadDeadline(time.Time{})
+	c, err := readChunk(ch.conn)
+	if err != nil {
+		return err
+	}
+	if c.typ != msgHello {
+		ch.fail(BadTcpMessageTypeInvalid, "expected HEL")
+		return BadTcpMessageTypeInvalid
+	}
+	var h hello
+	if err := decode(c.body, &h); err != nil {
+		return err
+	}
+	ch.chunkSize = bufferSize
+	if h.ReceiveBufferSize > 0 && int(h.ReceiveBufferSize) < ch.chunkSize {
+		ch.chunkSize = int(h.ReceiveBufferSize)
+	}
+	if ch.chunkSize < 8192 {
+		ch.chunkSize = 8192
+	}
+	ch.maxChunks = int(h.MaxChunkCount)
+	ack := acknowledge{
+		ProtocolVersion:   protocolVersion,
+		ReceiveBufferSize: bufferSize,
+		SendBufferSize:    uint32(ch.chunkSize),
+		MaxMessageSize:    maxMessageSize,
+		MaxChunkCount:     maxChunkCount,
+	}
+	return writeChunk(ch.conn, msgAcknowledge, chunkFinal, encode(&ack))
+}
+
+// fail sends an error message; the connection is closed afterwards.
+func (ch *channel) fail(code StatusCode, reason string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	writeChunk(ch.conn, msgError, chunkFinal, encode(&tcpError{Error: code, Reason: reason}))
+}
+
+// message is a complete OPN, MSG or CLO message.
+type message struct {
+	typ       string
+	requestId uint32
+	body      []byte
+}
+
+// read returns the next complete message, collecting its chunks.
+func (ch *channel) read() (*message, error) {
+	for {
+		c, err := readChunk(ch.conn)
+		if err != nil {
+			return nil, err
+		}
+		d := decoder{b: c.body}
+		switch c.typ {
+		case msgOpen:
+			d.uint32()
+			var h asymmetricHeader
+			d.decode(&h)
+			if d.err == nil && h.SecurityPolicyUri != SecurityPolicyNone {
+				ch.fail(BadSecurityPolicyRejected, h.SecurityPolicyUri)
+				return nil, BadSecurityPolicyRejected
+			}
+		case msgMessage, msgClose:
+			if id, token := d.uint32(), d.uint32(); d.err == nil && (id != ch.id || token != ch.tokenId) {
+				ch.fail(BadSecureChannelIdInvalid, "unknown secure channel")
+				return nil, BadSecureChannelIdInvalid
+			}
+		default:
+			ch.fail(BadTcpMessageTypeInvalid, c.typ)
+			return nil, BadTcpMessageTypeInvalid
+		}
+		var seq sequenceHeader
+		d.decode(&seq)
+		if d.err != nil {
+			return nil, d.err
+		}
+
+		body := append(ch.partial[seq.RequestId], d.b...)
+		switch c.chunkType {
+		case chunkIntermediate:
+			if len(body) > maxMessageSize {
+				return nil, BadTcpMessageTypeInvalid
+			}
+			ch.partial[seq.RequestId] = body
+			continue
+		case chunkAbort:
+			delete(ch.partial, seq.RequestId)
+			continue
+		}
+		delete(ch.partial, seq.RequestId)
+		return &message{typ: c.typ, requestId: seq.RequestId, body: body}, nil
+	}
+}
+
+// send writes a response to requestId, split into as many chunks as the
+// client's receive buffer requires.
+func (ch *channel) send(typ string, requestId uint32, body []byte) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	var security []byte
+	if typ == msgOpen {
+		security = encode(&asymmetricHeader{SecurityPolicyUri: SecurityPolicyNone})
+	} else {
+		security = make([]byte, 4)
+		binary.LittleEndian.PutUint32(security, ch.tokenId)
+	}
+	channelId := make([]byte, 4)
+	binary.LittleEndian.PutUint32(channelId, ch.id)
+
+	max := ch.chunkSize - headerLength - 4 - len(security) - 8
+	chunks := (len(body) + max - 1) / max
+	if ch.maxChunks > 0 && chunks > ch.maxChunks {
+		return fmt.Errorf("opcua: response of %d bytes exceeds %d chunks", len(body), ch.maxChunks)
+	}
+	for {
+		n := len(body)
+		chunkType := byte(chunkFinal)
+		if n > max {
+			n = max
+			chunkType = chunkIntermediate
+		}
+		ch.seq++
+		seq := encode(&sequenceHeader{SequenceNumber: ch.seq, RequestId: requestId})
+		if err := writeChunk(ch.conn, typ, chunkType, channelId, security, seq, body[:n]); err != nil {
+			return err
+		}
+		body = body[n:]
+		if chunkType == chunkFinal {
+			return nil
+		}
+	}
+}
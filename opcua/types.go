@@ -0,0 +1,521 @@
+package opcua
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// StatusCode is an OPC UA status code; the top two bits give the severity.
+type StatusCode uint32
+
+const (
+	Good                         StatusCode = 0
+	BadUnexpectedError           StatusCode = 0x80010000
+	BadInternalError             StatusCode = 0x80020000
+	BadCommunicationError        StatusCode = 0x80050000
+	BadDecodingError             StatusCode = 0x80070000
+	BadServiceUnsupported        StatusCode = 0x800B0000
+	BadNothingToDo               StatusCode = 0x800F0000
+	BadTooManyOperations         StatusCode = 0x80100000
+	BadUserAccessDenied          StatusCode = 0x801F0000
+	BadIdentityTokenInvalid      StatusCode = 0x80200000
+	BadIdentityTokenRejected     StatusCode = 0x80210000
+	BadSecureChannelIdInvalid    StatusCode = 0x80220000
+	BadSessionIdInvalid          StatusCode = 0x80250000
+	BadSessionClosed             StatusCode = 0x80260000
+	BadSessionNotActivated       StatusCode = 0x80270000
+	BadSubscriptionIdInvalid     StatusCode = 0x80280000
+	BadTimestampsToReturnInvalid StatusCode = 0x802B0000
+	BadNoCommunication           StatusCode = 0x80310000
+	BadWaitingForInitialData     StatusCode = 0x80320000
+	BadNodeIdInvalid             StatusCode = 0x80330000
+	BadNodeIdUnknown             StatusCode = 0x80340000
+	BadAttributeIdInvalid        StatusCode = 0x80350000
+	BadIndexRangeInvalid         StatusCode = 0x80360000
+	BadNotReadable               StatusCode = 0x803A0000
+	BadNotWritable               StatusCode = 0x803B0000
+	BadOutOfRange                StatusCode = 0x803C0000
+	BadNotSupported              StatusCode = 0x803D0000
+	BadMonitoringModeInvalid     StatusCode = 0x80410000
+	BadMonitoredItemIdInvalid    StatusCode = 0x80420000
+	BadContinuationPointInvalid  StatusCode = 0x804A0000
+	BadReferenceTypeIdInvalid    StatusCode = 0x804C0000
+	BadBrowseDirectionInvalid    StatusCode = 0x804D0000
+	BadSecurityPolicyRejected    StatusCode = 0x80550000
+	BadNoMatch                   StatusCode = 0x806F0000
+	BadTypeMismatch              StatusCode = 0x80740000
+	BadTooManyPublishRequests    StatusCode = 0x80780000
+	BadNoSubscription            StatusCode = 0x80790000
+	BadSequenceNumberUnknown     StatusCode = 0x807A0000
+	BadMessageNotAvailable       StatusCode = 0x807B0000
+	BadTcpMessageTypeInvalid     StatusCode = 0x807E0000
+	BadTcpInternalError          StatusCode = 0x80820000
+)
+
+func (s StatusCode) Error() string {
+	return fmt.Sprintf("opcua: status 0x%08X", uint32(s))
+}
+
+func (s StatusCode) IsBad() bool {
+	return s&0x80000000 != 0
+}
+
+const (
+	nodeIdTwoByte    = 0x00
+	nodeIdFourByte   = 0x01
+	nodeIdNumeric    = 0x02
+	nodeIdString     = 0x03
+	nodeIdGuid       = 0x04
+	nodeIdByteString = 0x05
+)
+
+// NodeId identifies a node by a numeric or string identifier within a
+// namespace. Guid and opaque identifiers are kept as raw bytes. Type is
+// zero for numeric identifiers.
+type NodeId struct {
+	Namespace uint16
+	Numeric   uint32
+	String    string
+	Opaque    []byte
+	Type      byte
+}
+
+func NewNumericNodeId(ns uint16, id uint32) NodeId {
+	return NodeId{Namespace: ns, Numeric: id}
+}
+
+func NewStringNodeId(ns uint16, id string) NodeId {
+	return NodeId{Namespace: ns, String: id, Type: nodeIdString}
+}
+
+func (n NodeId) IsNull() bool {
+	return n.Namespace == 0 && n.Numeric == 0 && n.String == "" && n.Opaque == nil
+}
+
+// Key returns a comparable form of the node id.
+func (n NodeId) Key() string {
+	switch n.Type {
+	case nodeIdString:
+		return fmt.Sprintf("ns=%d;s=%s", n.Namespace, n.String)
+	case nodeIdGuid:
+		return fmt.Sprintf("ns=%d;g=%x", n.Namespace, n.Opaque)
+	case nodeIdByteString:
+		return fmt.Sprintf("ns=%d;b=%x", n.Namespace, n.Opaque)
+	}
+	return fmt.Sprintf("ns=%d;i=%d", n.Namespace, n.Numeric)
+}
+
+func (n NodeId) encode(e *encoder) {
+	n.encodeWithFlags(e, 0)
+}
+
+func (n NodeId) encodeWithFlags(e *encoder, flags byte) {
+	switch n.Type {
+	case nodeIdString:
+		e.uint8(nodeIdString | flags)
+		e.uint16(n.Namespace)
+		e.string(n.String)
+	case nodeIdGuid:
+		e.uint8(nodeIdGuid | flags)
+		e.uint16(n.Namespace)
+		b := make([]byte, 16)
+		copy(b, n.Opaque)
+		e.Write(b)
+	case nodeIdByteString:
+		e.uint8(nodeIdByteString | flags)
+		e.uint16(n.Namespace)
+		e.byteString(n.Opaque)
+	default:
+		switch {
+		case n.Namespace == 0 && n.Numeric <= 0xFF:
+			e.uint8(nodeIdTwoByte | flags)
+			e.uint8(uint8(n.Numeric))
+		case n.Namespace <= 0xFF && n.Numeric <= 0xFFFF:
+			e.uint8(nodeIdFourByte | flags)
+			e.uint8(uint8(n.Namespace))
+			e.uint16(uint16(n.Numeric))
+		default:
+			e.uint8(nodeIdNumeric | flags)
+			e.uint16(n.Namespace)
+			e.uint32(n.Numeric)
+		}
+	}
+}
+
+func (n *NodeId) decode(d *decoder) {
+	n.decodeWithFlags(d)
+}
+
+func (n *NodeId) decodeWithFlags(d *decoder) byte {
+	b := d.uint8()
+	*n = NodeId{}
+	switch b & 0x3F {
+	case nodeIdTwoByte:
+		n.Numeric = uint32(d.uint8())
+	case nodeIdFourByte:
+		n.Namespace = uint16(d.uint8())
+		n.Numeric = uint32(d.uint16())
+	case nodeIdNumeric:
+		n.Namespace = d.uint16()
+		n.Numeric = d.uint32()
+	case nodeIdString:
+		n.Type = nodeIdString
+		n.Namespace = d.uint16()
+		n.String = d.string()
+	case nodeIdGuid:
+		n.Type = nodeIdGuid
+		n.Namespace = d.uint16()
+		n.Opaque = append([]byte(nil), d.read(16)...)
+	case nodeIdByteString:
+		n.Type = nodeIdByteString
+		n.Namespace = d.uint16()
+		n.Opaque = d.byteString()
+	default:
+		d.err = errDecoding
+	}
+	return b & 0xC0
+}
+
+// ExpandedNodeId is a NodeId that may name its namespace by URI or live on
+// another server. goplc only produces local ones.
+type ExpandedNodeId struct {
+	NodeId       NodeId
+	NamespaceUri string
+	ServerIndex  uint32
+}
+
+func (n ExpandedNodeId) encode(e *encoder) {
+	var flags byte
+	if n.NamespaceUri != "" {
+		flags |= 0x80
+	}
+	if n.ServerIndex != 0 {
+		flags |= 0x40
+	}
+	n.NodeId.encodeWithFlags(e, flags)
+	if n.NamespaceUri != "" {
+		e.string(n.NamespaceUri)
+	}
+	if n.ServerIndex != 0 {
+		e.uint32(n.ServerIndex)
+	}
+}
+
+func (n *ExpandedNodeId) decode(d *decoder) {
+	flags := n.NodeId.decodeWithFlags(d)
+	if flags&0x80 != 0 {
+		n.NamespaceUri = d.string()
+	}
+	if flags&0x40 != 0 {
+		n.ServerIndex = d.uint32()
+	}
+}
+
+type QualifiedName struct {
+	NamespaceIndex uint16
+	Name           string
+}
+
+type LocalizedText struct {
+	Locale string
+	Text   string
+}
+
+func (l LocalizedText) encode(e *encoder) {
+	var mask byte
+	if l.Locale != "" {
+		mask |= 0x01
+	}
+	if l.Text != "" {
+		mask |= 0x02
+	}
+	e.uint8(mask)
+	if l.Locale != "" {
+		e.string(l.Locale)
+	}
+	if l.Text != "" {
+		e.string(l.Text)
+	}
+}
+
+func (l *LocalizedText) decode(d *decoder) {
+	mask := d.uint8()
+	*l = LocalizedText{}
+	if mask&0x01 != 0 {
+		l.Locale = d.string()
+	}
+	if mask&0x02 != 0 {
+		l.Text = d.string()
+	}
+}
+
+// ExtensionObject carries a structure identified by the node id of its
+// binary encoding. Value holds the decoded structure when its type is
+// registered, Body the raw bytes otherwise.
+type ExtensionObject struct {
+	TypeId NodeId
+	Body   []byte
+	Value  interface{}
+}
+
+func NewExtensionObject(v interface{}) ExtensionObject {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	id, ok := encodingIds[t]
+	if !ok {
+		panic(fmt.Sprintf("opcua: %T has no encoding id", v))
+	}
+	return ExtensionObject{TypeId: NewNumericNodeId(0, id), Value: v}
+}
+
+func (x ExtensionObject) encode(e *encoder) {
+	body := x.Body
+	if x.Value != nil {
+		body = encode(x.Value)
+	}
+	x.TypeId.encode(e)
+	if body == nil {
+		e.uint8(0x00)
+		return
+	}
+	e.uint8(0x01)
+	e.byteString(body)
+}
+
+func (x *ExtensionObject) decode(d *decoder) {
+	*x = ExtensionObject{}
+	x.TypeId.decode(d)
+	switch d.uint8() {
+	case 0x00:
+		return
+	case 0x01, 0x02:
+		x.Body = d.byteString()
+	default:
+		d.err = errDecoding
+		return
+	}
+	if t, ok := encodingTypes[x.TypeId.Numeric]; ok && x.TypeId.Namespace == 0 && x.Body != nil {
+		v := reflect.New(t)
+		if err := decode(x.Body, v.Interface()); err == nil {
+			x.Value = v.Interface()
+		}
+	}
+}
+
+// DiagnosticInfo is never produced; incoming ones are skipped.
+type DiagnosticInfo struct{}
+
+func (DiagnosticInfo) encode(e *encoder) {
+	e.uint8(0)
+}
+
+func (di *DiagnosticInfo) decode(d *decoder) {
+	mask := d.uint8()
+	for _, bit := range []byte{0x01, 0x02, 0x04, 0x08} {
+		if mask&bit != 0 {
+			d.int32()
+		}
+	}
+	if mask&0x10 != 0 {
+		d.string()
+	}
+	if mask&0x20 != 0 {
+		d.uint32()
+	}
+	if mask&0x40 != 0 {
+		di.decode(d)
+	}
+}
+
+// Variant type ids.
+const (
+	TypeBoolean        = 1
+	TypeSByte          = 2
+	TypeByte           = 3
+	TypeInt16          = 4
+	TypeUInt16         = 5
+	TypeInt32          = 6
+	TypeUInt32         = 7
+	TypeInt64          = 8
+	TypeUInt64         = 9
+	TypeFloat          = 10
+	TypeDouble         = 11
+	TypeString         = 12
+	TypeDateTime       = 13
+	TypeGuid           = 14
+	TypeByteString     = 15
+	TypeXmlElement     = 16
+	TypeNodeId         = 17
+	TypeExpandedNodeId = 18
+	TypeStatusCode     = 19
+	TypeQualifiedName  = 20
+	TypeLocalizedText  = 21
+	TypeExtensionObj   = 22
+	TypeDataValue      = 23
+	TypeVariant        = 24
+	TypeDiagnosticInfo = 25
+)
+
+var variantTypes = map[byte]reflect.Type{
+	TypeBoolean:        reflect.TypeOf(false),
+	TypeSByte:          reflect.TypeOf(int8(0)),
+	TypeByte:           reflect.TypeOf(uint8(0)),
+	TypeInt16:          reflect.TypeOf(int16(0)),
+	TypeUInt16:         reflect.TypeOf(uint16(0)),
+	TypeInt32:          reflect.TypeOf(int32(0)),
+	TypeUInt32:         reflect.TypeOf(uint32(0)),
+	TypeInt64:          reflect.TypeOf(int64(0)),
+	TypeUInt64:         reflect.TypeOf(uint64(0)),
+	TypeFloat:          reflect.TypeOf(float32(0)),
+	TypeDouble:         reflect.TypeOf(float64(0)),
+	TypeString:         reflect.TypeOf(""),
+	TypeDateTime:       timeType,
+	TypeByteString:     reflect.TypeOf([]byte(nil)),
+	TypeXmlElement:     reflect.TypeOf([]byte(nil)),
+	TypeNodeId:         reflect.TypeOf(NodeId{}),
+	TypeExpandedNodeId: reflect.TypeOf(ExpandedNodeId{}),
+	TypeStatusCode:     reflect.TypeOf(StatusCode(0)),
+	TypeQualifiedName:  reflect.TypeOf(QualifiedName{}),
+	TypeLocalizedText:  reflect.TypeOf(LocalizedText{}),
+	TypeExtensionObj:   reflect.TypeOf(ExtensionObject{}),
+	TypeDataValue:      reflect.TypeOf(DataValue{}),
+	TypeVariant:        reflect.TypeOf(Variant{}),
+	TypeDiagnosticInfo: reflect.TypeOf(DiagnosticInfo{}),
+}
+
+func variantTypeOf(t reflect.Type) (byte, bool) {
+	for id, vt := range variantTypes {
+		if vt == t && id != TypeXmlElement {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Variant holds a scalar or one-dimensional array of a built-in type, as
+// the matching Go type: bool, int8 ... uint64, float32, float64, string,
+// time.Time, []byte, NodeId, StatusCode, LocalizedText and so on. A nil
+// Value is the null variant.
+type Variant struct {
+	Value interface{}
+}
+
+func (v Variant) encode(e *encoder) {
+	if v.Value == nil {
+		e.uint8(0)
+		return
+	}
+	rv := reflect.ValueOf(v.Value)
+	if id, ok := variantTypeOf(rv.Type()); ok {
+		e.uint8(id)
+		e.value(rv)
+		return
+	}
+	if rv.Kind() == reflect.Slice {
+		if id, ok := variantTypeOf(rv.Type().Elem()); ok {
+			e.uint8(id | 0x80)
+			e.int32(int32(rv.Len()))
+			for i := 0; i < rv.Len(); i++ {
+				e.value(rv.Index(i))
+			}
+			return
+		}
+	}
+	panic(fmt.Sprintf("opcua: %T is not a variant type", v.Value))
+}
+
+func (v *Variant) decode(d *decoder) {
+	mask := d.uint8()
+	v.Value = nil
+	if mask == 0 {
+		return
+	}
+	t, ok := variantTypes[mask&0x3F]
+	if !ok {
+		d.err = errDecoding
+		return
+	}
+	if mask&0x80 == 0 {
+		rv := reflect.New(t).Elem()
+		d.value(rv)
+		v.Value = rv.Interface()
+		return
+	}
+	n := d.length()
+	if n < 0 {
+		n = 0
+	}
+	rv := reflect.MakeSlice(reflect.SliceOf(t), n, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		d.value(rv.Index(i))
+	}
+	v.Value = rv.Interface()
+	if mask&0x40 != 0 {
+		var dims []int32
+		d.value(reflect.ValueOf(&dims).Elem())
+	}
+}
+
+type DataValue struct {
+	Value           Variant
+	Status          StatusCode
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+	HasValue        bool
+}
+
+func (dv DataValue) encode(e *encoder) {
+	var mask byte
+	if dv.HasValue || dv.Value.Value != nil {
+		mask |= 0x01
+	}
+	if dv.Status != Good {
+		mask |= 0x02
+	}
+	if !dv.SourceTimestamp.IsZero() {
+		mask |= 0x04
+	}
+	if !dv.ServerTimestamp.IsZero() {
+		mask |= 0x08
+	}
+	e.uint8(mask)
+	if mask&0x01 != 0 {
+		dv.Value.encode(e)
+	}
+	if mask&0x02 != 0 {
+		e.uint32(uint32(dv.Status))
+	}
+	if mask&0x04 != 0 {
+		e.time(dv.SourceTimestamp)
+	}
+	if mask&0x08 != 0 {
+		e.time(dv.ServerTimestamp)
+	}
+}
+
+func (dv *DataValue) decode(d *decoder) {
+	mask := d.uint8()
+	*dv = DataValue{}
+	if mask&0x01 != 0 {
+		dv.HasValue = true
+		dv.Value.decode(d)
+	}
+	if mask&0x02 != 0 {
+		dv.Status = StatusCode(d.uint32())
+	}
+	if mask&0x04 != 0 {
+		dv.SourceTimestamp = d.time()
+	}
+	if mask&0x10 != 0 {
+		d.uint16()
+	}
+	if mask&0x08 != 0 {
+		dv.ServerTimestamp = d.time()
+	}
+	if mask&0x20 != 0 {
+		d.uint16()
+	}
+}
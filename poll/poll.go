@@ -0,0 +1,102 @@
+// Package poll reads tag groups periodically through the regular ReadTags
+// path and reports the tags whose value or quality changed.
+package poll
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	Good = "good"
+	Bad  = "bad"
+)
+
+// Reader is the read side of PlcServer.
+type Reader interface {
+	ReadTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error)
+}
+
+type Group struct {
+	Plc      *pb.Plc
+	Tags     []*pb.Tag
+	Interval time.Duration
+}
+
+// Sample is one tag value read at Time. On a bad read Tag keeps the last
+// good value, if there was one.
+type Sample struct {
+	Index   int
+	Tag     *pb.Tag
+	Time    time.Time
+	Quality string
+	Err     error
+}
+
+// Watch polls g every g.Interval until ctx is done. After each poll fn is
+// called with the samples that differ from the previous poll; the first
+// poll reports every tag.
+func Watch(ctx context.Context, r Reader, g Group, fn func([]Sample)) {
+	last := make([]*Sample, len(g.Tags))
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		if changed := poll(ctx, r, g, last); len(changed) > 0 {
+			fn(changed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func poll(ctx context.Context, r Reader, g Group, last []*Sample) []Sample {
+	tags, now, err := read(ctx, r, g.Plc, g.Tags)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return diff(tags, now, err, last)
+}
+
+// read reads fresh copies of tags, so that the caller's tags are never
+// written to.
+func read(ctx context.Context, r Reader, plc *pb.Plc, tags []*pb.Tag) ([]*pb.Tag, time.Time, error) {
+	fresh := make([]*pb.Tag, len(tags))
+	for i, tag := range tags {
+		fresh[i] = &pb.Tag{Address: tag.GetAddress(), Dt: tag.GetDt()}
+	}
+	now := time.Now()
+	_, err := r.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: fresh})
+	return fresh, now, err
+}
+
+// diff turns a read into samples, updates last and returns the samples
+// that differ from it.
+func diff(tags []*pb.Tag, now time.Time, err error, last []*Sample) []Sample {
+	var changed []Sample
+	for i, tag := range tags {
+		s := Sample{Index: i, Tag: tag, Time: now, Quality: Good}
+		if err != nil || tag.GetErr() != "" {
+			s.Quality = Bad
+			s.Err = err
+			if s.Err == nil {
+				s.Err = errors.New(tag.GetErr())
+			}
+			if last[i] != nil {
+				s.Tag = last[i].Tag
+			}
+		}
+		if last[i] == nil || last[i].Quality != s.Quality || !proto.Equal(last[i].Tag, s.Tag) {
+			changed = append(changed, s)
+		}
+		last[i] = &s
+	}
+	return changed
+}
@@ -0,0 +1,75 @@
+package poll
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+type countingReader struct {
+	*plctest.MemoryPlc
+	reads int32
+	tags  int32
+}
+
+func (r *countingReader) ReadTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
+	atomic.AddInt32(&r.reads, 1)
+	atomic.StoreInt32(&r.tags, int32(len(req.GetTags())))
+	return r.MemoryPlc.ReadTags(ctx, req)
+}
+
+func TestShared(t *testing.T) {
+	r := &countingReader{MemoryPlc: plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB2P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 1}},
+		&pb.Tag{Address: "DB2P2", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 2}},
+	)}
+	shared := NewShared(r)
+	plc := &pb.Plc{Host: "127.0.0.1"}
+
+	a := make(chan []Sample, 16)
+	b := make(chan []Sample, 16)
+	cancelA := shared.Subscribe(plc, []*pb.Tag{{Address: "DB2P0", Dt: "Int"}}, 10*time.Millisecond, func(s []Sample) { a <- s })
+	cancelB := shared.Subscribe(plc, []*pb.Tag{{Address: "DB2P0", Dt: "Int"}, {Address: "DB2P2", Dt: "Int"}}, 10*time.Millisecond, func(s []Sample) { b <- s })
+
+	next := func(c chan []Sample) []Sample {
+		select {
+		case s := <-c:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+		return nil
+	}
+	if s := next(a); len(s) != 1 || s[0].Tag.GetValueInteger() != 1 {
+		t.Fatalf("a: %v", s)
+	}
+	for got := 0; got < 2; {
+		got += len(next(b))
+	}
+
+	r.Set(&pb.Tag{Address: "DB2P2", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 3}})
+	if s := next(b); len(s) != 1 || s[0].Index != 1 || s[0].Tag.GetValueInteger() != 3 {
+		t.Fatalf("b: %v", s)
+	}
+	if n := atomic.LoadInt32(&r.tags); n != 2 {
+		t.Fatalf("union read %d tags, want 2", n)
+	}
+
+	r.SetOffline(true)
+	if s := next(a); s[0].Quality != Bad || s[0].Tag.GetValueInteger() != 1 {
+		t.Fatalf("a offline: %v", s)
+	}
+
+	cancelA()
+	cancelB()
+	time.Sleep(50 * time.Millisecond)
+	reads := atomic.LoadInt32(&r.reads)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&r.reads) != reads {
+		t.Fatal("loop still reading after the last subscriber left")
+	}
+}
@@ -0,0 +1,162 @@
+package poll
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// Shared merges every subscription to the same PLC into one read loop: the
+// union of the subscribed tags is read at the shortest subscribed interval
+// and each subscriber is told about its own tags at its own interval.
+type Shared struct {
+	reader Reader
+
+	mu    sync.Mutex
+	loops map[string]*loop
+}
+
+type subscriber struct {
+	tags     []*pb.Tag
+	interval time.Duration
+	fn       func([]Sample)
+
+	// owned by the loop goroutine
+	last []*Sample
+	next time.Time
+}
+
+type loop struct {
+	plc    *pb.Plc
+	subs   map[*subscriber]struct{}
+	wake   chan struct{}
+	cancel context.CancelFunc
+}
+
+func NewShared(r Reader) *Shared {
+	return &Shared{reader: r, loops: make(map[string]*loop)}
+}
+
+func tagKey(tag *pb.Tag) string {
+	return tag.GetAddress() + " " + tag.GetDt()
+}
+
+// Subscribe calls fn with the changed samples of tags, as Watch does, until
+// the returned function is called. fn runs on the loop goroutine of the
+// PLC and must not block.
+func (s *Shared) Subscribe(plc *pb.Plc, tags []*pb.Tag, interval time.Duration, fn func([]Sample)) (cancel func()) {
+	sub := &subscriber{
+		tags:     tags,
+		interval: interval,
+		fn:       fn,
+		last:     make([]*Sample, len(tags)),
+	}
+	key := proto.CompactTextString(plc)
+
+	s.mu.Lock()
+	l, ok := s.loops[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		l = &loop{
+			plc:    proto.Clone(plc).(*pb.Plc),
+			subs:   make(map[*subscriber]struct{}),
+			wake:   make(chan struct{}, 1),
+			cancel: cancel,
+		}
+		s.loops[key] = l
+		go s.run(ctx, l)
+	}
+	l.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(l.subs, sub)
+			if len(l.subs) == 0 {
+				l.cancel()
+				delete(s.loops, key)
+			}
+		})
+	}
+}
+
+func (s *Shared) run(ctx context.Context, l *loop) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-l.wake:
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		var due []*subscriber
+		var union []*pb.Tag
+		index := make(map[string]int)
+		for sub := range l.subs {
+			if sub.next.After(now) {
+				continue
+			}
+			due = append(due, sub)
+			for _, tag := range sub.tags {
+				if _, ok := index[tagKey(tag)]; !ok {
+					index[tagKey(tag)] = len(union)
+					union = append(union, tag)
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		if len(due) > 0 {
+			tags, readAt, err := read(ctx, s.reader, l.plc, union)
+			if ctx.Err() != nil {
+				return
+			}
+			for _, sub := range due {
+				own := make([]*pb.Tag, len(sub.tags))
+				for i, tag := range sub.tags {
+					own[i] = proto.Clone(tags[index[tagKey(tag)]]).(*pb.Tag)
+				}
+				sub.next = now.Add(sub.interval)
+				if changed := diff(own, readAt, err, sub.last); len(changed) > 0 {
+					sub.fn(changed)
+				}
+			}
+		}
+
+		// sleep until the next subscriber is due; a new subscriber wakes
+		// the loop early
+		s.mu.Lock()
+		wait := time.Hour
+		for sub := range l.subs {
+			if d := time.Until(sub.next); d < wait {
+				wait = d
+			}
+		}
+		s.mu.Unlock()
+		if wait < 0 {
+			wait = 0
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
@@ -0,0 +1,301 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/poll"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	DefaultHeartbeat = 15 * time.Second
+
+	minInterval      = 100 * time.Millisecond
+	maxSubscriptions = 64
+	writeTimeout     = 30 * time.Second
+)
+
+// FeedRequest is a message from a feed client. Type is "subscribe",
+// "unsubscribe" or "ping"; Id names the subscription.
+type FeedRequest struct {
+	Type       string `json:"type"`
+	Id         string `json:"id,omitempty"`
+	Plc        Plc    `json:"plc"`
+	Tags       []Tag  `json:"tags,omitempty"`
+	IntervalMs int64  `json:"interval_ms,omitempty"`
+}
+
+// FeedMessage is a message to a feed client. Type is "subscribed",
+// "unsubscribed", "values", "error", "pong" or "heartbeat".
+type FeedMessage struct {
+	Type   string      `json:"type"`
+	Id     string      `json:"id,omitempty"`
+	Values []FeedValue `json:"values,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Time   *time.Time  `json:"time,omitempty"`
+}
+
+// FeedValue is a changed tag of a subscription. Index is the position of
+// the tag in the subscribe request. A bad value keeps the last good value,
+// if there was one.
+type FeedValue struct {
+	Index   int         `json:"index"`
+	Address string      `json:"address"`
+	Dt      string      `json:"dt"`
+	Value   interface{} `json:"value,omitempty"`
+	Quality string      `json:"quality"`
+	Time    time.Time   `json:"time"`
+	Err     string      `json:"err,omitempty"`
+}
+
+// Feed streams value changes of polled tags to WebSocket clients. All
+// clients share one read loop per PLC. A client that reads slower than its
+// tags change only gets the latest value of each tag.
+type Feed struct {
+	// Heartbeat is the interval of heartbeat messages, DefaultHeartbeat
+	// if zero.
+	Heartbeat time.Duration
+
+	poller *poll.Shared
+}
+
+func NewFeed(r poll.Reader) *Feed {
+	return &Feed{poller: poll.NewShared(r)}
+}
+
+// ServeHTTP upgrades the request to a WebSocket. Origins are not checked,
+// so that dashboards may be served from anywhere.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   f.serve,
+	}.ServeHTTP(w, r)
+}
+
+type feedSub struct {
+	cancel  func()
+	pending []*poll.Sample
+	dirty   bool
+}
+
+type feedConn struct {
+	feed *Feed
+	ws   *websocket.Conn
+	wake chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	subs    map[string]*feedSub
+	control []FeedMessage
+}
+
+func (f *Feed) serve(ws *websocket.Conn) {
+	ws.MaxPayloadBytes = 1 << 20
+	c := &feedConn{
+		feed: f,
+		ws:   ws,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+		subs: make(map[string]*feedSub),
+	}
+	go c.writeLoop()
+	defer c.close()
+	for {
+		var req FeedRequest
+		if err := websocket.JSON.Receive(ws, &req); err != nil {
+			return
+		}
+		switch req.Type {
+		case "subscribe":
+			c.subscribe(&req)
+		case "unsubscribe":
+			c.unsubscribe(req.Id)
+		case "ping":
+			c.reply(FeedMessage{Type: "pong"})
+		default:
+			c.reply(FeedMessage{Type: "error", Id: req.Id, Error: fmt.Sprintf("unknown message type %q", req.Type)})
+		}
+	}
+}
+
+func (c *feedConn) close() {
+	c.mu.Lock()
+	for id, sub := range c.subs {
+		sub.cancel()
+		delete(c.subs, id)
+	}
+	c.mu.Unlock()
+	close(c.done)
+	c.ws.Close()
+}
+
+func (c *feedConn) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *feedConn) reply(m FeedMessage) {
+	c.mu.Lock()
+	c.control = append(c.control, m)
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *feedConn) subscribe(req *FeedRequest) {
+	fail := func(err error) {
+		c.reply(FeedMessage{Type: "error", Id: req.Id, Error: err.Error()})
+	}
+	if req.Id == "" {
+		fail(fmt.Errorf("id is required"))
+		return
+	}
+	if req.Plc.Host == "" {
+		fail(fmt.Errorf("plc.host is required"))
+		return
+	}
+	if len(req.Tags) == 0 {
+		fail(fmt.Errorf("no tags"))
+		return
+	}
+	tags := make([]*pb.Tag, len(req.Tags))
+	for i, t := range req.Tags {
+		tags[i] = &pb.Tag{Address: t.Address, Dt: t.Dt}
+		if err := driver.Validate(req.Plc.pb(), tags[i]); err != nil {
+			fail(fmt.Errorf("%s %s: %v", t.Address, t.Dt, err))
+			return
+		}
+	}
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval < minInterval {
+		interval = minInterval
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.subs[req.Id]; ok {
+		c.control = append(c.control, FeedMessage{Type: "error", Id: req.Id, Error: "subscription exists"})
+		c.signal()
+		return
+	}
+	if len(c.subs) >= maxSubscriptions {
+		c.control = append(c.control, FeedMessage{Type: "error", Id: req.Id, Error: "too many subscriptions"})
+		c.signal()
+		return
+	}
+	sub := &feedSub{pending: make([]*poll.Sample, len(tags))}
+	c.subs[req.Id] = sub
+	c.control = append(c.control, FeedMessage{Type: "subscribed", Id: req.Id})
+	c.signal()
+	// the acknowledgement is queued before the first values
+	sub.cancel = c.feed.poller.Subscribe(req.Plc.pb(), tags, interval, func(samples []poll.Sample) {
+		c.update(req.Id, sub, samples)
+	})
+}
+
+func (c *feedConn) unsubscribe(id string) {
+	c.mu.Lock()
+	sub, ok := c.subs[id]
+	delete(c.subs, id)
+	c.mu.Unlock()
+	if !ok {
+		c.reply(FeedMessage{Type: "error", Id: id, Error: "no such subscription"})
+		return
+	}
+	sub.cancel()
+	c.reply(FeedMessage{Type: "unsubscribed", Id: id})
+}
+
+// update records changed samples. Samples the writer has not sent yet are
+// replaced, so a slow client skips intermediate values.
+func (c *feedConn) update(id string, sub *feedSub, samples []poll.Sample) {
+	c.mu.Lock()
+	if c.subs[id] != sub {
+		c.mu.Unlock()
+		return
+	}
+	for i := range samples {
+		s := samples[i]
+		sub.pending[s.Index] = &s
+	}
+	sub.dirty = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+// take returns the queued messages and the pending values of every
+// subscription.
+func (c *feedConn) take() []FeedMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msgs := c.control
+	c.control = nil
+	for id, sub := range c.subs {
+		if !sub.dirty {
+			continue
+		}
+		m := FeedMessage{Type: "values", Id: id}
+		for i, s := range sub.pending {
+			if s == nil {
+				continue
+			}
+			m.Values = append(m.Values, feedValue(s))
+			sub.pending[i] = nil
+		}
+		sub.dirty = false
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+func feedValue(s *poll.Sample) FeedValue {
+	v := FeedValue{
+		Index:   s.Index,
+		Address: s.Tag.GetAddress(),
+		Dt:      s.Tag.GetDt(),
+		Quality: s.Quality,
+		Time:    s.Time,
+	}
+	if s.Tag.GetValue() != nil {
+		v.Value = s.Tag.GetJSONValue()
+	}
+	if s.Err != nil {
+		v.Err = errorMessage(s.Err).Error()
+	}
+	return v
+}
+
+func (c *feedConn) writeLoop() {
+	heartbeat := c.feed.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.wake:
+		case now := <-ticker.C:
+			c.reply(FeedMessage{Type: "heartbeat", Time: &now})
+			continue
+		}
+		for _, m := range c.take() {
+			c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := websocket.JSON.Send(c.ws, m); err != nil {
+				log.Printf("feed: %v", err)
+				c.ws.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	"github.com/thinkontrolsy/goplc/poll"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+func TestFeed(t *testing.T) {
+	plc := plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB10P0", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+		&pb.Tag{Address: "DB10P4", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: -2}},
+	)
+	feed := NewFeed(plc)
+	feed.Heartbeat = 50 * time.Millisecond
+	gateway := NewGateway(Config{}, plc)
+	gateway.Handle("/feed", feed)
+	srv := httptest.NewServer(gateway)
+	defer srv.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/feed", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	send := func(req string) {
+		t.Helper()
+		if err := websocket.Message.Send(ws, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// next returns the next message that is not a heartbeat
+	heartbeats := 0
+	next := func() FeedMessage {
+		t.Helper()
+		for {
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var m FeedMessage
+			if err := websocket.JSON.Receive(ws, &m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Type != "heartbeat" {
+				return m
+			}
+			heartbeats++
+		}
+	}
+
+	send(`{"type":"subscribe","id":"s1","plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Real"},{"address":"DB10P4","dt":"Int"}],"interval_ms":100}`)
+	if m := next(); m.Type != "subscribed" || m.Id != "s1" {
+		t.Fatalf("subscribe: %+v", m)
+	}
+	m := next()
+	if m.Type != "values" || len(m.Values) != 2 || m.Values[0].Value != 12.5 || m.Values[1].Value != float64(-2) {
+		t.Fatalf("first values: %+v", m)
+	}
+
+	plc.Set(&pb.Tag{Address: "DB10P4", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 5}})
+	if m := next(); m.Type != "values" || len(m.Values) != 1 || m.Values[0].Index != 1 || m.Values[0].Value != float64(5) {
+		t.Fatalf("changed values: %+v", m)
+	}
+
+	plc.SetOffline(true)
+	if m := next(); len(m.Values) != 2 || m.Values[0].Quality != poll.Bad || m.Values[0].Err != plctest.ErrOffline.Error() {
+		t.Fatalf("offline values: %+v", m)
+	}
+
+	send(`{"type":"subscribe","id":"s2","plc":{"host":"10.0.0.1"},"tags":[{"address":"X1","dt":"Int"}]}`)
+	if m := next(); m.Type != "error" || m.Id != "s2" {
+		t.Fatalf("bad address: %+v", m)
+	}
+	send(`{"type":"ping"}`)
+	if m := next(); m.Type != "pong" {
+		t.Fatalf("ping: %+v", m)
+	}
+	send(`{"type":"unsubscribe","id":"s1"}`)
+	if m := next(); m.Type != "unsubscribed" || m.Id != "s1" {
+		t.Fatalf("unsubscribe: %+v", m)
+	}
+	for heartbeats == 0 {
+		send(`{"type":"ping"}`)
+		next()
+	}
+}
+
+func TestFeedCoalesce(t *testing.T) {
+	c := &feedConn{wake: make(chan struct{}, 1), subs: make(map[string]*feedSub)}
+	sub := &feedSub{pending: make([]*poll.Sample, 2)}
+	c.subs["s"] = sub
+	sample := func(i int, v int64) poll.Sample {
+		return poll.Sample{Index: i, Quality: poll.Good, Tag: &pb.Tag{Address: "DB1P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: v}}}
+	}
+	// the writer is slow: three polls arrive before it takes anything
+	c.update("s", sub, []poll.Sample{sample(0, 1), sample(1, 1)})
+	c.update("s", sub, []poll.Sample{sample(0, 2)})
+	c.update("s", sub, []poll.Sample{sample(0, 3)})
+
+	msgs := c.take()
+	if len(msgs) != 1 || len(msgs[0].Values) != 2 {
+		t.Fatalf("got %+v, want one message with two values", msgs)
+	}
+	if v := msgs[0].Values; v[0].Value != int64(3) || v[1].Value != int64(1) {
+		t.Fatalf("values %+v, want the latest of each tag", v)
+	}
+	if msgs := c.take(); len(msgs) != 0 {
+		t.Fatalf("second take: %+v", msgs)
+	}
+}
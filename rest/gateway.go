@@ -0,0 +1,652 @@
+// Package rest serves the PlcRW service over HTTP with JSON bodies, for
+// scripts and web tools that cannot speak gRPC. Tag values are plain JSON
+// values coerced according to the tag datatype.
+package rest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+type Config struct {
+	Listen string `json:"listen"`
+	// Users may log in with HTTP basic authentication. Without users
+	// everyone may read and write.
+	Users auth.Users `json:"users,omitempty"`
+}
+
+type Plc struct {
+	Protocol string `json:"protocol,omitempty"`
+	Host     string `json:"host"`
+	Rack     uint32 `json:"rack"`
+	Slot     uint32 `json:"slot"`
+	Port     uint32 `json:"port,omitempty"`
+	// Options are protocol specific settings.
+	Options map[string]string `json:"options,omitempty"`
+	// PasswordRef names the stored password of a protected S7 CPU.
+	PasswordRef string `json:"password_ref,omitempty"`
+}
+
+func (p Plc) pb() *pb.Plc {
+	return &pb.Plc{Host: p.Host, Rack: p.Rack, Slot: p.Slot, Port: p.Port, Protocol: p.Protocol, Options: p.Options, PasswordRef: p.PasswordRef}
+}
+
+// Tag is a tag with its value as a natural JSON value: a boolean, a
+// number, an RFC 3339 time, a Go duration string or a string.
+type Tag struct {
+	Address string      `json:"address"`
+	Dt      string      `json:"dt"`
+	Value   interface{} `json:"value,omitempty"`
+	Err     string      `json:"err,omitempty"`
+}
+
+type RWReq struct {
+	Plc  Plc   `json:"plc"`
+	Tags []Tag `json:"tags"`
+}
+
+type RWResult struct {
+	Tags []Tag `json:"tags"`
+}
+
+type CpuInfo struct {
+	ModuleTypeName string `json:"module_type_name"`
+	SerialNumber   string `json:"serial_number"`
+	AsName         string `json:"as_name"`
+	Copyright      string `json:"copyright"`
+	ModuleName     string `json:"module_name"`
+	// OrderCode to CpuFamily are left out when the CPU does not tell.
+	OrderCode       string `json:"order_code,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	ProtectionLevel uint32 `json:"protection_level,omitempty"`
+	ModeSelector    string `json:"mode_selector,omitempty"`
+	PduSize         uint32 `json:"pdu_size,omitempty"`
+	MaxConnections  uint32 `json:"max_connections,omitempty"`
+	CpuFamily       string `json:"cpu_family,omitempty"`
+}
+
+type CpuState struct {
+	// State is RUN, STOP, STARTUP, HOLD, DEFECT or UNKNOWN.
+	State string `json:"state"`
+}
+
+// CpuControlReq asks to start or stop a CPU. Without Confirm it only
+// returns a confirmation token, to repeat in Confirm.
+type CpuControlReq struct {
+	Plc Plc `json:"plc"`
+	// Mode is the kind of restart, "warm" (default) or "cold".
+	Mode    string `json:"mode,omitempty"`
+	Confirm string `json:"confirm,omitempty"`
+}
+
+type CpuControlResult struct {
+	Confirm        string     `json:"confirm,omitempty"`
+	ConfirmExpires *time.Time `json:"confirm_expires,omitempty"`
+	State          string     `json:"state,omitempty"`
+}
+
+// Szl is a system status list; Raw records are hexadecimal.
+type Szl struct {
+	Id         uint32      `json:"id"`
+	Index      uint32      `json:"index"`
+	RecordSize uint32      `json:"record_size"`
+	Records    []SzlRecord `json:"records"`
+}
+
+type SzlRecord struct {
+	Raw    string            `json:"raw"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type DiagnosticEntry struct {
+	EventId  uint32    `json:"event_id"`
+	Priority uint32    `json:"priority"`
+	ObNumber uint32    `json:"ob_number"`
+	DatId    uint32    `json:"dat_id"`
+	Info1    uint32    `json:"info1"`
+	Info2    uint32    `json:"info2"`
+	Time     time.Time `json:"time"`
+	Text     string    `json:"text"`
+}
+
+// DiagnosticBuffer holds the entries newest first.
+type DiagnosticBuffer struct {
+	Entries []DiagnosticEntry `json:"entries"`
+}
+
+// BlockList lists the blocks of a CPU by type.
+type BlockList struct {
+	Types []BlockType `json:"types"`
+}
+
+type BlockType struct {
+	Type    string   `json:"type"`
+	Count   uint32   `json:"count"`
+	Numbers []uint32 `json:"numbers"`
+}
+
+type BlockInfo struct {
+	Type          string    `json:"type"`
+	Number        uint32    `json:"number"`
+	Language      string    `json:"language"`
+	Flags         uint32    `json:"flags"`
+	Mc7Size       uint32    `json:"mc7_size"`
+	LoadSize      uint32    `json:"load_size"`
+	LocalData     uint32    `json:"local_data"`
+	SbbLength     uint32    `json:"sbb_length"`
+	Checksum      uint32    `json:"checksum"`
+	Version       string    `json:"version"`
+	CodeDate      time.Time `json:"code_date"`
+	InterfaceDate time.Time `json:"interface_date"`
+	Author        string    `json:"author"`
+	Family        string    `json:"family"`
+	Header        string    `json:"header"`
+}
+
+// Block is an uploaded block, with data and mc7 in hexadecimal.
+type Block struct {
+	Info BlockInfo `json:"info"`
+	Data string    `json:"data"`
+	Mc7  string    `json:"mc7"`
+}
+
+type BaselineDiff struct {
+	BaselineTaken *time.Time    `json:"baseline_taken,omitempty"`
+	Changes       []BlockChange `json:"changes"`
+}
+
+// BlockChange is a block added, removed or changed since the baseline.
+type BlockChange struct {
+	Type     string     `json:"type"`
+	Number   uint32     `json:"number"`
+	Kind     string     `json:"kind"`
+	Fields   []string   `json:"fields,omitempty"`
+	Baseline *BlockInfo `json:"baseline,omitempty"`
+	Current  *BlockInfo `json:"current,omitempty"`
+}
+
+// Error is the body of every response with an error status.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Gateway is an http.Handler translating requests to PlcRW calls. Other
+// HTTP services of goplc can be mounted with Handle; they are behind the
+// same authentication.
+type Gateway struct {
+	users  auth.Users
+	server pb.PlcRWServer
+	mux    *http.ServeMux
+}
+
+func NewGateway(config Config, server pb.PlcRWServer) *Gateway {
+	g := &Gateway{users: config.Users, server: server, mux: http.NewServeMux()}
+	g.mux.HandleFunc("/me", g.me)
+	g.mux.HandleFunc("/plcs/", g.plcs)
+	g.mux.HandleFunc("/cpu/start", g.cpuControl)
+	g.mux.HandleFunc("/cpu/stop", g.cpuControl)
+	g.mux.HandleFunc("/read", g.read)
+	g.mux.HandleFunc("/write", g.write)
+	g.mux.HandleFunc("/openapi.json", serveOpenAPI)
+	return g
+}
+
+// Handle registers another handler on the gateway's server.
+func (g *Gateway) Handle(pattern string, handler http.Handler) {
+	g.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP authenticates the caller and stores it in the request context
+// for the handlers.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller := auth.Caller{Role: auth.Operator}
+	if len(g.users) > 0 {
+		name, password, _ := r.BasicAuth()
+		role, ok := g.users.Authenticate(name, password)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="goplc", charset="UTF-8"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+			return
+		}
+		caller = auth.Caller{Name: name, Role: role}
+	}
+	g.mux.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), caller)))
+}
+
+// allow answers 403 unless the caller has the required role.
+func allow(w http.ResponseWriter, r *http.Request, required auth.Role) bool {
+	if caller, ok := auth.FromContext(r.Context()); ok && caller.Role.Allows(required) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, fmt.Errorf("%s role required", required))
+	return false
+}
+
+// me serves GET /me, the caller and its role.
+func (g *Gateway) me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", r.Method))
+		return
+	}
+	caller, _ := auth.FromContext(r.Context())
+	writeJSON(w, http.StatusOK, caller)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("rest: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, Error{Error: err.Error()})
+}
+
+// httpStatus maps a PlcRW error onto an HTTP status: refused writes are
+// 403, bad requests 400, unconfirmed operations and functions the
+// protection of a CPU refuses 409 and everything else, usually an
+// unreachable PLC, 502.
+func httpStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.NotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+// errorMessage strips the gRPC prefix from status errors.
+func errorMessage(err error) error {
+	if s, ok := status.FromError(err); ok {
+		return fmt.Errorf("%s", s.Message())
+	}
+	return err
+}
+
+// plcs serves GET /plcs/{host}/info, /plcs/{host}/state, /plcs/{host}/szl,
+// /plcs/{host}/diagnostics, /plcs/{host}/baseline and /plcs/{host}/blocks
+// with what follows it, with the query ?rack=0&slot=1&port=102&protocol=s7.
+func (g *Gateway) plcs(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/plcs/"), "/")
+	handlers := map[string]func(http.ResponseWriter, *http.Request, *pb.Plc){
+		"info":        g.info,
+		"state":       g.state,
+		"szl":         g.szl,
+		"diagnostics": g.diagnostics,
+		"blocks":      g.blocks,
+		"baseline":    g.baseline,
+	}
+	if len(parts) < 2 || parts[0] == "" || handlers[parts[1]] == nil || (len(parts) > 2 && parts[1] != "blocks") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", r.Method))
+		return
+	}
+	plc := &pb.Plc{Host: parts[0], Protocol: r.URL.Query().Get("protocol"), PasswordRef: r.URL.Query().Get("password_ref")}
+	for _, p := range []struct {
+		name string
+		v    *uint32
+	}{{"rack", &plc.Rack}, {"slot", &plc.Slot}, {"port", &plc.Port}} {
+		s := r.URL.Query().Get(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%s: %v", p.name, err))
+			return
+		}
+		*p.v = uint32(n)
+	}
+	handlers[parts[1]](w, r, plc)
+}
+
+func (g *Gateway) info(w http.ResponseWriter, r *http.Request, plc *pb.Plc) {
+	info, err := g.server.GetCpuInfo(r.Context(), plc)
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, CpuInfo{
+		ModuleTypeName:  info.GetModuleTypeName(),
+		SerialNumber:    info.GetSerialNumber(),
+		AsName:          info.GetAsName(),
+		Copyright:       info.GetCopyright(),
+		ModuleName:      info.GetModuleName(),
+		OrderCode:       info.GetOrderCode(),
+		FirmwareVersion: info.GetFirmwareVersion(),
+		ProtectionLevel: info.GetProtectionLevel(),
+		ModeSelector:    info.GetModeSelector(),
+		PduSize:         info.GetPduSize(),
+		MaxConnections:  info.GetMaxConnections(),
+		CpuFamily:       info.GetCpuFamily(),
+	})
+}
+
+func (g *Gateway) state(w http.ResponseWriter, r *http.Request, plc *pb.Plc) {
+	state, err := g.server.GetCpuState(r.Context(), plc)
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, CpuState{State: state.GetState().String()})
+}
+
+// szl serves GET /plcs/{host}/szl?id=0x0011&index=0.
+func (g *Gateway) szl(w http.ResponseWriter, r *http.Request, plc *pb.Plc) {
+	req := &pb.SzlReq{Plc: plc}
+	for _, p := range []struct {
+		name string
+		v    *uint32
+	}{{"id", &req.Id}, {"index", &req.Index}} {
+		n, err := strconv.ParseUint(r.URL.Query().Get(p.name), 0, 16)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%s: %v", p.name, err))
+			return
+		}
+		*p.v = uint32(n)
+	}
+	list, err := g.server.ReadSzl(r.Context(), req)
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	res := Szl{Id: list.GetId(), Index: list.GetIndex(), RecordSize: list.GetRecordSize(), Records: []SzlRecord{}}
+	for _, record := range list.GetRecords() {
+		res.Records = append(res.Records, SzlRecord{Raw: hex.EncodeToString(record.GetRaw()), Fields: record.GetFields()})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (g *Gateway) diagnostics(w http.ResponseWriter, r *http.Request, plc *pb.Plc) {
+	buf, err := g.server.GetDiagnosticBuffer(r.Context(), &pb.DiagnosticReq{Plc: plc})
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	res := DiagnosticBuffer{Entries: []DiagnosticEntry{}}
+	for _, e := range buf.GetEntries() {
+		t, _ := ptypes.Timestamp(e.GetTime())
+		res.Entries = append(res.Entries, DiagnosticEntry{
+			EventId:  e.GetEventId(),
+			Priority: e.GetPriority(),
+			ObNumber: e.GetObNumber(),
+			DatId:    e.GetDatId(),
+			Info1:    e.GetInfo1(),
+			Info2:    e.GetInfo2(),
+			Time:     t,
+			Text:     e.GetText(),
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// blocks serves GET /plcs/{host}/blocks, the info of a block at
+// /plcs/{host}/blocks/{type}/{number} and its upload at
+// /plcs/{host}/blocks/{type}/{number}/upload.
+func (g *Gateway) blocks(w http.ResponseWriter, r *http.Request, plc *pb.Plc) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/plcs/"), "/")[2:]
+	if len(parts) == 0 {
+		list, err := g.server.ListBlocks(r.Context(), plc)
+		if err != nil {
+			writeError(w, httpStatus(err), errorMessage(err))
+			return
+		}
+		res := BlockList{Types: []BlockType{}}
+		for _, t := range list.GetTypes() {
+			numbers := t.GetNumbers()
+			if numbers == nil {
+				numbers = []uint32{}
+			}
+			res.Types = append(res.Types, BlockType{Type: t.GetType(), Count: t.GetCount(), Numbers: numbers})
+		}
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "upload") {
+		http.NotFound(w, r)
+		return
+	}
+	n, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("block number: %v", err))
+		return
+	}
+	req := &pb.BlockReq{Plc: plc, Type: strings.ToUpper(parts[0]), Number: uint32(n)}
+	if len(parts) == 2 {
+		info, err := g.server.GetBlockInfo(r.Context(), req)
+		if err != nil {
+			writeError(w, httpStatus(err), errorMessage(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, blockInfo(info))
+		return
+	}
+	block, err := g.server.UploadBlock(r.Context(), req)
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, Block{
+		Info: blockInfo(block.GetInfo()),
+		Data: hex.EncodeToString(block.GetData()),
+		Mc7:  hex.EncodeToString(block.GetMc7()),
+	})
+}
+
+// baseline serves GET /plcs/{host}/baseline, the changes to the blocks
+// since the baseline.
+func (g *Gateway) baseline(w http.ResponseWriter, r *http.Request, plc *pb.Plc) {
+	diff, err := g.server.CompareToBaseline(r.Context(), &pb.BaselineReq{Plc: plc})
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	res := BaselineDiff{Changes: []BlockChange{}}
+	if diff.GetBaselineTaken() != nil {
+		t, _ := ptypes.Timestamp(diff.GetBaselineTaken())
+		res.BaselineTaken = &t
+	}
+	for _, c := range diff.GetChanges() {
+		change := BlockChange{Type: c.GetType(), Number: c.GetNumber(), Kind: strings.ToLower(c.GetKind().String()), Fields: c.GetFields()}
+		if c.GetBaseline() != nil {
+			b := blockInfo(c.GetBaseline())
+			change.Baseline = &b
+		}
+		if c.GetCurrent() != nil {
+			b := blockInfo(c.GetCurrent())
+			change.Current = &b
+		}
+		res.Changes = append(res.Changes, change)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func blockInfo(info *pb.BlockInfo) BlockInfo {
+	codeDate, _ := ptypes.Timestamp(info.GetCodeDate())
+	intfDate, _ := ptypes.Timestamp(info.GetInterfaceDate())
+	return BlockInfo{
+		Type:          info.GetType(),
+		Number:        info.GetNumber(),
+		Language:      info.GetLanguage(),
+		Flags:         info.GetFlags(),
+		Mc7Size:       info.GetMc7Size(),
+		LoadSize:      info.GetLoadSize(),
+		LocalData:     info.GetLocalData(),
+		SbbLength:     info.GetSbbLength(),
+		Checksum:      info.GetChecksum(),
+		Version:       info.GetVersion(),
+		CodeDate:      codeDate,
+		InterfaceDate: intfDate,
+		Author:        info.GetAuthor(),
+		Family:        info.GetFamily(),
+		Header:        info.GetHeader(),
+	}
+}
+
+// cpuControl serves POST /cpu/start and POST /cpu/stop for engineers.
+func (g *Gateway) cpuControl(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", r.Method))
+		return
+	}
+	if !allow(w, r, auth.Engineer) {
+		return
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	var req CpuControlReq
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Plc.Host == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("plc.host is required"))
+		return
+	}
+	ctl := &pb.CpuControlReq{Plc: req.Plc.pb(), Confirm: req.Confirm}
+	switch req.Mode {
+	case "", "warm":
+	case "cold":
+		ctl.Mode = pb.CpuControlReq_COLD
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown mode %q", req.Mode))
+		return
+	}
+	call := g.server.StartCpu
+	if r.URL.Path == "/cpu/stop" {
+		call = g.server.StopCpu
+	}
+	res, err := call(r.Context(), ctl)
+	if err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	out := CpuControlResult{Confirm: res.GetConfirm()}
+	if res.GetConfirmExpires() != nil {
+		t, _ := ptypes.Timestamp(res.GetConfirmExpires())
+		out.ConfirmExpires = &t
+	}
+	if res.GetState() != nil {
+		out.State = res.GetState().GetState().String()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// decodeRequest reads a RWReq body; numbers are kept as json.Number so
+// that 64 bit integers survive.
+func decodeRequest(w http.ResponseWriter, r *http.Request) (*RWReq, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", r.Method))
+		return nil, false
+	}
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, 1<<20)); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	dec := json.NewDecoder(&body)
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	var req RWReq
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	if req.Plc.Host == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("plc.host is required"))
+		return nil, false
+	}
+	if len(req.Tags) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("no tags"))
+		return nil, false
+	}
+	return &req, true
+}
+
+func result(tags []*pb.Tag) RWResult {
+	res := RWResult{Tags: make([]Tag, len(tags))}
+	for i, tag := range tags {
+		res.Tags[i] = Tag{Address: tag.GetAddress(), Dt: tag.GetDt(), Err: tag.GetErr()}
+		if tag.GetErr() == "" {
+			res.Tags[i].Value = tag.GetJSONValue()
+		}
+	}
+	return res
+}
+
+func (g *Gateway) read(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	tags := make([]*pb.Tag, len(req.Tags))
+	for i, t := range req.Tags {
+		tags[i] = &pb.Tag{Address: t.Address, Dt: t.Dt}
+		if err := driver.Validate(req.Plc.pb(), tags[i]); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%s %s: %v", t.Address, t.Dt, err))
+			return
+		}
+	}
+	if _, err := g.server.ReadTags(r.Context(), &pb.RWReq{Plc: req.Plc.pb(), Tags: tags}); err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result(tags))
+}
+
+func (g *Gateway) write(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok || !allow(w, r, auth.Operator) {
+		return
+	}
+	tags := make([]*pb.Tag, len(req.Tags))
+	for i, t := range req.Tags {
+		tags[i] = &pb.Tag{Address: t.Address, Dt: t.Dt}
+		if err := driver.Validate(req.Plc.pb(), tags[i]); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%s %s: %v", t.Address, t.Dt, err))
+			return
+		}
+		if err := tags[i].SetJSONValue(t.Value); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if _, err := g.server.WriteTags(r.Context(), &pb.RWReq{Plc: req.Plc.pb(), Tags: tags}); err != nil {
+		writeError(w, httpStatus(err), errorMessage(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result(tags))
+}
@@ -0,0 +1,234 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	"github.com/thinkontrolsy/goplc/internal/s7sim"
+	"github.com/thinkontrolsy/goplc/s7"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+type policyPlc struct {
+	*plctest.MemoryPlc
+	policy s7.WritePolicy
+}
+
+// WriteTags refuses writes the way PlcServer does.
+func (p policyPlc) WriteTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
+	for _, tag := range req.GetTags() {
+		if err := p.policy.AllowWrite(req.GetPlc(), tag); err != nil {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	return p.MemoryPlc.WriteTags(ctx, req)
+}
+
+func TestGateway(t *testing.T) {
+	plc := plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB10P0", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+		&pb.Tag{Address: "DB10P4", Dt: "LInt", Value: &pb.Tag_ValueInteger{ValueInteger: 9007199254740993}},
+		&pb.Tag{Address: "DB10P12", Dt: "ULInt", Value: &pb.Tag_ValueUinteger{ValueUinteger: 1}},
+	)
+	policy := s7.WritePolicyFunc(func(plc *pb.Plc, tag *pb.Tag) error {
+		if tag.GetAddress() == "DB10P4" {
+			return fmt.Errorf("%s is read only", tag.GetAddress())
+		}
+		return nil
+	})
+	srv := httptest.NewServer(NewGateway(Config{}, policyPlc{plc, policy}))
+	defer srv.Close()
+
+	call := func(method, path, body string, wantCode int) map[string]interface{} {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantCode {
+			t.Fatalf("%s %s: status %d, want %d", method, path, resp.StatusCode, wantCode)
+		}
+		var v map[string]interface{}
+		dec := json.NewDecoder(resp.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tags := func(v map[string]interface{}) []interface{} {
+		return v["tags"].([]interface{})
+	}
+
+	info := call("GET", "/plcs/10.0.0.1/info?rack=0&slot=1", "", http.StatusOK)
+	if info["module_type_name"] != "CPU 1511-1 PN" || info["module_name"] != "10.0.0.1" {
+		t.Fatalf("info: %v", info)
+	}
+	call("GET", "/plcs/10.0.0.1/info?slot=x", "", http.StatusBadRequest)
+
+	read := call("POST", "/read", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Real"},{"address":"DB10P4","dt":"LInt"}]}`, http.StatusOK)
+	got := []interface{}{tags(read)[0].(map[string]interface{})["value"], tags(read)[1].(map[string]interface{})["value"]}
+	// beyond 2^53 as a string, which float64 clients cannot mangle
+	if want := []interface{}{json.Number("12.5"), "9007199254740993"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("read values %v, want %v", got, want)
+	}
+
+	call("POST", "/write", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Real","value":-3.25}]}`, http.StatusOK)
+	if v := plc.Get("DB10P0").GetValueDouble(); v != -3.25 {
+		t.Fatalf("DB10P0 = %v", v)
+	}
+	call("POST", "/write", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Real","value":"fast"}]}`, http.StatusBadRequest)
+	call("POST", "/write", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P12","dt":"ULInt","value":"18446744073709551615"}]}`, http.StatusOK)
+	if v := plc.Get("DB10P12").GetValueUinteger(); v != 18446744073709551615 {
+		t.Fatalf("DB10P12 = %d", v)
+	}
+	read = call("POST", "/read", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P12","dt":"ULInt"}]}`, http.StatusOK)
+	if v := tags(read)[0].(map[string]interface{})["value"]; v != "18446744073709551615" {
+		t.Fatalf("DB10P12 read %#v", v)
+	}
+	call("POST", "/write", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P12","dt":"ULInt","value":9007199254740992}]}`, http.StatusOK)
+	read = call("POST", "/read", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P12","dt":"ULInt"}]}`, http.StatusOK)
+	if v := tags(read)[0].(map[string]interface{})["value"]; v != json.Number("9007199254740992") {
+		t.Fatalf("DB10P12 read %#v, want a number up to 2^53", v)
+	}
+	call("POST", "/write", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P4","dt":"LInt","value":1}]}`, http.StatusForbidden)
+	call("POST", "/read", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"X1","dt":"Real"}]}`, http.StatusBadRequest)
+	call("GET", "/read", "", http.StatusMethodNotAllowed)
+
+	plc.SetOffline(true)
+	if v := call("POST", "/read", `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Real"}]}`, http.StatusBadGateway); v["error"] != plctest.ErrOffline.Error() {
+		t.Fatalf("offline error: %v", v)
+	}
+
+	doc := call("GET", "/openapi.json", "", http.StatusOK)
+	if _, ok := doc["paths"].(map[string]interface{})["/plcs/{host}/info"]; !ok {
+		t.Fatalf("OpenAPI document lacks /plcs/{host}/info")
+	}
+}
+
+func TestGatewayRoles(t *testing.T) {
+	plc := plctest.NewMemoryPlc(&pb.Tag{Address: "DB10P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 1}})
+	srv := httptest.NewServer(NewGateway(Config{Users: auth.Users{
+		"tech": {Password: "look", Role: auth.Viewer},
+		"op":   {Password: "turn", Role: auth.Operator},
+		"eng":  {Password: "plan", Role: auth.Engineer},
+	}}, plc))
+	defer srv.Close()
+
+	call := func(user, password, method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var caller auth.Caller
+		json.NewDecoder(resp.Body).Decode(&caller)
+		return resp.StatusCode, caller.Role.String()
+	}
+	read := `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Int"}]}`
+	write := `{"plc":{"host":"10.0.0.1"},"tags":[{"address":"DB10P0","dt":"Int","value":2}]}`
+	stop := `{"plc":{"host":"10.0.0.1"}}`
+	for _, c := range []struct {
+		user, password, method, path, body string
+		code                               int
+	}{
+		{"", "", "GET", "/me", "", http.StatusUnauthorized},
+		{"tech", "wrong", "POST", "/read", read, http.StatusUnauthorized},
+		{"tech", "look", "POST", "/read", read, http.StatusOK},
+		{"tech", "look", "POST", "/write", write, http.StatusForbidden},
+		{"op", "turn", "POST", "/write", write, http.StatusOK},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/state", "", http.StatusOK},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/szl?id=0x10000&index=0", "", http.StatusBadRequest},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/szl?id=0x0011&index=0", "", http.StatusNotImplemented},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/diagnostics", "", http.StatusNotImplemented},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/blocks", "", http.StatusNotImplemented},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/blocks/fc/12", "", http.StatusNotImplemented},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/blocks/fc/12/upload", "", http.StatusNotImplemented},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/blocks/fc/x", "", http.StatusBadRequest},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/blocks/fc/12/download", "", http.StatusNotFound},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/state/x", "", http.StatusNotFound},
+		{"tech", "look", "GET", "/plcs/10.0.0.1/baseline", "", http.StatusNotImplemented},
+		{"op", "turn", "POST", "/cpu/stop", stop, http.StatusForbidden},
+		{"eng", "plan", "POST", "/cpu/stop", stop, http.StatusOK},
+		{"eng", "plan", "POST", "/cpu/stop", stop[:len(stop)-1] + `,"confirm":"guess"}`, http.StatusConflict},
+		{"eng", "plan", "POST", "/cpu/start", stop[:len(stop)-1] + `,"mode":"hot"}`, http.StatusBadRequest},
+		{"eng", "plan", "POST", "/cpu/stop", stop[:len(stop)-1] + `,"confirm":"confirm"}`, http.StatusOK},
+	} {
+		if code, _ := call(c.user, c.password, c.method, c.path, c.body); code != c.code {
+			t.Errorf("%s %s as %q: status %d, want %d", c.method, c.path, c.user, code, c.code)
+		}
+	}
+	if _, role := call("tech", "look", "GET", "/me", ""); role != "viewer" {
+		t.Errorf("/me role %q, want viewer", role)
+	}
+	if v := plc.Get("DB10P0").GetValueInteger(); v != 2 {
+		t.Errorf("DB10P0 = %d, want 2", v)
+	}
+	if s, _ := plc.GetCpuState(context.Background(), nil); s.GetState() != pb.CpuState_STOP {
+		t.Errorf("CPU %v after confirmed stop", s.GetState())
+	}
+}
+
+func TestGatewayProtectedCpu(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	cpu.Protect(3, "secret")
+	server := &s7.PlcServer{}
+	srv := httptest.NewServer(NewGateway(Config{}, server))
+	defer srv.Close()
+
+	plc, _ := json.Marshal(cpu.Plc())
+	resp, err := http.Post(srv.URL+"/read", "application/json", strings.NewReader(`{"plc":`+string(plc)+`,"tags":[{"address":"MP0","dt":"Byte"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var e Error
+	json.NewDecoder(resp.Body).Decode(&e)
+	// not a failed login: no 401 and no prompt for credentials
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("WWW-Authenticate") != "" || !strings.HasPrefix(e.Error, "CPU protection") {
+		t.Fatalf("status %d, %q", resp.StatusCode, e.Error)
+	}
+	// the descriptor and the query name a stored password
+	server.KeepPasswords(context.Background(), map[string]string{"gateway-right": "secret", "gateway-wrong": "guess"})
+	p := cpu.Plc()
+	p.PasswordRef = "gateway-right"
+	plc, _ = json.Marshal(p)
+	resp, err = http.Post(srv.URL+"/read", "application/json", strings.NewReader(`{"plc":`+string(plc)+`,"tags":[{"address":"MP0","dt":"Byte"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("read with password_ref: status %d", resp.StatusCode)
+	}
+	q := fmt.Sprintf("/plcs/%s/state?slot=1&port=%d&password_ref=", p.GetHost(), p.GetPort())
+	for ref, want := range map[string]int{"gateway-right": http.StatusOK, "gateway-wrong": http.StatusConflict} {
+		resp, err := http.Get(srv.URL + q + ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("state with %s: status %d, want %d", ref, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package rest
+
+import (
+	"net/http"
+)
+
+// OpenAPI describes the gateway as an OpenAPI 3.0 document.
+const OpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "goplc",
+    "description": "HTTP/JSON gateway to Siemens S7 PLCs. Tag values are plain JSON values coerced according to dt: booleans for Bool, numbers for bit strings, integers and reals, RFC 3339 strings for dates and times, Go duration strings (or milliseconds) for durations and strings for Char and String.",
+    "version": "1.0.0"
+  },
+  "security": [{ "basic": [] }],
+  "paths": {
+    "/me": {
+      "get": {
+        "summary": "The caller and its role",
+        "operationId": "getCaller",
+        "responses": {
+          "200": { "description": "Caller", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Caller" } } } },
+          "401": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/info": {
+      "get": {
+        "summary": "Read the CPU identification",
+        "operationId": "getCpuInfo",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "CPU information", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CpuInfo" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/state": {
+      "get": {
+        "summary": "Read the operating state of the CPU",
+        "operationId": "getCpuState",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "CPU state", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CpuState" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/szl": {
+      "get": {
+        "summary": "Read a system status list of an S7 CPU",
+        "operationId": "readSzl",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "id", "in": "query", "required": true, "schema": { "type": "string", "example": "0x0011" }, "description": "SZL id, decimal or 0x hexadecimal" },
+          { "name": "index", "in": "query", "required": true, "schema": { "type": "string", "example": "0x0000" } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "The records, decoded for the lists goplc knows", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Szl" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/diagnostics": {
+      "get": {
+        "summary": "Read the diagnostic buffer of an S7 CPU",
+        "operationId": "getDiagnosticBuffer",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "The entries, newest first", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/DiagnosticBuffer" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/blocks": {
+      "get": {
+        "summary": "List the blocks of an S7 CPU",
+        "operationId": "listBlocks",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "OBs, FBs, FCs, DBs, SFBs and SFCs", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/BlockList" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/blocks/{type}/{number}": {
+      "get": {
+        "summary": "Read the header of a block",
+        "operationId": "getBlockInfo",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "type", "in": "path", "required": true, "schema": { "type": "string", "enum": ["OB", "FB", "FC", "DB", "SFB", "SFC"] } },
+          { "name": "number", "in": "path", "required": true, "schema": { "type": "integer", "minimum": 0, "maximum": 65535 } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "The block info", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/BlockInfo" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/blocks/{type}/{number}/upload": {
+      "get": {
+        "summary": "Upload a block",
+        "operationId": "uploadBlock",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "type", "in": "path", "required": true, "schema": { "type": "string", "enum": ["OB", "FB", "FC", "DB", "SFB", "SFC"] } },
+          { "name": "number", "in": "path", "required": true, "schema": { "type": "integer", "minimum": 0, "maximum": 65535 } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "The block", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Block" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/plcs/{host}/baseline": {
+      "get": {
+        "summary": "Compare the blocks of an S7 CPU to their baseline",
+        "operationId": "compareToBaseline",
+        "parameters": [
+          { "name": "host", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "rack", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "slot", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 0 } },
+          { "name": "port", "in": "query", "schema": { "type": "integer", "minimum": 0, "default": 102 } },
+          { "name": "protocol", "in": "query", "schema": { "type": "string", "default": "s7" } },
+          { "name": "password_ref", "in": "query", "description": "Name of the stored password of a protected S7 CPU", "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "The blocks added, removed and changed", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/BaselineDiff" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" },
+          "409": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/cpu/start": {
+      "post": {
+        "summary": "Restart a stopped CPU",
+        "description": "Needs the engineer role. The first call, without confirm, returns a confirmation token; repeating the call with it starts the CPU.",
+        "operationId": "startCpu",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CpuControlReq" } } } },
+        "responses": {
+          "200": { "description": "A confirmation token or the new state", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CpuControlResult" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "409": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/cpu/stop": {
+      "post": {
+        "summary": "Stop a CPU",
+        "description": "Needs the engineer role. The first call, without confirm, returns a confirmation token; repeating the call with it stops the CPU. mode is ignored.",
+        "operationId": "stopCpu",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CpuControlReq" } } } },
+        "responses": {
+          "200": { "description": "A confirmation token or the new state", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/CpuControlResult" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "409": { "$ref": "#/components/responses/Error" },
+          "501": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/read": {
+      "post": {
+        "summary": "Read tags",
+        "operationId": "readTags",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/RWReq" } } } },
+        "responses": {
+          "200": { "description": "The tags with their values", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/RWResult" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/write": {
+      "post": {
+        "summary": "Write tags",
+        "operationId": "writeTags",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/RWReq" } } } },
+        "responses": {
+          "200": { "description": "The tags written", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/RWResult" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "502": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Plc": {
+        "type": "object",
+        "required": ["host"],
+        "properties": {
+          "protocol": { "type": "string", "default": "s7" },
+          "host": { "type": "string", "example": "192.168.0.1" },
+          "rack": { "type": "integer", "minimum": 0 },
+          "slot": { "type": "integer", "minimum": 0 },
+          "port": { "type": "integer", "minimum": 0 },
+          "options": { "type": "object", "additionalProperties": { "type": "string" }, "description": "Protocol specific settings" },
+          "password_ref": { "type": "string", "description": "Name of the stored password of a protected S7 CPU; the password itself is never sent" }
+        }
+      },
+      "Tag": {
+        "type": "object",
+        "required": ["address", "dt"],
+        "properties": {
+          "address": { "type": "string", "example": "DB10P0", "description": "Address in the syntax of the protocol" },
+          "dt": { "type": "string", "example": "Real" },
+          "value": { "description": "Natural JSON value for dt. LWord, LInt and ULInt values beyond 2^53 either way are decimal strings, which clients decoding numbers as doubles would round; writes take integers as numbers or strings", "example": 12.5 },
+          "err": { "type": "string" }
+        }
+      },
+      "RWReq": {
+        "type": "object",
+        "required": ["plc", "tags"],
+        "properties": {
+          "plc": { "$ref": "#/components/schemas/Plc" },
+          "tags": { "type": "array", "items": { "$ref": "#/components/schemas/Tag" } }
+        }
+      },
+      "RWResult": {
+        "type": "object",
+        "properties": {
+          "tags": { "type": "array", "items": { "$ref": "#/components/schemas/Tag" } }
+        }
+      },
+      "CpuInfo": {
+        "type": "object",
+        "properties": {
+          "module_type_name": { "type": "string" },
+          "serial_number": { "type": "string" },
+          "as_name": { "type": "string" },
+          "copyright": { "type": "string" },
+          "module_name": { "type": "string" },
+          "order_code": { "type": "string", "example": "6ES7 315-2EH14-0AB0" },
+          "firmware_version": { "type": "string", "example": "V3.2.8" },
+          "protection_level": { "type": "integer", "minimum": 1, "maximum": 3 },
+          "mode_selector": { "type": "string", "enum": ["RUN", "RUN-P", "STOP", "MRES"] },
+          "pdu_size": { "type": "integer" },
+          "max_connections": { "type": "integer" },
+          "cpu_family": { "type": "string", "example": "S7-300" }
+        }
+      },
+      "CpuState": {
+        "type": "object",
+        "properties": {
+          "state": { "type": "string", "enum": ["UNKNOWN", "RUN", "STOP", "STARTUP", "HOLD", "DEFECT"] }
+        }
+      },
+      "Szl": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "integer" },
+          "index": { "type": "integer" },
+          "record_size": { "type": "integer" },
+          "records": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "raw": { "type": "string", "description": "Hexadecimal" },
+                "fields": { "type": "object", "additionalProperties": { "type": "string" } }
+              }
+            }
+          }
+        }
+      },
+      "DiagnosticBuffer": {
+        "type": "object",
+        "properties": {
+          "entries": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "event_id": { "type": "integer" },
+                "priority": { "type": "integer" },
+                "ob_number": { "type": "integer" },
+                "dat_id": { "type": "integer" },
+                "info1": { "type": "integer" },
+                "info2": { "type": "integer" },
+                "time": { "type": "string", "format": "date-time" },
+                "text": { "type": "string", "example": "Mode transition from STARTUP to RUN" }
+              }
+            }
+          }
+        }
+      },
+      "BlockList": {
+        "type": "object",
+        "properties": {
+          "types": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "type": { "type": "string", "enum": ["OB", "FB", "FC", "DB", "SFB", "SFC"] },
+                "count": { "type": "integer" },
+                "numbers": { "type": "array", "items": { "type": "integer" } }
+              }
+            }
+          }
+        }
+      },
+      "BlockInfo": {
+        "type": "object",
+        "properties": {
+          "type": { "type": "string" },
+          "number": { "type": "integer" },
+          "language": { "type": "string", "example": "STL" },
+          "flags": { "type": "integer" },
+          "mc7_size": { "type": "integer" },
+          "load_size": { "type": "integer" },
+          "local_data": { "type": "integer" },
+          "sbb_length": { "type": "integer" },
+          "checksum": { "type": "integer" },
+          "version": { "type": "string", "example": "1.2" },
+          "code_date": { "type": "string", "format": "date-time" },
+          "interface_date": { "type": "string", "format": "date-time" },
+          "author": { "type": "string" },
+          "family": { "type": "string" },
+          "header": { "type": "string", "description": "Name of the block" }
+        }
+      },
+      "Block": {
+        "type": "object",
+        "properties": {
+          "info": { "$ref": "#/components/schemas/BlockInfo" },
+          "data": { "type": "string", "description": "The block as uploaded, in hexadecimal" },
+          "mc7": { "type": "string", "description": "The MC7 code, or the values of a DB, in hexadecimal" }
+        }
+      },
+      "BaselineDiff": {
+        "type": "object",
+        "properties": {
+          "baseline_taken": { "type": "string", "format": "date-time" },
+          "changes": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "type": { "type": "string" },
+                "number": { "type": "integer" },
+                "kind": { "type": "string", "enum": ["changed", "added", "removed"] },
+                "fields": { "type": "array", "items": { "type": "string", "enum": ["checksum", "mc7_size", "load_size", "code_date", "interface_date"] } },
+                "baseline": { "$ref": "#/components/schemas/BlockInfo" },
+                "current": { "$ref": "#/components/schemas/BlockInfo" }
+              }
+            }
+          }
+        }
+      },
+      "CpuControlReq": {
+        "type": "object",
+        "required": ["plc"],
+        "properties": {
+          "plc": { "$ref": "#/components/schemas/Plc" },
+          "mode": { "type": "string", "enum": ["warm", "cold"], "default": "warm", "description": "Kind of restart; a cold restart also resets retentive data" },
+          "confirm": { "type": "string", "description": "Token returned by the same call without it" }
+        }
+      },
+      "CpuControlResult": {
+        "type": "object",
+        "properties": {
+          "confirm": { "type": "string" },
+          "confirm_expires": { "type": "string", "format": "date-time" },
+          "state": { "type": "string", "enum": ["UNKNOWN", "RUN", "STOP", "STARTUP", "HOLD", "DEFECT"] }
+        }
+      },
+      "Caller": {
+        "type": "object",
+        "properties": {
+          "name": { "type": "string" },
+          "role": { "type": "string", "enum": ["viewer", "operator", "engineer"] }
+        }
+      },
+      "Error": {
+        "type": "object",
+        "properties": {
+          "error": { "type": "string" }
+        }
+      }
+    },
+    "securitySchemes": {
+      "basic": { "type": "http", "scheme": "basic", "description": "Required when users are configured" }
+    },
+    "responses": {
+      "Error": {
+        "description": "Error",
+        "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Error" } } }
+      }
+    }
+  }
+}
+`
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(OpenAPI))
+}
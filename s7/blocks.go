@@ -0,0 +1,288 @@
+package s7
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	blockListAll    = 0x01
+	blockListOfType = 0x02
+	blockGetInfo    = 0x03
+
+	funcStartUpload = 0x1D
+	funcUpload      = 0x1E
+	funcEndUpload   = 0x1F
+
+	// blockHeaderSize is the length of the header an uploaded block starts
+	// with, before the MC7 code
+	blockHeaderSize = 36
+)
+
+// blockTypes are the types of blocks ListBlocks lists, in order, their
+// codes and the codes the header of a block in load memory gives them.
+var blockTypes = []struct {
+	name string
+	code byte
+	mc7  byte
+}{
+	{"OB", 0x38, 0x08},
+	{"FB", 0x45, 0x0E},
+	{"FC", 0x43, 0x0C},
+	{"DB", 0x41, 0x0A},
+	{"SFB", 0x46, 0x0F},
+	{"SFC", 0x44, 0x0D},
+}
+
+var blockLanguages = map[byte]string{
+	1: "STL",
+	2: "LAD",
+	3: "FBD",
+	4: "SCL",
+	5: "DB",
+	6: "GRAPH",
+}
+
+var errNoBlock = errors.New("s7: block not found")
+
+// blockType returns the code of the block type name.
+func blockType(name string) (byte, bool) {
+	for _, t := range blockTypes {
+		if t.name == name {
+			return t.code, true
+		}
+	}
+	return 0, false
+}
+
+// headerType returns the code of the block type the header of a block in
+// load memory gives.
+func headerType(mc7 byte) (byte, bool) {
+	for _, t := range blockTypes {
+		if t.mc7 == mc7 {
+			return t.code, true
+		}
+	}
+	return 0, false
+}
+
+func blockTypeName(code byte) string {
+	for _, t := range blockTypes {
+		if t.code == code {
+			return t.name
+		}
+	}
+	return fmt.Sprintf("%#02x", code)
+}
+
+// fileName names a block the way the CPU does: type, five digits of number
+// and A for the active file system.
+func fileName(code byte, number uint16) []byte {
+	return []byte(fmt.Sprintf("0%c%05dA", code, number))
+}
+
+// siemensTime is a time counted in days since 1984 and milliseconds since
+// midnight.
+func siemensTime(days uint16, ms uint32) time.Time {
+	return time.Date(1984, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)).Add(time.Duration(ms) * time.Millisecond)
+}
+
+// listBlocks counts the blocks of every type and lists the numbers of the
+// types there are blocks of.
+func listBlocks(h *gos7.TCPClientHandler) (*pb.BlockList, error) {
+	data, code, err := userData(h, groupBlock, blockListAll, []byte{0x0A, 0, 0, 0})
+	if err != nil {
+		return nil, err
+	}
+	if code != 0xFF {
+		return nil, fmt.Errorf("s7: block list return code %#02x", code)
+	}
+	counts := make(map[byte]int)
+	for i := 0; i+4 <= len(data); i += 4 {
+		counts[data[i+1]] = int(binary.BigEndian.Uint16(data[i+2:]))
+	}
+	list := &pb.BlockList{}
+	for _, t := range blockTypes {
+		bt := &pb.BlockList_Type{Type: t.name, Count: uint32(counts[t.code])}
+		if counts[t.code] > 0 {
+			data, code, err := userData(h, groupBlock, blockListOfType, []byte{0xFF, 0x09, 0, 2, 0x30, t.code})
+			if err != nil {
+				return nil, err
+			}
+			if code != 0xFF {
+				return nil, fmt.Errorf("s7: %s list return code %#02x", t.name, code)
+			}
+			// each block is its number, flags and language
+			for i := 0; i+4 <= len(data); i += 4 {
+				bt.Numbers = append(bt.Numbers, uint32(binary.BigEndian.Uint16(data[i:])))
+			}
+		}
+		list.Types = append(list.Types, bt)
+	}
+	return list, nil
+}
+
+// blockInfo reads the header of a block.
+func blockInfo(h *gos7.TCPClientHandler, code byte, number uint16) (*pb.BlockInfo, error) {
+	data, rc, err := userData(h, groupBlock, blockGetInfo, append([]byte{0xFF, 0x09, 0, 8}, fileName(code, number)...))
+	if err != nil {
+		return nil, err
+	}
+	if rc != 0xFF {
+		return nil, errNoBlock
+	}
+	if len(data) < 70 {
+		return nil, errShortResponse
+	}
+	codeDate, _ := ptypes.TimestampProto(siemensTime(binary.BigEndian.Uint16(data[26:]), binary.BigEndian.Uint32(data[22:])))
+	intfDate, _ := ptypes.TimestampProto(siemensTime(binary.BigEndian.Uint16(data[32:]), binary.BigEndian.Uint32(data[28:])))
+	language, ok := blockLanguages[data[10]]
+	if !ok {
+		language = strconv.Itoa(int(data[10]))
+	}
+	return &pb.BlockInfo{
+		Type:          blockTypeName(code),
+		Number:        uint32(binary.BigEndian.Uint16(data[12:])),
+		Language:      language,
+		Flags:         uint32(data[9]),
+		LoadSize:      binary.BigEndian.Uint32(data[14:]),
+		CodeDate:      codeDate,
+		InterfaceDate: intfDate,
+		SbbLength:     uint32(binary.BigEndian.Uint16(data[34:])),
+		LocalData:     uint32(binary.BigEndian.Uint16(data[38:])),
+		Mc7Size:       uint32(binary.BigEndian.Uint16(data[40:])),
+		Author:        text(data[42:50]),
+		Family:        text(data[50:58]),
+		Header:        text(data[58:66]),
+		Version:       fmt.Sprintf("%d.%d", data[66]>>4, data[66]&0x0F),
+		Checksum:      uint32(binary.BigEndian.Uint16(data[68:])),
+	}, nil
+}
+
+// upload uploads a block as it is in load memory: start upload returns an
+// id for the upload, upload the block a PDU at a time and end upload ends
+// it.
+func upload(h *gos7.TCPClientHandler, code byte, number uint16) ([]byte, error) {
+	params := append([]byte{funcStartUpload, 0, 0, 0, 0, 0, 0, 0, 9, '_'}, fileName(code, number)...)
+	resp, err := exchange(h, telegram(rosctrJob, params, nil))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < ackParams+8 || resp[ackParams] != funcStartUpload {
+		return nil, errShortResponse
+	}
+	id := append([]byte(nil), resp[ackParams+4:ackParams+8]...)
+
+	var block []byte
+	for {
+		resp, err := exchange(h, telegram(rosctrJob, append([]byte{funcUpload, 0, 0, 0}, id...), nil))
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) < ackParams+2 || resp[ackParams] != funcUpload {
+			return nil, errShortResponse
+		}
+		at := ackParams + int(binary.BigEndian.Uint16(resp[13:]))
+		if at > len(resp) {
+			return nil, errShortResponse
+		}
+		data := resp[at:]
+		if len(data) < 4 {
+			return nil, errShortResponse
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		if len(data) < 4+n {
+			return nil, errShortResponse
+		}
+		block = append(block, data[4:4+n]...)
+		// the status says whether more data follows
+		if resp[ackParams+1]&0x01 == 0 {
+			break
+		}
+	}
+
+	if _, err := exchange(h, telegram(rosctrJob, append([]byte{funcEndUpload, 0, 0, 0}, id...), nil)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// blockError maps the errors of a block that is not there to NotFound.
+func blockError(err error) error {
+	if err == errNoBlock || err == ErrorCode(0xD209) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return err
+}
+
+func blockReq(req *pb.BlockReq) (byte, uint16, error) {
+	code, ok := blockType(req.GetType())
+	if !ok {
+		return 0, 0, status.Error(codes.InvalidArgument, fmt.Sprintf("block type %q", req.GetType()))
+	}
+	if req.GetNumber() > 0xFFFF {
+		return 0, 0, status.Error(codes.InvalidArgument, "block numbers are 16 bit")
+	}
+	return code, uint16(req.GetNumber()), nil
+}
+
+func (s *PlcServer) ListBlocks(ctx context.Context, plc *pb.Plc) (*pb.BlockList, error) {
+	c, err := s7Conn(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return listBlocks(c.handler)
+}
+
+func (s *PlcServer) GetBlockInfo(ctx context.Context, req *pb.BlockReq) (*pb.BlockInfo, error) {
+	code, number, err := blockReq(req)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s7Conn(ctx, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	info, err := blockInfo(c.handler, code, number)
+	return info, blockError(err)
+}
+
+// UploadBlock uploads a block and cuts the MC7 code out of it, by the MC7
+// size of its header.
+func (s *PlcServer) UploadBlock(ctx context.Context, req *pb.BlockReq) (*pb.Block, error) {
+	code, number, err := blockReq(req)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s7Conn(ctx, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	info, err := blockInfo(c.handler, code, number)
+	if err != nil {
+		return nil, blockError(err)
+	}
+	data, err := upload(c.handler, code, number)
+	if err != nil {
+		return nil, blockError(err)
+	}
+	end := blockHeaderSize + int(info.GetMc7Size())
+	if end > len(data) {
+		return nil, fmt.Errorf("s7: uploaded %d bytes of a block with %d bytes of MC7", len(data), info.GetMc7Size())
+	}
+	return &pb.Block{Info: info, Data: data, Mc7: data[blockHeaderSize:end]}, nil
+}
@@ -0,0 +1,181 @@
+package s7
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	groupTime = 0x47
+
+	clockRead = 0x01
+	clockSet  = 0x02
+)
+
+func bcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
+
+func toBcd(v int) byte {
+	return byte(v/10<<4 | v%10)
+}
+
+// location is the time zone the clock of plc runs in, from its time_zone
+// option, UTC without one.
+func location(plc *pb.Plc) (*time.Location, error) {
+	name := plc.GetOptions()["time_zone"]
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("time_zone: %v", err))
+	}
+	return loc, nil
+}
+
+// decodeClock decodes the clock as the CPU sends it: a reserved byte, then
+// year, month, day, hour, minute, second and milliseconds in BCD, the last
+// digit of the milliseconds sharing a byte with the weekday.
+func decodeClock(b []byte, loc *time.Location) (time.Time, error) {
+	if len(b) < 10 {
+		return time.Time{}, errShortResponse
+	}
+	ms := bcd(b[8])*10 + int(b[9]>>4)
+	return time.Date(bcd(b[1])*100+bcd(b[2]), time.Month(bcd(b[3])), bcd(b[4]),
+		bcd(b[5]), bcd(b[6]), bcd(b[7]), ms*int(time.Millisecond), loc), nil
+}
+
+func encodeClock(t time.Time) []byte {
+	ms := t.Nanosecond() / int(time.Millisecond)
+	return []byte{0, toBcd(t.Year() / 100), toBcd(t.Year() % 100), toBcd(int(t.Month())), toBcd(t.Day()),
+		toBcd(t.Hour()), toBcd(t.Minute()), toBcd(t.Second()), toBcd(ms / 10), byte(ms%10)<<4 | byte(t.Weekday()+1)}
+}
+
+// readClock reads the clock of the CPU and how far it is ahead of the
+// host, taking the host time halfway through the exchange.
+func readClock(h *gos7.TCPClientHandler, loc *time.Location) (time.Time, time.Duration, error) {
+	before := time.Now()
+	payload, code, err := userData(h, groupTime, clockRead, []byte{0x0A, 0, 0, 0})
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	host := before.Add(time.Since(before) / 2)
+	if code != 0xFF {
+		return time.Time{}, 0, fmt.Errorf("s7: read clock: return code %#02x", code)
+	}
+	t, err := decodeClock(payload, loc)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return t, t.Sub(host), nil
+}
+
+func setClock(h *gos7.TCPClientHandler, t time.Time) error {
+	data := append([]byte{0xFF, 0x09, 0, 10}, encodeClock(t)...)
+	_, _, err := userData(h, groupTime, clockSet, data)
+	return err
+}
+
+func plcTime(t time.Time, drift time.Duration) *pb.PlcTime {
+	ts, _ := ptypes.TimestampProto(t)
+	return &pb.PlcTime{Time: ts, Drift: ptypes.DurationProto(drift)}
+}
+
+func (s *PlcServer) GetPlcTime(ctx context.Context, plc *pb.Plc) (*pb.PlcTime, error) {
+	loc, err := location(plc)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s7Conn(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	t, drift, err := readClock(c.handler, loc)
+	if err != nil {
+		return nil, err
+	}
+	return plcTime(t, drift), nil
+}
+
+// SetPlcTime sets the clock and reads it back.
+func (s *PlcServer) SetPlcTime(ctx context.Context, req *pb.SetPlcTimeReq) (*pb.PlcTime, error) {
+	entry := audit.Entry{Action: "set_plc_time", Plc: plcName(req.GetPlc())}
+	res, err := func() (*pb.PlcTime, error) {
+		if err := engineer(ctx, &entry); err != nil {
+			return nil, err
+		}
+		var t time.Time
+		entry.Detail = "host time"
+		if req.GetTime() != nil {
+			var err error
+			if t, err = ptypes.Timestamp(req.GetTime()); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			entry.Detail = t.UTC().Format(time.RFC3339Nano)
+		}
+		drift, err := s.setPlcTime(ctx, req.GetPlc(), t)
+		if err != nil {
+			return nil, err
+		}
+		entry.Detail += fmt.Sprintf(", drift was %v", drift)
+		return s.GetPlcTime(ctx, req.GetPlc())
+	}()
+	switch {
+	case entry.Result != "":
+	case err != nil:
+		entry.Result = err.Error()
+	default:
+		entry.Result = "ok"
+	}
+	s.Audit.Record(ctx, entry)
+	return res, err
+}
+
+// setPlcTime sets the clock of plc to t, or to the time of the host when t
+// is zero, and returns the drift before.
+func (s *PlcServer) setPlcTime(ctx context.Context, plc *pb.Plc, t time.Time) (time.Duration, error) {
+	loc, err := location(plc)
+	if err != nil {
+		return 0, err
+	}
+	c, err := s7Conn(ctx, plc)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	_, drift, err := readClock(c.handler, loc)
+	if err != nil {
+		return 0, err
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return drift, setClock(c.handler, t.In(loc))
+}
+
+// PlcClockDrift is how far the clock of plc is ahead of that of the host.
+func (s *PlcServer) PlcClockDrift(ctx context.Context, plc *pb.Plc) (time.Duration, error) {
+	res, err := s.GetPlcTime(ctx, plc)
+	if err != nil {
+		return 0, err
+	}
+	return ptypes.Duration(res.GetDrift())
+}
+
+// SyncPlcClock sets the clock of plc to the time of the host and returns
+// the drift before. Unlike SetPlcTime it checks no role and audits
+// nothing; the clock monitor does.
+func (s *PlcServer) SyncPlcClock(ctx context.Context, plc *pb.Plc) (time.Duration, error) {
+	return s.setPlcTime(ctx, plc, time.Time{})
+}
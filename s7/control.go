@@ -0,0 +1,210 @@
+package s7
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/integrity"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// ConfirmTimeout is how long a confirmation token stays valid.
+const ConfirmTimeout = time.Minute
+
+// confirmation is an operation a caller has been asked to confirm.
+type confirmation struct {
+	caller    string
+	operation string
+	expires   time.Time
+}
+
+// confirmations holds the outstanding confirmation tokens.
+type confirmations struct {
+	mu      sync.Mutex
+	pending map[string]confirmation
+}
+
+// issue returns a new token for caller to confirm operation with.
+func (c *confirmations) issue(caller, operation string) (string, time.Time, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[string]confirmation)
+	}
+	for t, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, t)
+		}
+	}
+	expires := now.Add(ConfirmTimeout)
+	c.pending[token] = confirmation{caller: caller, operation: operation, expires: expires}
+	return token, expires, nil
+}
+
+// take consumes token and reports whether it confirms operation for
+// caller.
+func (c *confirmations) take(token, caller, operation string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[token]
+	delete(c.pending, token)
+	return ok && p.caller == caller && p.operation == operation && time.Now().Before(p.expires)
+}
+
+// plcName identifies plc, down to its rack and slot, in the audit log and
+// in the operations confirmation tokens are for.
+func plcName(plc *pb.Plc) string {
+	return integrity.Key(plc)
+}
+
+// cpuControl connects to plc for a CpuControl.
+func cpuControl(ctx context.Context, d driver.Driver, plc *pb.Plc) (driver.Conn, driver.CpuControl, error) {
+	conn, err := d.Connect(ctx, plc)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctl, ok := conn.(driver.CpuControl)
+	if !ok {
+		conn.Close()
+		return nil, nil, unsupported(driver.ErrUnsupported)
+	}
+	return conn, ctl, nil
+}
+
+// lookupCpuControl returns the driver of plc if it can control CPUs.
+func lookupCpuControl(plc *pb.Plc) (driver.Driver, error) {
+	d, err := lookup(plc, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !d.Capabilities().CpuControl {
+		return nil, unsupported(driver.ErrUnsupported)
+	}
+	return d, nil
+}
+
+func (s *PlcServer) GetCpuState(ctx context.Context, req *pb.Plc) (*pb.CpuState, error) {
+	d, err := lookupCpuControl(req)
+	if err != nil {
+		return nil, err
+	}
+	conn, ctl, err := cpuControl(ctx, d, req)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	state, err := ctl.CpuState(ctx)
+	if err != nil {
+		return nil, unsupported(err)
+	}
+	return &pb.CpuState{State: state}, nil
+}
+
+func (s *PlcServer) StartCpu(ctx context.Context, req *pb.CpuControlReq) (*pb.CpuControlResult, error) {
+	detail := "warm"
+	if req.GetMode() == pb.CpuControlReq_COLD {
+		detail = "cold"
+	}
+	return s.control(ctx, req, "start_cpu", detail, func(ctl driver.CpuControl) error {
+		return ctl.StartCpu(ctx, req.GetMode() == pb.CpuControlReq_COLD)
+	})
+}
+
+func (s *PlcServer) StopCpu(ctx context.Context, req *pb.CpuControlReq) (*pb.CpuControlResult, error) {
+	return s.control(ctx, req, "stop_cpu", "", func(ctl driver.CpuControl) error {
+		return ctl.StopCpu(ctx)
+	})
+}
+
+// control runs a gated operation: only engineers may, and only with a
+// confirmation token from a previous call for the same operation. Every
+// call is audited.
+func (s *PlcServer) control(ctx context.Context, req *pb.CpuControlReq, action, detail string, op func(driver.CpuControl) error) (*pb.CpuControlResult, error) {
+	entry := audit.Entry{Action: action, Plc: plcName(req.GetPlc()), Detail: detail}
+	result, err := s.doControl(ctx, req, &entry, op)
+	switch {
+	case entry.Result != "":
+	case err != nil:
+		entry.Result = err.Error()
+	case result.GetConfirm() != "":
+		entry.Result = "confirm"
+	default:
+		entry.Result = "ok"
+	}
+	s.Audit.Record(ctx, entry)
+	return result, err
+}
+
+func (s *PlcServer) doControl(ctx context.Context, req *pb.CpuControlReq, entry *audit.Entry, op func(driver.CpuControl) error) (*pb.CpuControlResult, error) {
+	if err := engineer(ctx, entry); err != nil {
+		return nil, err
+	}
+	d, err := lookupCpuControl(req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	token, expires, err := s.confirm(ctx, entry, req.GetConfirm())
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		ts, _ := ptypes.TimestampProto(expires)
+		return &pb.CpuControlResult{Confirm: token, ConfirmExpires: ts}, nil
+	}
+	conn, ctl, err := cpuControl(ctx, d, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := op(ctl); err != nil {
+		return nil, unsupported(err)
+	}
+	state, err := ctl.CpuState(ctx)
+	if err != nil {
+		// the operation went through, only the state is unknown
+		state = pb.CpuState_UNKNOWN
+	}
+	return &pb.CpuControlResult{State: &pb.CpuState{State: state}}, nil
+}
+
+// engineer refuses callers below engineer, marking entry denied.
+func engineer(ctx context.Context, entry *audit.Entry) error {
+	if caller, ok := auth.FromContext(ctx); !ok || !caller.Role.Allows(auth.Engineer) {
+		entry.Result = "denied"
+		return status.Error(codes.PermissionDenied, "engineer role required")
+	}
+	return nil
+}
+
+// confirm checks that token confirms the operation of entry for the
+// caller. Without a token it issues one, returned with when it expires, for
+// the caller to repeat the call with.
+func (s *PlcServer) confirm(ctx context.Context, entry *audit.Entry, token string) (string, time.Time, error) {
+	caller, _ := auth.FromContext(ctx)
+	operation := fmt.Sprintf("%s %s %s", entry.Action, entry.Detail, entry.Plc)
+	if token == "" {
+		return s.confirmations.issue(caller.Name, operation)
+	}
+	if !s.confirmations.take(token, caller.Name, operation) {
+		entry.Result = "denied"
+		return "", time.Time{}, status.Error(codes.FailedPrecondition, "confirmation token invalid or expired")
+	}
+	return "", time.Time{}, nil
+}
@@ -0,0 +1,259 @@
+package s7
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	szlDiagnosticBuffer = 0x00A0
+	// diagnosticEntrySize is the length of an entry of the buffer
+	diagnosticEntrySize = 20
+
+	// DefaultDiagnosticInterval is how often WatchDiagnosticBuffer reads
+	// the buffer unless asked otherwise, MinDiagnosticInterval the least.
+	DefaultDiagnosticInterval = time.Second
+	MinDiagnosticInterval     = 100 * time.Millisecond
+	// DefaultDiagnosticOutage is how long WatchDiagnosticBuffer waits for
+	// a CPU it cannot read to answer again.
+	DefaultDiagnosticOutage = time.Minute
+)
+
+// eventTexts is the catalog of the events of S7-300 and S7-400 CPUs most
+// often seen in diagnostic buffers.
+var eventTexts = map[uint16]string{
+	0x2521: "BCD conversion error",
+	0x2522: "Area length error when reading",
+	0x2523: "Area length error when writing",
+	0x2524: "Area error when reading",
+	0x2525: "Area error when writing",
+	0x2526: "Timer number error",
+	0x2527: "Counter number error",
+	0x2528: "Alignment error when reading",
+	0x2529: "Alignment error when writing",
+	0x2530: "Write error when accessing the DB",
+	0x2531: "Write error when accessing the DI",
+	0x2532: "Block number error when opening a DB",
+	0x2533: "Block number error when opening a DI",
+	0x2534: "Block number error when calling an FC",
+	0x2535: "Block number error when calling an FB",
+	0x253A: "DB not loaded",
+	0x253C: "FC not loaded",
+	0x253D: "SFC not loaded",
+	0x253E: "FB not loaded",
+	0x253F: "SFB not loaded",
+	0x2942: "I/O access error, reading",
+	0x2943: "I/O access error, writing",
+	0x3501: "Cycle time exceeded",
+	0x3502: "User interface (OB or FRB) request error",
+	0x3505: "Time-of-day interrupt(s) skipped due to new clock setting",
+	0x3507: "Multiple OB request errors caused internal buffer overflow",
+	0x3821: "BATTF: backup battery problem eliminated",
+	0x3921: "BATTF: failure of at least one backup battery",
+	0x3822: "BAF: backup voltage failure eliminated",
+	0x3922: "BAF: backup voltage failure",
+	0x3861: "Module inserted, module type OK",
+	0x3961: "Module removed or cannot be addressed",
+	0x38C4: "Distributed I/O: station returned",
+	0x39C4: "Distributed I/O: station failure",
+	0x4301: "Mode transition from STOP to STARTUP",
+	0x4302: "Mode transition from STARTUP to RUN",
+	0x4303: "STOP caused by stop switch being activated",
+	0x4304: "STOP caused by PG STOP operation or by SFB 20 STOP",
+	0x4305: "HOLD: breakpoint reached",
+	0x4306: "HOLD: breakpoint exited",
+	0x4307: "Memory reset started by PG operation",
+	0x4308: "Memory reset started by switch setting",
+	0x4309: "Memory reset started automatically (power on not backed up)",
+	0x430A: "HOLD exited, transition to STOP",
+	0x430D: "STOP caused by other CPU in multicomputing",
+	0x430E: "Memory reset executed",
+	0x4520: "DEFECTIVE: STOP not possible",
+	0x4521: "DEFECTIVE: failure of instruction processing processor",
+	0x4562: "STOP caused by programming error (OB not loaded or not possible)",
+	0x4563: "STOP caused by I/O access error (OB not loaded or not possible)",
+	0x4567: "STOP caused by H event",
+	0x4568: "STOP caused by time error (OB not loaded or not possible)",
+	0x456A: "STOP caused by diagnostic interrupt (OB not loaded or not possible)",
+	0x456B: "STOP caused by removing or inserting a module (OB not loaded or not possible)",
+	0x456C: "STOP caused by CPU hardware error (OB not loaded or not possible)",
+	0x456D: "STOP caused by program sequence error (OB not loaded or not possible)",
+	0x456E: "STOP caused by communication error (OB not loaded or not possible)",
+	0x456F: "STOP caused by rack failure (OB not loaded or not possible)",
+	0x4571: "STOP caused by nesting stack error",
+	0x4572: "STOP caused by master control relay stack error",
+	0x4573: "STOP caused by exceeding the nesting depth for synchronous errors",
+	0x4574: "STOP caused by exceeding the interrupt stack nesting depth",
+	0x4575: "STOP caused by exceeding the block stack nesting depth",
+	0x4576: "STOP caused by error when allocating the local data",
+	0x4578: "STOP caused by unknown opcode",
+	0x457A: "STOP caused by code length error",
+	0x457F: "STOP caused by STOP command",
+	0x4580: "STOP: backup buffer contents inconsistent",
+	0x4590: "STOP caused by overloading the internal functions",
+}
+
+// eventClasses name the events outside the catalog by their class, the
+// high nibble of the event id.
+var eventClasses = map[uint16]string{
+	0x1: "Standard OB event",
+	0x2: "Synchronous error",
+	0x3: "Asynchronous error",
+	0x4: "Mode transition",
+	0x5: "Run-time event",
+	0x6: "Communication event",
+	0x7: "H/F system event",
+	0x8: "Module diagnostic event",
+	0x9: "User event",
+	0xA: "User event",
+	0xB: "User event",
+}
+
+// EventText describes the diagnostic event id.
+func EventText(id uint16) string {
+	if text, ok := eventTexts[id]; ok {
+		return text
+	}
+	if class, ok := eventClasses[id>>12]; ok {
+		return fmt.Sprintf("%s %#04x", class, id)
+	}
+	return fmt.Sprintf("Event %#04x", id)
+}
+
+// decodeDiagnosticEntry decodes an entry of the diagnostic buffer: the
+// event id, priority class, OB number, data id, two words of information
+// and the time as DATE_AND_TIME.
+func decodeDiagnosticEntry(r []byte) *pb.DiagnosticEntry {
+	var helper gos7.Helper
+	id := binary.BigEndian.Uint16(r)
+	ts, _ := ptypes.TimestampProto(helper.GetDateTimeAt(r, 12))
+	return &pb.DiagnosticEntry{
+		EventId:  uint32(id),
+		Priority: uint32(r[2]),
+		ObNumber: uint32(r[3]),
+		DatId:    uint32(binary.BigEndian.Uint16(r[4:])),
+		Info1:    uint32(binary.BigEndian.Uint16(r[6:])),
+		Info2:    binary.BigEndian.Uint32(r[8:]),
+		Time:     ts,
+		Text:     EventText(id),
+	}
+}
+
+// diagnosticRecords reads the entries of the diagnostic buffer of plc as
+// they are stored, newest first.
+func diagnosticRecords(ctx context.Context, plc *pb.Plc) ([][]byte, error) {
+	c, err := s7Conn(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	list, err := readSzl(c.handler, szlDiagnosticBuffer, 0)
+	if err != nil {
+		return nil, err
+	}
+	if list.size != diagnosticEntrySize {
+		return nil, fmt.Errorf("s7: diagnostic entries of %d bytes", list.size)
+	}
+	var records [][]byte
+	for i := 0; i < list.count; i++ {
+		r := list.record(i)
+		if r == nil {
+			break
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+func (s *PlcServer) GetDiagnosticBuffer(ctx context.Context, req *pb.DiagnosticReq) (*pb.DiagnosticBuffer, error) {
+	records, err := diagnosticRecords(ctx, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	buf := &pb.DiagnosticBuffer{}
+	for _, r := range records {
+		buf.Entries = append(buf.Entries, decodeDiagnosticEntry(r))
+	}
+	return buf, nil
+}
+
+// WatchDiagnosticBuffer reads the buffer every interval and sends the
+// entries that are new since the last read. When the buffer overflows
+// between reads the entries lost on the way are not sent. A failed read is
+// logged and the next one tried; the stream ends when the reads fail for
+// longer than the DiagnosticOutage of s.
+func (s *PlcServer) WatchDiagnosticBuffer(req *pb.DiagnosticReq, stream pb.PlcRW_WatchDiagnosticBufferServer) error {
+	interval := DefaultDiagnosticInterval
+	if req.GetInterval() != nil {
+		d, err := ptypes.Duration(req.GetInterval())
+		if err != nil || d < MinDiagnosticInterval {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("interval below %v", MinDiagnosticInterval))
+		}
+		interval = d
+	}
+	ctx := stream.Context()
+	records, err := diagnosticRecords(ctx, req.GetPlc())
+	if err != nil {
+		return err
+	}
+	var newest []byte
+	if len(records) > 0 {
+		newest = records[0]
+	}
+	outage := s.DiagnosticOutage
+	if outage == 0 {
+		outage = DefaultDiagnosticOutage
+	}
+	// failing is when the reads began to fail, zero while they succeed
+	var failing time.Time
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		records, err := diagnosticRecords(ctx, req.GetPlc())
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if failing.IsZero() {
+				failing = time.Now()
+			}
+			if time.Since(failing) >= outage {
+				return status.Error(codes.Unavailable, fmt.Sprintf("diagnostic buffer unread for %v: %v", outage, err))
+			}
+			log.Printf("s7: diagnostic buffer of %s: %v", plcName(req.GetPlc()), err)
+			continue
+		}
+		failing = time.Time{}
+		n := len(records)
+		for i, r := range records {
+			if newest != nil && bytes.Equal(r, newest) {
+				n = i
+				break
+			}
+		}
+		for i := n - 1; i >= 0; i-- {
+			if err := stream.Send(decodeDiagnosticEntry(records[i])); err != nil {
+				return err
+			}
+		}
+		if len(records) > 0 {
+			newest = records[0]
+		}
+	}
+}
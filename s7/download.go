@@ -0,0 +1,238 @@
+package s7
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const (
+	funcStartDownload = 0x1A
+	funcDownloadBlock = 0x1B
+	funcDownloadEnded = 0x1C
+
+	// file systems: passive for a block downloaded but not yet inserted,
+	// both for deleting
+	fsPassive = 'P'
+	fsBoth    = 'B'
+
+	// protectionNone is the protection level of a CPU anyone may write to
+	protectionNone = 1
+)
+
+// protection reads the protection level in effect, 0 when the CPU does not
+// say.
+func protection(h *gos7.TCPClientHandler) (int, error) {
+	s, err := readSzl(h, szlProtection, 4)
+	if unavailable(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if r := s.record(0); len(r) >= 8 {
+		return int(binary.BigEndian.Uint16(r[6:])), nil
+	}
+	return 0, nil
+}
+
+// checkProtection refuses to change the blocks of a CPU protected against
+// writes, unless the connection sent the password.
+func checkProtection(c *conn) error {
+	if c.legitimated {
+		return nil
+	}
+	level, err := protection(c.handler)
+	if err != nil {
+		return err
+	}
+	if level > protectionNone {
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf("CPU protection: level %d forbids changing blocks without a password", level))
+	}
+	return nil
+}
+
+// blockService frames a call of the PI service _INSE or _DELE for a block
+// in file system fs.
+func blockService(service string, fs, code byte, number uint16) []byte {
+	name := fileName(code, number)
+	name[7] = fs
+	params := append([]byte{funcStart, 0, 0, 0, 0, 0, 0, 0xFD, 0, 0x0A, 1, 0}, name...)
+	return telegram(rosctrJob, append(append(params, byte(len(service))), service...), nil)
+}
+
+func serviceResult(resp []byte) error {
+	if len(resp) <= ackParams || resp[ackParams] != funcStart {
+		return errShortResponse
+	}
+	if len(resp) > ackParams+1 && resp[ackParams+1] != 0 {
+		return fmt.Errorf("s7: program invocation result %#02x", resp[ackParams+1])
+	}
+	return nil
+}
+
+// download loads block into the passive file system and inserts it. After
+// the request to download the CPU asks for the block a PDU at a time, then
+// ends the download; its requests are read with an empty Send.
+func download(h *gos7.TCPClientHandler, code byte, number uint16, block []byte) error {
+	params := append([]byte{funcStartDownload, 0, 0, 0, 0, 0, 0, 0, 9, '_'}, fileName(code, number)...)
+	params[len(params)-1] = fsPassive
+	params = append(params, 0x0D, '1')
+	params = append(params, fmt.Sprintf("%06d%06d", len(block), binary.BigEndian.Uint16(block[34:]))...)
+	resp, err := exchange(h, telegram(rosctrJob, params, nil))
+	if err != nil {
+		return err
+	}
+	if len(resp) <= ackParams || resp[ackParams] != funcStartDownload {
+		return errShortResponse
+	}
+
+	// the most block data that fits an ack data PDU
+	chunk := h.PDULength - (ackParams - 7) - 2 - 4
+	rest := block
+	req, err := exchange(h, nil)
+	for {
+		if err != nil {
+			return err
+		}
+		if req[8] != rosctrJob || len(req) <= jobParams {
+			return errShortResponse
+		}
+		ref := binary.BigEndian.Uint16(req[11:])
+		switch req[jobParams] {
+		case funcDownloadBlock:
+			n, more := len(rest), byte(0)
+			if n > chunk {
+				n, more = chunk, 1
+			}
+			data := append([]byte{byte(n >> 8), byte(n), 0, 0xFB}, rest[:n]...)
+			rest = rest[n:]
+			req, err = exchange(h, ackTelegram(ref, []byte{funcDownloadBlock, more}, data))
+		case funcDownloadEnded:
+			// the CPU has nothing more to say, so acknowledging the end
+			// goes with inserting the block
+			resp, err := exchange(h, append(ackTelegram(ref, []byte{funcDownloadEnded}, nil), blockService("_INSE", fsPassive, code, number)...))
+			if err != nil {
+				return err
+			}
+			return serviceResult(resp)
+		default:
+			return fmt.Errorf("s7: unexpected function %#02x during download", req[jobParams])
+		}
+	}
+}
+
+func (s *PlcServer) DownloadBlock(ctx context.Context, req *pb.DownloadBlockReq) (*pb.BlockOperationResult, error) {
+	code, number, err := blockReq(&pb.BlockReq{Type: req.GetType(), Number: req.GetNumber()})
+	if err != nil {
+		return nil, err
+	}
+	data := req.GetData()
+	if len(data) < blockHeaderSize || int(binary.BigEndian.Uint32(data[8:])) != len(data) ||
+		blockHeaderSize+int(binary.BigEndian.Uint16(data[34:])) > len(data) {
+		return nil, status.Error(codes.InvalidArgument, "data is not a block as uploaded")
+	}
+	// the CPU takes the block the header names, whatever the file name
+	if typ, _ := headerType(data[5]); typ != code || binary.BigEndian.Uint16(data[6:]) != number {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("data is %s%d, not %s%d", blockTypeName(typ), binary.BigEndian.Uint16(data[6:]), req.GetType(), number))
+	}
+	if req.GetReason() == "" {
+		return nil, status.Error(codes.InvalidArgument, "a reason is required")
+	}
+	sum := sha256.Sum256(data)
+	detail := fmt.Sprintf("%s%d sha256:%s (%d bytes): %s", req.GetType(), number, hex.EncodeToString(sum[:8]), len(data), req.GetReason())
+	return s.blockOperation(ctx, req.GetPlc(), "download_block", detail, req.GetConfirm(), func(h *gos7.TCPClientHandler) (*pb.BlockInfo, error) {
+		if err := download(h, code, number, data); err != nil {
+			return nil, err
+		}
+		return blockInfo(h, code, number)
+	})
+}
+
+func (s *PlcServer) DeleteBlock(ctx context.Context, req *pb.DeleteBlockReq) (*pb.BlockOperationResult, error) {
+	code, number, err := blockReq(&pb.BlockReq{Type: req.GetType(), Number: req.GetNumber()})
+	if err != nil {
+		return nil, err
+	}
+	if req.GetReason() == "" {
+		return nil, status.Error(codes.InvalidArgument, "a reason is required")
+	}
+	detail := fmt.Sprintf("%s%d: %s", req.GetType(), number, req.GetReason())
+	return s.blockOperation(ctx, req.GetPlc(), "delete_block", detail, req.GetConfirm(), func(h *gos7.TCPClientHandler) (*pb.BlockInfo, error) {
+		resp, err := exchange(h, blockService("_DELE", fsBoth, code, number))
+		if err != nil {
+			return nil, blockError(err)
+		}
+		return nil, serviceResult(resp)
+	})
+}
+
+// blockOperation runs gated an operation on the blocks of plc, if the CPU
+// is not protected or the password was sent.
+func (s *PlcServer) blockOperation(ctx context.Context, plc *pb.Plc, action, detail, confirm string, op func(*gos7.TCPClientHandler) (*pb.BlockInfo, error)) (*pb.BlockOperationResult, error) {
+	var info *pb.BlockInfo
+	token, expires, err := s.gated(ctx, plc, action, detail, confirm, func(c *conn, entry *audit.Entry) error {
+		if err := checkProtection(c); err != nil {
+			entry.Result = "denied"
+			return err
+		}
+		var err error
+		info, err = op(c.handler)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		ts, _ := ptypes.TimestampProto(expires)
+		return &pb.BlockOperationResult{Confirm: token, ConfirmExpires: ts}, nil
+	}
+	return &pb.BlockOperationResult{Info: info}, nil
+}
+
+// gated gates and audits an operation on the S7 CPU plc like control does
+// the operating state: it returns a confirmation token, or with one runs op
+// connected to the CPU. op may mark entry denied.
+func (s *PlcServer) gated(ctx context.Context, plc *pb.Plc, action, detail, confirm string, op func(*conn, *audit.Entry) error) (string, time.Time, error) {
+	entry := audit.Entry{Action: action, Plc: plcName(plc), Detail: detail}
+	token, expires, err := func() (string, time.Time, error) {
+		if err := engineer(ctx, &entry); err != nil {
+			return "", time.Time{}, err
+		}
+		if err := checkS7(plc); err != nil {
+			return "", time.Time{}, err
+		}
+		token, expires, err := s.confirm(ctx, &entry, confirm)
+		if err != nil || token != "" {
+			return token, expires, err
+		}
+		c, err := s7Conn(ctx, plc)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		defer c.Close()
+		return "", time.Time{}, protectionError(op(c, &entry))
+	}()
+	switch {
+	case entry.Result != "":
+	case err != nil:
+		entry.Result = err.Error()
+	case token != "":
+		entry.Result = "confirm"
+	default:
+		entry.Result = "ok"
+	}
+	s.Audit.Record(ctx, entry)
+	return token, expires, err
+}
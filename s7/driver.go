@@ -0,0 +1,231 @@
+package s7
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	gos7 "github.com/thinkontrolsy/gos7"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+const timeout = 5 * time.Second
+
+func init() {
+	driver.Register("s7", Driver{})
+}
+
+// Driver talks S7 communication over ISO-on-TCP to S7-300/400/1200/1500
+// CPUs. Tags are addressed as M, I, Q or DBn areas, as in DB2P4 or MP0.1,
+// or as timers and counters by number, as in T12 (S5Time) or C5 (Counter).
+type Driver struct{}
+
+func (Driver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	handler := gos7.NewTCPClientHandler(plc.GetHost(), int(plc.GetRack()), int(plc.GetSlot()))
+	if plc.GetPort() != 0 {
+		handler.Address = net.JoinHostPort(plc.GetHost(), strconv.Itoa(int(plc.GetPort())))
+	}
+	handler.Timeout = timeout
+	handler.IdleTimeout = timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < handler.Timeout {
+		handler.Timeout = time.Until(deadline)
+	}
+	if err := handler.Connect(); err != nil {
+		return nil, err
+	}
+	password := sessionPassword(plc)
+	if password != "" {
+		if err := legitimate(handler, password); err != nil {
+			handler.Close()
+			return nil, protectionError(err)
+		}
+	}
+	g := &guard{}
+	return &conn{handler: handler, guard: g, client: gos7.NewClient2(g, handler), legitimated: password != ""}, nil
+}
+
+func (Driver) Validate(tag *pb.Tag) error {
+	_, err := tag.GetArea()
+	return err
+}
+
+func (Driver) Capabilities() driver.Capabilities {
+	dts := make([]string, 0, len(pb.DT)+1)
+	for dt := range pb.DT {
+		dts = append(dts, dt)
+	}
+	dts = append(dts, "String")
+	sort.Strings(dts)
+	return driver.Capabilities{Write: true, DeviceInfo: true, CpuControl: true, Datatypes: dts}
+}
+
+type conn struct {
+	handler *gos7.TCPClientHandler
+	guard   *guard
+	client  gos7.Client
+	// legitimated is whether the password of the CPU was sent
+	legitimated bool
+}
+
+func (c *conn) Close() error {
+	return c.handler.Close()
+}
+
+// DeviceInfo describes the CPU from its system status lists. Details
+// hold what only some CPUs tell: order_code, protection_level,
+// mode_selector and max_connections, and cpu_family and pdu_size.
+func (c *conn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	id, err := readIdentity(c.handler)
+	if err != nil {
+		return nil, err
+	}
+	details := map[string]string{
+		"as_name":   id.asName,
+		"copyright": id.copyright,
+		"pdu_size":  strconv.Itoa(c.handler.PDULength),
+	}
+	for k, v := range map[string]string{
+		"order_code":    id.orderCode,
+		"mode_selector": id.modeSelector,
+		"cpu_family":    id.cpuFamily(),
+	} {
+		if v != "" {
+			details[k] = v
+		}
+	}
+	if id.protectionLevel != 0 {
+		details["protection_level"] = strconv.Itoa(id.protectionLevel)
+	}
+	if id.maxConnections != 0 {
+		details["max_connections"] = strconv.Itoa(id.maxConnections)
+	}
+	return &pb.DeviceInfo{
+		Protocol:     "s7",
+		Vendor:       "Siemens",
+		Model:        id.moduleTypeName,
+		SerialNumber: id.serialNumber,
+		Name:         id.moduleName,
+		Version:      id.firmwareVersion,
+		Details:      details,
+	}, nil
+}
+
+func (c *conn) CpuState(ctx context.Context) (pb.CpuState_State, error) {
+	return cpuState(c.handler)
+}
+
+func (c *conn) StartCpu(ctx context.Context, cold bool) error {
+	return protectionError(programInvocation(c.handler, funcStart, cold))
+}
+
+func (c *conn) StopCpu(ctx context.Context) error {
+	return protectionError(programInvocation(c.handler, funcStop, false))
+}
+
+func (c *conn) ReadTags(ctx context.Context, tags []*pb.Tag) error {
+	return protectionError(c.refusal(c.readTags(tags)))
+}
+
+func (c *conn) WriteTags(ctx context.Context, tags []*pb.Tag) error {
+	return protectionError(c.refusal(c.writeTags(tags)))
+}
+
+func (c *conn) readTags(tags []*pb.Tag) error {
+	client := c.client
+	for area, ag := range generateAGMap(tags) {
+		size := ag.End - ag.Start
+		ag.Buffer = make([]byte, size)
+		var err error
+		switch area {
+		case "M":
+			err = client.AGReadMB(ag.Start, size, ag.Buffer)
+		case "I":
+			err = client.AGReadAB(ag.Start, size, ag.Buffer)
+		case "Q":
+			err = client.AGReadEB(ag.Start, size, ag.Buffer)
+		case "T", "C":
+			err = readTimers(c.handler, timerArea(area), ag.Start/2, ag.Buffer)
+		default:
+			err = client.AGReadDB(ag.DBNumber, ag.Start, size, ag.Buffer)
+		}
+		if err != nil {
+			return err
+		}
+		ag.ReadBuffer()
+	}
+	return nil
+}
+
+func (c *conn) writeTags(tags []*pb.Tag) error {
+	client := c.client
+	for area, ags := range generateAGGroupMap(tags) {
+		for _, ag := range ags {
+			size := ag.End - ag.Start
+			ag.Buffer = make([]byte, size)
+			switch area {
+			case "M":
+				{
+					if ag.HasBoolTag() {
+						if err := client.AGReadMB(ag.Start, size, ag.Buffer); err != nil {
+							return err
+						}
+					}
+					ag.FillBuffer()
+					if err := client.AGWriteMB(ag.Start, size, ag.Buffer); err != nil {
+						return err
+					}
+				}
+			case "I":
+				{
+					if ag.HasBoolTag() {
+						if err := client.AGReadAB(ag.Start, size, ag.Buffer); err != nil {
+							return err
+						}
+					}
+					ag.FillBuffer()
+					if err := client.AGWriteAB(ag.Start, size, ag.Buffer); err != nil {
+						return err
+					}
+				}
+			case "Q":
+				{
+					if ag.HasBoolTag() {
+						if err := client.AGReadEB(ag.Start, size, ag.Buffer); err != nil {
+							return err
+						}
+					}
+					ag.FillBuffer()
+					if err := client.AGWriteEB(ag.Start, size, ag.Buffer); err != nil {
+						return err
+					}
+				}
+			case "T", "C":
+				// a tag at a time, so that the timers or counters between
+				// them keep their values
+				for _, tag := range ag.Tags {
+					address, _ := tag.GetArea()
+					if err := writeTimers(c.handler, timerArea(area), address.Start/2, tag.FillBuffer(0)); err != nil {
+						return err
+					}
+				}
+			default:
+				{
+					if ag.HasBoolTag() {
+						if err := client.AGReadDB(ag.DBNumber, ag.Start, size, ag.Buffer); err != nil {
+							return err
+						}
+					}
+					ag.FillBuffer()
+					if err := client.AGWriteDB(ag.DBNumber, ag.Start, size, ag.Buffer); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
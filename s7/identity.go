@@ -0,0 +1,124 @@
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	gos7 "github.com/thinkontrolsy/gos7"
+)
+
+const (
+	szlComponentIdentification = 0x001C
+	szlModuleIdentification    = 0x0011
+	szlCommunicationCapability = 0x0131
+	szlProtection              = 0x0232
+)
+
+// cpuFamilies are the families of CPUs by the start of their order codes.
+var cpuFamilies = []struct {
+	prefix, family string
+}{
+	{"6ES7 2", "S7-1200"},
+	{"6ES7 3", "S7-300"},
+	{"6ES7 4", "S7-400"},
+	{"6ES7 5", "S7-1500"},
+}
+
+var modeSelectors = map[uint16]string{
+	1: "RUN",
+	2: "RUN-P",
+	3: "STOP",
+	4: "MRES",
+}
+
+// identity is what a CPU tells about itself in its system status lists.
+// Fields of lists the CPU does not have are left empty.
+type identity struct {
+	asName, moduleName, copyright, serialNumber, moduleTypeName string
+
+	orderCode, firmwareVersion string
+
+	protectionLevel int
+	modeSelector    string
+
+	maxConnections int
+}
+
+func text(b []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+func (id *identity) cpuFamily() string {
+	for _, f := range cpuFamilies {
+		if strings.HasPrefix(id.orderCode, f.prefix) {
+			return f.family
+		}
+	}
+	return ""
+}
+
+// readIdentity reads the component identification and whatever the CPU
+// has of module identification, protection and communication capability.
+func readIdentity(h *gos7.TCPClientHandler) (*identity, error) {
+	id := &identity{}
+	s, err := readSzl(h, szlComponentIdentification, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < s.count; i++ {
+		r := s.record(i)
+		if len(r) < 2 {
+			break
+		}
+		v := text(r[2:])
+		switch binary.BigEndian.Uint16(r) {
+		case 1:
+			id.asName = v
+		case 2:
+			id.moduleName = v
+		case 4:
+			id.copyright = v
+		case 5:
+			id.serialNumber = v
+		case 7:
+			id.moduleTypeName = v
+		}
+	}
+
+	s, err = readSzl(h, szlModuleIdentification, 0)
+	if err != nil && !unavailable(err) {
+		return nil, err
+	}
+	for i := 0; err == nil && i < s.count; i++ {
+		r := s.record(i)
+		if len(r) < 28 {
+			break
+		}
+		switch binary.BigEndian.Uint16(r) {
+		case 1:
+			id.orderCode = text(r[2:22])
+		case 7:
+			// firmware, 'V' and the version in the last three bytes
+			id.firmwareVersion = fmt.Sprintf("V%d.%d.%d", r[25], r[26], r[27])
+		}
+	}
+
+	s, err = readSzl(h, szlProtection, 4)
+	if err != nil && !unavailable(err) {
+		return nil, err
+	}
+	if r := s.record(0); len(r) >= 10 {
+		id.protectionLevel = int(binary.BigEndian.Uint16(r[6:]))
+		id.modeSelector = modeSelectors[binary.BigEndian.Uint16(r[8:])]
+	}
+
+	s, err = readSzl(h, szlCommunicationCapability, 1)
+	if err != nil && !unavailable(err) {
+		return nil, err
+	}
+	if r := s.record(0); len(r) >= 6 {
+		id.maxConnections = int(binary.BigEndian.Uint16(r[4:]))
+	}
+	return id, nil
+}
@@ -0,0 +1,109 @@
+package s7
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/integrity"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+var changeKinds = map[string]pb.BlockChange_Kind{
+	integrity.Added:   pb.BlockChange_ADDED,
+	integrity.Removed: pb.BlockChange_REMOVED,
+	integrity.Changed: pb.BlockChange_CHANGED,
+}
+
+// BlockInfos reads the info of every block of plc over one connection.
+// Blocks deleted between listing and reading are left out.
+func (s *PlcServer) BlockInfos(ctx context.Context, plc *pb.Plc) ([]*pb.BlockInfo, error) {
+	c, err := s7Conn(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	list, err := listBlocks(c.handler)
+	if err != nil {
+		return nil, err
+	}
+	var infos []*pb.BlockInfo
+	for _, t := range list.GetTypes() {
+		code, _ := blockType(t.GetType())
+		for _, n := range t.GetNumbers() {
+			info, err := blockInfo(c.handler, code, uint16(n))
+			if err == errNoBlock || err == ErrorCode(0xD209) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func (s *PlcServer) CompareToBaseline(ctx context.Context, req *pb.BaselineReq) (*pb.BaselineDiff, error) {
+	if s.Baselines == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no baseline store configured")
+	}
+	if !req.GetUpdate() {
+		return s.compareToBaseline(ctx, req.GetPlc(), false)
+	}
+	entry := audit.Entry{Action: "integrity_baseline", Plc: plcName(req.GetPlc())}
+	diff, err := s.compareToBaseline(ctx, req.GetPlc(), true)
+	switch {
+	case status.Code(err) == codes.PermissionDenied:
+		entry.Result = "denied"
+	case err != nil:
+		entry.Result = err.Error()
+	default:
+		entry.Detail = fmt.Sprintf("%d changes", len(diff.GetChanges()))
+		entry.Result = "ok"
+	}
+	s.Audit.Record(ctx, entry)
+	return diff, err
+}
+
+func (s *PlcServer) compareToBaseline(ctx context.Context, plc *pb.Plc, update bool) (*pb.BaselineDiff, error) {
+	if update {
+		if caller, ok := auth.FromContext(ctx); !ok || !caller.Role.Allows(auth.Engineer) {
+			return nil, status.Error(codes.PermissionDenied, "engineer role required")
+		}
+	}
+	current, err := integrity.Collect(ctx, s, plc)
+	if err != nil {
+		return nil, err
+	}
+	base, ok := s.Baselines.Get(plc)
+	if !ok && !update {
+		return nil, status.Error(codes.NotFound, "no baseline for "+plcName(plc))
+	}
+	diff := &pb.BaselineDiff{}
+	if ok {
+		diff.BaselineTaken, _ = ptypes.TimestampProto(base.Taken)
+		for _, c := range integrity.Compare(base, current) {
+			change := &pb.BlockChange{Kind: changeKinds[c.Kind], Fields: c.Fields}
+			if c.Baseline != nil {
+				change.Type, change.Number, change.Baseline = c.Baseline.Type, c.Baseline.Number, c.Baseline.Pb()
+			}
+			if c.Current != nil {
+				change.Type, change.Number, change.Current = c.Current.Type, c.Current.Number, c.Current.Pb()
+			}
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+	if update {
+		if err := s.Baselines.Put(plc, current); err != nil {
+			return nil, err
+		}
+		diff.Updated = true
+	}
+	return diff, nil
+}
@@ -0,0 +1,265 @@
+package s7
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	gos7 "github.com/thinkontrolsy/gos7"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// The functions here are those of a programming device that gos7 lacks or
+// gets wrong. They frame S7 telegrams themselves and exchange them through
+// the Send of the handler, which adds nothing to a frame.
+
+const (
+	rosctrJob      = 0x01
+	rosctrAckData  = 0x03
+	rosctrUserData = 0x07
+
+	// userdata function groups
+	groupBlock = 0x43
+	groupSzl   = 0x44
+
+	// a job request has a 10 byte S7 header, an ack data response 12
+	jobParams      = 17
+	ackParams      = 19
+	userDataParams = 17
+
+	funcStart = 0x28
+	funcStop  = 0x29
+	// PI service results when the CPU already is in the state asked for
+	alreadyStarted = 0x02
+	alreadyStopped = 0x07
+
+	szlCpuState = 0x0424
+)
+
+var errShortResponse = errors.New("s7: response too short")
+
+// ErrorCode is the error of an S7 response: the error class and code of
+// the header, or the error code of a userdata response.
+type ErrorCode uint16
+
+var errorTexts = map[ErrorCode]string{
+	0x8104: "function not available in the current context",
+	0x8500: "PDU too large",
+	0xD209: "block not found",
+	0xD241: "protected, a password is required",
+	0xD401: "information function unavailable",
+	0xD602: "invalid password",
+	0xD402: "information function unavailable",
+}
+
+func (e ErrorCode) Error() string {
+	if text, ok := errorTexts[e]; ok {
+		return fmt.Sprintf("s7: %s (%#04x)", text, uint16(e))
+	}
+	return fmt.Sprintf("s7: error %#04x", uint16(e))
+}
+
+// szlError is the return code of an SZL read that found nothing, such as
+// 0x0A for an SZL the CPU does not have.
+type szlError struct {
+	id, index uint16
+	code      byte
+}
+
+func (e szlError) Error() string {
+	return fmt.Sprintf("s7: SZL %#04x index %#04x: return code %#02x", e.id, e.index, e.code)
+}
+
+// unavailable reports whether err says that the CPU does not have an SZL.
+func unavailable(err error) bool {
+	switch e := err.(type) {
+	case szlError:
+		return true
+	case ErrorCode:
+		return e>>8 == 0xD4 || e == 0x8104
+	}
+	return false
+}
+
+// telegram frames the params and data of an S7 PDU in TPKT and COTP.
+func telegram(rosctr byte, params, data []byte) []byte {
+	return frame(rosctr, 0x0100, params, data)
+}
+
+// ackTelegram frames the response to a job the CPU sent, with the PDU
+// reference ref of the job.
+func ackTelegram(ref uint16, params, data []byte) []byte {
+	return frame(rosctrAckData, ref, params, data)
+}
+
+func frame(rosctr byte, ref uint16, params, data []byte) []byte {
+	n := jobParams
+	if rosctr == rosctrAckData {
+		n = ackParams
+	}
+	b := make([]byte, n, n+len(params)+len(data))
+	binary.BigEndian.PutUint16(b[2:], uint16(n+len(params)+len(data)))
+	b[0], b[4], b[5], b[6] = 3, 2, 0xF0, 0x80
+	b[7], b[8] = 0x32, rosctr
+	binary.BigEndian.PutUint16(b[11:], ref)
+	binary.BigEndian.PutUint16(b[13:], uint16(len(params)))
+	binary.BigEndian.PutUint16(b[15:], uint16(len(data)))
+	return append(append(b, params...), data...)
+}
+
+// exchange sends a telegram and checks the header of the response. A nil
+// telegram only reads what the CPU sends next.
+func exchange(h *gos7.TCPClientHandler, req []byte) ([]byte, error) {
+	resp, err := h.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < jobParams || resp[7] != 0x32 {
+		return nil, errShortResponse
+	}
+	if resp[8] == rosctrAckData {
+		if len(resp) < ackParams {
+			return nil, errShortResponse
+		}
+		if code := binary.BigEndian.Uint16(resp[17:]); code != 0 {
+			return nil, ErrorCode(code)
+		}
+	}
+	return resp, nil
+}
+
+// szl is a system status list extract: records of size bytes each.
+type szl struct {
+	id, index uint16
+	size      int
+	count     int
+	data      []byte
+}
+
+// record returns record i, nil if there is none.
+func (s *szl) record(i int) []byte {
+	if s == nil || i >= s.count || (i+1)*s.size > len(s.data) {
+		return nil
+	}
+	return s.data[i*s.size : (i+1)*s.size]
+}
+
+// userData sends a userdata request of function group and subfunction and
+// returns the data of the response, following it over as many responses as
+// it takes, and the return code, which is 0xFF when there is data.
+func userData(h *gos7.TCPClientHandler, group, subfunction byte, data []byte) ([]byte, byte, error) {
+	req := telegram(rosctrUserData, []byte{0, 1, 0x12, 4, 0x11, group, subfunction, 0}, data)
+	var res []byte
+	for {
+		resp, err := exchange(h, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(resp) < userDataParams+12+4 {
+			return nil, 0, errShortResponse
+		}
+		if code := binary.BigEndian.Uint16(resp[userDataParams+10:]); code != 0 {
+			return nil, 0, ErrorCode(code)
+		}
+		payload := resp[userDataParams+12:]
+		if payload[0] != 0xFF {
+			return nil, payload[0], nil
+		}
+		n := int(binary.BigEndian.Uint16(payload[2:]))
+		if len(payload) < 4+n {
+			return nil, 0, errShortResponse
+		}
+		res = append(res, payload[4:4+n]...)
+		// the last data unit flag is clear on the last response
+		if resp[userDataParams+9] == 0 {
+			return res, 0xFF, nil
+		}
+		seq := resp[userDataParams+7]
+		req = telegram(rosctrUserData, []byte{0, 1, 0x12, 8, 0x12, group, subfunction, seq, 0, 0, 0, 0}, []byte{0x0A, 0, 0, 0})
+	}
+}
+
+// readSzl reads the extract index of SZL id.
+func readSzl(h *gos7.TCPClientHandler, id, index uint16) (*szl, error) {
+	data := []byte{0xFF, 0x09, 0, 4, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], id)
+	binary.BigEndian.PutUint16(data[6:], index)
+	payload, code, err := userData(h, groupSzl, 0x01, data)
+	if err != nil {
+		return nil, err
+	}
+	if code != 0xFF {
+		return nil, szlError{id: id, index: index, code: code}
+	}
+	if len(payload) < 8 {
+		return nil, errShortResponse
+	}
+	return &szl{
+		id:    binary.BigEndian.Uint16(payload),
+		index: binary.BigEndian.Uint16(payload[2:]),
+		size:  int(binary.BigEndian.Uint16(payload[4:])),
+		count: int(binary.BigEndian.Uint16(payload[6:])),
+		data:  payload[8:],
+	}, nil
+}
+
+// cpuState reads the operating state from SZL 0x0424.
+func cpuState(h *gos7.TCPClientHandler) (pb.CpuState_State, error) {
+	s, err := readSzl(h, szlCpuState, 0)
+	if err != nil {
+		return pb.CpuState_UNKNOWN, err
+	}
+	r := s.record(0)
+	if len(r) < 4 {
+		return pb.CpuState_UNKNOWN, errShortResponse
+	}
+	return operatingState(r[3]), nil
+}
+
+// operatingState maps the state byte of SZL 0x0424.
+func operatingState(b byte) pb.CpuState_State {
+	switch b & 0x0F {
+	case 0x08, 0x09:
+		return pb.CpuState_RUN
+	case 0x01, 0x02, 0x03, 0x04:
+		return pb.CpuState_STOP
+	case 0x05, 0x06, 0x07:
+		return pb.CpuState_STARTUP
+	case 0x0A:
+		return pb.CpuState_HOLD
+	case 0x0D:
+		return pb.CpuState_DEFECT
+	}
+	return pb.CpuState_UNKNOWN
+}
+
+// programInvocation calls the P_PROGRAM service of the CPU with function
+// start or stop.
+func programInvocation(h *gos7.TCPClientHandler, function byte, cold bool) error {
+	var params []byte
+	switch {
+	case function == funcStop:
+		params = []byte{funcStop, 0, 0, 0, 0, 0}
+	case cold:
+		params = []byte{funcStart, 0, 0, 0, 0, 0, 0, 0xFD, 0, 2, 'C', ' '}
+	default:
+		params = []byte{funcStart, 0, 0, 0, 0, 0, 0, 0xFD, 0, 0}
+	}
+	params = append(append(params, 9), "P_PROGRAM"...)
+	resp, err := exchange(h, telegram(rosctrJob, params, nil))
+	if err != nil {
+		return err
+	}
+	if len(resp) <= ackParams || resp[ackParams] != function {
+		return errShortResponse
+	}
+	if len(resp) > ackParams+1 {
+		switch resp[ackParams+1] {
+		case 0, alreadyStarted, alreadyStopped:
+		default:
+			return fmt.Errorf("s7: program invocation result %#02x", resp[ackParams+1])
+		}
+	}
+	return nil
+}
@@ -26,12 +26,118 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+type CpuState_State int32
+
+const (
+	CpuState_UNKNOWN CpuState_State = 0
+	CpuState_RUN     CpuState_State = 1
+	CpuState_STOP    CpuState_State = 2
+	CpuState_STARTUP CpuState_State = 3
+	CpuState_HOLD    CpuState_State = 4
+	CpuState_DEFECT  CpuState_State = 5
+)
+
+var CpuState_State_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "RUN",
+	2: "STOP",
+	3: "STARTUP",
+	4: "HOLD",
+	5: "DEFECT",
+}
+
+var CpuState_State_value = map[string]int32{
+	"UNKNOWN": 0,
+	"RUN":     1,
+	"STOP":    2,
+	"STARTUP": 3,
+	"HOLD":    4,
+	"DEFECT":  5,
+}
+
+func (x CpuState_State) String() string {
+	return proto.EnumName(CpuState_State_name, int32(x))
+}
+
+func (CpuState_State) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{6, 0}
+}
+
+type CpuControlReq_Mode int32
+
+const (
+	CpuControlReq_WARM CpuControlReq_Mode = 0
+	CpuControlReq_COLD CpuControlReq_Mode = 1
+)
+
+var CpuControlReq_Mode_name = map[int32]string{
+	0: "WARM",
+	1: "COLD",
+}
+
+var CpuControlReq_Mode_value = map[string]int32{
+	"WARM": 0,
+	"COLD": 1,
+}
+
+func (x CpuControlReq_Mode) String() string {
+	return proto.EnumName(CpuControlReq_Mode_name, int32(x))
+}
+
+func (CpuControlReq_Mode) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{7, 0}
+}
+
+type BlockChange_Kind int32
+
+const (
+	BlockChange_CHANGED BlockChange_Kind = 0
+	BlockChange_ADDED   BlockChange_Kind = 1
+	BlockChange_REMOVED BlockChange_Kind = 2
+)
+
+var BlockChange_Kind_name = map[int32]string{
+	0: "CHANGED",
+	1: "ADDED",
+	2: "REMOVED",
+}
+
+var BlockChange_Kind_value = map[string]int32{
+	"CHANGED": 0,
+	"ADDED":   1,
+	"REMOVED": 2,
+}
+
+func (x BlockChange_Kind) String() string {
+	return proto.EnumName(BlockChange_Kind_name, int32(x))
+}
+
+func (BlockChange_Kind) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{20, 0}
+}
+
 type S7CpuInfo struct {
-	ModuleTypeName       string   `protobuf:"bytes,1,opt,name=module_type_name,json=moduleTypeName,proto3" json:"module_type_name,omitempty"`
-	SerialNumber         string   `protobuf:"bytes,2,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
-	AsName               string   `protobuf:"bytes,3,opt,name=as_name,json=asName,proto3" json:"as_name,omitempty"`
-	Copyright            string   `protobuf:"bytes,4,opt,name=copyright,proto3" json:"copyright,omitempty"`
-	ModuleName           string   `protobuf:"bytes,5,opt,name=module_name,json=moduleName,proto3" json:"module_name,omitempty"`
+	ModuleTypeName string `protobuf:"bytes,1,opt,name=module_type_name,json=moduleTypeName,proto3" json:"module_type_name,omitempty"`
+	SerialNumber   string `protobuf:"bytes,2,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	AsName         string `protobuf:"bytes,3,opt,name=as_name,json=asName,proto3" json:"as_name,omitempty"`
+	Copyright      string `protobuf:"bytes,4,opt,name=copyright,proto3" json:"copyright,omitempty"`
+	ModuleName     string `protobuf:"bytes,5,opt,name=module_name,json=moduleName,proto3" json:"module_name,omitempty"`
+	// order_code and firmware_version are from SZL 0x0011, as in
+	// 6ES7 315-2EH14-0AB0 and V3.2.8
+	OrderCode       string `protobuf:"bytes,6,opt,name=order_code,json=orderCode,proto3" json:"order_code,omitempty"`
+	FirmwareVersion string `protobuf:"bytes,7,opt,name=firmware_version,json=firmwareVersion,proto3" json:"firmware_version,omitempty"`
+	// protection_level in effect, 1 (none) to 3 (read and write protected),
+	// and mode_selector, the position of the key switch (RUN, RUN-P, STOP or
+	// MRES, empty without one), are from SZL 0x0232
+	ProtectionLevel uint32 `protobuf:"varint,8,opt,name=protection_level,json=protectionLevel,proto3" json:"protection_level,omitempty"`
+	ModeSelector    string `protobuf:"bytes,9,opt,name=mode_selector,json=modeSelector,proto3" json:"mode_selector,omitempty"`
+	// pdu_size is the PDU length negotiated with goplc, max_connections
+	// from SZL 0x0131
+	PduSize        uint32 `protobuf:"varint,10,opt,name=pdu_size,json=pduSize,proto3" json:"pdu_size,omitempty"`
+	MaxConnections uint32 `protobuf:"varint,11,opt,name=max_connections,json=maxConnections,proto3" json:"max_connections,omitempty"`
+	// cpu_family is S7-300, S7-400, S7-1200 or S7-1500, empty when the order
+	// code does not tell
+	CpuFamily            string   `protobuf:"bytes,12,opt,name=cpu_family,json=cpuFamily,proto3" json:"cpu_family,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -97,11 +203,70 @@ func (m *S7CpuInfo) GetModuleName() string {
 	return ""
 }
 
+func (m *S7CpuInfo) GetOrderCode() string {
+	if m != nil {
+		return m.OrderCode
+	}
+	return ""
+}
+
+func (m *S7CpuInfo) GetFirmwareVersion() string {
+	if m != nil {
+		return m.FirmwareVersion
+	}
+	return ""
+}
+
+func (m *S7CpuInfo) GetProtectionLevel() uint32 {
+	if m != nil {
+		return m.ProtectionLevel
+	}
+	return 0
+}
+
+func (m *S7CpuInfo) GetModeSelector() string {
+	if m != nil {
+		return m.ModeSelector
+	}
+	return ""
+}
+
+func (m *S7CpuInfo) GetPduSize() uint32 {
+	if m != nil {
+		return m.PduSize
+	}
+	return 0
+}
+
+func (m *S7CpuInfo) GetMaxConnections() uint32 {
+	if m != nil {
+		return m.MaxConnections
+	}
+	return 0
+}
+
+func (m *S7CpuInfo) GetCpuFamily() string {
+	if m != nil {
+		return m.CpuFamily
+	}
+	return ""
+}
+
 type Plc struct {
-	Host                 string   `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
-	Rack                 uint32   `protobuf:"varint,2,opt,name=rack,proto3" json:"rack,omitempty"`
-	Slot                 uint32   `protobuf:"varint,3,opt,name=slot,proto3" json:"slot,omitempty"`
-	Port                 uint32   `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Rack uint32 `protobuf:"varint,2,opt,name=rack,proto3" json:"rack,omitempty"`
+	Slot uint32 `protobuf:"varint,3,opt,name=slot,proto3" json:"slot,omitempty"`
+	Port uint32 `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	// protocol selects the driver, "s7" when empty
+	Protocol string `protobuf:"bytes,5,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	// options are protocol specific settings, such as the Modbus unit id
+	Options map[string]string `protobuf:"bytes,6,rep,name=options,proto3" json:"options,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// password_ref names the password of a protected S7 CPU among those
+	// goplc keeps, from its configuration or SetSessionPassword, which it
+	// sends whenever it connects. Calls never carry the password itself.
+	// Functions the protection level refuses fail with FAILED_PRECONDITION
+	// and a message starting with "CPU protection".
+	PasswordRef          string   `protobuf:"bytes,7,opt,name=password_ref,json=passwordRef,proto3" json:"password_ref,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -160,6 +325,116 @@ func (m *Plc) GetPort() uint32 {
 	return 0
 }
 
+func (m *Plc) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *Plc) GetOptions() map[string]string {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *Plc) GetPasswordRef() string {
+	if m != nil {
+		return m.PasswordRef
+	}
+	return ""
+}
+
+// DeviceInfo identifies a PLC of any protocol. details holds protocol
+// specific fields.
+type DeviceInfo struct {
+	Protocol             string            `protobuf:"bytes,1,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Vendor               string            `protobuf:"bytes,2,opt,name=vendor,proto3" json:"vendor,omitempty"`
+	Model                string            `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	SerialNumber         string            `protobuf:"bytes,4,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	Name                 string            `protobuf:"bytes,5,opt,name=name,proto3" json:"name,omitempty"`
+	Version              string            `protobuf:"bytes,6,opt,name=version,proto3" json:"version,omitempty"`
+	Details              map[string]string `protobuf:"bytes,7,rep,name=details,proto3" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *DeviceInfo) Reset()         { *m = DeviceInfo{} }
+func (m *DeviceInfo) String() string { return proto.CompactTextString(m) }
+func (*DeviceInfo) ProtoMessage()    {}
+func (*DeviceInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{2}
+}
+
+func (m *DeviceInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeviceInfo.Unmarshal(m, b)
+}
+func (m *DeviceInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeviceInfo.Marshal(b, m, deterministic)
+}
+func (m *DeviceInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeviceInfo.Merge(m, src)
+}
+func (m *DeviceInfo) XXX_Size() int {
+	return xxx_messageInfo_DeviceInfo.Size(m)
+}
+func (m *DeviceInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeviceInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeviceInfo proto.InternalMessageInfo
+
+func (m *DeviceInfo) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetVendor() string {
+	if m != nil {
+		return m.Vendor
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetModel() string {
+	if m != nil {
+		return m.Model
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetSerialNumber() string {
+	if m != nil {
+		return m.SerialNumber
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *DeviceInfo) GetDetails() map[string]string {
+	if m != nil {
+		return m.Details
+	}
+	return nil
+}
+
 type Tag struct {
 	Address string `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
 	Dt      string `protobuf:"bytes,2,opt,name=dt,proto3" json:"dt,omitempty"`
@@ -183,7 +458,7 @@ func (m *Tag) Reset()         { *m = Tag{} }
 func (m *Tag) String() string { return proto.CompactTextString(m) }
 func (*Tag) ProtoMessage()    {}
 func (*Tag) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a0a6ab4644bfacb6, []int{2}
+	return fileDescriptor_a0a6ab4644bfacb6, []int{3}
 }
 
 func (m *Tag) XXX_Unmarshal(b []byte) error {
@@ -365,7 +640,7 @@ func (m *RWResult) Reset()         { *m = RWResult{} }
 func (m *RWResult) String() string { return proto.CompactTextString(m) }
 func (*RWResult) ProtoMessage()    {}
 func (*RWResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a0a6ab4644bfacb6, []int{3}
+	return fileDescriptor_a0a6ab4644bfacb6, []int{4}
 }
 
 func (m *RWResult) XXX_Unmarshal(b []byte) error {
@@ -405,7 +680,7 @@ func (m *RWReq) Reset()         { *m = RWReq{} }
 func (m *RWReq) String() string { return proto.CompactTextString(m) }
 func (*RWReq) ProtoMessage()    {}
 func (*RWReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a0a6ab4644bfacb6, []int{4}
+	return fileDescriptor_a0a6ab4644bfacb6, []int{5}
 }
 
 func (m *RWReq) XXX_Unmarshal(b []byte) error {
@@ -440,183 +715,3361 @@ func (m *RWReq) GetTags() []*Tag {
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*S7CpuInfo)(nil), "plc_api.S7CpuInfo")
-	proto.RegisterType((*Plc)(nil), "plc_api.Plc")
-	proto.RegisterType((*Tag)(nil), "plc_api.Tag")
-	proto.RegisterType((*RWResult)(nil), "plc_api.RWResult")
-	proto.RegisterType((*RWReq)(nil), "plc_api.RWReq")
+type CpuState struct {
+	State                CpuState_State `protobuf:"varint,1,opt,name=state,proto3,enum=plc_api.CpuState_State" json:"state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
 }
 
-func init() { proto.RegisterFile("plc.proto", fileDescriptor_a0a6ab4644bfacb6) }
+func (m *CpuState) Reset()         { *m = CpuState{} }
+func (m *CpuState) String() string { return proto.CompactTextString(m) }
+func (*CpuState) ProtoMessage()    {}
+func (*CpuState) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{6}
+}
 
-var fileDescriptor_a0a6ab4644bfacb6 = []byte{
-	// 574 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xcf, 0x6e, 0xd3, 0x40,
-	0x10, 0xc6, 0xed, 0x38, 0x69, 0xe2, 0xc9, 0x1f, 0xca, 0x5e, 0x30, 0x11, 0x6a, 0x83, 0x2b, 0x44,
-	0x0e, 0x28, 0xad, 0xca, 0x81, 0x7b, 0x28, 0x22, 0xbd, 0x54, 0xd5, 0x36, 0x55, 0x8f, 0xd1, 0xc6,
-	0xde, 0xba, 0x16, 0x1b, 0xaf, 0xd9, 0x5d, 0x23, 0xe5, 0x5d, 0x78, 0x0a, 0x78, 0x41, 0xb4, 0xb3,
-	0xb6, 0x4b, 0xe1, 0x00, 0xb7, 0xd9, 0xdf, 0x7c, 0xf3, 0x79, 0x66, 0x3d, 0x0b, 0x61, 0x29, 0x92,
-	0x45, 0xa9, 0xa4, 0x91, 0xa4, 0x5f, 0x8a, 0x64, 0xc3, 0xca, 0x7c, 0x7a, 0x9c, 0x49, 0x99, 0x09,
-	0x7e, 0x8a, 0x78, 0x5b, 0xdd, 0x9f, 0x9a, 0x7c, 0xc7, 0xb5, 0x61, 0xbb, 0xd2, 0x29, 0xa7, 0x47,
-	0x7f, 0x0a, 0xd2, 0x4a, 0x31, 0x93, 0xcb, 0xc2, 0xe5, 0xe3, 0x1f, 0x3e, 0x84, 0x37, 0x1f, 0x3e,
-	0x96, 0xd5, 0x65, 0x71, 0x2f, 0xc9, 0x1c, 0x0e, 0x77, 0x32, 0xad, 0x04, 0xdf, 0x98, 0x7d, 0xc9,
-	0x37, 0x05, 0xdb, 0xf1, 0xc8, 0x9f, 0xf9, 0xf3, 0x90, 0x4e, 0x1c, 0x5f, 0xef, 0x4b, 0x7e, 0xc5,
-	0x76, 0x9c, 0x9c, 0xc0, 0x58, 0x73, 0x95, 0x33, 0xb1, 0x29, 0xaa, 0xdd, 0x96, 0xab, 0xa8, 0x83,
-	0xb2, 0x91, 0x83, 0x57, 0xc8, 0xc8, 0x0b, 0xe8, 0x33, 0xed, 0x5c, 0x02, 0x4c, 0x1f, 0x30, 0x8d,
-	0xd5, 0xaf, 0x20, 0x4c, 0x64, 0xb9, 0x57, 0x79, 0xf6, 0x60, 0xa2, 0x2e, 0xa6, 0x1e, 0x01, 0x39,
-	0x86, 0x61, 0xdd, 0x05, 0x96, 0xf6, 0x30, 0x0f, 0x0e, 0xd9, 0xf2, 0xf8, 0x16, 0x82, 0x6b, 0x91,
-	0x10, 0x02, 0xdd, 0x07, 0xa9, 0x4d, 0xdd, 0x21, 0xc6, 0x96, 0x29, 0x96, 0x7c, 0xc1, 0x76, 0xc6,
-	0x14, 0x63, 0xcb, 0xb4, 0x90, 0x06, 0x7b, 0x18, 0x53, 0x8c, 0x2d, 0x2b, 0xa5, 0x72, 0x1f, 0x1f,
-	0x53, 0x8c, 0xe3, 0x9f, 0x01, 0x04, 0x6b, 0x96, 0x91, 0x08, 0xfa, 0x2c, 0x4d, 0x15, 0xd7, 0xba,
-	0xb6, 0x6e, 0x8e, 0x64, 0x02, 0x9d, 0xd4, 0xd4, 0xa3, 0x76, 0x52, 0xdb, 0x29, 0x7c, 0x63, 0xa2,
-	0xe2, 0x9b, 0xad, 0x94, 0x02, 0xfd, 0x07, 0x2b, 0x8f, 0x86, 0xc8, 0x96, 0x52, 0x0a, 0xf2, 0x06,
-	0xc6, 0x4e, 0x90, 0x17, 0x86, 0x67, 0x5c, 0xe1, 0xf7, 0x82, 0x95, 0x47, 0x47, 0x88, 0x2f, 0x1d,
-	0x25, 0x6f, 0x61, 0xe2, 0x64, 0x55, 0xa3, 0xb3, 0x43, 0x77, 0x57, 0x1e, 0x75, 0xe5, 0xb7, 0x35,
-	0x26, 0x27, 0xe0, 0x0a, 0x37, 0xa9, 0xac, 0xb6, 0x82, 0x47, 0x07, 0x33, 0x7f, 0xee, 0xaf, 0x3c,
-	0x3a, 0x44, 0x7a, 0x81, 0x90, 0xbc, 0x86, 0x61, 0xdd, 0xd5, 0xde, 0x70, 0x1d, 0xf5, 0x67, 0xfe,
-	0x7c, 0xb4, 0xf2, 0xa8, 0x6b, 0x75, 0x69, 0xd9, 0xa3, 0x8f, 0x36, 0x2a, 0x2f, 0xb2, 0x68, 0x60,
-	0x47, 0x6a, 0x7d, 0x6e, 0x10, 0x92, 0x4f, 0xf0, 0xcc, 0x89, 0xda, 0xa5, 0x8a, 0xc2, 0x99, 0x3f,
-	0x1f, 0x9e, 0x4f, 0x17, 0x6e, 0xab, 0x16, 0xcd, 0x56, 0x2d, 0xd6, 0x8d, 0x62, 0xe5, 0x51, 0x37,
-	0x4a, 0x4b, 0xc8, 0xb2, 0x19, 0xae, 0x59, 0xbd, 0x08, 0xd0, 0xe5, 0xe5, 0x5f, 0x2e, 0x17, 0xb5,
-	0xa0, 0x9d, 0xbb, 0x01, 0xe4, 0x10, 0x02, 0xae, 0x54, 0x34, 0xc4, 0x9b, 0xb7, 0xe1, 0xb2, 0x0f,
-	0x3d, 0x94, 0xc4, 0xef, 0x60, 0x40, 0xef, 0x28, 0xd7, 0x95, 0x30, 0x64, 0x06, 0x5d, 0xc3, 0x32,
-	0x1d, 0x75, 0x66, 0xc1, 0x7c, 0x78, 0x3e, 0x5a, 0xd4, 0xcf, 0x64, 0xb1, 0x66, 0x19, 0xc5, 0x4c,
-	0x7c, 0x09, 0x3d, 0xab, 0xfe, 0x4a, 0x8e, 0x20, 0x28, 0x45, 0x82, 0x3f, 0xf8, 0x77, 0xe5, 0xb5,
-	0x48, 0xa8, 0x4d, 0xfc, 0xdb, 0xea, 0xfc, 0xbb, 0x0f, 0x3d, 0x2b, 0xbf, 0x23, 0x67, 0x00, 0x9f,
-	0xb9, 0x69, 0x1e, 0xd1, 0x13, 0xb3, 0x29, 0x69, 0x4f, 0xed, 0x33, 0x8b, 0x3d, 0x72, 0x0a, 0x03,
-	0xca, 0x59, 0xba, 0x66, 0x99, 0x26, 0x93, 0x56, 0x81, 0x9d, 0x4d, 0x9f, 0x3f, 0x39, 0xdb, 0xb9,
-	0x62, 0x8f, 0x9c, 0x41, 0x78, 0xa7, 0x72, 0xc3, 0xff, 0xbb, 0x62, 0x7b, 0x80, 0xd7, 0xfa, 0xfe,
-	0x57, 0x00, 0x00, 0x00, 0xff, 0xff, 0x65, 0xd0, 0xe4, 0x62, 0x37, 0x04, 0x00, 0x00,
+func (m *CpuState) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CpuState.Unmarshal(m, b)
+}
+func (m *CpuState) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CpuState.Marshal(b, m, deterministic)
+}
+func (m *CpuState) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CpuState.Merge(m, src)
+}
+func (m *CpuState) XXX_Size() int {
+	return xxx_messageInfo_CpuState.Size(m)
+}
+func (m *CpuState) XXX_DiscardUnknown() {
+	xxx_messageInfo_CpuState.DiscardUnknown(m)
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+var xxx_messageInfo_CpuState proto.InternalMessageInfo
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
+func (m *CpuState) GetState() CpuState_State {
+	if m != nil {
+		return m.State
+	}
+	return CpuState_UNKNOWN
+}
 
-// PlcRWClient is the client API for PlcRW service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type PlcRWClient interface {
-	GetCpuInfo(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*S7CpuInfo, error)
-	ReadTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error)
-	WriteTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error)
+type CpuControlReq struct {
+	Plc *Plc `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	// mode is the kind of restart of StartCpu; a cold restart also resets
+	// retentive data
+	Mode CpuControlReq_Mode `protobuf:"varint,2,opt,name=mode,proto3,enum=plc_api.CpuControlReq_Mode" json:"mode,omitempty"`
+	// confirm is the token returned by the same call without it
+	Confirm              string   `protobuf:"bytes,3,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-type plcRWClient struct {
-	cc *grpc.ClientConn
+func (m *CpuControlReq) Reset()         { *m = CpuControlReq{} }
+func (m *CpuControlReq) String() string { return proto.CompactTextString(m) }
+func (*CpuControlReq) ProtoMessage()    {}
+func (*CpuControlReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{7}
 }
 
-func NewPlcRWClient(cc *grpc.ClientConn) PlcRWClient {
-	return &plcRWClient{cc}
+func (m *CpuControlReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CpuControlReq.Unmarshal(m, b)
+}
+func (m *CpuControlReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CpuControlReq.Marshal(b, m, deterministic)
+}
+func (m *CpuControlReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CpuControlReq.Merge(m, src)
+}
+func (m *CpuControlReq) XXX_Size() int {
+	return xxx_messageInfo_CpuControlReq.Size(m)
+}
+func (m *CpuControlReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_CpuControlReq.DiscardUnknown(m)
 }
 
-func (c *plcRWClient) GetCpuInfo(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*S7CpuInfo, error) {
-	out := new(S7CpuInfo)
-	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetCpuInfo", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_CpuControlReq proto.InternalMessageInfo
+
+func (m *CpuControlReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
 	}
-	return out, nil
+	return nil
 }
 
-func (c *plcRWClient) ReadTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error) {
-	out := new(RWResult)
-	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/ReadTags", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *CpuControlReq) GetMode() CpuControlReq_Mode {
+	if m != nil {
+		return m.Mode
 	}
-	return out, nil
+	return CpuControlReq_WARM
 }
 
-func (c *plcRWClient) WriteTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error) {
-	out := new(RWResult)
-	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/WriteTags", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *CpuControlReq) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
 	}
-	return out, nil
+	return ""
 }
 
-// PlcRWServer is the server API for PlcRW service.
-type PlcRWServer interface {
-	GetCpuInfo(context.Context, *Plc) (*S7CpuInfo, error)
-	ReadTags(context.Context, *RWReq) (*RWResult, error)
-	WriteTags(context.Context, *RWReq) (*RWResult, error)
+// CpuControlResult is either a confirmation token that expires, or the
+// state after the change.
+type CpuControlResult struct {
+	Confirm              string               `protobuf:"bytes,1,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	ConfirmExpires       *timestamp.Timestamp `protobuf:"bytes,2,opt,name=confirm_expires,json=confirmExpires,proto3" json:"confirm_expires,omitempty"`
+	State                *CpuState            `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
 }
 
-// UnimplementedPlcRWServer can be embedded to have forward compatible implementations.
-type UnimplementedPlcRWServer struct {
+func (m *CpuControlResult) Reset()         { *m = CpuControlResult{} }
+func (m *CpuControlResult) String() string { return proto.CompactTextString(m) }
+func (*CpuControlResult) ProtoMessage()    {}
+func (*CpuControlResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{8}
 }
 
-func (*UnimplementedPlcRWServer) GetCpuInfo(ctx context.Context, req *Plc) (*S7CpuInfo, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetCpuInfo not implemented")
+func (m *CpuControlResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CpuControlResult.Unmarshal(m, b)
 }
-func (*UnimplementedPlcRWServer) ReadTags(ctx context.Context, req *RWReq) (*RWResult, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ReadTags not implemented")
+func (m *CpuControlResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CpuControlResult.Marshal(b, m, deterministic)
 }
-func (*UnimplementedPlcRWServer) WriteTags(ctx context.Context, req *RWReq) (*RWResult, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method WriteTags not implemented")
+func (m *CpuControlResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CpuControlResult.Merge(m, src)
 }
-
-func RegisterPlcRWServer(s *grpc.Server, srv PlcRWServer) {
-	s.RegisterService(&_PlcRW_serviceDesc, srv)
+func (m *CpuControlResult) XXX_Size() int {
+	return xxx_messageInfo_CpuControlResult.Size(m)
+}
+func (m *CpuControlResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_CpuControlResult.DiscardUnknown(m)
 }
 
-func _PlcRW_GetCpuInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Plc)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(PlcRWServer).GetCpuInfo(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/plc_api.PlcRW/GetCpuInfo",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PlcRWServer).GetCpuInfo(ctx, req.(*Plc))
+var xxx_messageInfo_CpuControlResult proto.InternalMessageInfo
+
+func (m *CpuControlResult) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _PlcRW_ReadTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RWReq)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(PlcRWServer).ReadTags(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/plc_api.PlcRW/ReadTags",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PlcRWServer).ReadTags(ctx, req.(*RWReq))
+func (m *CpuControlResult) GetConfirmExpires() *timestamp.Timestamp {
+	if m != nil {
+		return m.ConfirmExpires
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-func _PlcRW_WriteTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RWReq)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(PlcRWServer).WriteTags(ctx, in)
+func (m *CpuControlResult) GetState() *CpuState {
+	if m != nil {
+		return m.State
+	}
+	return nil
+}
+
+type SzlReq struct {
+	Plc *Plc `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	// id and index select the list and the extract, as 0x0011 and 0x0001
+	Id                   uint32   `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	Index                uint32   `protobuf:"varint,3,opt,name=index,proto3" json:"index,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SzlReq) Reset()         { *m = SzlReq{} }
+func (m *SzlReq) String() string { return proto.CompactTextString(m) }
+func (*SzlReq) ProtoMessage()    {}
+func (*SzlReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{9}
+}
+
+func (m *SzlReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SzlReq.Unmarshal(m, b)
+}
+func (m *SzlReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SzlReq.Marshal(b, m, deterministic)
+}
+func (m *SzlReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SzlReq.Merge(m, src)
+}
+func (m *SzlReq) XXX_Size() int {
+	return xxx_messageInfo_SzlReq.Size(m)
+}
+func (m *SzlReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SzlReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SzlReq proto.InternalMessageInfo
+
+func (m *SzlReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *SzlReq) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *SzlReq) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+type Szl struct {
+	Id                   uint32       `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Index                uint32       `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	RecordSize           uint32       `protobuf:"varint,3,opt,name=record_size,json=recordSize,proto3" json:"record_size,omitempty"`
+	Records              []*SzlRecord `protobuf:"bytes,4,rep,name=records,proto3" json:"records,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *Szl) Reset()         { *m = Szl{} }
+func (m *Szl) String() string { return proto.CompactTextString(m) }
+func (*Szl) ProtoMessage()    {}
+func (*Szl) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{10}
+}
+
+func (m *Szl) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Szl.Unmarshal(m, b)
+}
+func (m *Szl) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Szl.Marshal(b, m, deterministic)
+}
+func (m *Szl) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Szl.Merge(m, src)
+}
+func (m *Szl) XXX_Size() int {
+	return xxx_messageInfo_Szl.Size(m)
+}
+func (m *Szl) XXX_DiscardUnknown() {
+	xxx_messageInfo_Szl.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Szl proto.InternalMessageInfo
+
+func (m *Szl) GetId() uint32 {
+	if m != nil {
+		return m.Id
+	}
+	return 0
+}
+
+func (m *Szl) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *Szl) GetRecordSize() uint32 {
+	if m != nil {
+		return m.RecordSize
+	}
+	return 0
+}
+
+func (m *Szl) GetRecords() []*SzlRecord {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+// SzlRecord is a record as read, and decoded into fields for the lists
+// goplc knows: module identification (0x0011), CPU characteristics
+// (0x0012), LED states (0x0019 and 0x0074), component identification
+// (0x001C), communication status (0x0132 and 0x0232) and operating state
+// (0x0424).
+type SzlRecord struct {
+	Raw                  []byte            `protobuf:"bytes,1,opt,name=raw,proto3" json:"raw,omitempty"`
+	Fields               map[string]string `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *SzlRecord) Reset()         { *m = SzlRecord{} }
+func (m *SzlRecord) String() string { return proto.CompactTextString(m) }
+func (*SzlRecord) ProtoMessage()    {}
+func (*SzlRecord) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{11}
+}
+
+func (m *SzlRecord) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SzlRecord.Unmarshal(m, b)
+}
+func (m *SzlRecord) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SzlRecord.Marshal(b, m, deterministic)
+}
+func (m *SzlRecord) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SzlRecord.Merge(m, src)
+}
+func (m *SzlRecord) XXX_Size() int {
+	return xxx_messageInfo_SzlRecord.Size(m)
+}
+func (m *SzlRecord) XXX_DiscardUnknown() {
+	xxx_messageInfo_SzlRecord.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SzlRecord proto.InternalMessageInfo
+
+func (m *SzlRecord) GetRaw() []byte {
+	if m != nil {
+		return m.Raw
+	}
+	return nil
+}
+
+func (m *SzlRecord) GetFields() map[string]string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type DiagnosticReq struct {
+	Plc *Plc `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	// interval is how often WatchDiagnosticBuffer reads the buffer, every
+	// second by default
+	Interval             *duration.Duration `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *DiagnosticReq) Reset()         { *m = DiagnosticReq{} }
+func (m *DiagnosticReq) String() string { return proto.CompactTextString(m) }
+func (*DiagnosticReq) ProtoMessage()    {}
+func (*DiagnosticReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{12}
+}
+
+func (m *DiagnosticReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiagnosticReq.Unmarshal(m, b)
+}
+func (m *DiagnosticReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiagnosticReq.Marshal(b, m, deterministic)
+}
+func (m *DiagnosticReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiagnosticReq.Merge(m, src)
+}
+func (m *DiagnosticReq) XXX_Size() int {
+	return xxx_messageInfo_DiagnosticReq.Size(m)
+}
+func (m *DiagnosticReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiagnosticReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiagnosticReq proto.InternalMessageInfo
+
+func (m *DiagnosticReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *DiagnosticReq) GetInterval() *duration.Duration {
+	if m != nil {
+		return m.Interval
+	}
+	return nil
+}
+
+type DiagnosticEntry struct {
+	EventId uint32 `protobuf:"varint,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// priority is the priority class, ob_number the organization block the
+	// event started or would have started
+	Priority uint32 `protobuf:"varint,2,opt,name=priority,proto3" json:"priority,omitempty"`
+	ObNumber uint32 `protobuf:"varint,3,opt,name=ob_number,json=obNumber,proto3" json:"ob_number,omitempty"`
+	DatId    uint32 `protobuf:"varint,4,opt,name=dat_id,json=datId,proto3" json:"dat_id,omitempty"`
+	Info1    uint32 `protobuf:"varint,5,opt,name=info1,proto3" json:"info1,omitempty"`
+	Info2    uint32 `protobuf:"varint,6,opt,name=info2,proto3" json:"info2,omitempty"`
+	// time is the CPU clock at the event, taken as UTC
+	Time *timestamp.Timestamp `protobuf:"bytes,7,opt,name=time,proto3" json:"time,omitempty"`
+	// text describes the event from the catalog of goplc, or its class
+	Text                 string   `protobuf:"bytes,8,opt,name=text,proto3" json:"text,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DiagnosticEntry) Reset()         { *m = DiagnosticEntry{} }
+func (m *DiagnosticEntry) String() string { return proto.CompactTextString(m) }
+func (*DiagnosticEntry) ProtoMessage()    {}
+func (*DiagnosticEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{13}
+}
+
+func (m *DiagnosticEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiagnosticEntry.Unmarshal(m, b)
+}
+func (m *DiagnosticEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiagnosticEntry.Marshal(b, m, deterministic)
+}
+func (m *DiagnosticEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiagnosticEntry.Merge(m, src)
+}
+func (m *DiagnosticEntry) XXX_Size() int {
+	return xxx_messageInfo_DiagnosticEntry.Size(m)
+}
+func (m *DiagnosticEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiagnosticEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiagnosticEntry proto.InternalMessageInfo
+
+func (m *DiagnosticEntry) GetEventId() uint32 {
+	if m != nil {
+		return m.EventId
+	}
+	return 0
+}
+
+func (m *DiagnosticEntry) GetPriority() uint32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *DiagnosticEntry) GetObNumber() uint32 {
+	if m != nil {
+		return m.ObNumber
+	}
+	return 0
+}
+
+func (m *DiagnosticEntry) GetDatId() uint32 {
+	if m != nil {
+		return m.DatId
+	}
+	return 0
+}
+
+func (m *DiagnosticEntry) GetInfo1() uint32 {
+	if m != nil {
+		return m.Info1
+	}
+	return 0
+}
+
+func (m *DiagnosticEntry) GetInfo2() uint32 {
+	if m != nil {
+		return m.Info2
+	}
+	return 0
+}
+
+func (m *DiagnosticEntry) GetTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *DiagnosticEntry) GetText() string {
+	if m != nil {
+		return m.Text
+	}
+	return ""
+}
+
+type DiagnosticBuffer struct {
+	Entries              []*DiagnosticEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *DiagnosticBuffer) Reset()         { *m = DiagnosticBuffer{} }
+func (m *DiagnosticBuffer) String() string { return proto.CompactTextString(m) }
+func (*DiagnosticBuffer) ProtoMessage()    {}
+func (*DiagnosticBuffer) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{14}
+}
+
+func (m *DiagnosticBuffer) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiagnosticBuffer.Unmarshal(m, b)
+}
+func (m *DiagnosticBuffer) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiagnosticBuffer.Marshal(b, m, deterministic)
+}
+func (m *DiagnosticBuffer) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiagnosticBuffer.Merge(m, src)
+}
+func (m *DiagnosticBuffer) XXX_Size() int {
+	return xxx_messageInfo_DiagnosticBuffer.Size(m)
+}
+func (m *DiagnosticBuffer) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiagnosticBuffer.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiagnosticBuffer proto.InternalMessageInfo
+
+func (m *DiagnosticBuffer) GetEntries() []*DiagnosticEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+type BlockList struct {
+	Types                []*BlockList_Type `protobuf:"bytes,1,rep,name=types,proto3" json:"types,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *BlockList) Reset()         { *m = BlockList{} }
+func (m *BlockList) String() string { return proto.CompactTextString(m) }
+func (*BlockList) ProtoMessage()    {}
+func (*BlockList) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{15}
+}
+
+func (m *BlockList) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockList.Unmarshal(m, b)
+}
+func (m *BlockList) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockList.Marshal(b, m, deterministic)
+}
+func (m *BlockList) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockList.Merge(m, src)
+}
+func (m *BlockList) XXX_Size() int {
+	return xxx_messageInfo_BlockList.Size(m)
+}
+func (m *BlockList) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockList.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockList proto.InternalMessageInfo
+
+func (m *BlockList) GetTypes() []*BlockList_Type {
+	if m != nil {
+		return m.Types
+	}
+	return nil
+}
+
+type BlockList_Type struct {
+	// type is OB, FB, FC, DB, SFB or SFC
+	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Count                uint32   `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Numbers              []uint32 `protobuf:"varint,3,rep,packed,name=numbers,proto3" json:"numbers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockList_Type) Reset()         { *m = BlockList_Type{} }
+func (m *BlockList_Type) String() string { return proto.CompactTextString(m) }
+func (*BlockList_Type) ProtoMessage()    {}
+func (*BlockList_Type) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{15, 0}
+}
+
+func (m *BlockList_Type) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockList_Type.Unmarshal(m, b)
+}
+func (m *BlockList_Type) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockList_Type.Marshal(b, m, deterministic)
+}
+func (m *BlockList_Type) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockList_Type.Merge(m, src)
+}
+func (m *BlockList_Type) XXX_Size() int {
+	return xxx_messageInfo_BlockList_Type.Size(m)
+}
+func (m *BlockList_Type) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockList_Type.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockList_Type proto.InternalMessageInfo
+
+func (m *BlockList_Type) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *BlockList_Type) GetCount() uint32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *BlockList_Type) GetNumbers() []uint32 {
+	if m != nil {
+		return m.Numbers
+	}
+	return nil
+}
+
+type BlockReq struct {
+	Plc                  *Plc     `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	Type                 string   `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Number               uint32   `protobuf:"varint,3,opt,name=number,proto3" json:"number,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BlockReq) Reset()         { *m = BlockReq{} }
+func (m *BlockReq) String() string { return proto.CompactTextString(m) }
+func (*BlockReq) ProtoMessage()    {}
+func (*BlockReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{16}
+}
+
+func (m *BlockReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockReq.Unmarshal(m, b)
+}
+func (m *BlockReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockReq.Marshal(b, m, deterministic)
+}
+func (m *BlockReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockReq.Merge(m, src)
+}
+func (m *BlockReq) XXX_Size() int {
+	return xxx_messageInfo_BlockReq.Size(m)
+}
+func (m *BlockReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockReq proto.InternalMessageInfo
+
+func (m *BlockReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *BlockReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *BlockReq) GetNumber() uint32 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+type BlockInfo struct {
+	Type   string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Number uint32 `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	// language is STL, LAD, FBD, SCL, DB, GRAPH or the number the CPU reports
+	Language  string `protobuf:"bytes,3,opt,name=language,proto3" json:"language,omitempty"`
+	Flags     uint32 `protobuf:"varint,4,opt,name=flags,proto3" json:"flags,omitempty"`
+	Mc7Size   uint32 `protobuf:"varint,5,opt,name=mc7_size,json=mc7Size,proto3" json:"mc7_size,omitempty"`
+	LoadSize  uint32 `protobuf:"varint,6,opt,name=load_size,json=loadSize,proto3" json:"load_size,omitempty"`
+	LocalData uint32 `protobuf:"varint,7,opt,name=local_data,json=localData,proto3" json:"local_data,omitempty"`
+	SbbLength uint32 `protobuf:"varint,8,opt,name=sbb_length,json=sbbLength,proto3" json:"sbb_length,omitempty"`
+	Checksum  uint32 `protobuf:"varint,9,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	// version is the block version as major.minor
+	Version              string               `protobuf:"bytes,10,opt,name=version,proto3" json:"version,omitempty"`
+	CodeDate             *timestamp.Timestamp `protobuf:"bytes,11,opt,name=code_date,json=codeDate,proto3" json:"code_date,omitempty"`
+	InterfaceDate        *timestamp.Timestamp `protobuf:"bytes,12,opt,name=interface_date,json=interfaceDate,proto3" json:"interface_date,omitempty"`
+	Author               string               `protobuf:"bytes,13,opt,name=author,proto3" json:"author,omitempty"`
+	Family               string               `protobuf:"bytes,14,opt,name=family,proto3" json:"family,omitempty"`
+	Header               string               `protobuf:"bytes,15,opt,name=header,proto3" json:"header,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *BlockInfo) Reset()         { *m = BlockInfo{} }
+func (m *BlockInfo) String() string { return proto.CompactTextString(m) }
+func (*BlockInfo) ProtoMessage()    {}
+func (*BlockInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{17}
+}
+
+func (m *BlockInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockInfo.Unmarshal(m, b)
+}
+func (m *BlockInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockInfo.Marshal(b, m, deterministic)
+}
+func (m *BlockInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockInfo.Merge(m, src)
+}
+func (m *BlockInfo) XXX_Size() int {
+	return xxx_messageInfo_BlockInfo.Size(m)
+}
+func (m *BlockInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockInfo proto.InternalMessageInfo
+
+func (m *BlockInfo) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *BlockInfo) GetNumber() uint32 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *BlockInfo) GetFlags() uint32 {
+	if m != nil {
+		return m.Flags
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetMc7Size() uint32 {
+	if m != nil {
+		return m.Mc7Size
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetLoadSize() uint32 {
+	if m != nil {
+		return m.LoadSize
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetLocalData() uint32 {
+	if m != nil {
+		return m.LocalData
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetSbbLength() uint32 {
+	if m != nil {
+		return m.SbbLength
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetChecksum() uint32 {
+	if m != nil {
+		return m.Checksum
+	}
+	return 0
+}
+
+func (m *BlockInfo) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *BlockInfo) GetCodeDate() *timestamp.Timestamp {
+	if m != nil {
+		return m.CodeDate
+	}
+	return nil
+}
+
+func (m *BlockInfo) GetInterfaceDate() *timestamp.Timestamp {
+	if m != nil {
+		return m.InterfaceDate
+	}
+	return nil
+}
+
+func (m *BlockInfo) GetAuthor() string {
+	if m != nil {
+		return m.Author
+	}
+	return ""
+}
+
+func (m *BlockInfo) GetFamily() string {
+	if m != nil {
+		return m.Family
+	}
+	return ""
+}
+
+func (m *BlockInfo) GetHeader() string {
+	if m != nil {
+		return m.Header
+	}
+	return ""
+}
+
+// Block is a block as uploaded: data is all of it, as a download takes it
+// back, mc7 the code or, for a DB, the values.
+type Block struct {
+	Info                 *BlockInfo `protobuf:"bytes,1,opt,name=info,proto3" json:"info,omitempty"`
+	Data                 []byte     `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Mc7                  []byte     `protobuf:"bytes,3,opt,name=mc7,proto3" json:"mc7,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *Block) Reset()         { *m = Block{} }
+func (m *Block) String() string { return proto.CompactTextString(m) }
+func (*Block) ProtoMessage()    {}
+func (*Block) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{18}
+}
+
+func (m *Block) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Block.Unmarshal(m, b)
+}
+func (m *Block) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Block.Marshal(b, m, deterministic)
+}
+func (m *Block) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Block.Merge(m, src)
+}
+func (m *Block) XXX_Size() int {
+	return xxx_messageInfo_Block.Size(m)
+}
+func (m *Block) XXX_DiscardUnknown() {
+	xxx_messageInfo_Block.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Block proto.InternalMessageInfo
+
+func (m *Block) GetInfo() *BlockInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *Block) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Block) GetMc7() []byte {
+	if m != nil {
+		return m.Mc7
+	}
+	return nil
+}
+
+type BaselineReq struct {
+	Plc                  *Plc     `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	Update               bool     `protobuf:"varint,2,opt,name=update,proto3" json:"update,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BaselineReq) Reset()         { *m = BaselineReq{} }
+func (m *BaselineReq) String() string { return proto.CompactTextString(m) }
+func (*BaselineReq) ProtoMessage()    {}
+func (*BaselineReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{19}
+}
+
+func (m *BaselineReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BaselineReq.Unmarshal(m, b)
+}
+func (m *BaselineReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BaselineReq.Marshal(b, m, deterministic)
+}
+func (m *BaselineReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BaselineReq.Merge(m, src)
+}
+func (m *BaselineReq) XXX_Size() int {
+	return xxx_messageInfo_BaselineReq.Size(m)
+}
+func (m *BaselineReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_BaselineReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BaselineReq proto.InternalMessageInfo
+
+func (m *BaselineReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *BaselineReq) GetUpdate() bool {
+	if m != nil {
+		return m.Update
+	}
+	return false
+}
+
+// BlockChange is a block added, removed or changed since the baseline;
+// fields names what changed of checksum, mc7_size, load_size, code_date and
+// interface_date.
+type BlockChange struct {
+	Type                 string           `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Number               uint32           `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	Kind                 BlockChange_Kind `protobuf:"varint,3,opt,name=kind,proto3,enum=plc_api.BlockChange_Kind" json:"kind,omitempty"`
+	Fields               []string         `protobuf:"bytes,4,rep,name=fields,proto3" json:"fields,omitempty"`
+	Baseline             *BlockInfo       `protobuf:"bytes,5,opt,name=baseline,proto3" json:"baseline,omitempty"`
+	Current              *BlockInfo       `protobuf:"bytes,6,opt,name=current,proto3" json:"current,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *BlockChange) Reset()         { *m = BlockChange{} }
+func (m *BlockChange) String() string { return proto.CompactTextString(m) }
+func (*BlockChange) ProtoMessage()    {}
+func (*BlockChange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{20}
+}
+
+func (m *BlockChange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockChange.Unmarshal(m, b)
+}
+func (m *BlockChange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockChange.Marshal(b, m, deterministic)
+}
+func (m *BlockChange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockChange.Merge(m, src)
+}
+func (m *BlockChange) XXX_Size() int {
+	return xxx_messageInfo_BlockChange.Size(m)
+}
+func (m *BlockChange) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockChange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockChange proto.InternalMessageInfo
+
+func (m *BlockChange) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *BlockChange) GetNumber() uint32 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *BlockChange) GetKind() BlockChange_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return BlockChange_CHANGED
+}
+
+func (m *BlockChange) GetFields() []string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+func (m *BlockChange) GetBaseline() *BlockInfo {
+	if m != nil {
+		return m.Baseline
+	}
+	return nil
+}
+
+func (m *BlockChange) GetCurrent() *BlockInfo {
+	if m != nil {
+		return m.Current
+	}
+	return nil
+}
+
+type BaselineDiff struct {
+	// baseline_taken is when the baseline compared to was taken, unset when
+	// there was none
+	BaselineTaken        *timestamp.Timestamp `protobuf:"bytes,1,opt,name=baseline_taken,json=baselineTaken,proto3" json:"baseline_taken,omitempty"`
+	Changes              []*BlockChange       `protobuf:"bytes,2,rep,name=changes,proto3" json:"changes,omitempty"`
+	Updated              bool                 `protobuf:"varint,3,opt,name=updated,proto3" json:"updated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *BaselineDiff) Reset()         { *m = BaselineDiff{} }
+func (m *BaselineDiff) String() string { return proto.CompactTextString(m) }
+func (*BaselineDiff) ProtoMessage()    {}
+func (*BaselineDiff) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{21}
+}
+
+func (m *BaselineDiff) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BaselineDiff.Unmarshal(m, b)
+}
+func (m *BaselineDiff) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BaselineDiff.Marshal(b, m, deterministic)
+}
+func (m *BaselineDiff) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BaselineDiff.Merge(m, src)
+}
+func (m *BaselineDiff) XXX_Size() int {
+	return xxx_messageInfo_BaselineDiff.Size(m)
+}
+func (m *BaselineDiff) XXX_DiscardUnknown() {
+	xxx_messageInfo_BaselineDiff.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BaselineDiff proto.InternalMessageInfo
+
+func (m *BaselineDiff) GetBaselineTaken() *timestamp.Timestamp {
+	if m != nil {
+		return m.BaselineTaken
+	}
+	return nil
+}
+
+func (m *BaselineDiff) GetChanges() []*BlockChange {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
+func (m *BaselineDiff) GetUpdated() bool {
+	if m != nil {
+		return m.Updated
+	}
+	return false
+}
+
+type DownloadBlockReq struct {
+	Plc    *Plc   `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	Type   string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Number uint32 `protobuf:"varint,3,opt,name=number,proto3" json:"number,omitempty"`
+	// data is the block as in Block.data
+	Data                 []byte   `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Reason               string   `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	Confirm              string   `protobuf:"bytes,6,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DownloadBlockReq) Reset()         { *m = DownloadBlockReq{} }
+func (m *DownloadBlockReq) String() string { return proto.CompactTextString(m) }
+func (*DownloadBlockReq) ProtoMessage()    {}
+func (*DownloadBlockReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{22}
+}
+
+func (m *DownloadBlockReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DownloadBlockReq.Unmarshal(m, b)
+}
+func (m *DownloadBlockReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DownloadBlockReq.Marshal(b, m, deterministic)
+}
+func (m *DownloadBlockReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadBlockReq.Merge(m, src)
+}
+func (m *DownloadBlockReq) XXX_Size() int {
+	return xxx_messageInfo_DownloadBlockReq.Size(m)
+}
+func (m *DownloadBlockReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadBlockReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DownloadBlockReq proto.InternalMessageInfo
+
+func (m *DownloadBlockReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *DownloadBlockReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *DownloadBlockReq) GetNumber() uint32 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *DownloadBlockReq) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *DownloadBlockReq) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *DownloadBlockReq) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
+	}
+	return ""
+}
+
+type DeleteBlockReq struct {
+	Plc                  *Plc     `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	Type                 string   `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Number               uint32   `protobuf:"varint,3,opt,name=number,proto3" json:"number,omitempty"`
+	Reason               string   `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Confirm              string   `protobuf:"bytes,5,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteBlockReq) Reset()         { *m = DeleteBlockReq{} }
+func (m *DeleteBlockReq) String() string { return proto.CompactTextString(m) }
+func (*DeleteBlockReq) ProtoMessage()    {}
+func (*DeleteBlockReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{23}
+}
+
+func (m *DeleteBlockReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteBlockReq.Unmarshal(m, b)
+}
+func (m *DeleteBlockReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteBlockReq.Marshal(b, m, deterministic)
+}
+func (m *DeleteBlockReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteBlockReq.Merge(m, src)
+}
+func (m *DeleteBlockReq) XXX_Size() int {
+	return xxx_messageInfo_DeleteBlockReq.Size(m)
+}
+func (m *DeleteBlockReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteBlockReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteBlockReq proto.InternalMessageInfo
+
+func (m *DeleteBlockReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *DeleteBlockReq) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *DeleteBlockReq) GetNumber() uint32 {
+	if m != nil {
+		return m.Number
+	}
+	return 0
+}
+
+func (m *DeleteBlockReq) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *DeleteBlockReq) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
+	}
+	return ""
+}
+
+// BlockOperationResult is either a confirmation token that expires, or for
+// a download the info of the block downloaded.
+type BlockOperationResult struct {
+	Confirm              string               `protobuf:"bytes,1,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	ConfirmExpires       *timestamp.Timestamp `protobuf:"bytes,2,opt,name=confirm_expires,json=confirmExpires,proto3" json:"confirm_expires,omitempty"`
+	Info                 *BlockInfo           `protobuf:"bytes,3,opt,name=info,proto3" json:"info,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *BlockOperationResult) Reset()         { *m = BlockOperationResult{} }
+func (m *BlockOperationResult) String() string { return proto.CompactTextString(m) }
+func (*BlockOperationResult) ProtoMessage()    {}
+func (*BlockOperationResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{24}
+}
+
+func (m *BlockOperationResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BlockOperationResult.Unmarshal(m, b)
+}
+func (m *BlockOperationResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BlockOperationResult.Marshal(b, m, deterministic)
+}
+func (m *BlockOperationResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockOperationResult.Merge(m, src)
+}
+func (m *BlockOperationResult) XXX_Size() int {
+	return xxx_messageInfo_BlockOperationResult.Size(m)
+}
+func (m *BlockOperationResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockOperationResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockOperationResult proto.InternalMessageInfo
+
+func (m *BlockOperationResult) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
+	}
+	return ""
+}
+
+func (m *BlockOperationResult) GetConfirmExpires() *timestamp.Timestamp {
+	if m != nil {
+		return m.ConfirmExpires
+	}
+	return nil
+}
+
+func (m *BlockOperationResult) GetInfo() *BlockInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+// AreaRange is size bytes of area, M or a DB as in DB5, from start; a size
+// of 0 runs to the end of the area.
+type AreaRange struct {
+	Area                 string   `protobuf:"bytes,1,opt,name=area,proto3" json:"area,omitempty"`
+	Start                uint32   `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	Size                 uint32   `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AreaRange) Reset()         { *m = AreaRange{} }
+func (m *AreaRange) String() string { return proto.CompactTextString(m) }
+func (*AreaRange) ProtoMessage()    {}
+func (*AreaRange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{25}
+}
+
+func (m *AreaRange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AreaRange.Unmarshal(m, b)
+}
+func (m *AreaRange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AreaRange.Marshal(b, m, deterministic)
+}
+func (m *AreaRange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AreaRange.Merge(m, src)
+}
+func (m *AreaRange) XXX_Size() int {
+	return xxx_messageInfo_AreaRange.Size(m)
+}
+func (m *AreaRange) XXX_DiscardUnknown() {
+	xxx_messageInfo_AreaRange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AreaRange proto.InternalMessageInfo
+
+func (m *AreaRange) GetArea() string {
+	if m != nil {
+		return m.Area
+	}
+	return ""
+}
+
+func (m *AreaRange) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *AreaRange) GetSize() uint32 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+type SnapshotReq struct {
+	Plc                  *Plc         `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	Areas                []*AreaRange `protobuf:"bytes,2,rep,name=areas,proto3" json:"areas,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *SnapshotReq) Reset()         { *m = SnapshotReq{} }
+func (m *SnapshotReq) String() string { return proto.CompactTextString(m) }
+func (*SnapshotReq) ProtoMessage()    {}
+func (*SnapshotReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{26}
+}
+
+func (m *SnapshotReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotReq.Unmarshal(m, b)
+}
+func (m *SnapshotReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotReq.Marshal(b, m, deterministic)
+}
+func (m *SnapshotReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotReq.Merge(m, src)
+}
+func (m *SnapshotReq) XXX_Size() int {
+	return xxx_messageInfo_SnapshotReq.Size(m)
+}
+func (m *SnapshotReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotReq proto.InternalMessageInfo
+
+func (m *SnapshotReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *SnapshotReq) GetAreas() []*AreaRange {
+	if m != nil {
+		return m.Areas
+	}
+	return nil
+}
+
+// Snapshot is the contents of areas of a CPU at a time. version is that of
+// the format, saved with it in snapshot files.
+type Snapshot struct {
+	Version              uint32               `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Plc                  *Plc                 `protobuf:"bytes,2,opt,name=plc,proto3" json:"plc,omitempty"`
+	Device               *DeviceInfo          `protobuf:"bytes,3,opt,name=device,proto3" json:"device,omitempty"`
+	Taken                *timestamp.Timestamp `protobuf:"bytes,4,opt,name=taken,proto3" json:"taken,omitempty"`
+	Areas                []*SnapshotArea      `protobuf:"bytes,5,rep,name=areas,proto3" json:"areas,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{27}
+}
+
+func (m *Snapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Snapshot.Unmarshal(m, b)
+}
+func (m *Snapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Snapshot.Marshal(b, m, deterministic)
+}
+func (m *Snapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Snapshot.Merge(m, src)
+}
+func (m *Snapshot) XXX_Size() int {
+	return xxx_messageInfo_Snapshot.Size(m)
+}
+func (m *Snapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_Snapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Snapshot proto.InternalMessageInfo
+
+func (m *Snapshot) GetVersion() uint32 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Snapshot) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *Snapshot) GetDevice() *DeviceInfo {
+	if m != nil {
+		return m.Device
+	}
+	return nil
+}
+
+func (m *Snapshot) GetTaken() *timestamp.Timestamp {
+	if m != nil {
+		return m.Taken
+	}
+	return nil
+}
+
+func (m *Snapshot) GetAreas() []*SnapshotArea {
+	if m != nil {
+		return m.Areas
+	}
+	return nil
+}
+
+// SnapshotArea is data read from area at start; area_size is the size of
+// the whole DB or M memory when taken.
+type SnapshotArea struct {
+	Area                 string   `protobuf:"bytes,1,opt,name=area,proto3" json:"area,omitempty"`
+	Start                uint32   `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	AreaSize             uint32   `protobuf:"varint,3,opt,name=area_size,json=areaSize,proto3" json:"area_size,omitempty"`
+	Data                 []byte   `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotArea) Reset()         { *m = SnapshotArea{} }
+func (m *SnapshotArea) String() string { return proto.CompactTextString(m) }
+func (*SnapshotArea) ProtoMessage()    {}
+func (*SnapshotArea) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{28}
+}
+
+func (m *SnapshotArea) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotArea.Unmarshal(m, b)
+}
+func (m *SnapshotArea) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotArea.Marshal(b, m, deterministic)
+}
+func (m *SnapshotArea) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotArea.Merge(m, src)
+}
+func (m *SnapshotArea) XXX_Size() int {
+	return xxx_messageInfo_SnapshotArea.Size(m)
+}
+func (m *SnapshotArea) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotArea.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotArea proto.InternalMessageInfo
+
+func (m *SnapshotArea) GetArea() string {
+	if m != nil {
+		return m.Area
+	}
+	return ""
+}
+
+func (m *SnapshotArea) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *SnapshotArea) GetAreaSize() uint32 {
+	if m != nil {
+		return m.AreaSize
+	}
+	return 0
+}
+
+func (m *SnapshotArea) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type RestoreReq struct {
+	Plc      *Plc      `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	Snapshot *Snapshot `protobuf:"bytes,2,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	// ranges picks what to restore; all of the snapshot when empty
+	Ranges               []*AreaRange `protobuf:"bytes,3,rep,name=ranges,proto3" json:"ranges,omitempty"`
+	Reason               string       `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Confirm              string       `protobuf:"bytes,5,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *RestoreReq) Reset()         { *m = RestoreReq{} }
+func (m *RestoreReq) String() string { return proto.CompactTextString(m) }
+func (*RestoreReq) ProtoMessage()    {}
+func (*RestoreReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{29}
+}
+
+func (m *RestoreReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreReq.Unmarshal(m, b)
+}
+func (m *RestoreReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreReq.Marshal(b, m, deterministic)
+}
+func (m *RestoreReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreReq.Merge(m, src)
+}
+func (m *RestoreReq) XXX_Size() int {
+	return xxx_messageInfo_RestoreReq.Size(m)
+}
+func (m *RestoreReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreReq proto.InternalMessageInfo
+
+func (m *RestoreReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *RestoreReq) GetSnapshot() *Snapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+func (m *RestoreReq) GetRanges() []*AreaRange {
+	if m != nil {
+		return m.Ranges
+	}
+	return nil
+}
+
+func (m *RestoreReq) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *RestoreReq) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
+	}
+	return ""
+}
+
+// RestoreResult is either a confirmation token that expires, or the ranges
+// written.
+type RestoreResult struct {
+	Confirm              string               `protobuf:"bytes,1,opt,name=confirm,proto3" json:"confirm,omitempty"`
+	ConfirmExpires       *timestamp.Timestamp `protobuf:"bytes,2,opt,name=confirm_expires,json=confirmExpires,proto3" json:"confirm_expires,omitempty"`
+	Written              []*AreaRange         `protobuf:"bytes,3,rep,name=written,proto3" json:"written,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *RestoreResult) Reset()         { *m = RestoreResult{} }
+func (m *RestoreResult) String() string { return proto.CompactTextString(m) }
+func (*RestoreResult) ProtoMessage()    {}
+func (*RestoreResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{30}
+}
+
+func (m *RestoreResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RestoreResult.Unmarshal(m, b)
+}
+func (m *RestoreResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RestoreResult.Marshal(b, m, deterministic)
+}
+func (m *RestoreResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RestoreResult.Merge(m, src)
+}
+func (m *RestoreResult) XXX_Size() int {
+	return xxx_messageInfo_RestoreResult.Size(m)
+}
+func (m *RestoreResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_RestoreResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RestoreResult proto.InternalMessageInfo
+
+func (m *RestoreResult) GetConfirm() string {
+	if m != nil {
+		return m.Confirm
+	}
+	return ""
+}
+
+func (m *RestoreResult) GetConfirmExpires() *timestamp.Timestamp {
+	if m != nil {
+		return m.ConfirmExpires
+	}
+	return nil
+}
+
+func (m *RestoreResult) GetWritten() []*AreaRange {
+	if m != nil {
+		return m.Written
+	}
+	return nil
+}
+
+// DiffSnapshotReq compares snapshot base to current or, without current, to
+// the same areas of plc as they are now, plc defaulting to the CPU of base.
+// The changes are interpreted as the tags and UDTs that lie in them.
+type DiffSnapshotReq struct {
+	Base                 *Snapshot `protobuf:"bytes,1,opt,name=base,proto3" json:"base,omitempty"`
+	Current              *Snapshot `protobuf:"bytes,2,opt,name=current,proto3" json:"current,omitempty"`
+	Plc                  *Plc      `protobuf:"bytes,3,opt,name=plc,proto3" json:"plc,omitempty"`
+	Tags                 []*Tag    `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Udts                 []*Udt    `protobuf:"bytes,5,rep,name=udts,proto3" json:"udts,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *DiffSnapshotReq) Reset()         { *m = DiffSnapshotReq{} }
+func (m *DiffSnapshotReq) String() string { return proto.CompactTextString(m) }
+func (*DiffSnapshotReq) ProtoMessage()    {}
+func (*DiffSnapshotReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{31}
+}
+
+func (m *DiffSnapshotReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DiffSnapshotReq.Unmarshal(m, b)
+}
+func (m *DiffSnapshotReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DiffSnapshotReq.Marshal(b, m, deterministic)
+}
+func (m *DiffSnapshotReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DiffSnapshotReq.Merge(m, src)
+}
+func (m *DiffSnapshotReq) XXX_Size() int {
+	return xxx_messageInfo_DiffSnapshotReq.Size(m)
+}
+func (m *DiffSnapshotReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_DiffSnapshotReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DiffSnapshotReq proto.InternalMessageInfo
+
+func (m *DiffSnapshotReq) GetBase() *Snapshot {
+	if m != nil {
+		return m.Base
+	}
+	return nil
+}
+
+func (m *DiffSnapshotReq) GetCurrent() *Snapshot {
+	if m != nil {
+		return m.Current
+	}
+	return nil
+}
+
+func (m *DiffSnapshotReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *DiffSnapshotReq) GetTags() []*Tag {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *DiffSnapshotReq) GetUdts() []*Udt {
+	if m != nil {
+		return m.Udts
+	}
+	return nil
+}
+
+// Udt lays out an instance of a structure at address, as DB5P20: each
+// field is at its offset from there.
+type Udt struct {
+	Name                 string      `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address              string      `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Fields               []*UdtField `protobuf:"bytes,3,rep,name=fields,proto3" json:"fields,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *Udt) Reset()         { *m = Udt{} }
+func (m *Udt) String() string { return proto.CompactTextString(m) }
+func (*Udt) ProtoMessage()    {}
+func (*Udt) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{32}
+}
+
+func (m *Udt) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Udt.Unmarshal(m, b)
+}
+func (m *Udt) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Udt.Marshal(b, m, deterministic)
+}
+func (m *Udt) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Udt.Merge(m, src)
+}
+func (m *Udt) XXX_Size() int {
+	return xxx_messageInfo_Udt.Size(m)
+}
+func (m *Udt) XXX_DiscardUnknown() {
+	xxx_messageInfo_Udt.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Udt proto.InternalMessageInfo
+
+func (m *Udt) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Udt) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *Udt) GetFields() []*UdtField {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type UdtField struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Dt                   string   `protobuf:"bytes,2,opt,name=dt,proto3" json:"dt,omitempty"`
+	Offset               uint32   `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Bit                  uint32   `protobuf:"varint,4,opt,name=bit,proto3" json:"bit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *UdtField) Reset()         { *m = UdtField{} }
+func (m *UdtField) String() string { return proto.CompactTextString(m) }
+func (*UdtField) ProtoMessage()    {}
+func (*UdtField) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{33}
+}
+
+func (m *UdtField) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UdtField.Unmarshal(m, b)
+}
+func (m *UdtField) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UdtField.Marshal(b, m, deterministic)
+}
+func (m *UdtField) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UdtField.Merge(m, src)
+}
+func (m *UdtField) XXX_Size() int {
+	return xxx_messageInfo_UdtField.Size(m)
+}
+func (m *UdtField) XXX_DiscardUnknown() {
+	xxx_messageInfo_UdtField.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UdtField proto.InternalMessageInfo
+
+func (m *UdtField) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *UdtField) GetDt() string {
+	if m != nil {
+		return m.Dt
+	}
+	return ""
+}
+
+func (m *UdtField) GetOffset() uint32 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *UdtField) GetBit() uint32 {
+	if m != nil {
+		return m.Bit
+	}
+	return 0
+}
+
+// ByteChange is a run of bytes of area that differ, from start.
+type ByteChange struct {
+	Area                 string   `protobuf:"bytes,1,opt,name=area,proto3" json:"area,omitempty"`
+	Start                uint32   `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	Before               []byte   `protobuf:"bytes,3,opt,name=before,proto3" json:"before,omitempty"`
+	After                []byte   `protobuf:"bytes,4,opt,name=after,proto3" json:"after,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ByteChange) Reset()         { *m = ByteChange{} }
+func (m *ByteChange) String() string { return proto.CompactTextString(m) }
+func (*ByteChange) ProtoMessage()    {}
+func (*ByteChange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{34}
+}
+
+func (m *ByteChange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ByteChange.Unmarshal(m, b)
+}
+func (m *ByteChange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ByteChange.Marshal(b, m, deterministic)
+}
+func (m *ByteChange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ByteChange.Merge(m, src)
+}
+func (m *ByteChange) XXX_Size() int {
+	return xxx_messageInfo_ByteChange.Size(m)
+}
+func (m *ByteChange) XXX_DiscardUnknown() {
+	xxx_messageInfo_ByteChange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ByteChange proto.InternalMessageInfo
+
+func (m *ByteChange) GetArea() string {
+	if m != nil {
+		return m.Area
+	}
+	return ""
+}
+
+func (m *ByteChange) GetStart() uint32 {
+	if m != nil {
+		return m.Start
+	}
+	return 0
+}
+
+func (m *ByteChange) GetBefore() []byte {
+	if m != nil {
+		return m.Before
+	}
+	return nil
+}
+
+func (m *ByteChange) GetAfter() []byte {
+	if m != nil {
+		return m.After
+	}
+	return nil
+}
+
+// TagChange is a tag whose value differs, as text; name is that of the UDT
+// field, as in recipe.speed, empty for a tag.
+type TagChange struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Address              string   `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Dt                   string   `protobuf:"bytes,3,opt,name=dt,proto3" json:"dt,omitempty"`
+	Before               string   `protobuf:"bytes,4,opt,name=before,proto3" json:"before,omitempty"`
+	After                string   `protobuf:"bytes,5,opt,name=after,proto3" json:"after,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TagChange) Reset()         { *m = TagChange{} }
+func (m *TagChange) String() string { return proto.CompactTextString(m) }
+func (*TagChange) ProtoMessage()    {}
+func (*TagChange) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{35}
+}
+
+func (m *TagChange) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TagChange.Unmarshal(m, b)
+}
+func (m *TagChange) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TagChange.Marshal(b, m, deterministic)
+}
+func (m *TagChange) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TagChange.Merge(m, src)
+}
+func (m *TagChange) XXX_Size() int {
+	return xxx_messageInfo_TagChange.Size(m)
+}
+func (m *TagChange) XXX_DiscardUnknown() {
+	xxx_messageInfo_TagChange.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TagChange proto.InternalMessageInfo
+
+func (m *TagChange) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *TagChange) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *TagChange) GetDt() string {
+	if m != nil {
+		return m.Dt
+	}
+	return ""
+}
+
+func (m *TagChange) GetBefore() string {
+	if m != nil {
+		return m.Before
+	}
+	return ""
+}
+
+func (m *TagChange) GetAfter() string {
+	if m != nil {
+		return m.After
+	}
+	return ""
+}
+
+type SnapshotDiff struct {
+	BaseTaken    *timestamp.Timestamp `protobuf:"bytes,1,opt,name=base_taken,json=baseTaken,proto3" json:"base_taken,omitempty"`
+	CurrentTaken *timestamp.Timestamp `protobuf:"bytes,2,opt,name=current_taken,json=currentTaken,proto3" json:"current_taken,omitempty"`
+	Bytes        []*ByteChange        `protobuf:"bytes,3,rep,name=bytes,proto3" json:"bytes,omitempty"`
+	Tags         []*TagChange         `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	// unmatched are the areas only one of the snapshots has
+	Unmatched            []string `protobuf:"bytes,5,rep,name=unmatched,proto3" json:"unmatched,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotDiff) Reset()         { *m = SnapshotDiff{} }
+func (m *SnapshotDiff) String() string { return proto.CompactTextString(m) }
+func (*SnapshotDiff) ProtoMessage()    {}
+func (*SnapshotDiff) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{36}
+}
+
+func (m *SnapshotDiff) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotDiff.Unmarshal(m, b)
+}
+func (m *SnapshotDiff) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotDiff.Marshal(b, m, deterministic)
+}
+func (m *SnapshotDiff) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotDiff.Merge(m, src)
+}
+func (m *SnapshotDiff) XXX_Size() int {
+	return xxx_messageInfo_SnapshotDiff.Size(m)
+}
+func (m *SnapshotDiff) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotDiff.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotDiff proto.InternalMessageInfo
+
+func (m *SnapshotDiff) GetBaseTaken() *timestamp.Timestamp {
+	if m != nil {
+		return m.BaseTaken
+	}
+	return nil
+}
+
+func (m *SnapshotDiff) GetCurrentTaken() *timestamp.Timestamp {
+	if m != nil {
+		return m.CurrentTaken
+	}
+	return nil
+}
+
+func (m *SnapshotDiff) GetBytes() []*ByteChange {
+	if m != nil {
+		return m.Bytes
+	}
+	return nil
+}
+
+func (m *SnapshotDiff) GetTags() []*TagChange {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *SnapshotDiff) GetUnmatched() []string {
+	if m != nil {
+		return m.Unmatched
+	}
+	return nil
+}
+
+type PlcTime struct {
+	Time *timestamp.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	// drift is how far the clock of the CPU is ahead of that of goplc
+	Drift                *duration.Duration `protobuf:"bytes,2,opt,name=drift,proto3" json:"drift,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *PlcTime) Reset()         { *m = PlcTime{} }
+func (m *PlcTime) String() string { return proto.CompactTextString(m) }
+func (*PlcTime) ProtoMessage()    {}
+func (*PlcTime) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{37}
+}
+
+func (m *PlcTime) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PlcTime.Unmarshal(m, b)
+}
+func (m *PlcTime) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PlcTime.Marshal(b, m, deterministic)
+}
+func (m *PlcTime) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PlcTime.Merge(m, src)
+}
+func (m *PlcTime) XXX_Size() int {
+	return xxx_messageInfo_PlcTime.Size(m)
+}
+func (m *PlcTime) XXX_DiscardUnknown() {
+	xxx_messageInfo_PlcTime.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PlcTime proto.InternalMessageInfo
+
+func (m *PlcTime) GetTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *PlcTime) GetDrift() *duration.Duration {
+	if m != nil {
+		return m.Drift
+	}
+	return nil
+}
+
+type SetPlcTimeReq struct {
+	Plc *Plc `protobuf:"bytes,1,opt,name=plc,proto3" json:"plc,omitempty"`
+	// time is the time to set, that of goplc when unset
+	Time                 *timestamp.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *SetPlcTimeReq) Reset()         { *m = SetPlcTimeReq{} }
+func (m *SetPlcTimeReq) String() string { return proto.CompactTextString(m) }
+func (*SetPlcTimeReq) ProtoMessage()    {}
+func (*SetPlcTimeReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{38}
+}
+
+func (m *SetPlcTimeReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetPlcTimeReq.Unmarshal(m, b)
+}
+func (m *SetPlcTimeReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetPlcTimeReq.Marshal(b, m, deterministic)
+}
+func (m *SetPlcTimeReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetPlcTimeReq.Merge(m, src)
+}
+func (m *SetPlcTimeReq) XXX_Size() int {
+	return xxx_messageInfo_SetPlcTimeReq.Size(m)
+}
+func (m *SetPlcTimeReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetPlcTimeReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetPlcTimeReq proto.InternalMessageInfo
+
+func (m *SetPlcTimeReq) GetPlc() *Plc {
+	if m != nil {
+		return m.Plc
+	}
+	return nil
+}
+
+func (m *SetPlcTimeReq) GetTime() *timestamp.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+type SetSessionPasswordReq struct {
+	// ref is the name Plc.password_ref gives the password
+	Ref                  string   `protobuf:"bytes,1,opt,name=ref,proto3" json:"ref,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetSessionPasswordReq) Reset()         { *m = SetSessionPasswordReq{} }
+func (m *SetSessionPasswordReq) String() string { return proto.CompactTextString(m) }
+func (*SetSessionPasswordReq) ProtoMessage()    {}
+func (*SetSessionPasswordReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{39}
+}
+
+func (m *SetSessionPasswordReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetSessionPasswordReq.Unmarshal(m, b)
+}
+func (m *SetSessionPasswordReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetSessionPasswordReq.Marshal(b, m, deterministic)
+}
+func (m *SetSessionPasswordReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetSessionPasswordReq.Merge(m, src)
+}
+func (m *SetSessionPasswordReq) XXX_Size() int {
+	return xxx_messageInfo_SetSessionPasswordReq.Size(m)
+}
+func (m *SetSessionPasswordReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetSessionPasswordReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetSessionPasswordReq proto.InternalMessageInfo
+
+func (m *SetSessionPasswordReq) GetRef() string {
+	if m != nil {
+		return m.Ref
+	}
+	return ""
+}
+
+func (m *SetSessionPasswordReq) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type SetSessionPasswordResult struct {
+	// replaced is whether goplc kept another password under ref before
+	Replaced             bool     `protobuf:"varint,1,opt,name=replaced,proto3" json:"replaced,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetSessionPasswordResult) Reset()         { *m = SetSessionPasswordResult{} }
+func (m *SetSessionPasswordResult) String() string { return proto.CompactTextString(m) }
+func (*SetSessionPasswordResult) ProtoMessage()    {}
+func (*SetSessionPasswordResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{40}
+}
+
+func (m *SetSessionPasswordResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetSessionPasswordResult.Unmarshal(m, b)
+}
+func (m *SetSessionPasswordResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetSessionPasswordResult.Marshal(b, m, deterministic)
+}
+func (m *SetSessionPasswordResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetSessionPasswordResult.Merge(m, src)
+}
+func (m *SetSessionPasswordResult) XXX_Size() int {
+	return xxx_messageInfo_SetSessionPasswordResult.Size(m)
+}
+func (m *SetSessionPasswordResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetSessionPasswordResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetSessionPasswordResult proto.InternalMessageInfo
+
+func (m *SetSessionPasswordResult) GetReplaced() bool {
+	if m != nil {
+		return m.Replaced
+	}
+	return false
+}
+
+type ClearSessionPasswordResult struct {
+	// cleared is false when goplc kept no password under the name
+	Cleared              bool     `protobuf:"varint,1,opt,name=cleared,proto3" json:"cleared,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ClearSessionPasswordResult) Reset()         { *m = ClearSessionPasswordResult{} }
+func (m *ClearSessionPasswordResult) String() string { return proto.CompactTextString(m) }
+func (*ClearSessionPasswordResult) ProtoMessage()    {}
+func (*ClearSessionPasswordResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a0a6ab4644bfacb6, []int{41}
+}
+
+func (m *ClearSessionPasswordResult) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ClearSessionPasswordResult.Unmarshal(m, b)
+}
+func (m *ClearSessionPasswordResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ClearSessionPasswordResult.Marshal(b, m, deterministic)
+}
+func (m *ClearSessionPasswordResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ClearSessionPasswordResult.Merge(m, src)
+}
+func (m *ClearSessionPasswordResult) XXX_Size() int {
+	return xxx_messageInfo_ClearSessionPasswordResult.Size(m)
+}
+func (m *ClearSessionPasswordResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_ClearSessionPasswordResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ClearSessionPasswordResult proto.InternalMessageInfo
+
+func (m *ClearSessionPasswordResult) GetCleared() bool {
+	if m != nil {
+		return m.Cleared
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterEnum("plc_api.CpuState_State", CpuState_State_name, CpuState_State_value)
+	proto.RegisterEnum("plc_api.CpuControlReq_Mode", CpuControlReq_Mode_name, CpuControlReq_Mode_value)
+	proto.RegisterEnum("plc_api.BlockChange_Kind", BlockChange_Kind_name, BlockChange_Kind_value)
+	proto.RegisterType((*S7CpuInfo)(nil), "plc_api.S7CpuInfo")
+	proto.RegisterType((*Plc)(nil), "plc_api.Plc")
+	proto.RegisterMapType((map[string]string)(nil), "plc_api.Plc.OptionsEntry")
+	proto.RegisterType((*DeviceInfo)(nil), "plc_api.DeviceInfo")
+	proto.RegisterMapType((map[string]string)(nil), "plc_api.DeviceInfo.DetailsEntry")
+	proto.RegisterType((*Tag)(nil), "plc_api.Tag")
+	proto.RegisterType((*RWResult)(nil), "plc_api.RWResult")
+	proto.RegisterType((*RWReq)(nil), "plc_api.RWReq")
+	proto.RegisterType((*CpuState)(nil), "plc_api.CpuState")
+	proto.RegisterType((*CpuControlReq)(nil), "plc_api.CpuControlReq")
+	proto.RegisterType((*CpuControlResult)(nil), "plc_api.CpuControlResult")
+	proto.RegisterType((*SzlReq)(nil), "plc_api.SzlReq")
+	proto.RegisterType((*Szl)(nil), "plc_api.Szl")
+	proto.RegisterType((*SzlRecord)(nil), "plc_api.SzlRecord")
+	proto.RegisterMapType((map[string]string)(nil), "plc_api.SzlRecord.FieldsEntry")
+	proto.RegisterType((*DiagnosticReq)(nil), "plc_api.DiagnosticReq")
+	proto.RegisterType((*DiagnosticEntry)(nil), "plc_api.DiagnosticEntry")
+	proto.RegisterType((*DiagnosticBuffer)(nil), "plc_api.DiagnosticBuffer")
+	proto.RegisterType((*BlockList)(nil), "plc_api.BlockList")
+	proto.RegisterType((*BlockList_Type)(nil), "plc_api.BlockList.Type")
+	proto.RegisterType((*BlockReq)(nil), "plc_api.BlockReq")
+	proto.RegisterType((*BlockInfo)(nil), "plc_api.BlockInfo")
+	proto.RegisterType((*Block)(nil), "plc_api.Block")
+	proto.RegisterType((*BaselineReq)(nil), "plc_api.BaselineReq")
+	proto.RegisterType((*BlockChange)(nil), "plc_api.BlockChange")
+	proto.RegisterType((*BaselineDiff)(nil), "plc_api.BaselineDiff")
+	proto.RegisterType((*DownloadBlockReq)(nil), "plc_api.DownloadBlockReq")
+	proto.RegisterType((*DeleteBlockReq)(nil), "plc_api.DeleteBlockReq")
+	proto.RegisterType((*BlockOperationResult)(nil), "plc_api.BlockOperationResult")
+	proto.RegisterType((*AreaRange)(nil), "plc_api.AreaRange")
+	proto.RegisterType((*SnapshotReq)(nil), "plc_api.SnapshotReq")
+	proto.RegisterType((*Snapshot)(nil), "plc_api.Snapshot")
+	proto.RegisterType((*SnapshotArea)(nil), "plc_api.SnapshotArea")
+	proto.RegisterType((*RestoreReq)(nil), "plc_api.RestoreReq")
+	proto.RegisterType((*RestoreResult)(nil), "plc_api.RestoreResult")
+	proto.RegisterType((*DiffSnapshotReq)(nil), "plc_api.DiffSnapshotReq")
+	proto.RegisterType((*Udt)(nil), "plc_api.Udt")
+	proto.RegisterType((*UdtField)(nil), "plc_api.UdtField")
+	proto.RegisterType((*ByteChange)(nil), "plc_api.ByteChange")
+	proto.RegisterType((*TagChange)(nil), "plc_api.TagChange")
+	proto.RegisterType((*SnapshotDiff)(nil), "plc_api.SnapshotDiff")
+	proto.RegisterType((*PlcTime)(nil), "plc_api.PlcTime")
+	proto.RegisterType((*SetPlcTimeReq)(nil), "plc_api.SetPlcTimeReq")
+	proto.RegisterType((*SetSessionPasswordReq)(nil), "plc_api.SetSessionPasswordReq")
+	proto.RegisterType((*SetSessionPasswordResult)(nil), "plc_api.SetSessionPasswordResult")
+	proto.RegisterType((*ClearSessionPasswordResult)(nil), "plc_api.ClearSessionPasswordResult")
+}
+
+func init() {
+	proto.RegisterFile("plc.proto", fileDescriptor_a0a6ab4644bfacb6)
+}
+
+var fileDescriptor_a0a6ab4644bfacb6 = []byte{
+	// 2813 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x39, 0x4b, 0x93, 0xdb, 0xc6,
+	0xd1, 0x04, 0xdf, 0x6c, 0x3e, 0x96, 0x1a, 0x4b, 0x6b, 0x88, 0xb2, 0x65, 0x19, 0x2e, 0x7f, 0x96,
+	0x2d, 0x9b, 0x92, 0xd7, 0xdf, 0x67, 0xd9, 0x3e, 0x7c, 0x8e, 0x96, 0x5c, 0x69, 0x65, 0x49, 0x2b,
+	0x15, 0xb8, 0x6b, 0x25, 0x97, 0xb0, 0x86, 0xc0, 0x90, 0x8b, 0x08, 0x04, 0x60, 0x60, 0xb8, 0xd2,
+	0xea, 0xee, 0x5c, 0x52, 0x95, 0xca, 0x29, 0xa9, 0xca, 0xe3, 0x90, 0xaa, 0xdc, 0x73, 0xc8, 0x29,
+	0x3f, 0x21, 0xb7, 0x5c, 0xf3, 0x1f, 0xf2, 0x0f, 0x72, 0x49, 0xf5, 0x3c, 0x00, 0x70, 0x97, 0xeb,
+	0xa5, 0x5c, 0x65, 0x5f, 0x58, 0xe8, 0x9e, 0xee, 0x99, 0x9e, 0x7e, 0x4f, 0x13, 0x1a, 0x91, 0xef,
+	0xf4, 0xa3, 0x38, 0xe4, 0x21, 0xa9, 0x45, 0xbe, 0x33, 0xa6, 0x91, 0xd7, 0x7b, 0x6b, 0x16, 0x86,
+	0x33, 0x9f, 0xdd, 0x14, 0xe8, 0xc9, 0x62, 0x7a, 0x93, 0x7b, 0x73, 0x96, 0x70, 0x3a, 0x8f, 0x24,
+	0x65, 0xef, 0xea, 0x49, 0x02, 0x77, 0x11, 0x53, 0xee, 0x85, 0x81, 0x5c, 0xb7, 0xfe, 0x5a, 0x82,
+	0xc6, 0xe8, 0xf6, 0x20, 0x5a, 0xdc, 0x0f, 0xa6, 0x21, 0xb9, 0x0e, 0xdd, 0x79, 0xe8, 0x2e, 0x7c,
+	0x36, 0xe6, 0xc7, 0x11, 0x1b, 0x07, 0x74, 0xce, 0x4c, 0xe3, 0x9a, 0x71, 0xbd, 0x61, 0x77, 0x24,
+	0x7e, 0xff, 0x38, 0x62, 0x7b, 0x74, 0xce, 0xc8, 0x3b, 0xd0, 0x4e, 0x58, 0xec, 0x51, 0x7f, 0x1c,
+	0x2c, 0xe6, 0x13, 0x16, 0x9b, 0x45, 0x41, 0xd6, 0x92, 0xc8, 0x3d, 0x81, 0x23, 0xaf, 0x43, 0x8d,
+	0x26, 0x72, 0x97, 0x92, 0x58, 0xae, 0xd2, 0x44, 0x70, 0xbf, 0x01, 0x0d, 0x27, 0x8c, 0x8e, 0x63,
+	0x6f, 0x76, 0xc8, 0xcd, 0xb2, 0x58, 0xca, 0x10, 0xe4, 0x2d, 0x68, 0x2a, 0x29, 0x04, 0x6b, 0x45,
+	0xac, 0x83, 0x44, 0x09, 0xf6, 0x37, 0x01, 0xc2, 0xd8, 0x65, 0xf1, 0xd8, 0x09, 0x5d, 0x66, 0x56,
+	0x25, 0xbf, 0xc0, 0x0c, 0x42, 0x97, 0x91, 0xf7, 0xa1, 0x3b, 0xf5, 0xe2, 0xf9, 0x73, 0x1a, 0xb3,
+	0xf1, 0x11, 0x8b, 0x13, 0x2f, 0x0c, 0xcc, 0x9a, 0x20, 0xda, 0xd0, 0xf8, 0xaf, 0x25, 0x1a, 0x49,
+	0x51, 0x0f, 0xcc, 0x41, 0x95, 0x8c, 0x7d, 0x76, 0xc4, 0x7c, 0xb3, 0x7e, 0xcd, 0xb8, 0xde, 0xb6,
+	0x37, 0x32, 0xfc, 0x43, 0x44, 0xe3, 0x8d, 0xe7, 0xa1, 0xcb, 0xc6, 0x09, 0xf3, 0x99, 0xc3, 0xc3,
+	0xd8, 0x6c, 0xc8, 0x1b, 0x23, 0x72, 0xa4, 0x70, 0xe4, 0x32, 0xd4, 0x23, 0x77, 0x31, 0x4e, 0xbc,
+	0x97, 0xcc, 0x04, 0xb1, 0x4f, 0x2d, 0x72, 0x17, 0x23, 0xef, 0x25, 0x23, 0xef, 0xc1, 0xc6, 0x9c,
+	0xbe, 0x18, 0x3b, 0x61, 0x10, 0xc8, 0x6d, 0x13, 0xb3, 0x29, 0x28, 0x3a, 0x73, 0xfa, 0x62, 0x90,
+	0x61, 0xf1, 0x76, 0x4e, 0xb4, 0x18, 0x4f, 0xe9, 0xdc, 0xf3, 0x8f, 0xcd, 0x96, 0xd2, 0x4e, 0xb4,
+	0xb8, 0x2b, 0x10, 0xd6, 0xb7, 0x45, 0x28, 0x3d, 0xf1, 0x1d, 0x42, 0xa0, 0x7c, 0x18, 0x26, 0x5c,
+	0xd9, 0x47, 0x7c, 0x23, 0x2e, 0xa6, 0xce, 0x33, 0x61, 0x8c, 0xb6, 0x2d, 0xbe, 0x11, 0x97, 0xf8,
+	0x21, 0x17, 0x16, 0x68, 0xdb, 0xe2, 0x1b, 0x71, 0x51, 0x18, 0x4b, 0xd5, 0xb7, 0x6d, 0xf1, 0x4d,
+	0x7a, 0x50, 0x17, 0x2e, 0xe1, 0x84, 0xbe, 0x52, 0x79, 0x0a, 0x93, 0x4f, 0xa0, 0x16, 0x46, 0x52,
+	0xe6, 0xea, 0xb5, 0xd2, 0xf5, 0xe6, 0xd6, 0xe5, 0xbe, 0xf2, 0xc0, 0xfe, 0x13, 0xdf, 0xe9, 0x3f,
+	0x96, 0x6b, 0x3b, 0x01, 0x8f, 0x8f, 0x6d, 0x4d, 0x49, 0xde, 0x86, 0x56, 0x44, 0x93, 0xe4, 0x79,
+	0x18, 0xbb, 0xe3, 0x98, 0x4d, 0x95, 0x09, 0x9a, 0x1a, 0x67, 0xb3, 0x69, 0xef, 0x0b, 0x68, 0xe5,
+	0x79, 0x49, 0x17, 0x4a, 0xcf, 0xd8, 0xb1, 0xba, 0x12, 0x7e, 0x92, 0x8b, 0x50, 0x39, 0xa2, 0xfe,
+	0x82, 0x29, 0xff, 0x92, 0xc0, 0x17, 0xc5, 0xcf, 0x0c, 0xeb, 0xcf, 0x45, 0x80, 0x21, 0x3b, 0xf2,
+	0x1c, 0x26, 0x5c, 0x37, 0x2f, 0xbe, 0x71, 0x42, 0xfc, 0x4d, 0xa8, 0x1e, 0xb1, 0xc0, 0x0d, 0xb5,
+	0x97, 0x2a, 0x08, 0x37, 0x47, 0xeb, 0xf9, 0xca, 0x3b, 0x25, 0x70, 0xda, 0xb5, 0xcb, 0x2b, 0x5c,
+	0x9b, 0x40, 0x39, 0xe7, 0x9c, 0xe2, 0x9b, 0x98, 0x50, 0xd3, 0xee, 0x26, 0x7d, 0x52, 0x83, 0xe4,
+	0x0b, 0xa8, 0xb9, 0x8c, 0x53, 0xcf, 0x4f, 0xcc, 0x9a, 0xd0, 0xdf, 0xb5, 0x54, 0x7f, 0xd9, 0x15,
+	0xfa, 0x43, 0x49, 0xa2, 0xd4, 0xa8, 0x18, 0x50, 0x47, 0xf9, 0x85, 0x57, 0xd2, 0xd1, 0xdf, 0x4a,
+	0x50, 0xda, 0xa7, 0x33, 0x94, 0x8c, 0xba, 0x6e, 0xcc, 0x92, 0x44, 0xf1, 0x69, 0x90, 0x74, 0xa0,
+	0xe8, 0x72, 0xc5, 0x58, 0x74, 0x31, 0xf6, 0x40, 0xb0, 0x8f, 0x27, 0x61, 0x28, 0xf5, 0x52, 0xdf,
+	0x2d, 0xd8, 0x0d, 0x81, 0xdb, 0x0e, 0x43, 0x9f, 0xbc, 0x0b, 0x6d, 0x49, 0xe0, 0x05, 0x9c, 0xcd,
+	0x94, 0x76, 0x4a, 0xbb, 0x05, 0xbb, 0x25, 0xd0, 0xf7, 0x25, 0x96, 0xbc, 0x07, 0x1d, 0x49, 0xb6,
+	0xd0, 0x74, 0xa8, 0xa9, 0xf2, 0x6e, 0xc1, 0x96, 0xec, 0x07, 0x0a, 0x4d, 0xde, 0x01, 0xc9, 0x38,
+	0x76, 0xc3, 0xc5, 0xc4, 0x97, 0xd1, 0x6c, 0xec, 0x16, 0xec, 0xa6, 0xc0, 0x0e, 0x05, 0x92, 0xbc,
+	0x0d, 0x4d, 0x25, 0xd5, 0x31, 0x67, 0x89, 0xf0, 0xa4, 0xd6, 0x6e, 0xc1, 0x96, 0xa2, 0x6e, 0x23,
+	0x2e, 0xdb, 0x27, 0xe1, 0xb1, 0x17, 0xcc, 0x44, 0x14, 0x37, 0xd2, 0x7d, 0x46, 0x02, 0x49, 0x76,
+	0x60, 0x43, 0x12, 0xa5, 0x69, 0x52, 0x44, 0x71, 0x73, 0xab, 0xd7, 0x97, 0x79, 0xb2, 0xaf, 0xf3,
+	0x64, 0x7f, 0x5f, 0x53, 0xec, 0x16, 0x6c, 0x79, 0x95, 0x14, 0x43, 0xb6, 0xf5, 0xe5, 0x74, 0x32,
+	0x15, 0xb1, 0x8e, 0x51, 0x71, 0x72, 0x97, 0xa1, 0x22, 0x48, 0xef, 0xad, 0x11, 0x68, 0x46, 0x16,
+	0xc7, 0x22, 0x05, 0x34, 0x6c, 0xfc, 0xdc, 0xae, 0x29, 0x33, 0x5a, 0x1f, 0x42, 0xdd, 0x7e, 0x6a,
+	0xb3, 0x64, 0xe1, 0x73, 0x72, 0x0d, 0xca, 0x9c, 0xce, 0x12, 0xb3, 0x28, 0xdc, 0xa6, 0x95, 0xba,
+	0xcd, 0x3e, 0x9d, 0xd9, 0x62, 0xc5, 0xba, 0x0f, 0x15, 0xa4, 0xfe, 0x86, 0x5c, 0x85, 0x52, 0xe4,
+	0x3b, 0xc2, 0xc0, 0x79, 0xca, 0x27, 0xbe, 0x63, 0xe3, 0xc2, 0x1a, 0x5b, 0x7d, 0x6b, 0x40, 0x7d,
+	0x10, 0x2d, 0x46, 0x9c, 0x72, 0x46, 0x3e, 0x82, 0x4a, 0x82, 0x1f, 0x62, 0xc3, 0xce, 0xd6, 0xeb,
+	0x29, 0xbd, 0xa6, 0xe8, 0x8b, 0x5f, 0x5b, 0x52, 0x59, 0x5f, 0x41, 0x45, 0xf2, 0x35, 0xa1, 0x76,
+	0xb0, 0xf7, 0x60, 0xef, 0xf1, 0xd3, 0xbd, 0x6e, 0x81, 0xd4, 0xa0, 0x64, 0x1f, 0xec, 0x75, 0x0d,
+	0x52, 0x87, 0xf2, 0x68, 0xff, 0xf1, 0x93, 0x6e, 0x11, 0xd7, 0x47, 0xfb, 0x77, 0xec, 0xfd, 0x83,
+	0x27, 0xdd, 0x12, 0xa2, 0x77, 0x1f, 0x3f, 0x1c, 0x76, 0xcb, 0x04, 0xa0, 0x3a, 0xdc, 0xb9, 0xbb,
+	0x33, 0xd8, 0xef, 0x56, 0xac, 0xdf, 0x1a, 0xd0, 0x1e, 0x44, 0x8b, 0x41, 0x18, 0xf0, 0x38, 0xf4,
+	0xd7, 0xb9, 0xdb, 0x4d, 0x28, 0x63, 0xf0, 0x0a, 0x47, 0xee, 0x6c, 0x5d, 0xc9, 0xcb, 0x9a, 0xed,
+	0xd2, 0x7f, 0x14, 0xba, 0xcc, 0x16, 0x84, 0x18, 0x11, 0x4e, 0x18, 0x60, 0x39, 0x50, 0xc1, 0xaf,
+	0x41, 0xab, 0x07, 0x65, 0xa4, 0x43, 0xd1, 0x9e, 0xde, 0xb1, 0x1f, 0x75, 0x0b, 0xf8, 0x35, 0x40,
+	0x21, 0x0d, 0xeb, 0xf7, 0x06, 0x74, 0xf3, 0x5b, 0x0a, 0x13, 0xe5, 0xb6, 0x32, 0x96, 0xb6, 0x22,
+	0x03, 0xd8, 0x50, 0x9f, 0x63, 0xf6, 0x22, 0xf2, 0x62, 0x96, 0x98, 0xc5, 0xf3, 0xdc, 0xcd, 0xee,
+	0x28, 0x96, 0x1d, 0xc9, 0x41, 0xde, 0xd3, 0x76, 0x28, 0x09, 0xd6, 0x0b, 0xa7, 0xec, 0xa0, 0x2d,
+	0xb0, 0x07, 0xd5, 0xd1, 0xcb, 0xb5, 0xb4, 0xd5, 0x81, 0xa2, 0xe7, 0xaa, 0x22, 0x51, 0xf4, 0x5c,
+	0x4c, 0x20, 0x5e, 0xe0, 0xb2, 0x17, 0xaa, 0x46, 0x48, 0xc0, 0x7a, 0x09, 0xa5, 0xd1, 0x4b, 0x5f,
+	0x11, 0x1b, 0xa7, 0x89, 0x8b, 0x39, 0x62, 0xac, 0xd9, 0x31, 0x73, 0x30, 0xd5, 0x8b, 0xda, 0x27,
+	0x37, 0x02, 0x89, 0x12, 0xe5, 0xef, 0x43, 0xa8, 0x49, 0x28, 0x31, 0xcb, 0xc2, 0x01, 0x49, 0x2a,
+	0x97, 0x90, 0x1a, 0x97, 0x6c, 0x4d, 0x62, 0xfd, 0xc6, 0x80, 0x46, 0x8a, 0xc6, 0x58, 0x89, 0xe9,
+	0x73, 0x21, 0x43, 0xcb, 0xc6, 0x4f, 0xf2, 0x29, 0x54, 0xa7, 0x1e, 0xf3, 0x5d, 0xed, 0xcd, 0x57,
+	0x4f, 0x6f, 0xd6, 0xbf, 0x2b, 0x08, 0x64, 0x36, 0x55, 0xd4, 0xbd, 0xcf, 0xa1, 0x99, 0x43, 0xbf,
+	0x52, 0x2e, 0x9d, 0x42, 0x7b, 0xe8, 0xd1, 0x59, 0x10, 0x26, 0xdc, 0x73, 0xd6, 0xd1, 0xf2, 0xff,
+	0x41, 0x1d, 0x93, 0x5c, 0x7c, 0x44, 0x7d, 0xb3, 0x78, 0x4e, 0x7e, 0xb0, 0x53, 0x52, 0xeb, 0xdf,
+	0x06, 0x6c, 0x64, 0x07, 0x49, 0x39, 0x2f, 0x43, 0x9d, 0x1d, 0xb1, 0x80, 0x8f, 0x53, 0x4b, 0xd4,
+	0x04, 0x7c, 0xdf, 0x95, 0x75, 0xcf, 0x0b, 0x63, 0x8f, 0x1f, 0x2b, 0x8b, 0xa4, 0x30, 0xb9, 0x02,
+	0x8d, 0x70, 0xa2, 0xab, 0x98, 0x34, 0x49, 0x3d, 0x9c, 0xa8, 0x0a, 0x76, 0x09, 0xaa, 0x2e, 0x15,
+	0x3b, 0xca, 0x2e, 0xa0, 0xe2, 0x52, 0x7e, 0x5f, 0x99, 0x77, 0x1a, 0x7e, 0x6c, 0x56, 0xb4, 0x79,
+	0xa7, 0xe1, 0xc7, 0x1a, 0xbb, 0x65, 0x56, 0x33, 0xec, 0x16, 0xe9, 0x43, 0x19, 0x13, 0xa9, 0x59,
+	0x3b, 0xd7, 0xa9, 0x05, 0x1d, 0x16, 0x4d, 0xce, 0x5e, 0x70, 0x99, 0x9b, 0x6d, 0xf1, 0x6d, 0xdd,
+	0x85, 0x6e, 0x76, 0xdb, 0xed, 0xc5, 0x74, 0xca, 0x62, 0xb2, 0x05, 0x35, 0x16, 0xf0, 0xd8, 0x63,
+	0x58, 0xae, 0xd0, 0xbc, 0x66, 0x56, 0x2e, 0x97, 0x35, 0x63, 0x6b, 0x42, 0xeb, 0x97, 0x06, 0x34,
+	0xb6, 0xfd, 0xd0, 0x79, 0xf6, 0xd0, 0x4b, 0x38, 0x26, 0x2f, 0xec, 0x60, 0x35, 0x7f, 0x96, 0xbc,
+	0x52, 0x92, 0x3e, 0xb6, 0xb2, 0xb6, 0xa4, 0xea, 0x7d, 0x05, 0x65, 0x04, 0x85, 0x80, 0xc7, 0x91,
+	0xee, 0x79, 0xc5, 0x37, 0x5e, 0xdd, 0x09, 0x17, 0x01, 0xd7, 0xfe, 0x2e, 0x00, 0x0c, 0x7a, 0xa9,
+	0xd7, 0xc4, 0x2c, 0x5d, 0x2b, 0xa1, 0x41, 0x14, 0x68, 0x7d, 0x0d, 0x75, 0x71, 0xc8, 0x3a, 0x2e,
+	0xa2, 0xcf, 0x2b, 0xe6, 0xce, 0xdb, 0x84, 0xea, 0x92, 0xc5, 0x14, 0x64, 0xfd, 0xab, 0xa4, 0x2e,
+	0x28, 0xda, 0x9d, 0x55, 0x92, 0x66, 0x9c, 0xc5, 0x3c, 0x27, 0xba, 0x88, 0x4f, 0x83, 0xd9, 0x82,
+	0xce, 0x74, 0x1f, 0x9e, 0xc2, 0x78, 0xbb, 0xa9, 0x8f, 0x55, 0x41, 0x39, 0x81, 0x00, 0xd0, 0xdf,
+	0xe6, 0xce, 0x6d, 0x19, 0xca, 0xd2, 0x0f, 0x6a, 0x73, 0xe7, 0xb6, 0x88, 0xe3, 0x2b, 0xd0, 0xf0,
+	0x43, 0xaa, 0xc2, 0x5c, 0x7a, 0x43, 0x1d, 0x11, 0x62, 0xf1, 0x4d, 0x00, 0x3f, 0x74, 0xa8, 0x3f,
+	0x76, 0x29, 0xa7, 0xc2, 0x2d, 0xda, 0x76, 0x43, 0x60, 0x86, 0x94, 0x53, 0x5c, 0x4e, 0x26, 0x93,
+	0xb1, 0xcf, 0x82, 0x19, 0x3f, 0x54, 0x7d, 0x76, 0x23, 0x99, 0x4c, 0x1e, 0x0a, 0x04, 0xca, 0xe9,
+	0x1c, 0x32, 0xe7, 0x59, 0xb2, 0x98, 0x8b, 0xb2, 0xdc, 0xb6, 0x53, 0x38, 0xdf, 0x5b, 0xc1, 0x72,
+	0x6f, 0x75, 0x1b, 0xdf, 0x12, 0x2e, 0xc3, 0x23, 0x99, 0xd9, 0x3c, 0xd7, 0x13, 0xeb, 0x48, 0x3c,
+	0xc4, 0x42, 0x75, 0x07, 0x3a, 0x22, 0xe8, 0xa6, 0xd4, 0x51, 0xdc, 0xad, 0x73, 0xb9, 0xdb, 0x29,
+	0x87, 0xd8, 0x62, 0x13, 0xaa, 0x74, 0xc1, 0x0f, 0xc3, 0xd8, 0x6c, 0xab, 0xf7, 0x8d, 0x80, 0x10,
+	0xaf, 0xda, 0xf7, 0x8e, 0xc4, 0x4b, 0x08, 0xf1, 0x87, 0x8c, 0xba, 0x2c, 0x36, 0x37, 0x24, 0x5e,
+	0x42, 0xd6, 0x01, 0x54, 0x84, 0x69, 0xc9, 0xff, 0x40, 0x19, 0x43, 0x4b, 0x79, 0x0c, 0x59, 0x76,
+	0x5b, 0x34, 0xbc, 0x5d, 0xf6, 0x94, 0xf9, 0x85, 0x8a, 0x8b, 0x22, 0x25, 0x8a, 0x6f, 0x4c, 0x66,
+	0x73, 0xe7, 0xb6, 0xb0, 0x70, 0xcb, 0xc6, 0x4f, 0x6b, 0x07, 0x9a, 0xdb, 0x34, 0x61, 0xbe, 0x17,
+	0xb0, 0x75, 0xbc, 0x71, 0x13, 0xaa, 0x8b, 0x48, 0x28, 0x02, 0xb7, 0xad, 0xdb, 0x0a, 0xb2, 0x7e,
+	0x55, 0x84, 0xa6, 0x10, 0x60, 0x70, 0x48, 0x83, 0x19, 0x7b, 0x25, 0xdf, 0xfb, 0x08, 0xca, 0xcf,
+	0xbc, 0xc0, 0x15, 0x52, 0x75, 0x72, 0xcf, 0x86, 0xdc, 0x7e, 0xfd, 0x07, 0x5e, 0xe0, 0xda, 0x82,
+	0x4c, 0x28, 0x4e, 0xe6, 0x75, 0x2c, 0x12, 0x0d, 0x9d, 0xb7, 0x49, 0x1f, 0xea, 0x13, 0x75, 0x13,
+	0xb3, 0x72, 0xa6, 0x6e, 0x52, 0x1a, 0xac, 0x36, 0xce, 0x22, 0x8e, 0x59, 0xc0, 0xcd, 0xea, 0x99,
+	0xe4, 0x9a, 0xc4, 0xba, 0x01, 0x65, 0x94, 0x01, 0x5b, 0x93, 0xc1, 0xee, 0x9d, 0xbd, 0x7b, 0x3b,
+	0xc3, 0x6e, 0x81, 0x34, 0xa0, 0x72, 0x67, 0x38, 0xdc, 0x19, 0x76, 0x0d, 0xc4, 0xdb, 0x3b, 0x8f,
+	0x1e, 0x7f, 0xbd, 0x33, 0xec, 0x16, 0xad, 0x3f, 0x18, 0xd0, 0xd2, 0x5a, 0x1d, 0x7a, 0xd3, 0x29,
+	0xfa, 0x91, 0x3e, 0x77, 0xcc, 0xe9, 0x33, 0x16, 0x98, 0xc6, 0xf9, 0x7e, 0xa4, 0x39, 0xf6, 0x91,
+	0x81, 0xf4, 0xa1, 0xe6, 0x08, 0x5d, 0xe8, 0x7a, 0x76, 0x71, 0x95, 0xa2, 0x6c, 0x4d, 0x84, 0xd1,
+	0x20, 0x6d, 0x23, 0x15, 0x5b, 0xb7, 0x35, 0x68, 0xfd, 0xc5, 0x80, 0xee, 0x30, 0x7c, 0x1e, 0x60,
+	0x48, 0xfe, 0x10, 0x69, 0x28, 0xf5, 0xbc, 0x72, 0xce, 0xf3, 0x36, 0xa1, 0x1a, 0x33, 0x9a, 0x84,
+	0x81, 0x7a, 0x0e, 0x29, 0x28, 0xdf, 0x19, 0x55, 0x97, 0x9b, 0xac, 0x5f, 0x1b, 0xd0, 0x19, 0x32,
+	0x9f, 0x71, 0xf6, 0x83, 0x08, 0x99, 0x09, 0x54, 0x3e, 0x4b, 0xa0, 0xca, 0xb2, 0x40, 0x7f, 0x32,
+	0xe0, 0xa2, 0x10, 0xe5, 0x71, 0xc4, 0x54, 0x49, 0xfe, 0x51, 0xba, 0x3b, 0x1d, 0xf0, 0xa5, 0xef,
+	0x0e, 0x78, 0xeb, 0x3e, 0x34, 0xee, 0xc4, 0x8c, 0xda, 0x3a, 0x00, 0x69, 0xcc, 0xa8, 0x0e, 0x40,
+	0xfc, 0xc6, 0x44, 0x9e, 0x70, 0x1a, 0xa7, 0x65, 0x4a, 0x00, 0x48, 0x99, 0xeb, 0xc7, 0xc4, 0xb7,
+	0xf5, 0x14, 0x9a, 0xa3, 0x80, 0x46, 0xc9, 0x61, 0xc8, 0xd7, 0xd1, 0xfb, 0x75, 0xa8, 0xe0, 0x01,
+	0xda, 0x33, 0x33, 0x11, 0x53, 0x79, 0x6c, 0x49, 0x60, 0xfd, 0xd3, 0x80, 0xba, 0xde, 0x39, 0x9f,
+	0xb0, 0x55, 0xc7, 0xa2, 0x40, 0x7d, 0x60, 0xf1, 0xac, 0x03, 0x6f, 0x40, 0xd5, 0x15, 0x8f, 0x62,
+	0xa5, 0x94, 0xd7, 0x56, 0xbc, 0x95, 0x6d, 0x45, 0x42, 0x6e, 0x41, 0x45, 0xc6, 0x5c, 0xf9, 0x5c,
+	0xd5, 0x4b, 0x42, 0x72, 0x43, 0xdf, 0xa7, 0x22, 0xee, 0x73, 0x29, 0xeb, 0x1c, 0x95, 0xe8, 0xe2,
+	0x5e, 0xea, 0x4a, 0x1e, 0xb4, 0xf2, 0xe8, 0x57, 0xd0, 0xfc, 0x15, 0x68, 0xe0, 0x6a, 0xbe, 0x1d,
+	0xae, 0x23, 0x42, 0xd4, 0xc9, 0x15, 0x41, 0x64, 0xfd, 0xdd, 0x00, 0xb0, 0x59, 0xc2, 0xc3, 0x78,
+	0xad, 0x64, 0xfd, 0x11, 0xd4, 0x13, 0x25, 0x99, 0x59, 0x3c, 0xf1, 0x32, 0x48, 0xcd, 0x9b, 0x92,
+	0x90, 0x0f, 0xa0, 0x1a, 0xcb, 0x04, 0x53, 0x3a, 0xd3, 0x8c, 0x8a, 0xe2, 0x7b, 0x44, 0xcf, 0x1f,
+	0x0d, 0x68, 0xa7, 0xb2, 0xff, 0x18, 0x61, 0xf3, 0x21, 0xd4, 0x9e, 0xc7, 0x1e, 0xe7, 0x2c, 0xf8,
+	0x8e, 0xfb, 0x68, 0x12, 0xeb, 0x1f, 0xa2, 0xa5, 0x9e, 0x4e, 0xf3, 0x6e, 0xff, 0x2e, 0x94, 0x31,
+	0x07, 0x9b, 0xc6, 0x59, 0xba, 0x13, 0xcb, 0xe4, 0x46, 0x56, 0x48, 0xce, 0xd4, 0xb2, 0xa6, 0xd0,
+	0x36, 0x2b, 0x9d, 0xf7, 0x02, 0x2f, 0x9f, 0xf5, 0x02, 0x47, 0x8a, 0x85, 0xcb, 0xb5, 0x6f, 0x66,
+	0x14, 0x07, 0x2e, 0xb7, 0xc5, 0x8a, 0xf5, 0x73, 0x28, 0x1d, 0xb8, 0x3c, 0x9d, 0x3f, 0x19, 0xcb,
+	0xf3, 0x27, 0x3d, 0xe5, 0x29, 0x2e, 0x4f, 0x79, 0xde, 0x4f, 0xcb, 0xaa, 0xd4, 0xd6, 0x85, 0xfc,
+	0xc6, 0xe2, 0x41, 0xa4, 0x2b, 0xad, 0xf5, 0x53, 0xa8, 0x6b, 0xdc, 0xca, 0x43, 0x4e, 0x0e, 0x8c,
+	0x36, 0xa1, 0x1a, 0x4e, 0xa7, 0x09, 0xd3, 0x03, 0x46, 0x05, 0x61, 0x37, 0x32, 0xf1, 0xf4, 0x84,
+	0x11, 0x3f, 0x2d, 0x17, 0x00, 0x47, 0x35, 0x59, 0x13, 0xb1, 0x66, 0x24, 0x6d, 0x42, 0x75, 0xc2,
+	0xa6, 0x61, 0xcc, 0x54, 0x6b, 0xa3, 0x20, 0xa4, 0xa6, 0x53, 0xae, 0x26, 0x50, 0x2d, 0x5b, 0x02,
+	0xd6, 0x73, 0x68, 0xec, 0xd3, 0x59, 0x76, 0xc8, 0x2b, 0x68, 0x49, 0x5e, 0xad, 0x94, 0xbf, 0x9a,
+	0x3a, 0x58, 0xc5, 0xc1, 0xc9, 0x83, 0x65, 0x14, 0xa8, 0x83, 0xff, 0x63, 0x64, 0xb9, 0x42, 0xf4,
+	0x05, 0x9f, 0x03, 0xa0, 0x0b, 0xad, 0xdd, 0x13, 0x34, 0x90, 0x5a, 0xf6, 0x03, 0x5f, 0x42, 0x5b,
+	0xf9, 0x94, 0xe2, 0x3e, 0x3f, 0x42, 0x5a, 0x8a, 0x41, 0x6e, 0xf0, 0x3e, 0x54, 0xe4, 0xa8, 0x4c,
+	0xda, 0x3b, 0x4b, 0xa1, 0x99, 0x05, 0x6c, 0x49, 0x81, 0x15, 0x28, 0xe7, 0x94, 0x24, 0xef, 0x94,
+	0x8a, 0x50, 0xba, 0xe6, 0x1b, 0xd0, 0x58, 0x04, 0x73, 0xca, 0x9d, 0x43, 0xe6, 0x0a, 0xff, 0x6c,
+	0xd8, 0x19, 0xc2, 0xfa, 0x05, 0xd4, 0x9e, 0xf8, 0x0e, 0x8a, 0x93, 0xbe, 0x0a, 0x8d, 0x35, 0x5f,
+	0x85, 0x37, 0xa1, 0xe2, 0xc6, 0xde, 0x94, 0x9f, 0xff, 0x48, 0x96, 0x74, 0xd6, 0x18, 0xda, 0x23,
+	0xc6, 0xd5, 0x71, 0xeb, 0xe4, 0x4a, 0x2d, 0x51, 0x71, 0x3d, 0x89, 0xac, 0x1d, 0xb8, 0x34, 0x62,
+	0x7c, 0xc4, 0x12, 0xac, 0x57, 0x4f, 0xd2, 0x79, 0xf5, 0x37, 0x62, 0x10, 0xc1, 0xa6, 0x7a, 0x5e,
+	0x10, 0xb3, 0xa9, 0x78, 0x7e, 0x2b, 0x02, 0xe5, 0x4e, 0x29, 0x6c, 0x7d, 0x0a, 0xe6, 0xaa, 0x6d,
+	0x44, 0x7e, 0xec, 0x41, 0x3d, 0x66, 0x91, 0x4f, 0x1d, 0x26, 0x5f, 0xf4, 0x75, 0x3b, 0x85, 0xad,
+	0x4f, 0xa1, 0x37, 0xf0, 0x19, 0x8d, 0x57, 0x73, 0x62, 0x66, 0xc5, 0xd5, 0x94, 0x51, 0x83, 0x5b,
+	0xbf, 0x6b, 0x42, 0x05, 0xef, 0xfc, 0x94, 0xdc, 0x02, 0xb8, 0xc7, 0xb8, 0xfe, 0x57, 0x67, 0x49,
+	0x23, 0xbd, 0xdc, 0xdc, 0x45, 0xff, 0xef, 0x63, 0x15, 0xc8, 0x4d, 0xa8, 0xdb, 0x8c, 0xba, 0xfb,
+	0x68, 0xe9, 0x4e, 0x4a, 0x21, 0x06, 0x8b, 0xbd, 0x0b, 0x4b, 0x30, 0x0a, 0x61, 0x15, 0xc8, 0x2d,
+	0x68, 0x3c, 0x8d, 0x3d, 0xce, 0xd6, 0xe7, 0xf8, 0x5f, 0x68, 0xdf, 0x63, 0x3c, 0x37, 0xb2, 0x5f,
+	0x96, 0x6b, 0x55, 0x99, 0x17, 0xe7, 0x34, 0xe5, 0x55, 0xe4, 0x74, 0x71, 0x99, 0xe7, 0xf4, 0x30,
+	0xcc, 0x2a, 0x90, 0x2f, 0xa1, 0x3e, 0xc2, 0xc4, 0x31, 0x88, 0x16, 0x64, 0x73, 0xf5, 0x24, 0xb0,
+	0x77, 0x79, 0x25, 0x5e, 0x09, 0xfa, 0xff, 0x50, 0x1b, 0xf1, 0x30, 0xfa, 0xde, 0xfc, 0x1f, 0x40,
+	0x0d, 0x75, 0x89, 0xc3, 0xb3, 0x8d, 0xe5, 0xb9, 0xd4, 0x37, 0xbd, 0x56, 0x1e, 0x61, 0x15, 0xc8,
+	0x57, 0xf0, 0x1a, 0x2a, 0xe5, 0xe4, 0x04, 0x64, 0x73, 0xc5, 0xc0, 0x63, 0xf9, 0xdc, 0x93, 0x2c,
+	0x56, 0x81, 0x3c, 0x82, 0x4b, 0x4f, 0x31, 0x1c, 0xd7, 0xde, 0xed, 0xcc, 0xb1, 0x8a, 0x55, 0xb8,
+	0x65, 0xa0, 0x13, 0xe1, 0xa0, 0x44, 0x74, 0xa2, 0xc9, 0x99, 0x4e, 0x94, 0x0e, 0x54, 0xac, 0x02,
+	0xb9, 0x0d, 0xad, 0x7b, 0x8c, 0x67, 0x43, 0x8a, 0x0b, 0xcb, 0x54, 0x78, 0xe4, 0x8a, 0x0e, 0xd7,
+	0x2a, 0x90, 0x2d, 0x68, 0x1e, 0x44, 0xe9, 0x93, 0x65, 0x15, 0x5f, 0x67, 0x19, 0x65, 0x15, 0xc8,
+	0x36, 0x5c, 0x18, 0x84, 0xf3, 0x88, 0xc6, 0x6c, 0x3f, 0xd4, 0xef, 0x31, 0x92, 0x7b, 0x37, 0x65,
+	0x0f, 0xdf, 0xde, 0xa5, 0x53, 0x58, 0x4c, 0xd0, 0x56, 0x81, 0x3c, 0x80, 0xf6, 0xd2, 0x63, 0x89,
+	0xe4, 0xf4, 0x7b, 0xe2, 0x11, 0xd5, 0x7b, 0x73, 0x59, 0x82, 0x13, 0xef, 0x04, 0xab, 0x40, 0xee,
+	0x41, 0x33, 0xf7, 0xa4, 0x21, 0xd9, 0xcc, 0x69, 0xf9, 0xa1, 0x73, 0xfe, 0x46, 0x9f, 0x43, 0x0b,
+	0xb3, 0x78, 0xda, 0x4a, 0x5f, 0x3c, 0xdd, 0x72, 0x2c, 0xc5, 0x98, 0xc6, 0x5a, 0x05, 0xf2, 0x13,
+	0xd8, 0x50, 0x7d, 0x58, 0xca, 0x9d, 0xc5, 0x55, 0xd6, 0x5d, 0xf6, 0x36, 0x4f, 0x23, 0xd5, 0xe1,
+	0x77, 0xa0, 0x95, 0x6f, 0x95, 0x48, 0xde, 0x47, 0x96, 0x3a, 0xa8, 0xde, 0xe9, 0xce, 0x59, 0x69,
+	0xb5, 0x2f, 0xb2, 0x8f, 0x2e, 0x07, 0xcb, 0x8e, 0xd3, 0xcd, 0x43, 0xb8, 0x6e, 0x15, 0xc8, 0x67,
+	0x00, 0x59, 0x3e, 0xcf, 0x39, 0xeb, 0x52, 0x92, 0x5f, 0xc9, 0xf9, 0x33, 0x20, 0xa7, 0x33, 0x2c,
+	0xb9, 0x9a, 0xdf, 0xe1, 0x74, 0x16, 0xef, 0xbd, 0xfd, 0x9d, 0xeb, 0x4a, 0x0f, 0x0f, 0xe0, 0xe2,
+	0xaa, 0x24, 0x7c, 0xe2, 0x3a, 0xef, 0x64, 0x79, 0xe0, 0xcc, 0x8c, 0x6d, 0x15, 0x26, 0x55, 0x51,
+	0x6a, 0x3e, 0xf9, 0xef, 0x00, 0x75, 0x27, 0x87, 0x3d, 0xc3, 0x1f, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// PlcRWClient is the client API for PlcRW service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PlcRWClient interface {
+	GetCpuInfo(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*S7CpuInfo, error)
+	ReadTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error)
+	WriteTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error)
+	GetDeviceInfo(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*DeviceInfo, error)
+	// GetCpuState reads the operating state of the CPU.
+	GetCpuState(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*CpuState, error)
+	// StartCpu and StopCpu change the operating state. They need the
+	// engineer role and two calls: the first, without confirm, returns a
+	// token that the second has to repeat.
+	StartCpu(ctx context.Context, in *CpuControlReq, opts ...grpc.CallOption) (*CpuControlResult, error)
+	StopCpu(ctx context.Context, in *CpuControlReq, opts ...grpc.CallOption) (*CpuControlResult, error)
+	// ReadSzl reads a system status list of an S7 CPU.
+	ReadSzl(ctx context.Context, in *SzlReq, opts ...grpc.CallOption) (*Szl, error)
+	// GetDiagnosticBuffer reads the diagnostic buffer of an S7 CPU, newest
+	// entry first. WatchDiagnosticBuffer streams the entries that appear
+	// after the call, oldest first.
+	GetDiagnosticBuffer(ctx context.Context, in *DiagnosticReq, opts ...grpc.CallOption) (*DiagnosticBuffer, error)
+	WatchDiagnosticBuffer(ctx context.Context, in *DiagnosticReq, opts ...grpc.CallOption) (PlcRW_WatchDiagnosticBufferClient, error)
+	// ListBlocks lists the OBs, FBs, FCs, DBs, SFBs and SFCs of an S7 CPU.
+	ListBlocks(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*BlockList, error)
+	// GetBlockInfo reads the header of a block, UploadBlock the block.
+	GetBlockInfo(ctx context.Context, in *BlockReq, opts ...grpc.CallOption) (*BlockInfo, error)
+	UploadBlock(ctx context.Context, in *BlockReq, opts ...grpc.CallOption) (*Block, error)
+	// CompareToBaseline compares the blocks of an S7 CPU to the baseline of
+	// their checksums, sizes and dates, and with update, which needs the
+	// engineer role, makes them the baseline.
+	CompareToBaseline(ctx context.Context, in *BaselineReq, opts ...grpc.CallOption) (*BaselineDiff, error)
+	// DownloadBlock loads a block as UploadBlock returns it into an S7 CPU,
+	// replacing the block of the same number, and DeleteBlock deletes one.
+	// Like StartCpu they need the engineer role and a confirmation token, and
+	// a reason, which is audited. A CPU protected against writes refuses
+	// them.
+	DownloadBlock(ctx context.Context, in *DownloadBlockReq, opts ...grpc.CallOption) (*BlockOperationResult, error)
+	DeleteBlock(ctx context.Context, in *DeleteBlockReq, opts ...grpc.CallOption) (*BlockOperationResult, error)
+	// TakeSnapshot reads DBs and M memory of an S7 CPU, a PDU at a time.
+	// RestoreSnapshot writes a snapshot back, all of it or the ranges asked
+	// for; like DownloadBlock it needs the engineer role, a confirmation token
+	// and a reason.
+	TakeSnapshot(ctx context.Context, in *SnapshotReq, opts ...grpc.CallOption) (*Snapshot, error)
+	RestoreSnapshot(ctx context.Context, in *RestoreReq, opts ...grpc.CallOption) (*RestoreResult, error)
+	// DiffSnapshot compares two snapshots, or a snapshot to the CPU, byte by
+	// byte and tag by tag.
+	DiffSnapshot(ctx context.Context, in *DiffSnapshotReq, opts ...grpc.CallOption) (*SnapshotDiff, error)
+	// GetPlcTime reads the clock of an S7 CPU and SetPlcTime, which needs
+	// the engineer role and is audited, sets it. The clock runs in the time
+	// zone of the time_zone option of the Plc, UTC by default.
+	GetPlcTime(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*PlcTime, error)
+	SetPlcTime(ctx context.Context, in *SetPlcTimeReq, opts ...grpc.CallOption) (*PlcTime, error)
+	// SetSessionPassword keeps a password of protected CPUs under a name for
+	// Plc.password_ref, and ClearSessionPassword forgets the one a Plc
+	// names, so that later connections no longer send it. Both need the
+	// engineer role and are audited.
+	SetSessionPassword(ctx context.Context, in *SetSessionPasswordReq, opts ...grpc.CallOption) (*SetSessionPasswordResult, error)
+	ClearSessionPassword(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*ClearSessionPasswordResult, error)
+}
+
+type plcRWClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPlcRWClient(cc grpc.ClientConnInterface) PlcRWClient {
+	return &plcRWClient{cc}
+}
+
+func (c *plcRWClient) GetCpuInfo(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*S7CpuInfo, error) {
+	out := new(S7CpuInfo)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetCpuInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) ReadTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error) {
+	out := new(RWResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/ReadTags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) WriteTags(ctx context.Context, in *RWReq, opts ...grpc.CallOption) (*RWResult, error) {
+	out := new(RWResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/WriteTags", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) GetDeviceInfo(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*DeviceInfo, error) {
+	out := new(DeviceInfo)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetDeviceInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) GetCpuState(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*CpuState, error) {
+	out := new(CpuState)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetCpuState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) StartCpu(ctx context.Context, in *CpuControlReq, opts ...grpc.CallOption) (*CpuControlResult, error) {
+	out := new(CpuControlResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/StartCpu", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) StopCpu(ctx context.Context, in *CpuControlReq, opts ...grpc.CallOption) (*CpuControlResult, error) {
+	out := new(CpuControlResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/StopCpu", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) ReadSzl(ctx context.Context, in *SzlReq, opts ...grpc.CallOption) (*Szl, error) {
+	out := new(Szl)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/ReadSzl", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) GetDiagnosticBuffer(ctx context.Context, in *DiagnosticReq, opts ...grpc.CallOption) (*DiagnosticBuffer, error) {
+	out := new(DiagnosticBuffer)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetDiagnosticBuffer", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) WatchDiagnosticBuffer(ctx context.Context, in *DiagnosticReq, opts ...grpc.CallOption) (PlcRW_WatchDiagnosticBufferClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_PlcRW_serviceDesc.Streams[0], "/plc_api.PlcRW/WatchDiagnosticBuffer", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &plcRWWatchDiagnosticBufferClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PlcRW_WatchDiagnosticBufferClient interface {
+	Recv() (*DiagnosticEntry, error)
+	grpc.ClientStream
+}
+
+type plcRWWatchDiagnosticBufferClient struct {
+	grpc.ClientStream
+}
+
+func (x *plcRWWatchDiagnosticBufferClient) Recv() (*DiagnosticEntry, error) {
+	m := new(DiagnosticEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *plcRWClient) ListBlocks(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*BlockList, error) {
+	out := new(BlockList)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/ListBlocks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) GetBlockInfo(ctx context.Context, in *BlockReq, opts ...grpc.CallOption) (*BlockInfo, error) {
+	out := new(BlockInfo)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetBlockInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) UploadBlock(ctx context.Context, in *BlockReq, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/UploadBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) CompareToBaseline(ctx context.Context, in *BaselineReq, opts ...grpc.CallOption) (*BaselineDiff, error) {
+	out := new(BaselineDiff)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/CompareToBaseline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) DownloadBlock(ctx context.Context, in *DownloadBlockReq, opts ...grpc.CallOption) (*BlockOperationResult, error) {
+	out := new(BlockOperationResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/DownloadBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) DeleteBlock(ctx context.Context, in *DeleteBlockReq, opts ...grpc.CallOption) (*BlockOperationResult, error) {
+	out := new(BlockOperationResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/DeleteBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) TakeSnapshot(ctx context.Context, in *SnapshotReq, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/TakeSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) RestoreSnapshot(ctx context.Context, in *RestoreReq, opts ...grpc.CallOption) (*RestoreResult, error) {
+	out := new(RestoreResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/RestoreSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) DiffSnapshot(ctx context.Context, in *DiffSnapshotReq, opts ...grpc.CallOption) (*SnapshotDiff, error) {
+	out := new(SnapshotDiff)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/DiffSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) GetPlcTime(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*PlcTime, error) {
+	out := new(PlcTime)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/GetPlcTime", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) SetPlcTime(ctx context.Context, in *SetPlcTimeReq, opts ...grpc.CallOption) (*PlcTime, error) {
+	out := new(PlcTime)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/SetPlcTime", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) SetSessionPassword(ctx context.Context, in *SetSessionPasswordReq, opts ...grpc.CallOption) (*SetSessionPasswordResult, error) {
+	out := new(SetSessionPasswordResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/SetSessionPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *plcRWClient) ClearSessionPassword(ctx context.Context, in *Plc, opts ...grpc.CallOption) (*ClearSessionPasswordResult, error) {
+	out := new(ClearSessionPasswordResult)
+	err := c.cc.Invoke(ctx, "/plc_api.PlcRW/ClearSessionPassword", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PlcRWServer is the server API for PlcRW service.
+type PlcRWServer interface {
+	GetCpuInfo(context.Context, *Plc) (*S7CpuInfo, error)
+	ReadTags(context.Context, *RWReq) (*RWResult, error)
+	WriteTags(context.Context, *RWReq) (*RWResult, error)
+	GetDeviceInfo(context.Context, *Plc) (*DeviceInfo, error)
+	// GetCpuState reads the operating state of the CPU.
+	GetCpuState(context.Context, *Plc) (*CpuState, error)
+	// StartCpu and StopCpu change the operating state. They need the
+	// engineer role and two calls: the first, without confirm, returns a
+	// token that the second has to repeat.
+	StartCpu(context.Context, *CpuControlReq) (*CpuControlResult, error)
+	StopCpu(context.Context, *CpuControlReq) (*CpuControlResult, error)
+	// ReadSzl reads a system status list of an S7 CPU.
+	ReadSzl(context.Context, *SzlReq) (*Szl, error)
+	// GetDiagnosticBuffer reads the diagnostic buffer of an S7 CPU, newest
+	// entry first. WatchDiagnosticBuffer streams the entries that appear
+	// after the call, oldest first.
+	GetDiagnosticBuffer(context.Context, *DiagnosticReq) (*DiagnosticBuffer, error)
+	WatchDiagnosticBuffer(*DiagnosticReq, PlcRW_WatchDiagnosticBufferServer) error
+	// ListBlocks lists the OBs, FBs, FCs, DBs, SFBs and SFCs of an S7 CPU.
+	ListBlocks(context.Context, *Plc) (*BlockList, error)
+	// GetBlockInfo reads the header of a block, UploadBlock the block.
+	GetBlockInfo(context.Context, *BlockReq) (*BlockInfo, error)
+	UploadBlock(context.Context, *BlockReq) (*Block, error)
+	// CompareToBaseline compares the blocks of an S7 CPU to the baseline of
+	// their checksums, sizes and dates, and with update, which needs the
+	// engineer role, makes them the baseline.
+	CompareToBaseline(context.Context, *BaselineReq) (*BaselineDiff, error)
+	// DownloadBlock loads a block as UploadBlock returns it into an S7 CPU,
+	// replacing the block of the same number, and DeleteBlock deletes one.
+	// Like StartCpu they need the engineer role and a confirmation token, and
+	// a reason, which is audited. A CPU protected against writes refuses
+	// them.
+	DownloadBlock(context.Context, *DownloadBlockReq) (*BlockOperationResult, error)
+	DeleteBlock(context.Context, *DeleteBlockReq) (*BlockOperationResult, error)
+	// TakeSnapshot reads DBs and M memory of an S7 CPU, a PDU at a time.
+	// RestoreSnapshot writes a snapshot back, all of it or the ranges asked
+	// for; like DownloadBlock it needs the engineer role, a confirmation token
+	// and a reason.
+	TakeSnapshot(context.Context, *SnapshotReq) (*Snapshot, error)
+	RestoreSnapshot(context.Context, *RestoreReq) (*RestoreResult, error)
+	// DiffSnapshot compares two snapshots, or a snapshot to the CPU, byte by
+	// byte and tag by tag.
+	DiffSnapshot(context.Context, *DiffSnapshotReq) (*SnapshotDiff, error)
+	// GetPlcTime reads the clock of an S7 CPU and SetPlcTime, which needs
+	// the engineer role and is audited, sets it. The clock runs in the time
+	// zone of the time_zone option of the Plc, UTC by default.
+	GetPlcTime(context.Context, *Plc) (*PlcTime, error)
+	SetPlcTime(context.Context, *SetPlcTimeReq) (*PlcTime, error)
+	// SetSessionPassword keeps a password of protected CPUs under a name for
+	// Plc.password_ref, and ClearSessionPassword forgets the one a Plc
+	// names, so that later connections no longer send it. Both need the
+	// engineer role and are audited.
+	SetSessionPassword(context.Context, *SetSessionPasswordReq) (*SetSessionPasswordResult, error)
+	ClearSessionPassword(context.Context, *Plc) (*ClearSessionPasswordResult, error)
+}
+
+// UnimplementedPlcRWServer can be embedded to have forward compatible implementations.
+type UnimplementedPlcRWServer struct {
+}
+
+func (*UnimplementedPlcRWServer) GetCpuInfo(ctx context.Context, req *Plc) (*S7CpuInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCpuInfo not implemented")
+}
+func (*UnimplementedPlcRWServer) ReadTags(ctx context.Context, req *RWReq) (*RWResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadTags not implemented")
+}
+func (*UnimplementedPlcRWServer) WriteTags(ctx context.Context, req *RWReq) (*RWResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WriteTags not implemented")
+}
+func (*UnimplementedPlcRWServer) GetDeviceInfo(ctx context.Context, req *Plc) (*DeviceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeviceInfo not implemented")
+}
+func (*UnimplementedPlcRWServer) GetCpuState(ctx context.Context, req *Plc) (*CpuState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCpuState not implemented")
+}
+func (*UnimplementedPlcRWServer) StartCpu(ctx context.Context, req *CpuControlReq) (*CpuControlResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartCpu not implemented")
+}
+func (*UnimplementedPlcRWServer) StopCpu(ctx context.Context, req *CpuControlReq) (*CpuControlResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopCpu not implemented")
+}
+func (*UnimplementedPlcRWServer) ReadSzl(ctx context.Context, req *SzlReq) (*Szl, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadSzl not implemented")
+}
+func (*UnimplementedPlcRWServer) GetDiagnosticBuffer(ctx context.Context, req *DiagnosticReq) (*DiagnosticBuffer, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDiagnosticBuffer not implemented")
+}
+func (*UnimplementedPlcRWServer) WatchDiagnosticBuffer(req *DiagnosticReq, srv PlcRW_WatchDiagnosticBufferServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchDiagnosticBuffer not implemented")
+}
+func (*UnimplementedPlcRWServer) ListBlocks(ctx context.Context, req *Plc) (*BlockList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBlocks not implemented")
+}
+func (*UnimplementedPlcRWServer) GetBlockInfo(ctx context.Context, req *BlockReq) (*BlockInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockInfo not implemented")
+}
+func (*UnimplementedPlcRWServer) UploadBlock(ctx context.Context, req *BlockReq) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadBlock not implemented")
+}
+func (*UnimplementedPlcRWServer) CompareToBaseline(ctx context.Context, req *BaselineReq) (*BaselineDiff, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareToBaseline not implemented")
+}
+func (*UnimplementedPlcRWServer) DownloadBlock(ctx context.Context, req *DownloadBlockReq) (*BlockOperationResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DownloadBlock not implemented")
+}
+func (*UnimplementedPlcRWServer) DeleteBlock(ctx context.Context, req *DeleteBlockReq) (*BlockOperationResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBlock not implemented")
+}
+func (*UnimplementedPlcRWServer) TakeSnapshot(ctx context.Context, req *SnapshotReq) (*Snapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TakeSnapshot not implemented")
+}
+func (*UnimplementedPlcRWServer) RestoreSnapshot(ctx context.Context, req *RestoreReq) (*RestoreResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreSnapshot not implemented")
+}
+func (*UnimplementedPlcRWServer) DiffSnapshot(ctx context.Context, req *DiffSnapshotReq) (*SnapshotDiff, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffSnapshot not implemented")
+}
+func (*UnimplementedPlcRWServer) GetPlcTime(ctx context.Context, req *Plc) (*PlcTime, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPlcTime not implemented")
+}
+func (*UnimplementedPlcRWServer) SetPlcTime(ctx context.Context, req *SetPlcTimeReq) (*PlcTime, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPlcTime not implemented")
+}
+func (*UnimplementedPlcRWServer) SetSessionPassword(ctx context.Context, req *SetSessionPasswordReq) (*SetSessionPasswordResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSessionPassword not implemented")
+}
+func (*UnimplementedPlcRWServer) ClearSessionPassword(ctx context.Context, req *Plc) (*ClearSessionPasswordResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearSessionPassword not implemented")
+}
+
+func RegisterPlcRWServer(s *grpc.Server, srv PlcRWServer) {
+	s.RegisterService(&_PlcRW_serviceDesc, srv)
+}
+
+func _PlcRW_GetCpuInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Plc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).GetCpuInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/GetCpuInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).GetCpuInfo(ctx, req.(*Plc))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_ReadTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RWReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).ReadTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/ReadTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).ReadTags(ctx, req.(*RWReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_WriteTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RWReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).WriteTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/WriteTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).WriteTags(ctx, req.(*RWReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_GetDeviceInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Plc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).GetDeviceInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/GetDeviceInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).GetDeviceInfo(ctx, req.(*Plc))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_GetCpuState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Plc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).GetCpuState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/GetCpuState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).GetCpuState(ctx, req.(*Plc))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_StartCpu_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CpuControlReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).StartCpu(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/StartCpu",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).StartCpu(ctx, req.(*CpuControlReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_StopCpu_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CpuControlReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).StopCpu(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/plc_api.PlcRW/WriteTags",
+		FullMethod: "/plc_api.PlcRW/StopCpu",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PlcRWServer).WriteTags(ctx, req.(*RWReq))
+		return srv.(PlcRWServer).StopCpu(ctx, req.(*CpuControlReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_ReadSzl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SzlReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).ReadSzl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/ReadSzl",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).ReadSzl(ctx, req.(*SzlReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_GetDiagnosticBuffer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiagnosticReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).GetDiagnosticBuffer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/GetDiagnosticBuffer",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).GetDiagnosticBuffer(ctx, req.(*DiagnosticReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_WatchDiagnosticBuffer_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DiagnosticReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PlcRWServer).WatchDiagnosticBuffer(m, &plcRWWatchDiagnosticBufferServer{stream})
+}
+
+type PlcRW_WatchDiagnosticBufferServer interface {
+	Send(*DiagnosticEntry) error
+	grpc.ServerStream
+}
+
+type plcRWWatchDiagnosticBufferServer struct {
+	grpc.ServerStream
+}
+
+func (x *plcRWWatchDiagnosticBufferServer) Send(m *DiagnosticEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PlcRW_ListBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Plc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).ListBlocks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/ListBlocks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).ListBlocks(ctx, req.(*Plc))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_GetBlockInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).GetBlockInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/GetBlockInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).GetBlockInfo(ctx, req.(*BlockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_UploadBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).UploadBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/UploadBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).UploadBlock(ctx, req.(*BlockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_CompareToBaseline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BaselineReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).CompareToBaseline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/CompareToBaseline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).CompareToBaseline(ctx, req.(*BaselineReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_DownloadBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DownloadBlockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).DownloadBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/DownloadBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).DownloadBlock(ctx, req.(*DownloadBlockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_DeleteBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteBlockReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).DeleteBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/DeleteBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).DeleteBlock(ctx, req.(*DeleteBlockReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_TakeSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).TakeSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/TakeSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).TakeSnapshot(ctx, req.(*SnapshotReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_RestoreSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).RestoreSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/RestoreSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).RestoreSnapshot(ctx, req.(*RestoreReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_DiffSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffSnapshotReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).DiffSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/DiffSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).DiffSnapshot(ctx, req.(*DiffSnapshotReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_GetPlcTime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Plc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).GetPlcTime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/GetPlcTime",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).GetPlcTime(ctx, req.(*Plc))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_SetPlcTime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPlcTimeReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).SetPlcTime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/SetPlcTime",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).SetPlcTime(ctx, req.(*SetPlcTimeReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_SetSessionPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSessionPasswordReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).SetSessionPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/SetSessionPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).SetSessionPassword(ctx, req.(*SetSessionPasswordReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlcRW_ClearSessionPassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Plc)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlcRWServer).ClearSessionPassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plc_api.PlcRW/ClearSessionPassword",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlcRWServer).ClearSessionPassword(ctx, req.(*Plc))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -637,7 +4090,89 @@ var _PlcRW_serviceDesc = grpc.ServiceDesc{
 			MethodName: "WriteTags",
 			Handler:    _PlcRW_WriteTags_Handler,
 		},
+		{
+			MethodName: "GetDeviceInfo",
+			Handler:    _PlcRW_GetDeviceInfo_Handler,
+		},
+		{
+			MethodName: "GetCpuState",
+			Handler:    _PlcRW_GetCpuState_Handler,
+		},
+		{
+			MethodName: "StartCpu",
+			Handler:    _PlcRW_StartCpu_Handler,
+		},
+		{
+			MethodName: "StopCpu",
+			Handler:    _PlcRW_StopCpu_Handler,
+		},
+		{
+			MethodName: "ReadSzl",
+			Handler:    _PlcRW_ReadSzl_Handler,
+		},
+		{
+			MethodName: "GetDiagnosticBuffer",
+			Handler:    _PlcRW_GetDiagnosticBuffer_Handler,
+		},
+		{
+			MethodName: "ListBlocks",
+			Handler:    _PlcRW_ListBlocks_Handler,
+		},
+		{
+			MethodName: "GetBlockInfo",
+			Handler:    _PlcRW_GetBlockInfo_Handler,
+		},
+		{
+			MethodName: "UploadBlock",
+			Handler:    _PlcRW_UploadBlock_Handler,
+		},
+		{
+			MethodName: "CompareToBaseline",
+			Handler:    _PlcRW_CompareToBaseline_Handler,
+		},
+		{
+			MethodName: "DownloadBlock",
+			Handler:    _PlcRW_DownloadBlock_Handler,
+		},
+		{
+			MethodName: "DeleteBlock",
+			Handler:    _PlcRW_DeleteBlock_Handler,
+		},
+		{
+			MethodName: "TakeSnapshot",
+			Handler:    _PlcRW_TakeSnapshot_Handler,
+		},
+		{
+			MethodName: "RestoreSnapshot",
+			Handler:    _PlcRW_RestoreSnapshot_Handler,
+		},
+		{
+			MethodName: "DiffSnapshot",
+			Handler:    _PlcRW_DiffSnapshot_Handler,
+		},
+		{
+			MethodName: "GetPlcTime",
+			Handler:    _PlcRW_GetPlcTime_Handler,
+		},
+		{
+			MethodName: "SetPlcTime",
+			Handler:    _PlcRW_SetPlcTime_Handler,
+		},
+		{
+			MethodName: "SetSessionPassword",
+			Handler:    _PlcRW_SetSessionPassword_Handler,
+		},
+		{
+			MethodName: "ClearSessionPassword",
+			Handler:    _PlcRW_ClearSessionPassword_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchDiagnosticBuffer",
+			Handler:       _PlcRW_WatchDiagnosticBuffer_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "plc.proto",
 }
@@ -16,7 +16,7 @@ import (
 
 const (
 	DT_REG  = `^String\[(\d+)\]$`
-	ADD_REG = `^(M|I|Q|(?:DB(\d+)))P(\d+)(?:\.([0-7]))?$`
+	ADD_REG = `^(?:(M|I|Q|(?:DB(\d+)))P(\d+)(?:\.([0-7]))?|([TC])(\d+))$`
 )
 
 var DT = map[string]int{
@@ -56,6 +56,9 @@ var DT = map[string]int{
 	"Time":        4,
 	"Time_Of_Day": 4,
 
+	// a counter value, 0 to 999 in BCD
+	"Counter": 2,
+
 	"String": 256,
 }
 
@@ -112,6 +115,19 @@ func (tag *Tag) GetArea() (*TagAddress, error) {
 
 	if match == nil {
 		return nil, fmt.Errorf("Address illegal")
+	} else if match[5] != "" {
+		// timers and counters are words numbered from 0, Start is the
+		// offset of the word in bytes
+		dt := map[string]string{"T": "S5Time", "C": "Counter"}[match[5]]
+		if tag.GetDt() != dt {
+			return nil, fmt.Errorf("Datatype illegal, %s is %s", tag.GetAddress(), dt)
+		}
+		number, _ := strconv.Atoi(match[6])
+		return &TagAddress{
+			Area:   match[5],
+			Amount: amount,
+			Start:  number * 2,
+		}, nil
 	} else {
 		dbNum, _ := strconv.Atoi(match[2])
 		start, _ := strconv.Atoi(match[3])
@@ -287,6 +303,13 @@ func (tag *Tag) FillBuffer(b byte) []byte {
 			// 	buffer[0] = encodeBcd(int(ms)/10000/100)&^0b11000000 | 0b00110000
 			// }
 		}
+	case "Counter":
+		{
+			// WriteTags refuses values beyond 0..999 with CheckRange
+			v := int(tag.GetValueInteger())
+			buffer[0] = encodeBcd(v / 100)
+			buffer[1] = encodeBcd(v % 100)
+		}
 	case "Time":
 		{
 			v, _ := ptypes.Duration(tag.GetValueDuration())
@@ -470,6 +493,11 @@ func (tag *Tag) SetTagValue(buffer []byte) {
 			d := helper.GetS5TimeAt(buffer, 0)
 			tag.Value = &Tag_ValueDuration{ValueDuration: ptypes.DurationProto(d)}
 		}
+	case "Counter":
+		{
+			v := decodeBcd(buffer[0]&0x0F)*100 + decodeBcd(buffer[1])
+			tag.Value = &Tag_ValueInteger{ValueInteger: int64(v)}
+		}
 	case "Time":
 		{
 			var ms int32
@@ -515,7 +543,7 @@ func (tag *Tag) GetTagValue() interface{} {
 		{
 			return tag.GetValueString()
 		}
-	case "SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "ULInt":
+	case "SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "ULInt", "Counter":
 		{
 			return tag.GetValueInteger()
 		}
@@ -0,0 +1,288 @@
+package plc_api
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	ptypes "github.com/golang/protobuf/ptypes"
+)
+
+// maxSafeInteger is the largest integer JSON clients, which read numbers
+// as float64, get back exactly, 2^53.
+const maxSafeInteger = 1 << 53
+
+// GetBitString returns the value of a Byte, Word, DWord or LWord tag as an
+// unsigned integer.
+func (tag *Tag) GetBitString() uint64 {
+	b := make([]byte, 8)
+	v := tag.GetValueBytes()
+	l := Min(len(v), tag.GetLength())
+	copy(b[8-tag.GetLength():], v[:l])
+	return binary.BigEndian.Uint64(b)
+}
+
+// GetJSONValue returns the tag value as a plain value that encodes naturally
+// to JSON: numbers for integer, real and bit string types, RFC 3339 strings
+// for dates and times, and Go duration strings for durations. LWord, LInt
+// and ULInt values beyond 2^53 either way, which a float64 cannot hold,
+// are decimal strings.
+func (tag *Tag) GetJSONValue() interface{} {
+	switch tag.GetDt() {
+	case "Bool":
+		return tag.GetValueBool()
+	case "Byte", "Word", "DWord", "LWord":
+		n := tag.GetBitString()
+		if n > maxSafeInteger {
+			return strconv.FormatUint(n, 10)
+		}
+		return n
+	case "SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "Counter":
+		n := tag.GetValueInteger()
+		if n > maxSafeInteger || n < -maxSafeInteger {
+			return strconv.FormatInt(n, 10)
+		}
+		return n
+	case "ULInt":
+		n := tag.GetValueUinteger()
+		if n > maxSafeInteger {
+			return strconv.FormatUint(n, 10)
+		}
+		return n
+	case "Real", "LReal":
+		return tag.GetValueDouble()
+	case "DTL", "Date", "Date_And_Time", "LDT", "LTime_Of_Day", "Time_Of_Day":
+		t, _ := ptypes.Timestamp(tag.GetValueTimestamp())
+		return t.UTC().Format(time.RFC3339Nano)
+	case "LTime", "S5Time", "Time":
+		d, _ := ptypes.Duration(tag.GetValueDuration())
+		return d.String()
+	// Char, String or String[n]
+	default:
+		return tag.GetValueString()
+	}
+}
+
+// SetJSONValue coerces a value decoded from JSON into the oneof field that
+// matches the tag datatype. Numbers may be given as float64, json.Number or
+// numeric strings; a float64 integer beyond 2^53 either way is refused, as
+// it may have lost digits, and has to be a string or json.Number.
+func (tag *Tag) SetJSONValue(v interface{}) error {
+	if tag.GetLength() == 0 {
+		return fmt.Errorf("Datatype illegal")
+	}
+	switch tag.GetDt() {
+	case "Bool":
+		{
+			switch b := v.(type) {
+			case bool:
+				tag.Value = &Tag_ValueBool{ValueBool: b}
+				return nil
+			case string:
+				p, err := strconv.ParseBool(b)
+				if err != nil {
+					return fmt.Errorf("%s: %v is not a Bool", tag.GetAddress(), v)
+				}
+				tag.Value = &Tag_ValueBool{ValueBool: p}
+				return nil
+			}
+			n, err := jsonInteger(v)
+			if err != nil || (n != 0 && n != 1) {
+				return fmt.Errorf("%s: %v is not a Bool", tag.GetAddress(), v)
+			}
+			tag.Value = &Tag_ValueBool{ValueBool: n == 1}
+		}
+	case "Byte", "Word", "DWord", "LWord":
+		{
+			n, err := jsonUnsigned(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+			}
+			length := tag.GetLength()
+			if length < 8 && n>>(uint(length)*8) != 0 {
+				return fmt.Errorf("%s: %d overflows %s", tag.GetAddress(), n, tag.GetDt())
+			}
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint64(b, n)
+			tag.Value = &Tag_ValueBytes{ValueBytes: b[8-length:]}
+		}
+	case "SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "Counter":
+		{
+			n, err := jsonInteger(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+			}
+			if min, max := integerRange(tag.GetDt()); n < min || n > max {
+				return fmt.Errorf("%s: %d out of range for %s", tag.GetAddress(), n, tag.GetDt())
+			}
+			tag.Value = &Tag_ValueInteger{ValueInteger: n}
+		}
+	case "ULInt":
+		{
+			n, err := jsonUnsigned(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+			}
+			tag.Value = &Tag_ValueUinteger{ValueUinteger: n}
+		}
+	case "Real", "LReal":
+		{
+			f, err := jsonFloat(v)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+			}
+			tag.Value = &Tag_ValueDouble{ValueDouble: f}
+		}
+	case "DTL", "Date", "Date_And_Time", "LDT", "LTime_Of_Day", "Time_Of_Day":
+		{
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s: %v is not an RFC 3339 time", tag.GetAddress(), v)
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+			}
+			ts, err := ptypes.TimestampProto(t)
+			if err != nil {
+				return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+			}
+			tag.Value = &Tag_ValueTimestamp{ValueTimestamp: ts}
+		}
+	case "LTime", "S5Time", "Time":
+		{
+			var d time.Duration
+			if s, ok := v.(string); ok {
+				p, err := time.ParseDuration(s)
+				if err != nil {
+					return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+				}
+				d = p
+			} else {
+				// bare numbers are milliseconds, as in TIA Portal
+				ms, err := jsonInteger(v)
+				if err != nil {
+					return fmt.Errorf("%s: %v", tag.GetAddress(), err)
+				}
+				d = time.Duration(ms) * time.Millisecond
+			}
+			tag.Value = &Tag_ValueDuration{ValueDuration: ptypes.DurationProto(d)}
+		}
+	// Char, String or String[n]
+	default:
+		{
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s: %v is not a string", tag.GetAddress(), v)
+			}
+			tag.Value = &Tag_ValueString{ValueString: s}
+		}
+	}
+	return nil
+}
+
+// CheckRange reports an integer value beyond the range of the datatype of
+// the tag, which SetJSONValue refuses and no driver can encode; WriteTags
+// checks every tag written.
+func (tag *Tag) CheckRange() error {
+	switch tag.GetDt() {
+	case "SInt", "USInt", "Int", "UInt", "DInt", "UDInt", "LInt", "Counter":
+		n := tag.GetValueInteger()
+		if min, max := integerRange(tag.GetDt()); n < min || n > max {
+			return fmt.Errorf("%d out of range for %s", n, tag.GetDt())
+		}
+	}
+	return nil
+}
+
+func integerRange(dt string) (int64, int64) {
+	switch dt {
+	case "SInt":
+		return math.MinInt8, math.MaxInt8
+	case "USInt":
+		return 0, math.MaxUint8
+	case "Int":
+		return math.MinInt16, math.MaxInt16
+	case "UInt":
+		return 0, math.MaxUint16
+	case "DInt":
+		return math.MinInt32, math.MaxInt32
+	case "UDInt":
+		return 0, math.MaxUint32
+	case "Counter":
+		return 0, 999
+	}
+	return math.MinInt64, math.MaxInt64
+}
+
+func jsonFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(n), 64)
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func jsonInteger(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("%d out of range", n)
+		}
+		return int64(n), nil
+	case json.Number:
+		return strconv.ParseInt(n.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(n), 0, 64)
+	}
+	f, err := jsonFloat(v)
+	if err != nil {
+		return 0, err
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+	if f > maxSafeInteger || f < -maxSafeInteger {
+		return 0, fmt.Errorf("%v is beyond 2^53 and may have lost digits, give it as a string", v)
+	}
+	return int64(f), nil
+}
+
+func jsonUnsigned(v interface{}) (uint64, error) {
+	switch n := v.(type) {
+	case uint64:
+		return n, nil
+	case json.Number:
+		return strconv.ParseUint(n.String(), 10, 64)
+	case string:
+		return strconv.ParseUint(strings.TrimSpace(n), 0, 64)
+	}
+	i, err := jsonInteger(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("%d is negative", i)
+	}
+	return uint64(i), nil
+}
@@ -0,0 +1,22 @@
+package s7
+
+import (
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// WritePolicy decides whether a tag may be written to a PLC. PlcServer
+// rejects the whole request when any tag is refused.
+type WritePolicy interface {
+	AllowWrite(plc *pb.Plc, tag *pb.Tag) error
+}
+
+type WritePolicyFunc func(plc *pb.Plc, tag *pb.Tag) error
+
+func (f WritePolicyFunc) AllowWrite(plc *pb.Plc, tag *pb.Tag) error {
+	return f(plc, tag)
+}
+
+// ReadOnly refuses every write.
+var ReadOnly WritePolicy = WritePolicyFunc(func(plc *pb.Plc, tag *pb.Tag) error {
+	return errReadOnly
+})
@@ -0,0 +1,195 @@
+package s7
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// A protected CPU refuses the functions above its protection level until
+// a connection sends the password, the legitimation, which lasts as long
+// as the connection.
+
+const (
+	groupSecurity = 0x45
+
+	securityPassword = 0x01
+)
+
+// errAccessDenied is the return code 0x03 of a read or write item, access
+// to the object not allowed.
+var errAccessDenied = errors.New("s7: access denied by the protection level, a password is required")
+
+// passwords are the passwords of protected CPUs by the name
+// Plc.password_ref gives them. Only the configuration and engineers set
+// them; calls merely name one.
+var passwords = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// sessionPassword returns the password plc names, empty if there is none.
+func sessionPassword(plc *pb.Plc) string {
+	passwords.Lock()
+	defer passwords.Unlock()
+	return passwords.m[plc.GetPasswordRef()]
+}
+
+// keepPassword keeps password under ref and reports whether it replaced
+// another.
+func keepPassword(ref, password string) bool {
+	passwords.Lock()
+	defer passwords.Unlock()
+	_, ok := passwords.m[ref]
+	passwords.m[ref] = password
+	return ok
+}
+
+// forgetPassword forgets the password kept under ref and reports whether
+// there was one.
+func forgetPassword(ref string) bool {
+	passwords.Lock()
+	defer passwords.Unlock()
+	_, ok := passwords.m[ref]
+	delete(passwords.m, ref)
+	return ok
+}
+
+// encodePassword pads the password to 8 characters and scrambles it the
+// way the CPU expects.
+func encodePassword(password string) []byte {
+	b := []byte("        ")
+	copy(b, password)
+	b[0] ^= 0x55
+	b[1] ^= 0x55
+	for i := 2; i < len(b); i++ {
+		b[i] ^= 0x55 ^ b[i-2]
+	}
+	return b
+}
+
+// legitimate sends password on the connection of h.
+func legitimate(h *gos7.TCPClientHandler, password string) error {
+	_, _, err := userData(h, groupSecurity, securityPassword, append([]byte{0xFF, 0x09, 0, 8}, encodePassword(password)...))
+	return err
+}
+
+// protected reports whether err says that the protection of the CPU
+// refused a function.
+func protected(err error) bool {
+	return err == errAccessDenied || err == ErrorCode(0xD241) || err == ErrorCode(0xD602)
+}
+
+// protectionError makes the errors of protected functions
+// FailedPrecondition, with a message starting with "CPU protection", so
+// that callers can tell them from failed logins and the other errors.
+func protectionError(err error) error {
+	if protected(err) {
+		return status.Error(codes.FailedPrecondition, "CPU protection: "+err.Error())
+	}
+	return err
+}
+
+// guard verifies the responses to the reads and writes of gos7 and keeps
+// the first refusal of the CPU, which gos7 reduces to text for writes and
+// loses for reads.
+type guard struct {
+	refused error
+}
+
+func (g *guard) Verify(req, resp []byte) error {
+	if len(resp) <= ackParams+2 || resp[8] != rosctrAckData {
+		return nil
+	}
+	var err error
+	if code := ErrorCode(binary.BigEndian.Uint16(resp[17:])); code != 0 {
+		err = code
+	} else if (resp[19] == 0x04 || resp[19] == 0x05) && resp[21] == 0x03 {
+		err = errAccessDenied
+	}
+	if g.refused == nil {
+		g.refused = err
+	}
+	return err
+}
+
+// refusal returns how the CPU refused a read or write of gos7 since the
+// last call in place of err, if it did.
+func (c *conn) refusal(err error) error {
+	refused := c.guard.refused
+	c.guard.refused = nil
+	if refused != nil {
+		return refused
+	}
+	return err
+}
+
+// KeepPasswords keeps the passwords of the configuration by name, each
+// recorded in the audit log.
+func (s *PlcServer) KeepPasswords(ctx context.Context, m map[string]string) {
+	refs := make([]string, 0, len(m))
+	for ref := range m {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		keepPassword(ref, m[ref])
+		s.Audit.Record(ctx, audit.Entry{Action: "set_session_password", Detail: ref + " from the configuration", Result: "ok"})
+	}
+}
+
+func (s *PlcServer) SetSessionPassword(ctx context.Context, req *pb.SetSessionPasswordReq) (*pb.SetSessionPasswordResult, error) {
+	entry := audit.Entry{Action: "set_session_password", Detail: req.GetRef()}
+	res, err := func() (*pb.SetSessionPasswordResult, error) {
+		if err := engineer(ctx, &entry); err != nil {
+			return nil, err
+		}
+		if req.GetRef() == "" || req.GetPassword() == "" {
+			return nil, status.Error(codes.InvalidArgument, "ref and password required")
+		}
+		return &pb.SetSessionPasswordResult{Replaced: keepPassword(req.GetRef(), req.GetPassword())}, nil
+	}()
+	switch {
+	case entry.Result != "":
+	case err != nil:
+		entry.Result = err.Error()
+	default:
+		entry.Result = "ok"
+	}
+	s.Audit.Record(ctx, entry)
+	return res, err
+}
+
+func (s *PlcServer) ClearSessionPassword(ctx context.Context, plc *pb.Plc) (*pb.ClearSessionPasswordResult, error) {
+	entry := audit.Entry{Action: "clear_session_password", Plc: plcName(plc), Detail: plc.GetPasswordRef()}
+	res, err := func() (*pb.ClearSessionPasswordResult, error) {
+		if err := checkS7(plc); err != nil {
+			return nil, err
+		}
+		if err := engineer(ctx, &entry); err != nil {
+			return nil, err
+		}
+		if plc.GetPasswordRef() == "" {
+			return nil, status.Error(codes.InvalidArgument, "password_ref required")
+		}
+		return &pb.ClearSessionPasswordResult{Cleared: forgetPassword(plc.GetPasswordRef())}, nil
+	}()
+	switch {
+	case entry.Result != "":
+	case err != nil:
+		entry.Result = err.Error()
+	default:
+		entry.Result = "ok"
+	}
+	s.Audit.Record(ctx, entry)
+	return res, err
+}
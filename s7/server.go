@@ -2,140 +2,172 @@ package s7
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
-	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
-	gos7 "github.com/thinkontrolsy/gos7"
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/integrity"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
 )
 
+var errReadOnly = errors.New("Server is read only")
+
+// PlcServer serves the PlcRW API for PLCs of every registered protocol;
+// Plc.Protocol picks the driver. It connects for every call.
 type PlcServer struct {
 	pb.UnimplementedPlcRWServer
+	// WritePolicy is consulted by WriteTags; nil allows every write. Any
+	// policy refuses RestoreSnapshot.
+	WritePolicy WritePolicy
+	// Audit records the calls that change the operating state or the
+	// blocks of CPUs, and snapshot restores.
+	Audit *audit.Log
+	// Baselines keeps the baselines CompareToBaseline compares to; nil
+	// refuses the calls.
+	Baselines *integrity.Store
+	// DiagnosticOutage is how long the reads of WatchDiagnosticBuffer may
+	// keep failing before it ends the stream; zero is
+	// DefaultDiagnosticOutage.
+	DiagnosticOutage time.Duration
+
+	confirmations confirmations
 }
 
-func (s *PlcServer) GetCpuInfo(ctx context.Context, req *pb.Plc) (*pb.S7CpuInfo, error) {
-	handler := gos7.NewTCPClientHandler(req.GetHost(), int(req.GetRack()), int(req.GetSlot()))
-	handler.Timeout = 5 * time.Second
-	handler.IdleTimeout = 5 * time.Second
-	defer handler.Close()
-	if err := handler.Connect(); err == nil {
-		client := gos7.NewClient(handler)
-		info, err := client.GetCPUInfo()
-		if err == nil {
-			return &pb.S7CpuInfo{
-				ModuleTypeName: info.ModuleTypeName,
-				SerialNumber:   info.SerialNumber,
-				AsName:         info.ASName,
-				Copyright:      info.Copyright,
-				ModuleName:     info.ModuleName,
-			}, nil
-		} else {
-			return nil, err
+// checkWrite refuses writes by authenticated callers below operator and
+// those the write policy refuses.
+func (s *PlcServer) checkWrite(ctx context.Context, req *pb.RWReq) error {
+	if c, ok := auth.FromContext(ctx); ok && !c.Role.Allows(auth.Operator) {
+		return status.Error(codes.PermissionDenied, "operator role required")
+	}
+	if s.WritePolicy == nil {
+		return nil
+	}
+	for _, tag := range req.GetTags() {
+		if err := s.WritePolicy.AllowWrite(req.GetPlc(), tag); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
 		}
-	} else {
-		return nil, err
 	}
+	return nil
 }
-func (s *PlcServer) ReadTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
-	handler := gos7.NewTCPClientHandler(req.GetPlc().GetHost(), int(req.GetPlc().GetRack()), int(req.GetPlc().GetSlot()))
-	handler.Timeout = 5 * time.Second
-	handler.IdleTimeout = 5 * time.Second
-	defer handler.Close()
-	if err := handler.Connect(); err == nil {
-		tags := req.GetTags()
-		client := gos7.NewClient(handler)
-		for area, ag := range generateAGMap(tags) {
-			size := ag.End - ag.Start
-			ag.Buffer = make([]byte, size)
-			var err error
-			switch area {
-			case "M":
-				err = client.AGReadMB(ag.Start, size, ag.Buffer)
-			case "I":
-				err = client.AGReadAB(ag.Start, size, ag.Buffer)
-			case "Q":
-				err = client.AGReadEB(ag.Start, size, ag.Buffer)
-			default:
-				err = client.AGReadDB(ag.DBNumber, ag.Start, size, ag.Buffer)
-			}
-			if err != nil {
-				return nil, err
-			}
-			ag.ReadBuffer()
+
+// lookup returns the driver of plc and checks tags with it.
+func lookup(plc *pb.Plc, tags []*pb.Tag) (driver.Driver, error) {
+	d, err := driver.Lookup(plc.GetProtocol())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	caps := d.Capabilities()
+	for _, tag := range tags {
+		if !caps.Supports(tag.GetDt()) {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("%s: datatype %s not supported", tag.GetAddress(), tag.GetDt()))
+		}
+		if err := d.Validate(tag); err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("%s %s: %v", tag.GetAddress(), tag.GetDt(), err))
 		}
-		return &pb.RWResult{Tags: tags}, nil
+	}
+	return d, nil
+}
+
+func unsupported(err error) error {
+	if err == driver.ErrUnsupported {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+	return err
+}
 
-	} else {
+func (s *PlcServer) GetDeviceInfo(ctx context.Context, req *pb.Plc) (*pb.DeviceInfo, error) {
+	d, err := lookup(req, nil)
+	if err != nil {
 		return nil, err
 	}
+	if !d.Capabilities().DeviceInfo {
+		return nil, unsupported(driver.ErrUnsupported)
+	}
+	conn, err := d.Connect(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	info, err := conn.DeviceInfo(ctx)
+	return info, unsupported(err)
 }
+
+// GetCpuInfo is GetDeviceInfo in the shape of the S7 CPU identification.
+func (s *PlcServer) GetCpuInfo(ctx context.Context, req *pb.Plc) (*pb.S7CpuInfo, error) {
+	info, err := s.GetDeviceInfo(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	details := info.GetDetails()
+	return &pb.S7CpuInfo{
+		ModuleTypeName:  info.GetModel(),
+		SerialNumber:    info.GetSerialNumber(),
+		AsName:          details["as_name"],
+		Copyright:       details["copyright"],
+		ModuleName:      info.GetName(),
+		OrderCode:       details["order_code"],
+		FirmwareVersion: info.GetVersion(),
+		ProtectionLevel: detailUint(details, "protection_level"),
+		ModeSelector:    details["mode_selector"],
+		PduSize:         detailUint(details, "pdu_size"),
+		MaxConnections:  detailUint(details, "max_connections"),
+		CpuFamily:       details["cpu_family"],
+	}, nil
+}
+
+// detailUint returns the number in details under key, 0 if there is none.
+func detailUint(details map[string]string, key string) uint32 {
+	n, _ := strconv.ParseUint(details[key], 10, 32)
+	return uint32(n)
+}
+
+func (s *PlcServer) ReadTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
+	d, err := lookup(req.GetPlc(), req.GetTags())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.Connect(ctx, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.ReadTags(ctx, req.GetTags()); err != nil {
+		return nil, err
+	}
+	return &pb.RWResult{Tags: req.GetTags()}, nil
+}
+
 func (s *PlcServer) WriteTags(ctx context.Context, req *pb.RWReq) (*pb.RWResult, error) {
-	handler := gos7.NewTCPClientHandler(req.GetPlc().GetHost(), int(req.GetPlc().GetRack()), int(req.GetPlc().GetSlot()))
-	handler.Timeout = 5 * time.Second
-	handler.IdleTimeout = 5 * time.Second
-	defer handler.Close()
-	if err := handler.Connect(); err == nil {
-		tags := req.GetTags()
-		client := gos7.NewClient(handler)
-		for area, ags := range generateAGGroupMap(tags) {
-			for _, ag := range ags {
-				size := ag.End - ag.Start
-				ag.Buffer = make([]byte, size)
-				switch area {
-				case "M":
-					{
-						if ag.HasBoolTag() {
-							if err := client.AGReadMB(ag.Start, size, ag.Buffer); err != nil {
-								return nil, err
-							}
-						}
-						ag.FillBuffer()
-						if err := client.AGWriteMB(ag.Start, size, ag.Buffer); err != nil {
-							return nil, err
-						}
-					}
-				case "I":
-					{
-						if ag.HasBoolTag() {
-							if err := client.AGReadAB(ag.Start, size, ag.Buffer); err != nil {
-								return nil, err
-							}
-						}
-						ag.FillBuffer()
-						if err := client.AGWriteAB(ag.Start, size, ag.Buffer); err != nil {
-							return nil, err
-						}
-					}
-				case "Q":
-					{
-						if ag.HasBoolTag() {
-							if err := client.AGReadEB(ag.Start, size, ag.Buffer); err != nil {
-								return nil, err
-							}
-						}
-						ag.FillBuffer()
-						if err := client.AGWriteEB(ag.Start, size, ag.Buffer); err != nil {
-							return nil, err
-						}
-					}
-				default:
-					{
-						if ag.HasBoolTag() {
-							if err := client.AGReadDB(ag.DBNumber, ag.Start, size, ag.Buffer); err != nil {
-								return nil, err
-							}
-						}
-						ag.FillBuffer()
-						if err := client.AGWriteDB(ag.DBNumber, ag.Start, size, ag.Buffer); err != nil {
-							return nil, err
-						}
-					}
-				}
-			}
+	if err := s.checkWrite(ctx, req); err != nil {
+		return nil, err
+	}
+	d, err := lookup(req.GetPlc(), req.GetTags())
+	if err != nil {
+		return nil, err
+	}
+	// drivers encode integers without looking, so wrap what they can't hold
+	for _, tag := range req.GetTags() {
+		if err := tag.CheckRange(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("%s %s: %v", tag.GetAddress(), tag.GetDt(), err))
 		}
-		return &pb.RWResult{Tags: tags}, nil
-
-	} else {
+	}
+	if !d.Capabilities().Write {
+		return nil, unsupported(driver.ErrUnsupported)
+	}
+	conn, err := d.Connect(ctx, req.GetPlc())
+	if err != nil {
 		return nil, err
 	}
+	defer conn.Close()
+	if err := conn.WriteTags(ctx, req.GetTags()); err != nil {
+		return nil, unsupported(err)
+	}
+	return &pb.RWResult{Tags: req.GetTags()}, nil
 }
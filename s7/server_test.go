@@ -1,12 +1,32 @@
 package s7
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes"
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/thinkontrolsy/goplc/audit"
+	"github.com/thinkontrolsy/goplc/auth"
+	"github.com/thinkontrolsy/goplc/driver"
+	"github.com/thinkontrolsy/goplc/integrity"
+	"github.com/thinkontrolsy/goplc/internal/s7sim"
 	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
 )
 
@@ -351,3 +371,1163 @@ func TestReadTags(t *testing.T) {
 		t.Log(tag.GetTagValueString())
 	}
 }
+
+// echoDriver reads the address of every tag as its value and refuses
+// writes.
+type echoDriver struct{}
+
+func (echoDriver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	if plc.GetHost() == "" {
+		return nil, errors.New("no host")
+	}
+	return echoConn{}, nil
+}
+
+func (echoDriver) Validate(tag *pb.Tag) error {
+	if tag.GetDt() != "String" {
+		return errors.New("only String")
+	}
+	return nil
+}
+
+func (echoDriver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{DeviceInfo: true, Datatypes: []string{"String"}}
+}
+
+type echoConn struct{}
+
+func (echoConn) ReadTags(ctx context.Context, tags []*pb.Tag) error {
+	for _, tag := range tags {
+		tag.Value = &pb.Tag_ValueString{ValueString: tag.GetAddress()}
+	}
+	return nil
+}
+
+func (echoConn) WriteTags(ctx context.Context, tags []*pb.Tag) error {
+	return driver.ErrUnsupported
+}
+
+func (echoConn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	return &pb.DeviceInfo{Protocol: "echo", Model: "Echo 1", Name: "echo", Details: map[string]string{"as_name": "station"}}, nil
+}
+
+func (echoConn) Close() error { return nil }
+
+// sinkDriver takes writes of Int tags and forgets them.
+type sinkDriver struct{}
+
+func (sinkDriver) Connect(ctx context.Context, plc *pb.Plc) (driver.Conn, error) {
+	return sinkConn{}, nil
+}
+
+func (sinkDriver) Validate(tag *pb.Tag) error { return nil }
+
+func (sinkDriver) Capabilities() driver.Capabilities {
+	return driver.Capabilities{Write: true, Datatypes: []string{"Int"}}
+}
+
+type sinkConn struct{}
+
+func (sinkConn) ReadTags(ctx context.Context, tags []*pb.Tag) error { return nil }
+
+func (sinkConn) WriteTags(ctx context.Context, tags []*pb.Tag) error { return nil }
+
+func (sinkConn) DeviceInfo(ctx context.Context) (*pb.DeviceInfo, error) {
+	return nil, driver.ErrUnsupported
+}
+
+func (sinkConn) Close() error { return nil }
+
+func TestPlcServerDrivers(t *testing.T) {
+	driver.Register("echo", echoDriver{})
+	driver.Register("sink", sinkDriver{})
+	server := PlcServer{}
+	ctx := context.Background()
+	plc := &pb.Plc{Host: "10.0.0.1", Protocol: "echo"}
+
+	r, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: []*pb.Tag{{Address: "x", Dt: "String"}}})
+	if err != nil || r.GetTags()[0].GetValueString() != "x" {
+		t.Fatalf("read: %v %v", r, err)
+	}
+	sink := &pb.Plc{Host: "10.0.0.2", Protocol: "sink"}
+	if _, err := server.WriteTags(ctx, &pb.RWReq{Plc: sink, Tags: []*pb.Tag{{Address: "x", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: -32768}}}}); err != nil {
+		t.Fatalf("write to another protocol: %v", err)
+	}
+	info, err := server.GetCpuInfo(ctx, plc)
+	if err != nil || info.GetModuleTypeName() != "Echo 1" || info.GetAsName() != "station" {
+		t.Fatalf("cpu info: %v %v", info, err)
+	}
+	for _, c := range []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"bad tag", func() error {
+			_, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: []*pb.Tag{{Address: "x", Dt: "Int"}}})
+			return err
+		}(), codes.InvalidArgument},
+		{"unknown protocol", func() error {
+			_, err := server.GetDeviceInfo(ctx, &pb.Plc{Host: "10.0.0.1", Protocol: "carrier pigeon"})
+			return err
+		}(), codes.InvalidArgument},
+		{"read only driver", func() error {
+			_, err := server.WriteTags(ctx, &pb.RWReq{Plc: plc, Tags: []*pb.Tag{{Address: "x", Dt: "String"}}})
+			return err
+		}(), codes.Unimplemented},
+		{"cpu control", func() error {
+			_, err := server.GetCpuState(ctx, plc)
+			return err
+		}(), codes.Unimplemented},
+		{"system status list", func() error {
+			_, err := server.ReadSzl(ctx, &pb.SzlReq{Plc: plc, Id: 0x0011})
+			return err
+		}(), codes.Unimplemented},
+		{"blocks", func() error {
+			_, err := server.ListBlocks(ctx, plc)
+			return err
+		}(), codes.Unimplemented},
+		{"session password", func() error {
+			_, err := server.ClearSessionPassword(ctx, plc)
+			return err
+		}(), codes.Unimplemented},
+		{"block type", func() error {
+			_, err := server.GetBlockInfo(ctx, &pb.BlockReq{Plc: &pb.Plc{Host: "10.0.0.1"}, Type: "UDT", Number: 1})
+			return err
+		}(), codes.InvalidArgument},
+		{"block download", func() error {
+			ctx := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+			_, err := server.DeleteBlock(ctx, &pb.DeleteBlockReq{Plc: plc, Type: "FC", Number: 1, Reason: "test"})
+			return err
+		}(), codes.Unimplemented},
+		{"write out of range to another protocol", func() error {
+			_, err := server.WriteTags(ctx, &pb.RWReq{Plc: sink, Tags: []*pb.Tag{{Address: "x", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 70000}}}})
+			return err
+		}(), codes.InvalidArgument},
+		{"viewer write", func() error {
+			ctx := auth.NewContext(ctx, auth.Caller{Name: "tech", Role: auth.Viewer})
+			_, err := server.WriteTags(ctx, &pb.RWReq{Plc: plc, Tags: []*pb.Tag{{Address: "x", Dt: "String"}}})
+			return err
+		}(), codes.PermissionDenied},
+		{"bad S7 address", func() error {
+			_, err := server.ReadTags(ctx, &pb.RWReq{Plc: &pb.Plc{Host: "10.0.0.1"}, Tags: []*pb.Tag{{Address: "X1", Dt: "Int"}}})
+			return err
+		}(), codes.InvalidArgument},
+	} {
+		if status.Code(c.err) != c.code {
+			t.Errorf("%s: %v, want %v", c.name, c.err, c.code)
+		}
+	}
+}
+
+func TestCpuControl(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	var log bytes.Buffer
+	server := PlcServer{Audit: audit.New(&log)}
+	ctx := context.Background()
+	engineer := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+	plc := cpu.Plc()
+
+	if s, err := server.GetCpuState(ctx, plc); err != nil || s.GetState() != pb.CpuState_RUN {
+		t.Fatalf("state %v %v", s, err)
+	}
+
+	stop := &pb.CpuControlReq{Plc: plc}
+	if _, err := server.StopCpu(ctx, stop); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("anonymous stop: %v", err)
+	}
+	operator := auth.NewContext(ctx, auth.Caller{Name: "op", Role: auth.Operator})
+	if _, err := server.StopCpu(operator, stop); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("operator stop: %v", err)
+	}
+	r, err := server.StopCpu(engineer, stop)
+	if err != nil || r.GetConfirm() == "" || r.GetConfirmExpires() == nil {
+		t.Fatalf("stop without confirmation: %v %v", r, err)
+	}
+	if cpu.State() != s7sim.StateRun {
+		t.Fatal("stopped without confirmation")
+	}
+	// the token confirms this operation, for this caller only
+	other := auth.NewContext(ctx, auth.Caller{Name: "eng2", Role: auth.Engineer})
+	token := r.GetConfirm()
+	if _, err := server.StopCpu(other, &pb.CpuControlReq{Plc: plc, Confirm: token}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("stop confirmed by someone else: %v", err)
+	}
+	r, _ = server.StopCpu(engineer, stop)
+	token = r.GetConfirm()
+	if _, err := server.StartCpu(engineer, &pb.CpuControlReq{Plc: plc, Confirm: token}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("start confirmed with a stop token: %v", err)
+	}
+	// nor of the CPU in another slot of the rack
+	r, _ = server.StopCpu(engineer, stop)
+	slot := proto.Clone(plc).(*pb.Plc)
+	slot.Slot++
+	if _, err := server.StopCpu(engineer, &pb.CpuControlReq{Plc: slot, Confirm: r.GetConfirm()}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("stop of another slot confirmed: %v", err)
+	}
+	r, _ = server.StopCpu(engineer, stop)
+	token = r.GetConfirm()
+	r, err = server.StopCpu(engineer, &pb.CpuControlReq{Plc: plc, Confirm: token})
+	if err != nil || r.GetState().GetState() != pb.CpuState_STOP || cpu.State() != s7sim.StateStop {
+		t.Fatalf("confirmed stop: %v %v", r, err)
+	}
+	if _, err := server.StopCpu(engineer, &pb.CpuControlReq{Plc: plc, Confirm: token}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("token used twice: %v", err)
+	}
+
+	start := &pb.CpuControlReq{Plc: plc, Mode: pb.CpuControlReq_COLD}
+	r, _ = server.StartCpu(engineer, start)
+	start.Confirm = r.GetConfirm()
+	r, err = server.StartCpu(engineer, start)
+	if err != nil || r.GetState().GetState() != pb.CpuState_RUN {
+		t.Fatalf("confirmed start: %v %v", r, err)
+	}
+	if warm, cold := cpu.Starts(); warm != 0 || cold != 1 {
+		t.Fatalf("%d warm and %d cold restarts", warm, cold)
+	}
+	// starting a running CPU is no error
+	r, _ = server.StartCpu(engineer, &pb.CpuControlReq{Plc: plc})
+	if _, err := server.StartCpu(engineer, &pb.CpuControlReq{Plc: plc, Confirm: r.GetConfirm()}); err != nil {
+		t.Fatalf("start running CPU: %v", err)
+	}
+
+	var results []string
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, e.Action+" "+e.User+" "+e.Result)
+		// the stop of the other slot is told apart
+		plcWant := integrity.Key(plc)
+		if len(results) == 8 {
+			plcWant = integrity.Key(slot)
+		}
+		if e.Plc != plcWant {
+			t.Fatalf("audit entry %d of %s, want %s", len(results)-1, e.Plc, plcWant)
+		}
+	}
+	want := []string{
+		"stop_cpu  denied", "stop_cpu op denied", "stop_cpu eng confirm", "stop_cpu eng2 denied",
+		"stop_cpu eng confirm", "start_cpu eng denied", "stop_cpu eng confirm", "stop_cpu eng denied",
+		"stop_cpu eng confirm", "stop_cpu eng ok", "stop_cpu eng denied",
+		"start_cpu eng confirm", "start_cpu eng ok", "start_cpu eng confirm", "start_cpu eng ok",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("audit log %q", results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Fatalf("audit entry %d %q, want %q", i, results[i], want[i])
+		}
+	}
+}
+
+func TestCpuIdentity(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	server := PlcServer{}
+	info, err := server.GetCpuInfo(context.Background(), cpu.Plc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.S7CpuInfo{
+		ModuleTypeName:  s7sim.ModuleTypeName,
+		SerialNumber:    s7sim.SerialNumber,
+		AsName:          "goplc sim",
+		Copyright:       "Original Siemens Equipment",
+		ModuleName:      s7sim.ModuleTypeName,
+		OrderCode:       s7sim.OrderCode,
+		FirmwareVersion: s7sim.FirmwareVersion,
+		ProtectionLevel: 2,
+		ModeSelector:    "RUN-P",
+		PduSize:         s7sim.PduSize,
+		MaxConnections:  s7sim.MaxConnections,
+		CpuFamily:       "S7-300",
+	}
+	if !proto.Equal(info, want) {
+		t.Fatalf("cpu info %v, want %v", info, want)
+	}
+
+	// CPUs without protection or communication lists still identify
+	cpu.RemoveSZL(0x0232, 4)
+	cpu.RemoveSZL(0x0131, 1)
+	info, err = server.GetCpuInfo(context.Background(), cpu.Plc())
+	if err != nil || info.GetOrderCode() != s7sim.OrderCode || info.GetProtectionLevel() != 0 || info.GetModeSelector() != "" || info.GetMaxConnections() != 0 {
+		t.Fatalf("cpu info %v %v", info, err)
+	}
+}
+
+func TestReadSzl(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	// RUN on, STOP off, SF flashing slowly
+	cpu.SetSZL(0x0019, 0, s7sim.SZL{Size: 4, Records: []byte{0, 4, 1, 0, 0, 5, 0, 0, 0, 1, 1, 2}})
+	server := PlcServer{}
+	ctx := context.Background()
+	read := func(id, index uint32) *pb.Szl {
+		t.Helper()
+		s, err := server.ReadSzl(ctx, &pb.SzlReq{Plc: cpu.Plc(), Id: id, Index: index})
+		if err != nil {
+			t.Fatalf("SZL %#04x: %v", id, err)
+		}
+		return s
+	}
+
+	s := read(0x0011, 0)
+	if len(s.GetRecords()) != 3 || s.GetRecordSize() != 28 {
+		t.Fatalf("module identification %v", s)
+	}
+	if f := s.GetRecords()[0].GetFields(); f["order_code"] != s7sim.OrderCode || f["index"] != "0x0001" {
+		t.Fatalf("module %v", f)
+	}
+	if f := s.GetRecords()[2].GetFields(); f["version"] != s7sim.FirmwareVersion {
+		t.Fatalf("firmware %v", f)
+	}
+
+	// longer than one response
+	s = read(0x001C, 0)
+	if len(s.GetRecords()) != 6 || len(s.GetRecords()[5].GetRaw()) != 34 {
+		t.Fatalf("component identification %v", s)
+	}
+	if f := s.GetRecords()[4].GetFields(); f["component"] != "serial_number" || f["value"] != s7sim.SerialNumber {
+		t.Fatalf("serial number %v", f)
+	}
+
+	s = read(0x0019, 0)
+	var got []string
+	for _, r := range s.GetRecords() {
+		f := r.GetFields()
+		got = append(got, f["led"]+" "+f["on"]+" "+f["flashing"])
+	}
+	if want := []string{"RUN true no", "STOP false no", "SF true slow"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("LEDs %q, want %q", got, want)
+	}
+
+	if f := read(0x0232, 4).GetRecords()[0].GetFields(); f["protection_level"] != "2" || f["mode_selector"] != "RUN-P" {
+		t.Fatalf("protection %v", f)
+	}
+	cpu.SetState(s7sim.StateStop)
+	if f := read(0x0424, 0).GetRecords()[0].GetFields(); f["state"] != "STOP" {
+		t.Fatalf("state %v", f)
+	}
+	// lists without a decoder come raw
+	cpu.SetSZL(0x0F00, 0, s7sim.SZL{Size: 2, Records: []byte{1, 2}})
+	if r := read(0x0F00, 0).GetRecords(); len(r) != 1 || r[0].GetFields() != nil || string(r[0].GetRaw()) != "\x01\x02" {
+		t.Fatalf("raw records %v", r)
+	}
+
+	if _, err := server.ReadSzl(ctx, &pb.SzlReq{Plc: cpu.Plc(), Id: 0x0F01}); status.Code(err) != codes.NotFound {
+		t.Fatalf("missing SZL: %v", err)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	date := time.Date(2020, 3, 2, 14, 30, 0, 0, time.UTC)
+	// an FC bigger than an upload response
+	code := make([]byte, 450)
+	for i := range code {
+		code[i] = byte(i)
+	}
+	fc := s7sim.Block{Language: 1, Author: "goplc", Family: "test", Name: "MOTOR", Version: 0x12, Checksum: 0xBEEF, Date: date, MC7: code, Interface: []byte{1, 2, 3}}
+	cpu.SetBlock(s7sim.BlockOB, 1, s7sim.Block{Language: 1, MC7: []byte{0x70}})
+	cpu.SetBlock(s7sim.BlockFC, 12, fc)
+	cpu.SetBlock(s7sim.BlockDB, 2, s7sim.Block{Language: 5, MC7: []byte{0, 42}})
+	cpu.SetBlock(s7sim.BlockDB, 1, s7sim.Block{Language: 5, MC7: []byte{0, 7}})
+	server := PlcServer{}
+	ctx := context.Background()
+
+	list, err := server.ListBlocks(ctx, cpu.Plc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]uint32)
+	for _, bt := range list.GetTypes() {
+		if int(bt.GetCount()) != len(bt.GetNumbers()) {
+			t.Errorf("%s: %d blocks, numbers %v", bt.GetType(), bt.GetCount(), bt.GetNumbers())
+		}
+		got[bt.GetType()] = bt.GetNumbers()
+	}
+	want := map[string][]uint32{"OB": {1}, "FB": nil, "FC": {12}, "DB": {1, 2}, "SFB": nil, "SFC": nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("blocks %v, want %v", got, want)
+	}
+
+	info, err := server.GetBlockInfo(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codeDate, _ := ptypes.Timestamp(info.GetCodeDate())
+	if info.GetLanguage() != "STL" || info.GetMc7Size() != 450 || info.GetLoadSize() != 36+450+3 || info.GetChecksum() != 0xBEEF ||
+		info.GetAuthor() != "goplc" || info.GetFamily() != "test" || info.GetHeader() != "MOTOR" || info.GetVersion() != "1.2" ||
+		info.GetNumber() != 12 || info.GetType() != "FC" || !codeDate.Equal(date) {
+		t.Fatalf("block info %v", info)
+	}
+
+	block, err := server.UploadBlock(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(block.GetMc7(), code) || !bytes.Equal(block.GetData(), fc.Data(s7sim.BlockFC, 12)) || block.GetInfo().GetMc7Size() != 450 {
+		t.Fatalf("uploaded %d bytes, MC7 %d", len(block.GetData()), len(block.GetMc7()))
+	}
+	block, err = server.UploadBlock(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "DB", Number: 2})
+	if err != nil || string(block.GetMc7()) != "\x00*" {
+		t.Fatalf("DB2 %v %v", block, err)
+	}
+
+	for _, req := range []*pb.BlockReq{{Type: "FB", Number: 12}, {Type: "DB", Number: 3}} {
+		req.Plc = cpu.Plc()
+		if _, err := server.GetBlockInfo(ctx, req); status.Code(err) != codes.NotFound {
+			t.Errorf("info of missing %v: %v", req, err)
+		}
+		if _, err := server.UploadBlock(ctx, req); status.Code(err) != codes.NotFound {
+			t.Errorf("upload of missing %v: %v", req, err)
+		}
+	}
+
+	// a list of a type the CPU refuses is no empty list
+	cpu.Tamper(func(resp []byte) []byte {
+		if resp[8] == 0x07 && resp[22] == 0x83 && resp[23] == 0x02 {
+			resp[29] = 0x0A
+		}
+		return resp
+	})
+	if list, err := server.ListBlocks(ctx, cpu.Plc()); err == nil {
+		t.Fatalf("list with a refused type %v", list)
+	}
+	// nor does a parameter length beyond the response break an upload
+	cpu.Tamper(func(resp []byte) []byte {
+		if resp[8] == 0x03 && len(resp) > 19 && resp[19] == 0x1E {
+			resp[13], resp[14] = 0xFF, 0xFF
+		}
+		return resp
+	})
+	if _, err := server.UploadBlock(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 12}); err == nil {
+		t.Fatal("upload of a malformed response")
+	}
+}
+
+func TestDownloadBlock(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	code := make([]byte, 450)
+	for i := range code {
+		code[i] = byte(i * 7)
+	}
+	cpu.SetBlock(s7sim.BlockFC, 12, s7sim.Block{Language: 1, MC7: code, Interface: []byte{1, 2, 3}})
+	protect := func(level uint16) {
+		r := make([]byte, 40)
+		binary.BigEndian.PutUint16(r, 4)
+		binary.BigEndian.PutUint16(r[6:], level)
+		cpu.SetSZL(0x0232, 4, s7sim.SZL{Size: 40, Records: r})
+	}
+	protect(1)
+	var log bytes.Buffer
+	server := PlcServer{Audit: audit.New(&log)}
+	ctx := context.Background()
+	eng := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+	block, err := server.UploadBlock(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a copy of FC12 as FC13
+	data := append([]byte(nil), block.GetData()...)
+	binary.BigEndian.PutUint16(data[6:], 13)
+	req := &pb.DownloadBlockReq{Plc: cpu.Plc(), Type: "FC", Number: 13, Data: data, Reason: "restore"}
+	confirmed := func(req *pb.DownloadBlockReq) (*pb.BlockOperationResult, error) {
+		t.Helper()
+		res, err := server.DownloadBlock(eng, req)
+		if err != nil || res.GetConfirm() == "" || res.GetInfo() != nil {
+			t.Fatalf("download without confirmation %v %v", res, err)
+		}
+		req.Confirm = res.GetConfirm()
+		defer func() { req.Confirm = "" }()
+		return server.DownloadBlock(eng, req)
+	}
+
+	op := auth.NewContext(ctx, auth.Caller{Name: "op", Role: auth.Operator})
+	if _, err := server.DownloadBlock(op, req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("operator download: %v", err)
+	}
+	for _, bad := range []*pb.DownloadBlockReq{
+		{Plc: cpu.Plc(), Type: "FC", Number: 13, Data: data},
+		{Plc: cpu.Plc(), Type: "FC", Number: 13, Data: block.GetMc7(), Reason: "restore"},
+		{Plc: cpu.Plc(), Type: "UDT", Number: 13, Data: data, Reason: "restore"},
+		// the header names another block than the request
+		{Plc: cpu.Plc(), Type: "FC", Number: 13, Data: block.GetData(), Reason: "restore"},
+		{Plc: cpu.Plc(), Type: "DB", Number: 13, Data: data, Reason: "restore"},
+	} {
+		if _, err := server.DownloadBlock(eng, bad); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("download of %s%d, %d bytes, reason %q: %v", bad.GetType(), bad.GetNumber(), len(bad.GetData()), bad.GetReason(), err)
+		}
+	}
+	// a token confirms the download of the same block only
+	res, _ := server.DownloadBlock(eng, req)
+	other := proto.Clone(req).(*pb.DownloadBlockReq)
+	other.Confirm = res.GetConfirm()
+	other.Data[len(other.Data)-1]++
+	if _, err := server.DownloadBlock(eng, other); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("download confirmed for another block: %v", err)
+	}
+
+	res, err = confirmed(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := res.GetInfo(); info.GetNumber() != 13 || info.GetMc7Size() != 450 || info.GetLanguage() != "STL" {
+		t.Fatalf("downloaded %v", info)
+	}
+	copied, err := server.UploadBlock(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 13})
+	if err != nil || !bytes.Equal(copied.GetMc7(), code) {
+		t.Fatalf("FC13 %v", err)
+	}
+
+	del := &pb.DeleteBlockReq{Plc: cpu.Plc(), Type: "FC", Number: 13, Reason: "copy no longer needed"}
+	res, err = server.DeleteBlock(eng, del)
+	if err != nil || res.GetConfirm() == "" {
+		t.Fatalf("delete without confirmation %v %v", res, err)
+	}
+	del.Confirm = res.GetConfirm()
+	if _, err := server.DeleteBlock(eng, del); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.GetBlockInfo(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 13}); status.Code(err) != codes.NotFound {
+		t.Fatalf("deleted FC13: %v", err)
+	}
+
+	protect(2)
+	if _, err := confirmed(req); !protectionRefused(err) {
+		t.Fatalf("download to a protected CPU: %v", err)
+	}
+	if _, err := server.GetBlockInfo(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "FC", Number: 13}); status.Code(err) != codes.NotFound {
+		t.Fatalf("FC13 downloaded to a protected CPU: %v", err)
+	}
+
+	entries := log.String()
+	if !strings.Contains(entries, `"detail":"FC13 sha256:`) || !strings.Contains(entries, `: restore"`) {
+		t.Fatalf("audit details:\n%s", entries)
+	}
+	var got []string
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Action+" "+e.Result)
+	}
+	want := []string{
+		"download_block denied",
+		"download_block confirm", "download_block denied",
+		"download_block confirm", "download_block ok",
+		"delete_block confirm", "delete_block ok",
+		"download_block confirm", "download_block denied",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit %q, want %q", got, want)
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	date := time.Date(2020, 3, 2, 14, 30, 0, 0, time.UTC)
+	cpu.SetBlock(s7sim.BlockOB, 1, s7sim.Block{Language: 1, Checksum: 1, Date: date, MC7: []byte{0x70}})
+	cpu.SetBlock(s7sim.BlockFC, 12, s7sim.Block{Language: 1, Checksum: 2, Date: date, MC7: []byte{0x70, 0x0B}})
+	dir, err := ioutil.TempDir("", "baselines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := integrity.OpenStore(filepath.Join(dir, "baselines.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	server := PlcServer{Audit: audit.New(&log)}
+	ctx := context.Background()
+	eng := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+
+	if _, err := server.CompareToBaseline(ctx, &pb.BaselineReq{Plc: cpu.Plc()}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("without a store: %v", err)
+	}
+	server.Baselines = store
+	if _, err := server.CompareToBaseline(ctx, &pb.BaselineReq{Plc: cpu.Plc()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("without a baseline: %v", err)
+	}
+	op := auth.NewContext(ctx, auth.Caller{Name: "op", Role: auth.Operator})
+	if _, err := server.CompareToBaseline(op, &pb.BaselineReq{Plc: cpu.Plc(), Update: true}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("operator update: %v", err)
+	}
+	diff, err := server.CompareToBaseline(eng, &pb.BaselineReq{Plc: cpu.Plc(), Update: true})
+	if err != nil || !diff.GetUpdated() || diff.GetBaselineTaken() != nil {
+		t.Fatalf("first baseline %v %v", diff, err)
+	}
+	if b, ok := store.Get(cpu.Plc()); !ok || len(b.Blocks) != 2 {
+		t.Fatalf("stored baseline %+v", b)
+	}
+
+	cpu.SetBlock(s7sim.BlockFC, 12, s7sim.Block{Language: 1, Checksum: 3, Date: date.Add(time.Hour), MC7: []byte{0x70, 0x0B}})
+	cpu.SetBlock(s7sim.BlockDB, 5, s7sim.Block{Language: 5, Date: date, MC7: []byte{0, 0}})
+	cpu.RemoveBlock(s7sim.BlockOB, 1)
+	diff, err = server.CompareToBaseline(ctx, &pb.BaselineReq{Plc: cpu.Plc()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range diff.GetChanges() {
+		got = append(got, fmt.Sprintf("%s%d %v %v", c.GetType(), c.GetNumber(), c.GetKind(), c.GetFields()))
+	}
+	want := []string{"FC12 CHANGED [checksum code_date interface_date]", "DB5 ADDED []", "OB1 REMOVED []"}
+	if !reflect.DeepEqual(got, want) || diff.GetUpdated() {
+		t.Fatalf("changes %q, want %q", got, want)
+	}
+	if c := diff.GetChanges()[0]; c.GetBaseline().GetChecksum() != 2 || c.GetCurrent().GetChecksum() != 3 {
+		t.Fatalf("FC12 %v", c)
+	}
+
+	// accepting the changes leaves nothing to report
+	if _, err := server.CompareToBaseline(eng, &pb.BaselineReq{Plc: cpu.Plc(), Update: true}); err != nil {
+		t.Fatal(err)
+	}
+	diff, err = server.CompareToBaseline(ctx, &pb.BaselineReq{Plc: cpu.Plc()})
+	if err != nil || len(diff.GetChanges()) != 0 {
+		t.Fatalf("after update %v %v", diff, err)
+	}
+	if n := strings.Count(log.String(), `"integrity_baseline"`); n != 3 {
+		t.Fatalf("%d audited updates:\n%s", n, log.String())
+	}
+}
+
+// diagnosticEntry makes a diagnostic buffer entry of event id at t.
+func diagnosticEntry(id uint16, ob byte, t time.Time) []byte {
+	var helper gos7.Helper
+	r := make([]byte, 20)
+	binary.BigEndian.PutUint16(r, id)
+	r[2], r[3] = 1, ob
+	helper.SetDateTimeAt(r, 12, t)
+	return r
+}
+
+type diagnosticStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	entries chan *pb.DiagnosticEntry
+}
+
+func (s diagnosticStream) Context() context.Context { return s.ctx }
+
+func (s diagnosticStream) Send(e *pb.DiagnosticEntry) error {
+	s.entries <- e
+	return nil
+}
+
+func TestDiagnosticBuffer(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	t0 := time.Date(2020, 4, 1, 12, 0, 0, 250e6, time.UTC)
+	entries := append(diagnosticEntry(0x4302, 100, t0.Add(time.Second)), diagnosticEntry(0x4301, 100, t0)...)
+	cpu.SetSZL(0x00A0, 0, s7sim.SZL{Size: 20, Records: entries})
+	server := PlcServer{}
+	ctx := context.Background()
+
+	buf, err := server.GetDiagnosticBuffer(ctx, &pb.DiagnosticReq{Plc: cpu.Plc()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf.GetEntries()) != 2 {
+		t.Fatalf("entries %v", buf.GetEntries())
+	}
+	e := buf.GetEntries()[0]
+	if ts, _ := ptypes.Timestamp(e.GetTime()); e.GetEventId() != 0x4302 || e.GetObNumber() != 100 || e.GetPriority() != 1 || !ts.Equal(t0.Add(time.Second)) ||
+		e.GetText() != "Mode transition from STARTUP to RUN" {
+		t.Fatalf("newest entry %v", e)
+	}
+	if text := EventText(0x3F01); text != "Asynchronous error 0x3f01" {
+		t.Fatalf("text of an unknown event %q", text)
+	}
+
+	if err := server.WatchDiagnosticBuffer(&pb.DiagnosticReq{Plc: cpu.Plc(), Interval: ptypes.DurationProto(time.Millisecond)},
+		diagnosticStream{ctx: ctx}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("watch every millisecond: %v", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stream := diagnosticStream{ctx: ctx, entries: make(chan *pb.DiagnosticEntry, 10)}
+	done := make(chan error)
+	go func() {
+		done <- server.WatchDiagnosticBuffer(&pb.DiagnosticReq{Plc: cpu.Plc(), Interval: ptypes.DurationProto(MinDiagnosticInterval)}, stream)
+	}()
+	// the existing entries are not sent, new ones oldest first
+	time.Sleep(MinDiagnosticInterval / 2)
+	entries = append(append(diagnosticEntry(0x4304, 1, t0.Add(3*time.Second)), diagnosticEntry(0x2522, 1, t0.Add(2*time.Second))...), entries...)
+	cpu.SetSZL(0x00A0, 0, s7sim.SZL{Size: 20, Records: entries})
+	for _, want := range []uint32{0x2522, 0x4304} {
+		select {
+		case e := <-stream.entries:
+			if e.GetEventId() != want {
+				t.Fatalf("streamed %#x, want %#x", e.GetEventId(), want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no entry %#x", want)
+		}
+	}
+	// a failed read skips a poll, not the entries
+	cpu.Tamper(func(resp []byte) []byte {
+		if resp[8] == 0x07 && resp[22] == 0x84 {
+			resp[29] = 0x0A
+		}
+		return resp
+	})
+	time.Sleep(3 * MinDiagnosticInterval)
+	cpu.Tamper(nil)
+	entries = append(diagnosticEntry(0x4303, 1, t0.Add(4*time.Second)), entries...)
+	cpu.SetSZL(0x00A0, 0, s7sim.SZL{Size: 20, Records: entries})
+	select {
+	case e := <-stream.entries:
+		if e.GetEventId() != 0x4303 {
+			t.Fatalf("streamed %#x after a failed read", e.GetEventId())
+		}
+	case err := <-done:
+		t.Fatalf("watch ended on a failed read: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no entry after a failed read")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.entries) != 0 {
+		t.Fatalf("%d more entries", len(stream.entries))
+	}
+
+	// a CPU gone for longer than the outage ends the stream
+	server.DiagnosticOutage = 2 * MinDiagnosticInterval
+	stream = diagnosticStream{ctx: context.Background(), entries: make(chan *pb.DiagnosticEntry, 10)}
+	go func() {
+		done <- server.WatchDiagnosticBuffer(&pb.DiagnosticReq{Plc: cpu.Plc(), Interval: ptypes.DurationProto(MinDiagnosticInterval)}, stream)
+	}()
+	time.Sleep(MinDiagnosticInterval / 2)
+	cpu.Close()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("watch of a CPU gone: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch of a CPU gone goes on")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	// bigger than a PDU
+	values := make([]byte, 500)
+	for i := range values {
+		values[i] = byte(i * 3)
+	}
+	cpu.SetBlock(s7sim.BlockDB, 5, s7sim.Block{Language: 5, MC7: values})
+	cpu.SetBlock(s7sim.BlockDB, 6, s7sim.Block{Language: 5, MC7: make([]byte, 60)})
+	cpu.SetM(0, []byte{1, 2, 3, 4})
+	var log bytes.Buffer
+	server := PlcServer{Audit: audit.New(&log)}
+	ctx := context.Background()
+	eng := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+
+	for _, bad := range [][]*pb.AreaRange{nil, {{Area: "Q"}}, {{Area: "DB6", Start: 50, Size: 20}}} {
+		if _, err := server.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: cpu.Plc(), Areas: bad}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("snapshot of %v: %v", bad, err)
+		}
+	}
+	if _, err := server.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: cpu.Plc(), Areas: []*pb.AreaRange{{Area: "DB7"}}}); status.Code(err) != codes.NotFound {
+		t.Errorf("snapshot of DB7: %v", err)
+	}
+	snap, err := server.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: cpu.Plc(), Areas: []*pb.AreaRange{{Area: "DB5"}, {Area: "db6"}, {Area: "M", Size: 16}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.GetVersion() != 1 || snap.GetDevice().GetSerialNumber() != s7sim.SerialNumber || snap.GetTaken() == nil {
+		t.Fatalf("snapshot %v %v", snap.GetVersion(), snap.GetDevice())
+	}
+	areas := snap.GetAreas()
+	if len(areas) != 3 || !bytes.Equal(areas[0].GetData(), values) || areas[1].GetArea() != "DB6" || areas[1].GetAreaSize() != 60 ||
+		areas[2].GetAreaSize() != s7sim.MSize || !bytes.Equal(areas[2].GetData()[:5], []byte{1, 2, 3, 4, 0}) {
+		t.Fatalf("areas %v", areas)
+	}
+
+	changed := append([]byte(nil), values...)
+	for i := range changed {
+		changed[i]++
+	}
+	cpu.SetBlock(s7sim.BlockDB, 5, s7sim.Block{Language: 5, MC7: changed})
+	cpu.SetM(0, []byte{9, 9, 9, 9})
+	req := &pb.RestoreReq{Plc: cpu.Plc(), Snapshot: snap, Ranges: []*pb.AreaRange{{Area: "DB5", Start: 10, Size: 300}, {Area: "M", Start: 2}}, Reason: "undo commissioning"}
+	if _, err := server.RestoreSnapshot(auth.NewContext(ctx, auth.Caller{Name: "op", Role: auth.Operator}), req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("operator restore: %v", err)
+	}
+	res, err := server.RestoreSnapshot(eng, req)
+	if err != nil || res.GetConfirm() == "" || res.GetWritten() != nil {
+		t.Fatalf("restore without confirmation %v %v", res, err)
+	}
+	req.Confirm = res.GetConfirm()
+	if res, err = server.RestoreSnapshot(eng, req); err != nil {
+		t.Fatal(err)
+	}
+	if w := res.GetWritten(); len(w) != 2 || w[0].GetSize() != 300 || w[1].GetStart() != 2 || w[1].GetSize() != 16-2 {
+		t.Fatalf("written %v", w)
+	}
+	db5, _ := server.UploadBlock(ctx, &pb.BlockReq{Plc: cpu.Plc(), Type: "DB", Number: 5})
+	if want := append(append(changed[:10:10], values[10:310]...), changed[310:]...); !bytes.Equal(db5.GetMc7(), want) {
+		t.Fatal("DB5 not restored from 10 to 309 only")
+	}
+	if m := cpu.M(); !bytes.Equal(m[:4], []byte{9, 9, 3, 4}) {
+		t.Fatalf("M %v", m[:4])
+	}
+
+	// a DB that changed size is left alone
+	cpu.SetBlock(s7sim.BlockDB, 6, s7sim.Block{Language: 5, MC7: make([]byte, 64)})
+	req = &pb.RestoreReq{Plc: cpu.Plc(), Snapshot: snap, Reason: "undo commissioning"}
+	res, _ = server.RestoreSnapshot(eng, req)
+	req.Confirm = res.GetConfirm()
+	if _, err := server.RestoreSnapshot(eng, req); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("restore of a DB of another size: %v", err)
+	}
+	for _, bad := range []*pb.RestoreReq{
+		{Plc: cpu.Plc(), Snapshot: snap},
+		{Plc: cpu.Plc(), Snapshot: &pb.Snapshot{Areas: snap.GetAreas()}, Reason: "r"},
+		{Plc: cpu.Plc(), Snapshot: snap, Ranges: []*pb.AreaRange{{Area: "DB5", Start: 400, Size: 200}}, Reason: "r"},
+	} {
+		if _, err := server.RestoreSnapshot(eng, bad); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("restore %v: %v", bad.GetRanges(), err)
+		}
+	}
+	readOnly := PlcServer{WritePolicy: ReadOnly}
+	if _, err := readOnly.RestoreSnapshot(eng, req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("restore with a write policy: %v", err)
+	}
+
+	entries := log.String()
+	if !strings.Contains(entries, `"detail":"DB5:10-309, M:2-15 sha256:`) {
+		t.Fatalf("audit details:\n%s", entries)
+	}
+	var got []string
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Action+" "+e.Result)
+	}
+	want := []string{
+		"restore_snapshot denied", "restore_snapshot confirm", "restore_snapshot ok",
+		"restore_snapshot confirm", "restore_snapshot rpc error: code = FailedPrecondition desc = DB6 has 64 bytes, 60 when the snapshot was taken",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit %q, want %q", got, want)
+	}
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	cpu.SetBlock(s7sim.BlockDB, 5, s7sim.Block{Language: 5, MC7: []byte{0, 10, 0, 20}})
+	server := PlcServer{}
+	ctx := context.Background()
+	base, err := server.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: cpu.Plc(), Areas: []*pb.AreaRange{{Area: "DB5"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu.SetBlock(s7sim.BlockDB, 5, s7sim.Block{Language: 5, MC7: []byte{0, 10, 0, 25}})
+	tags := []*pb.Tag{{Address: "DB5P0", Dt: "Int"}, {Address: "DB5P2", Dt: "Int"}}
+	diff, err := server.DiffSnapshot(ctx, &pb.DiffSnapshotReq{Base: base, Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := diff.GetTags(); len(c) != 1 || c[0].GetAddress() != "DB5P2" || c[0].GetBefore() != "20" || c[0].GetAfter() != "25" {
+		t.Fatalf("tag changes %v", c)
+	}
+	if c := diff.GetBytes(); len(c) != 1 || c[0].GetStart() != 3 || diff.GetCurrentTaken() == nil {
+		t.Fatalf("byte changes %v", c)
+	}
+	// two snapshots need no CPU
+	diff, err = server.DiffSnapshot(ctx, &pb.DiffSnapshotReq{Base: base, Current: base, Plc: &pb.Plc{Host: "127.0.0.1", Port: 1}})
+	if err != nil || len(diff.GetBytes()) != 0 {
+		t.Fatalf("diff to itself %v %v", diff, err)
+	}
+	for _, bad := range []*pb.DiffSnapshotReq{
+		{},
+		{Base: base, Tags: []*pb.Tag{{Address: "DB5", Dt: "Int"}}},
+		{Base: base, Udts: []*pb.Udt{{Name: "x", Address: "DB5"}}},
+		{Base: &pb.Snapshot{}},
+	} {
+		if _, err := server.DiffSnapshot(ctx, bad); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("diff %v: %v", bad, err)
+		}
+	}
+}
+
+func TestPlcTime(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	var log bytes.Buffer
+	server := PlcServer{Audit: audit.New(&log)}
+	ctx := context.Background()
+	eng := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+
+	cpu.SetClock(time.Now().Add(90 * time.Second))
+	res, err := server.GetPlcTime(ctx, cpu.Plc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift, _ := ptypes.Duration(res.GetDrift()); drift < 89*time.Second || drift > 91*time.Second {
+		t.Fatalf("drift %v", drift)
+	}
+	if drift, err := server.PlcClockDrift(ctx, cpu.Plc()); err != nil || drift < 89*time.Second {
+		t.Fatalf("drift %v %v", drift, err)
+	}
+	// a clock running in local time
+	plc := cpu.Plc()
+	plc.Options = map[string]string{"time_zone": "Asia/Kolkata"}
+	if drift, err := server.PlcClockDrift(ctx, plc); err != nil || drift < -(5*time.Hour+30*time.Minute)+89*time.Second || drift > -(5*time.Hour+30*time.Minute)+91*time.Second {
+		t.Fatalf("drift in Asia/Kolkata %v %v", drift, err)
+	}
+	plc.Options["time_zone"] = "Mars/Olympus"
+	if _, err := server.GetPlcTime(ctx, plc); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown time zone: %v", err)
+	}
+
+	if _, err := server.SetPlcTime(auth.NewContext(ctx, auth.Caller{Name: "op", Role: auth.Operator}), &pb.SetPlcTimeReq{Plc: cpu.Plc()}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("operator set time: %v", err)
+	}
+	if res, err = server.SetPlcTime(eng, &pb.SetPlcTimeReq{Plc: cpu.Plc()}); err != nil {
+		t.Fatal(err)
+	}
+	if drift, _ := ptypes.Duration(res.GetDrift()); drift < -time.Second || drift > time.Second {
+		t.Fatalf("drift after setting %v", drift)
+	}
+	at := time.Date(2021, 6, 1, 8, 0, 0, 250*int(time.Millisecond), time.UTC)
+	ts, _ := ptypes.TimestampProto(at)
+	if _, err = server.SetPlcTime(eng, &pb.SetPlcTimeReq{Plc: cpu.Plc(), Time: ts}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cpu.Clock(); got.Before(at) || got.After(at.Add(time.Second)) {
+		t.Fatalf("clock %v, want %v", got, at)
+	}
+	if drift, err := server.SyncPlcClock(ctx, cpu.Plc()); err != nil || drift > -time.Hour {
+		t.Fatalf("drift before sync %v %v", drift, err)
+	}
+	if got := cpu.Clock(); time.Since(got) > time.Second {
+		t.Fatalf("clock %v after sync", got)
+	}
+
+	var got []string
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Action+" "+strings.SplitN(e.Detail, ",", 2)[0]+" "+e.Result)
+	}
+	want := []string{
+		"set_plc_time  denied",
+		"set_plc_time host time ok",
+		"set_plc_time 2021-06-01T08:00:00.25Z ok",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit %q, want %q", got, want)
+	}
+}
+
+// protectionRefused reports whether err is the protection of a CPU refusing
+// a function.
+func protectionRefused(err error) bool {
+	return status.Code(err) == codes.FailedPrecondition && strings.HasPrefix(status.Convert(err).Message(), "CPU protection")
+}
+
+func TestSessionPassword(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	cpu.SetBlock(s7sim.BlockDB, 5, s7sim.Block{Language: 5, MC7: []byte{0, 7, 0, 0}})
+	cpu.SetBlock(s7sim.BlockFC, 12, s7sim.Block{Language: 1, MC7: []byte{0x70, 0x0B}})
+	cpu.Protect(3, "secret")
+	var log bytes.Buffer
+	server := PlcServer{Audit: audit.New(&log)}
+	ctx := context.Background()
+	eng := auth.NewContext(ctx, auth.Caller{Name: "eng", Role: auth.Engineer})
+	viewer := auth.NewContext(ctx, auth.Caller{Name: "tech", Role: auth.Viewer})
+	tags := func() []*pb.Tag { return []*pb.Tag{{Address: "DB5P0", Dt: "Int"}} }
+	plc := cpu.Plc()
+	plc.PasswordRef = "press"
+
+	if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: tags()}); !protectionRefused(err) {
+		t.Fatalf("read without a password: %v", err)
+	}
+	if _, err := server.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: cpu.Plc(), Areas: []*pb.AreaRange{{Area: "DB5"}}}); !protectionRefused(err) {
+		t.Fatalf("snapshot without a password: %v", err)
+	}
+	if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: tags()}); !protectionRefused(err) {
+		t.Fatalf("read naming no kept password: %v", err)
+	}
+	if _, err := server.SetSessionPassword(viewer, &pb.SetSessionPasswordReq{Ref: "press", Password: "secret"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("viewer set a password: %v", err)
+	}
+	if res, err := server.SetSessionPassword(eng, &pb.SetSessionPasswordReq{Ref: "press", Password: "guess"}); err != nil || res.GetReplaced() {
+		t.Fatalf("set %v %v", res, err)
+	}
+	if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: tags()}); !protectionRefused(err) {
+		t.Fatalf("read with a wrong password: %v", err)
+	}
+	// a password the CPU refuses stays until an engineer replaces it
+	if res, err := server.SetSessionPassword(eng, &pb.SetSessionPasswordReq{Ref: "press", Password: "secret"}); err != nil || !res.GetReplaced() {
+		t.Fatalf("replaced %v %v", res, err)
+	}
+
+	res, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: tags()})
+	if err != nil || res.GetTags()[0].GetValueInteger() != 7 {
+		t.Fatalf("read with the password %v %v", res, err)
+	}
+	write := []*pb.Tag{{Address: "DB5P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 9}}}
+	if _, err := server.WriteTags(ctx, &pb.RWReq{Plc: plc, Tags: write}); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := server.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: plc, Areas: []*pb.AreaRange{{Area: "DB5"}}})
+	if err != nil || snap.GetPlc().GetPasswordRef() != "press" || !bytes.Equal(snap.GetAreas()[0].GetData(), []byte{0, 9, 0, 0}) {
+		t.Fatalf("snapshot %v %v", snap, err)
+	}
+	del := &pb.DeleteBlockReq{Plc: plc, Type: "FC", Number: 12, Reason: "obsolete"}
+	r, err := server.DeleteBlock(eng, del)
+	if err != nil {
+		t.Fatal(err)
+	}
+	del.Confirm = r.GetConfirm()
+	if _, err := server.DeleteBlock(eng, del); err != nil {
+		t.Fatalf("delete with the password: %v", err)
+	}
+
+	if _, err := server.ClearSessionPassword(viewer, plc); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("viewer cleared the password: %v", err)
+	}
+	if res, err := server.ClearSessionPassword(eng, plc); err != nil || !res.GetCleared() {
+		t.Fatalf("cleared %v %v", res, err)
+	}
+	if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: tags()}); !protectionRefused(err) {
+		t.Fatalf("read after clearing the password: %v", err)
+	}
+	if res, err := server.ClearSessionPassword(eng, plc); err != nil || res.GetCleared() {
+		t.Fatalf("cleared twice %v %v", res, err)
+	}
+
+	// passwords of the configuration
+	server.KeepPasswords(ctx, map[string]string{"oven": "secret"})
+	plc.PasswordRef = "oven"
+	if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: plc, Tags: tags()}); err != nil {
+		t.Fatalf("read with the configured password: %v", err)
+	}
+
+	var got []string
+	dec := json.NewDecoder(&log)
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(e.Action, "_session_password") {
+			got = append(got, e.User+" "+e.Action+" "+e.Detail+" "+e.Result)
+		}
+	}
+	want := []string{
+		"tech set_session_password press denied",
+		"eng set_session_password press ok",
+		"eng set_session_password press ok",
+		"tech clear_session_password press denied",
+		"eng clear_session_password press ok",
+		"eng clear_session_password press ok",
+		" set_session_password oven from the configuration ok",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit %q, want %q", got, want)
+	}
+}
+
+func TestTimersCounters(t *testing.T) {
+	cpu := s7sim.NewCPU(t)
+	defer cpu.Close()
+	server := PlcServer{}
+	ctx := context.Background()
+	// 123 s with a time base of 1 s, and 42 in BCD
+	cpu.SetTimer(12, 0x2123)
+	cpu.SetCounter(5, 0x0042)
+	cpu.SetCounter(255, 0x0999)
+
+	// the timers or counters of a read span more than a PDU
+	tags := []*pb.Tag{{Address: "T12", Dt: "S5Time"}, {Address: "C5", Dt: "Counter"}, {Address: "C0", Dt: "Counter"}, {Address: "C255", Dt: "Counter"}}
+	res, err := server.ReadTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := ptypes.Duration(res.GetTags()[0].GetValueDuration()); d != 123*time.Second {
+		t.Fatalf("T12 %v", d)
+	}
+	for i, want := range []int64{42, 0, 999} {
+		if got := res.GetTags()[i+1].GetValueInteger(); got != want {
+			t.Fatalf("%s %d, want %d", tags[i+1].GetAddress(), got, want)
+		}
+	}
+
+	write := []*pb.Tag{
+		{Address: "T12", Dt: "S5Time", Value: &pb.Tag_ValueDuration{ValueDuration: ptypes.DurationProto(5 * time.Second)}},
+		{Address: "C5", Dt: "Counter", Value: &pb.Tag_ValueInteger{ValueInteger: 317}},
+		{Address: "C7", Dt: "Counter", Value: &pb.Tag_ValueInteger{ValueInteger: 8}},
+	}
+	cpu.SetCounter(6, 0x0011)
+	if _, err := server.WriteTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: write}); err != nil {
+		t.Fatal(err)
+	}
+	// the counter between those written keeps its value
+	if c5, c6, c7 := cpu.Counter(5), cpu.Counter(6), cpu.Counter(7); c5 != 0x0317 || c6 != 0x0011 || c7 != 0x0008 {
+		t.Fatalf("counters %#04x %#04x %#04x", c5, c6, c7)
+	}
+	res, err = server.ReadTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: []*pb.Tag{{Address: "T12", Dt: "S5Time"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := ptypes.Duration(res.GetTags()[0].GetValueDuration()); d != 5*time.Second {
+		t.Fatalf("T12 %v after writing 5s", d)
+	}
+
+	// counters out of range are refused, not clamped
+	for _, v := range []int64{1000, 1500, -1} {
+		bad := []*pb.Tag{{Address: "C5", Dt: "Counter", Value: &pb.Tag_ValueInteger{ValueInteger: v}}}
+		if _, err := server.WriteTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: bad}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("write of %d: %v", v, err)
+		}
+	}
+	if c5 := cpu.Counter(5); c5 != 0x0317 {
+		t.Fatalf("C5 %#04x after writes out of range", c5)
+	}
+	// reads replace whatever value the request carries
+	res, err = server.ReadTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: []*pb.Tag{{Address: "C5", Dt: "Counter", Value: &pb.Tag_ValueInteger{ValueInteger: 1500}}}})
+	if err != nil || res.GetTags()[0].GetValueInteger() != 317 {
+		t.Fatalf("read of C5 with a value out of range: %v %v", res, err)
+	}
+	if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: []*pb.Tag{{Address: "T300", Dt: "S5Time"}}}); err == nil {
+		t.Fatal("read of a timer the CPU lacks")
+	}
+	for _, bad := range []*pb.Tag{
+		{Address: "T12", Dt: "Int"},
+		{Address: "C5", Dt: "S5Time"},
+		{Address: "T", Dt: "S5Time"},
+		{Address: "CP5", Dt: "Counter"},
+		{Address: "T1.2", Dt: "S5Time"},
+	} {
+		if _, err := server.ReadTags(ctx, &pb.RWReq{Plc: cpu.Plc(), Tags: []*pb.Tag{bad}}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s %s: %v", bad.GetAddress(), bad.GetDt(), err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package s7
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	gos7 "github.com/thinkontrolsy/gos7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/audit"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	"github.com/thinkontrolsy/goplc/snapshot"
+)
+
+const (
+	szlSystemAreas = 0x0014
+	// systemAreaMarkers is the record of SZL 0x0014 with the number of
+	// bytes of M memory
+	systemAreaMarkers = 3
+
+	blockDB = 0x41
+)
+
+// readArea reads len(buf) bytes of DB db, or of M when db is 0, from start,
+// a PDU at a time.
+func (c *conn) readArea(db, start int, buf []byte) error {
+	var err error
+	if db == 0 {
+		err = c.client.AGReadMB(start, len(buf), buf)
+	} else {
+		err = c.client.AGReadDB(db, start, len(buf), buf)
+	}
+	return protectionError(c.refusal(err))
+}
+
+// writeArea is readArea for writing.
+func (c *conn) writeArea(db, start int, buf []byte) error {
+	var err error
+	if db == 0 {
+		err = c.client.AGWriteMB(start, len(buf), buf)
+	} else {
+		err = c.client.AGWriteDB(db, start, len(buf), buf)
+	}
+	return protectionError(c.refusal(err))
+}
+
+// markerSize reads the size of M memory, 0 when the CPU does not say.
+func markerSize(h *gos7.TCPClientHandler) (int, error) {
+	s, err := readSzl(h, szlSystemAreas, 0)
+	if unavailable(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < s.count; i++ {
+		if r := s.record(i); len(r) >= 6 && binary.BigEndian.Uint16(r) == systemAreaMarkers {
+			return int(binary.BigEndian.Uint16(r[4:])), nil
+		}
+	}
+	return 0, nil
+}
+
+// areaSize is the size of DB db, its MC7 size, or of M memory when db is 0.
+func areaSize(h *gos7.TCPClientHandler, db int) (int, error) {
+	if db == 0 {
+		return markerSize(h)
+	}
+	info, err := blockInfo(h, blockDB, uint16(db))
+	if err != nil {
+		return 0, blockError(err)
+	}
+	return int(info.GetMc7Size()), nil
+}
+
+// TakeSnapshot reads the areas asked for. A range of M without a size
+// needs a CPU that tells the size of its M memory.
+func (s *PlcServer) TakeSnapshot(ctx context.Context, req *pb.SnapshotReq) (*pb.Snapshot, error) {
+	if len(req.GetAreas()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no areas")
+	}
+	dbs := make([]int, len(req.GetAreas()))
+	for i, r := range req.GetAreas() {
+		db, err := snapshot.Area(r.GetArea())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		dbs[i] = db
+	}
+	c, err := s7Conn(ctx, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	device, err := c.DeviceInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taken, _ := ptypes.TimestampProto(time.Now())
+	snap := &pb.Snapshot{Version: snapshot.Version, Plc: req.GetPlc(), Device: device, Taken: taken}
+	for i, r := range req.GetAreas() {
+		size, err := areaSize(c.handler, dbs[i])
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 && dbs[i] == 0 && r.GetSize() == 0 {
+			return nil, status.Error(codes.InvalidArgument, "the CPU does not tell the size of M, give one")
+		}
+		start, n := int(r.GetStart()), int(r.GetSize())
+		if n == 0 {
+			n = size - start
+		}
+		if size == 0 && r.GetSize() > 0 {
+			// the CPU does not say, so it is as big as asked for
+			size = start + n
+		}
+		if n <= 0 || start+n > size {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("%s is beyond the %d bytes of %s", snapshot.FormatRange(r), size, r.GetArea()))
+		}
+		data := make([]byte, n)
+		if err := c.readArea(dbs[i], start, data); err != nil {
+			return nil, err
+		}
+		snap.Areas = append(snap.Areas, &pb.SnapshotArea{Area: strings.ToUpper(r.GetArea()), Start: uint32(start), AreaSize: uint32(size), Data: data})
+	}
+	return snap, nil
+}
+
+// RestoreSnapshot writes back the ranges of a snapshot asked for. It
+// refuses to write to a DB that is not the size it was when the snapshot
+// was taken, and any restore when a write policy is set, as a restore
+// writes more than tags.
+func (s *PlcServer) RestoreSnapshot(ctx context.Context, req *pb.RestoreReq) (*pb.RestoreResult, error) {
+	snap := req.GetSnapshot()
+	if v := snap.GetVersion(); v == 0 || v > snapshot.Version {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("snapshot version %d", v))
+	}
+	parts, err := snapshot.Select(snap, req.GetRanges())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(parts) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "nothing to restore")
+	}
+	if req.GetReason() == "" {
+		return nil, status.Error(codes.InvalidArgument, "a reason is required")
+	}
+	if s.WritePolicy != nil {
+		return nil, status.Error(codes.PermissionDenied, "the write policy refuses restores")
+	}
+	dbs := make([]int, len(parts))
+	written := make([]*pb.AreaRange, len(parts))
+	names := make([]string, len(parts))
+	h, n := sha256.New(), 0
+	for i, p := range parts {
+		if dbs[i], err = snapshot.Area(p.GetArea()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		written[i] = &pb.AreaRange{Area: p.GetArea(), Start: p.GetStart(), Size: uint32(len(p.GetData()))}
+		names[i] = snapshot.FormatRange(written[i])
+		h.Write(p.GetData())
+		n += len(p.GetData())
+	}
+	detail := fmt.Sprintf("%s sha256:%s (%d bytes): %s", strings.Join(names, ", "), hex.EncodeToString(h.Sum(nil)[:8]), n, req.GetReason())
+	token, expires, err := s.gated(ctx, req.GetPlc(), "restore_snapshot", detail, req.GetConfirm(), func(c *conn, _ *audit.Entry) error {
+		for i, p := range parts {
+			if dbs[i] == 0 {
+				continue
+			}
+			size, err := areaSize(c.handler, dbs[i])
+			if err != nil {
+				return err
+			}
+			if size != int(p.GetAreaSize()) {
+				return status.Error(codes.FailedPrecondition, fmt.Sprintf("%s has %d bytes, %d when the snapshot was taken", p.GetArea(), size, p.GetAreaSize()))
+			}
+		}
+		for i, p := range parts {
+			if err := c.writeArea(dbs[i], int(p.GetStart()), p.GetData()); err != nil {
+				return fmt.Errorf("%s: %v", names[i], err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		ts, _ := ptypes.TimestampProto(expires)
+		return &pb.RestoreResult{Confirm: token, ConfirmExpires: ts}, nil
+	}
+	return &pb.RestoreResult{Written: written}, nil
+}
+
+// DiffSnapshot compares the base snapshot to the current one or, without
+// it, to the same ranges read from the CPU.
+func (s *PlcServer) DiffSnapshot(ctx context.Context, req *pb.DiffSnapshotReq) (*pb.SnapshotDiff, error) {
+	base, current := req.GetBase(), req.GetCurrent()
+	for _, snap := range []*pb.Snapshot{base, current} {
+		if v := snap.GetVersion(); snap != nil && (v == 0 || v > snapshot.Version) {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("snapshot version %d", v))
+		}
+	}
+	if base == nil {
+		return nil, status.Error(codes.InvalidArgument, "no base snapshot")
+	}
+	if _, err := lookup(base.GetPlc(), req.GetTags()); err != nil {
+		return nil, err
+	}
+	if _, _, err := snapshot.Expand(req.GetUdts()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if current == nil {
+		plc := req.GetPlc()
+		if plc == nil {
+			plc = base.GetPlc()
+		}
+		areas := make([]*pb.AreaRange, len(base.GetAreas()))
+		for i, a := range base.GetAreas() {
+			areas[i] = &pb.AreaRange{Area: a.GetArea(), Start: a.GetStart(), Size: uint32(len(a.GetData()))}
+		}
+		var err error
+		if current, err = s.TakeSnapshot(ctx, &pb.SnapshotReq{Plc: plc, Areas: areas}); err != nil {
+			return nil, err
+		}
+	}
+	diff, err := snapshot.Diff(base, current, req.GetTags(), req.GetUdts())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return diff, nil
+}
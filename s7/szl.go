@@ -0,0 +1,222 @@
+package s7
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/thinkontrolsy/goplc/driver"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// szlDecoders decode the records of the lists goplc knows, by list
+// number: the low byte of the SZL id, which is the same for every extract
+// of a list.
+var szlDecoders = map[byte]func(r []byte) map[string]string{
+	0x11: decodeModuleIdentification,
+	0x12: decodeCpuCharacteristic,
+	0x19: decodeLed,
+	0x1C: decodeComponentIdentification,
+	0x24: decodeOperatingState,
+	0x32: decodeCommunicationStatus,
+	0x74: decodeLed,
+	0xA0: decodeDiagnostic,
+}
+
+func hex16(v uint16) string {
+	return fmt.Sprintf("%#04x", v)
+}
+
+func decodeModuleIdentification(r []byte) map[string]string {
+	if len(r) < 28 {
+		return nil
+	}
+	f := map[string]string{
+		"index":       hex16(binary.BigEndian.Uint16(r)),
+		"order_code":  text(r[2:22]),
+		"module_type": hex16(binary.BigEndian.Uint16(r[22:])),
+	}
+	if r[24] == 'V' {
+		f["version"] = fmt.Sprintf("V%d.%d.%d", r[25], r[26], r[27])
+	} else {
+		f["version"] = strconv.Itoa(int(binary.BigEndian.Uint16(r[26:])))
+	}
+	return f
+}
+
+// cpuCharacteristicGroups are the groups of CPU characteristics by the
+// high byte of their code.
+var cpuCharacteristicGroups = map[byte]string{
+	0x00: "processor",
+	0x01: "time system",
+	0x02: "system response",
+	0x03: "language description",
+}
+
+func decodeCpuCharacteristic(r []byte) map[string]string {
+	if len(r) < 2 {
+		return nil
+	}
+	f := map[string]string{"characteristic": hex16(binary.BigEndian.Uint16(r))}
+	if g, ok := cpuCharacteristicGroups[r[0]]; ok {
+		f["group"] = g
+	}
+	return f
+}
+
+var leds = map[byte]string{
+	1: "SF", 2: "INTF", 3: "EXTF", 4: "RUN", 5: "STOP", 6: "FRCE", 7: "CRST",
+	8: "BAF", 9: "USR", 10: "USR1", 11: "BUS1F", 12: "BUS2F", 13: "REDF",
+	14: "MSTR", 15: "RACK0", 16: "RACK1", 17: "RACK2", 18: "IFM1F",
+	19: "IFM2F", 20: "BUS3F", 21: "MAINT",
+}
+
+var ledFlashing = map[byte]string{0: "no", 1: "fast", 2: "slow"}
+
+func decodeLed(r []byte) map[string]string {
+	if len(r) < 4 {
+		return nil
+	}
+	name, ok := leds[r[1]]
+	if !ok {
+		name = strconv.Itoa(int(r[1]))
+	}
+	return map[string]string{
+		"led":      name,
+		"on":       strconv.FormatBool(r[2] != 0),
+		"flashing": ledFlashing[r[3]],
+	}
+}
+
+// components are the entries of the component identification by index;
+// the others are not text.
+var components = map[uint16]string{
+	1:  "as_name",
+	2:  "module_name",
+	3:  "plant_id",
+	4:  "copyright",
+	5:  "serial_number",
+	7:  "module_type_name",
+	8:  "memory_card_serial_number",
+	11: "location",
+}
+
+func decodeComponentIdentification(r []byte) map[string]string {
+	if len(r) < 2 {
+		return nil
+	}
+	index := binary.BigEndian.Uint16(r)
+	f := map[string]string{"index": hex16(index)}
+	if name, ok := components[index]; ok {
+		f["component"] = name
+		f["value"] = text(r[2:])
+	}
+	return f
+}
+
+var startupSwitches = map[uint16]string{1: "CRST", 2: "WRST"}
+
+// decodeCommunicationStatus decodes the protection data of index 4.
+func decodeCommunicationStatus(r []byte) map[string]string {
+	if len(r) < 2 {
+		return nil
+	}
+	index := binary.BigEndian.Uint16(r)
+	f := map[string]string{"index": hex16(index)}
+	if index != 4 || len(r) < 12 {
+		return f
+	}
+	word := func(i int) string {
+		return strconv.Itoa(int(binary.BigEndian.Uint16(r[i:])))
+	}
+	f["selector_protection_level"] = word(2)
+	f["parameter_protection_level"] = word(4)
+	f["protection_level"] = word(6)
+	f["mode_selector"] = modeSelectors[binary.BigEndian.Uint16(r[8:])]
+	f["startup_switch"] = startupSwitches[binary.BigEndian.Uint16(r[10:])]
+	return f
+}
+
+func decodeOperatingState(r []byte) map[string]string {
+	if len(r) < 4 {
+		return nil
+	}
+	return map[string]string{"state": operatingState(r[3]).String()}
+}
+
+func decodeDiagnostic(r []byte) map[string]string {
+	if len(r) < diagnosticEntrySize {
+		return nil
+	}
+	e := decodeDiagnosticEntry(r)
+	t, _ := ptypes.Timestamp(e.GetTime())
+	return map[string]string{
+		"event_id":  hex16(uint16(e.GetEventId())),
+		"priority":  strconv.Itoa(int(e.GetPriority())),
+		"ob_number": strconv.Itoa(int(e.GetObNumber())),
+		"time":      t.Format(time.RFC3339Nano),
+		"text":      e.GetText(),
+	}
+}
+
+// checkS7 refuses plc unless it is an S7 CPU.
+func checkS7(plc *pb.Plc) error {
+	d, err := lookup(plc, nil)
+	if err != nil {
+		return err
+	}
+	if _, ok := d.(Driver); !ok {
+		return unsupported(driver.ErrUnsupported)
+	}
+	return nil
+}
+
+// s7Conn connects to plc, which has to be an S7 CPU.
+func s7Conn(ctx context.Context, plc *pb.Plc) (*conn, error) {
+	if err := checkS7(plc); err != nil {
+		return nil, err
+	}
+	c, err := Driver{}.Connect(ctx, plc)
+	if err != nil {
+		return nil, err
+	}
+	return c.(*conn), nil
+}
+
+func (s *PlcServer) ReadSzl(ctx context.Context, req *pb.SzlReq) (*pb.Szl, error) {
+	if req.GetId() > 0xFFFF || req.GetIndex() > 0xFFFF {
+		return nil, status.Error(codes.InvalidArgument, "SZL id and index are 16 bit")
+	}
+	c, err := s7Conn(ctx, req.GetPlc())
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	list, err := readSzl(c.handler, uint16(req.GetId()), uint16(req.GetIndex()))
+	if unavailable(err) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &pb.Szl{Id: uint32(list.id), Index: uint32(list.index), RecordSize: uint32(list.size)}
+	decode := szlDecoders[byte(list.id)]
+	for i := 0; i < list.count; i++ {
+		r := list.record(i)
+		if r == nil {
+			break
+		}
+		record := &pb.SzlRecord{Raw: r}
+		if decode != nil {
+			record.Fields = decode(r)
+		}
+		res.Records = append(res.Records, record)
+	}
+	return res, nil
+}
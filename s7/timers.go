@@ -0,0 +1,140 @@
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	gos7 "github.com/thinkontrolsy/gos7"
+)
+
+// Timers and counters are words of areas of their own, addressed by number
+// with the transport size of the area. gos7 cuts their number of words to
+// a byte, so they are read and written here.
+
+const (
+	funcReadVar  = 0x04
+	funcWriteVar = 0x05
+
+	areaCounters = 0x1C
+	areaTimers   = 0x1D
+
+	// the transport size of the data of a write, bytes
+	transportOctets = 0x09
+	itemOK          = 0xFF
+)
+
+// itemError is the return code of a read or write item that failed.
+type itemError byte
+
+var itemErrorTexts = map[itemError]string{
+	0x01: "hardware fault",
+	0x05: "address out of range",
+	0x06: "data type not supported",
+	0x07: "data type inconsistent",
+	0x0A: "object does not exist",
+}
+
+func (e itemError) Error() string {
+	if text, ok := itemErrorTexts[e]; ok {
+		return fmt.Sprintf("s7: %s (%#02x)", text, byte(e))
+	}
+	return fmt.Sprintf("s7: item error %#02x", byte(e))
+}
+
+// itemResult is the error of the return code of an item, if any.
+func itemResult(code byte) error {
+	switch code {
+	case itemOK:
+		return nil
+	case 0x03:
+		return errAccessDenied
+	}
+	return itemError(code)
+}
+
+// timerArea is the area code of the tag area T or C.
+func timerArea(area string) byte {
+	if area == "T" {
+		return areaTimers
+	}
+	return areaCounters
+}
+
+// varParams are the parameters of a read or write of n words of area from
+// number on.
+func varParams(function, area byte, number, n int) []byte {
+	return []byte{
+		function, 1,
+		0x12, 0x0A, 0x10, area, byte(n >> 8), byte(n), 0, 0,
+		area, byte(number >> 16), byte(number >> 8), byte(number),
+	}
+}
+
+// itemData returns the data of the item of a read or write response, after
+// its return code, once the return code is checked.
+func itemData(resp []byte) ([]byte, error) {
+	if len(resp) < ackParams || resp[8] != rosctrAckData {
+		return nil, errShortResponse
+	}
+	at := ackParams + int(binary.BigEndian.Uint16(resp[13:]))
+	if len(resp) <= at {
+		return nil, errShortResponse
+	}
+	if err := itemResult(resp[at]); err != nil {
+		return nil, err
+	}
+	return resp[at+1:], nil
+}
+
+// readTimers reads the words of area, areaTimers or areaCounters, from
+// number on into b, in as many requests as the PDU size takes.
+func readTimers(h *gos7.TCPClientHandler, area byte, number int, b []byte) error {
+	// the most words an ack data PDU carries
+	most := (h.PDULength - (ackParams - 7) - 2 - 4) / 2
+	for len(b) > 0 {
+		n := len(b) / 2
+		if n > most {
+			n = most
+		}
+		resp, err := exchange(h, telegram(rosctrJob, varParams(funcReadVar, area, number, n), nil))
+		if err != nil {
+			return err
+		}
+		data, err := itemData(resp)
+		if err != nil {
+			return err
+		}
+		// transport size and length before the words
+		if len(data) < 3+2*n {
+			return errShortResponse
+		}
+		copy(b, data[3:3+2*n])
+		b = b[2*n:]
+		number += n
+	}
+	return nil
+}
+
+// writeTimers writes b to the words of area from number on, in as many
+// requests as the PDU size takes.
+func writeTimers(h *gos7.TCPClientHandler, area byte, number int, b []byte) error {
+	// the most words a job PDU carries after the item
+	most := (h.PDULength - (jobParams - 7) - 14 - 4) / 2
+	for len(b) > 0 {
+		n := len(b) / 2
+		if n > most {
+			n = most
+		}
+		data := append([]byte{0, transportOctets, byte(2 * n >> 8), byte(2 * n)}, b[:2*n]...)
+		resp, err := exchange(h, telegram(rosctrJob, varParams(funcWriteVar, area, number, n), data))
+		if err != nil {
+			return err
+		}
+		if _, err := itemData(resp); err != nil {
+			return err
+		}
+		b = b[2*n:]
+		number += n
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package snapshot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// Expand returns the tags of the fields of udts, each named
+// udt.field.
+func Expand(udts []*pb.Udt) ([]*pb.Tag, []string, error) {
+	var tags []*pb.Tag
+	var names []string
+	for _, u := range udts {
+		base, err := (&pb.Tag{Address: u.GetAddress(), Dt: "Byte"}).GetArea()
+		if err != nil {
+			return nil, nil, fmt.Errorf("snapshot: UDT %s at %q: %v", u.GetName(), u.GetAddress(), err)
+		}
+		for _, f := range u.GetFields() {
+			address := fmt.Sprintf("%sP%d", base.Area, base.Start+int(f.GetOffset()))
+			if f.GetDt() == "Bool" {
+				address += fmt.Sprintf(".%d", f.GetBit())
+			}
+			tags = append(tags, &pb.Tag{Address: address, Dt: f.GetDt()})
+			names = append(names, u.GetName()+"."+f.GetName())
+		}
+	}
+	return tags, names, nil
+}
+
+// Diff compares current to base: the runs of bytes that differ where both
+// have an area, and the values of tags and the fields of udts there.
+func Diff(base, current *pb.Snapshot, tags []*pb.Tag, udts []*pb.Udt) (*pb.SnapshotDiff, error) {
+	fields, fieldNames, err := Expand(udts)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(tags), len(tags)+len(fields))
+	tags = append(append([]*pb.Tag(nil), tags...), fields...)
+	names = append(names, fieldNames...)
+
+	diff := &pb.SnapshotDiff{BaseTaken: base.GetTaken(), CurrentTaken: current.GetTaken()}
+	diff.Unmatched = append(unmatched(base, current), unmatched(current, base)...)
+	for _, a := range base.GetAreas() {
+		for _, b := range current.GetAreas() {
+			if !strings.EqualFold(a.GetArea(), b.GetArea()) {
+				continue
+			}
+			diff.Bytes = append(diff.Bytes, compare(a, b)...)
+		}
+	}
+	for i, tag := range tags {
+		address, err := tag.GetArea()
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: %s %s: %v", tag.GetAddress(), tag.GetDt(), err)
+		}
+		before := bytesAt(base, address)
+		after := bytesAt(current, address)
+		if before == nil || after == nil {
+			continue
+		}
+		b, a := value(tag, before), value(tag, after)
+		if b != a {
+			diff.Tags = append(diff.Tags, &pb.TagChange{Name: names[i], Address: tag.GetAddress(), Dt: tag.GetDt(), Before: b, After: a})
+		}
+	}
+	return diff, nil
+}
+
+// unmatched returns the areas of a that b has nothing of.
+func unmatched(a, b *pb.Snapshot) []string {
+	var names []string
+	for _, x := range a.GetAreas() {
+		found := false
+		for _, y := range b.GetAreas() {
+			found = found || strings.EqualFold(x.GetArea(), y.GetArea())
+		}
+		if !found {
+			names = append(names, x.GetArea())
+		}
+	}
+	return names
+}
+
+// compare returns the runs of bytes that differ where a and b overlap.
+func compare(a, b *pb.SnapshotArea) []*pb.ByteChange {
+	from, to := a.GetStart(), a.GetStart()+uint32(len(a.GetData()))
+	if s := b.GetStart(); s > from {
+		from = s
+	}
+	if e := b.GetStart() + uint32(len(b.GetData())); e < to {
+		to = e
+	}
+	var changes []*pb.ByteChange
+	for i := from; i < to; {
+		x, y := a.GetData()[i-a.GetStart()], b.GetData()[i-b.GetStart()]
+		if x == y {
+			i++
+			continue
+		}
+		j := i
+		for j < to && a.GetData()[j-a.GetStart()] != b.GetData()[j-b.GetStart()] {
+			j++
+		}
+		changes = append(changes, &pb.ByteChange{
+			Area:   a.GetArea(),
+			Start:  i,
+			Before: a.GetData()[i-a.GetStart() : j-a.GetStart()],
+			After:  b.GetData()[i-b.GetStart() : j-b.GetStart()],
+		})
+		i = j
+	}
+	return changes
+}
+
+// bytesAt returns the bytes of s at address, nil if s does not have all of
+// them.
+func bytesAt(s *pb.Snapshot, address *pb.TagAddress) []byte {
+	for _, a := range s.GetAreas() {
+		from := address.Start - int(a.GetStart())
+		if !strings.EqualFold(a.GetArea(), address.Area) || from < 0 || from+address.Amount > len(a.GetData()) {
+			continue
+		}
+		return a.GetData()[from : from+address.Amount]
+	}
+	return nil
+}
+
+// value decodes b as tag and formats it: bit strings in hexadecimal, as in
+// 16#00FF, reals always with a point, characters and strings quoted.
+func value(tag *pb.Tag, b []byte) string {
+	t := &pb.Tag{Address: tag.GetAddress(), Dt: tag.GetDt()}
+	t.SetTagValue(b)
+	switch dt := t.GetDt(); {
+	case dt == "Byte" || dt == "Word" || dt == "DWord" || dt == "LWord":
+		return fmt.Sprintf("16#%0*X", 2*t.GetLength(), t.GetBitString())
+	case dt == "Char":
+		return fmt.Sprintf("%q", t.GetValueString())
+	case dt == "String" || strings.HasPrefix(dt, "String["):
+		v := t.GetValueString()
+		if n := int(b[1]); n < len(v) {
+			v = v[:n]
+		}
+		return fmt.Sprintf("%q", v)
+	case dt == "Real" || dt == "LReal":
+		bits := 64
+		if dt == "Real" {
+			bits = 32
+		}
+		v := strconv.FormatFloat(t.GetValueDouble(), 'g', -1, bits)
+		if !strings.ContainsAny(v, ".eIN") {
+			v += ".0"
+		}
+		return v
+	}
+	return fmt.Sprint(t.GetJSONValue())
+}
+
+// FormatByteChange formats c as DB5:12-13 4060 → 4080.
+func FormatByteChange(c *pb.ByteChange) string {
+	r := FormatRange(&pb.AreaRange{Area: c.GetArea(), Start: c.GetStart(), Size: uint32(len(c.GetBefore()))})
+	return fmt.Sprintf("%s %X → %X", r, c.GetBefore(), c.GetAfter())
+}
+
+// FormatTagChange formats c as DB5P12 Real 3.5 → 4.0, after the name of the
+// UDT field if it is one.
+func FormatTagChange(c *pb.TagChange) string {
+	s := fmt.Sprintf("%s %s %s → %s", c.GetAddress(), c.GetDt(), c.GetBefore(), c.GetAfter())
+	if c.GetName() != "" {
+		return c.GetName() + " " + s
+	}
+	return s
+}
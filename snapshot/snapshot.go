@@ -0,0 +1,159 @@
+// Package snapshot keeps the contents of DBs and M memory of S7 CPUs in
+// files, and picks the parts of a snapshot to restore.
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+// Version is the version of the snapshot format written.
+const Version = 1
+
+var (
+	areaRe  = regexp.MustCompile(`^(M|DB([1-9][0-9]*))$`)
+	rangeRe = regexp.MustCompile(`^([^:]+)(?::([0-9]+)(?:-([0-9]+))?)?$`)
+)
+
+// Area parses the name of an area, M or DBn, returning the number of the
+// DB, 0 for M.
+func Area(name string) (int, error) {
+	m := areaRe.FindStringSubmatch(strings.ToUpper(name))
+	if m == nil {
+		return 0, fmt.Errorf("snapshot: area %q is neither M nor a DB", name)
+	}
+	if m[2] == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil || n > 0xFFFF {
+		return 0, fmt.Errorf("snapshot: no DB %s", m[2])
+	}
+	return n, nil
+}
+
+// ParseRange parses a range of an area: the area, as in DB5, for all of
+// it, DB5:10 from byte 10, or DB5:10-29 for bytes 10 to 29.
+func ParseRange(s string) (*pb.AreaRange, error) {
+	m := rangeRe.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("snapshot: range %q is not area[:start[-end]]", s)
+	}
+	if _, err := Area(m[1]); err != nil {
+		return nil, err
+	}
+	r := &pb.AreaRange{Area: strings.ToUpper(m[1])}
+	if m[2] != "" {
+		start, err := strconv.ParseUint(m[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: range %q: %v", s, err)
+		}
+		r.Start = uint32(start)
+	}
+	if m[3] != "" {
+		end, err := strconv.ParseUint(m[3], 10, 32)
+		if err != nil || uint32(end) < r.Start {
+			return nil, fmt.Errorf("snapshot: range %q ends before it starts", s)
+		}
+		r.Size = uint32(end) - r.Start + 1
+	}
+	return r, nil
+}
+
+// FormatRange formats r as ParseRange parses it.
+func FormatRange(r *pb.AreaRange) string {
+	switch {
+	case r.GetSize() > 0:
+		return fmt.Sprintf("%s:%d-%d", r.GetArea(), r.GetStart(), r.GetStart()+r.GetSize()-1)
+	case r.GetStart() > 0:
+		return fmt.Sprintf("%s:%d", r.GetArea(), r.GetStart())
+	}
+	return r.GetArea()
+}
+
+// Select returns the parts of the areas of s that ranges cover, all of them
+// when ranges is empty. A range s does not hold all of is an error.
+func Select(s *pb.Snapshot, ranges []*pb.AreaRange) ([]*pb.SnapshotArea, error) {
+	if len(ranges) == 0 {
+		return s.GetAreas(), nil
+	}
+	var parts []*pb.SnapshotArea
+	for _, r := range ranges {
+		part, err := find(s, r)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// find returns the part of an area of s that r covers.
+func find(s *pb.Snapshot, r *pb.AreaRange) (*pb.SnapshotArea, error) {
+	for _, a := range s.GetAreas() {
+		if !strings.EqualFold(a.GetArea(), r.GetArea()) || r.GetStart() < a.GetStart() {
+			continue
+		}
+		from := int(r.GetStart() - a.GetStart())
+		to := len(a.GetData())
+		if r.GetSize() > 0 {
+			to = from + int(r.GetSize())
+		}
+		if from >= len(a.GetData()) || to > len(a.GetData()) {
+			continue
+		}
+		return &pb.SnapshotArea{Area: a.GetArea(), Start: r.GetStart(), AreaSize: a.GetAreaSize(), Data: a.GetData()[from:to]}, nil
+	}
+	return nil, fmt.Errorf("snapshot: %s is not in the snapshot", FormatRange(r))
+}
+
+// Write writes s as indented JSON.
+func Write(w io.Writer, s *pb.Snapshot) error {
+	m := jsonpb.Marshaler{Indent: "  "}
+	return m.Marshal(w, s)
+}
+
+// Read reads a snapshot Write wrote, refusing versions it does not know.
+func Read(r io.Reader) (*pb.Snapshot, error) {
+	s := &pb.Snapshot{}
+	if err := jsonpb.Unmarshal(r, s); err != nil {
+		return nil, fmt.Errorf("snapshot: %v", err)
+	}
+	switch v := s.GetVersion(); {
+	case v == 0:
+		return nil, fmt.Errorf("snapshot: no version, not a snapshot")
+	case v > Version:
+		return nil, fmt.Errorf("snapshot: version %d is newer than %d", v, Version)
+	}
+	return s, nil
+}
+
+// Save writes s to the file at path.
+func Save(path string, s *pb.Snapshot) error {
+	var b bytes.Buffer
+	if err := Write(&b, s); err != nil {
+		return err
+	}
+	b.WriteByte('\n')
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
+
+// Load reads the snapshot in the file at path.
+func Load(path string) (*pb.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
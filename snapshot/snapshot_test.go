@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+)
+
+func TestParseRange(t *testing.T) {
+	for s, want := range map[string]*pb.AreaRange{
+		"DB5":       {Area: "DB5"},
+		"db5:10":    {Area: "DB5", Start: 10},
+		"DB5:10-29": {Area: "DB5", Start: 10, Size: 20},
+		"M:0-0":     {Area: "M", Size: 1},
+	} {
+		r, err := ParseRange(s)
+		if err != nil || !proto.Equal(r, want) {
+			t.Errorf("%s: %v %v", s, r, err)
+		}
+		if f := FormatRange(r); !strings.EqualFold(f, s) {
+			t.Errorf("%s formats as %s", s, f)
+		}
+	}
+	for _, s := range []string{"", "Q", "DB0", "DB5:", "DB5:10-9", "DB5:x", "DB70000"} {
+		if _, err := ParseRange(s); err == nil {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	s := &pb.Snapshot{Version: Version, Areas: []*pb.SnapshotArea{
+		{Area: "DB5", AreaSize: 8, Data: []byte{0, 1, 2, 3, 4, 5, 6, 7}},
+		{Area: "M", Start: 10, AreaSize: 256, Data: []byte{10, 11, 12}},
+	}}
+	if parts, err := Select(s, nil); err != nil || len(parts) != 2 {
+		t.Fatalf("all of it: %v %v", parts, err)
+	}
+	parts, err := Select(s, []*pb.AreaRange{{Area: "db5", Start: 6}, {Area: "M", Start: 11, Size: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parts[0].GetData(), []byte{6, 7}) || parts[0].GetStart() != 6 || parts[0].GetAreaSize() != 8 ||
+		!bytes.Equal(parts[1].GetData(), []byte{11}) || parts[1].GetStart() != 11 {
+		t.Fatalf("parts %v", parts)
+	}
+	for _, r := range []*pb.AreaRange{{Area: "DB6"}, {Area: "DB5", Start: 8}, {Area: "DB5", Start: 4, Size: 5}, {Area: "M", Start: 9}} {
+		if _, err := Select(s, []*pb.AreaRange{r}); err == nil {
+			t.Errorf("%s selected", FormatRange(r))
+		}
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	s := &pb.Snapshot{Version: Version, Plc: &pb.Plc{Host: "10.0.0.1"}, Areas: []*pb.SnapshotArea{{Area: "DB5", AreaSize: 2, Data: []byte{1, 2}}}}
+	var b bytes.Buffer
+	if err := Write(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bytes.NewReader(b.Bytes()))
+	if err != nil || !proto.Equal(got, s) {
+		t.Fatalf("read back %v %v", got, err)
+	}
+	for _, v := range []uint32{0, Version + 1} {
+		b.Reset()
+		s.Version = v
+		Write(&b, s)
+		if _, err := Read(&b); err == nil {
+			t.Errorf("read version %d", v)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	real := func(v float32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, math.Float32bits(v))
+		return b
+	}
+	data := func(real12 []byte, flags, count byte, name string) []byte {
+		d := make([]byte, 40)
+		copy(d[12:], real12)
+		d[16], d[19] = flags, count
+		d[20], d[21] = 10, byte(len(name))
+		copy(d[22:], name)
+		return d
+	}
+	base := &pb.Snapshot{Version: Version, Areas: []*pb.SnapshotArea{
+		{Area: "DB5", AreaSize: 40, Data: data(real(3.5), 0x01, 7, "pump")},
+		{Area: "DB6", Data: []byte{1}},
+	}}
+	current := &pb.Snapshot{Version: Version, Areas: []*pb.SnapshotArea{
+		{Area: "DB5", AreaSize: 40, Data: data(real(4), 0x03, 7, "pumps")},
+		{Area: "M", Data: []byte{1}},
+	}}
+	tags := []*pb.Tag{
+		{Address: "DB5P12", Dt: "Real"},
+		{Address: "DB5P16.0", Dt: "Bool"},
+		{Address: "DB5P19", Dt: "USInt"},
+		{Address: "DB7P0", Dt: "Int"},
+	}
+	udts := []*pb.Udt{{Name: "valve", Address: "DB5P16", Fields: []*pb.UdtField{
+		{Name: "open", Dt: "Bool", Bit: 1},
+		{Name: "state", Dt: "Byte"},
+		{Name: "name", Dt: "String[10]", Offset: 4},
+	}}}
+	diff, err := Diff(base, current, tags, udts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range diff.GetBytes() {
+		got = append(got, FormatByteChange(c))
+	}
+	for _, c := range diff.GetTags() {
+		got = append(got, FormatTagChange(c))
+	}
+	want := []string{
+		"DB5:13-13 60 → 80",
+		"DB5:16-16 01 → 03",
+		"DB5:21-21 04 → 05",
+		"DB5:26-26 00 → 73",
+		"DB5P12 Real 3.5 → 4.0",
+		"valve.open DB5P16.1 Bool false → true",
+		"valve.state DB5P16 Byte 16#01 → 16#03",
+		`valve.name DB5P20 String[10] "pump" → "pumps"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diff\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if u := diff.GetUnmatched(); !reflect.DeepEqual(u, []string{"DB6", "M"}) {
+		t.Fatalf("unmatched %v", u)
+	}
+	if _, err := Diff(base, current, []*pb.Tag{{Address: "DB5X", Dt: "Int"}}, nil); err == nil {
+		t.Fatal("diff with a bad tag")
+	}
+}
@@ -0,0 +1,191 @@
+package sparkplug
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	ptypes "github.com/golang/protobuf/ptypes"
+
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	spb "github.com/thinkontrolsy/goplc/sparkplug/sparkplug_b"
+)
+
+// DataTypes maps every name in pb.DT to the Sparkplug datatype its metric
+// is published as. Bit strings become unsigned integers of the same width,
+// dates and times of day become DateTime (ms since the Unix epoch) and
+// durations become signed integers: Int32 milliseconds for S5Time and
+// Time, Int64 nanoseconds for LTime.
+var DataTypes = map[string]spb.DataType{
+	"Bool": spb.DataType_Boolean,
+	"Byte": spb.DataType_UInt8,
+	"Char": spb.DataType_String,
+
+	"Word":  spb.DataType_UInt16,
+	"DWord": spb.DataType_UInt32,
+	"LWord": spb.DataType_UInt64,
+
+	"SInt":  spb.DataType_Int8,
+	"USInt": spb.DataType_UInt8,
+
+	"Int":  spb.DataType_Int16,
+	"UInt": spb.DataType_UInt16,
+
+	"DInt":  spb.DataType_Int32,
+	"UDInt": spb.DataType_UInt32,
+
+	"LInt":  spb.DataType_Int64,
+	"ULInt": spb.DataType_UInt64,
+
+	"Real":  spb.DataType_Float,
+	"LReal": spb.DataType_Double,
+
+	"DTL": spb.DataType_DateTime,
+
+	"Date":          spb.DataType_DateTime,
+	"Date_And_Time": spb.DataType_DateTime,
+	"LDT":           spb.DataType_DateTime,
+
+	"LTime":        spb.DataType_Int64,
+	"LTime_Of_Day": spb.DataType_DateTime,
+
+	"S5Time":      spb.DataType_Int32,
+	"Time":        spb.DataType_Int32,
+	"Time_Of_Day": spb.DataType_DateTime,
+
+	"Counter": spb.DataType_UInt16,
+
+	"String": spb.DataType_String,
+}
+
+var stringReg = regexp.MustCompile(pb.DT_REG)
+
+// DataType returns the Sparkplug datatype for a tag datatype name,
+// including String[n].
+func DataType(dt string) (spb.DataType, error) {
+	if t, ok := DataTypes[dt]; ok {
+		return t, nil
+	}
+	if stringReg.MatchString(dt) {
+		return spb.DataType_String, nil
+	}
+	return spb.DataType_Unknown, fmt.Errorf("Datatype illegal")
+}
+
+func millis(t time.Time) uint64 {
+	return uint64(t.UnixNano() / int64(time.Millisecond))
+}
+
+// metric converts a tag to a metric named name. A tag without a value
+// gives a null metric.
+func metric(name string, tag *pb.Tag, ts time.Time) (*spb.Payload_Metric, error) {
+	dt, err := DataType(tag.GetDt())
+	if err != nil {
+		return nil, err
+	}
+	m := &spb.Payload_Metric{
+		Name:      &name,
+		Timestamp: newUint64(millis(ts)),
+		Datatype:  newUint32(uint32(dt)),
+	}
+	if tag.GetValue() == nil {
+		m.IsNull = newBool(true)
+		return m, nil
+	}
+	switch dt {
+	case spb.DataType_Boolean:
+		m.Value = &spb.Payload_Metric_BooleanValue{BooleanValue: tag.GetValueBool()}
+	case spb.DataType_Int8, spb.DataType_Int16, spb.DataType_Int32,
+		spb.DataType_UInt8, spb.DataType_UInt16, spb.DataType_UInt32:
+		{
+			var v int64
+			switch tag.GetDt() {
+			case "S5Time", "Time":
+				d, _ := ptypes.Duration(tag.GetValueDuration())
+				v = int64(d / time.Millisecond)
+			case "Byte", "Word", "DWord":
+				v = int64(tag.GetBitString())
+			default:
+				v = tag.GetValueInteger()
+			}
+			// signed values travel as their two's complement
+			m.Value = &spb.Payload_Metric_IntValue{IntValue: uint32(v)}
+		}
+	case spb.DataType_Int64, spb.DataType_UInt64:
+		{
+			var v uint64
+			switch tag.GetDt() {
+			case "LTime":
+				d, _ := ptypes.Duration(tag.GetValueDuration())
+				v = uint64(d)
+			case "LWord":
+				v = tag.GetBitString()
+			case "ULInt":
+				v = tag.GetValueUinteger()
+			default:
+				v = uint64(tag.GetValueInteger())
+			}
+			m.Value = &spb.Payload_Metric_LongValue{LongValue: v}
+		}
+	case spb.DataType_Float:
+		m.Value = &spb.Payload_Metric_FloatValue{FloatValue: float32(tag.GetValueDouble())}
+	case spb.DataType_Double:
+		m.Value = &spb.Payload_Metric_DoubleValue{DoubleValue: tag.GetValueDouble()}
+	case spb.DataType_DateTime:
+		{
+			t, _ := ptypes.Timestamp(tag.GetValueTimestamp())
+			m.Value = &spb.Payload_Metric_LongValue{LongValue: millis(t)}
+		}
+	default:
+		m.Value = &spb.Payload_Metric_StringValue{StringValue: tag.GetValueString()}
+	}
+	return m, nil
+}
+
+// setMetricValue stores the value of a command metric in tag.
+func setMetricValue(tag *pb.Tag, m *spb.Payload_Metric) error {
+	dt, err := DataType(tag.GetDt())
+	if err != nil {
+		return err
+	}
+	if m.GetIsNull() || m.GetValue() == nil {
+		return fmt.Errorf("%s: metric has no value", m.GetName())
+	}
+	var v interface{}
+	switch dt {
+	case spb.DataType_Boolean:
+		v = m.GetBooleanValue()
+	case spb.DataType_Int8:
+		v = int64(int8(m.GetIntValue()))
+	case spb.DataType_Int16:
+		v = int64(int16(m.GetIntValue()))
+	case spb.DataType_Int32:
+		v = int64(int32(m.GetIntValue()))
+	case spb.DataType_UInt8, spb.DataType_UInt16, spb.DataType_UInt32:
+		v = uint64(m.GetIntValue())
+	case spb.DataType_Int64:
+		v = int64(m.GetLongValue())
+	case spb.DataType_UInt64:
+		v = m.GetLongValue()
+	case spb.DataType_Float:
+		v = float64(m.GetFloatValue())
+	case spb.DataType_Double:
+		v = m.GetDoubleValue()
+	case spb.DataType_DateTime:
+		ms := int64(m.GetLongValue())
+		v = time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano)
+	default:
+		v = m.GetStringValue()
+	}
+	switch tag.GetDt() {
+	case "S5Time", "Time":
+		v = (time.Duration(v.(int64)) * time.Millisecond).String()
+	case "LTime":
+		v = time.Duration(v.(int64)).String()
+	}
+	return tag.SetJSONValue(v)
+}
+
+func newUint64(v uint64) *uint64 { return &v }
+func newUint32(v uint32) *uint32 { return &v }
+func newBool(v bool) *bool       { return &v }
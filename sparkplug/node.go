@@ -0,0 +1,394 @@
+// Package sparkplug runs goplc as a Sparkplug B edge node: every configured
+// PLC is a device whose metrics are its configured tags.
+package sparkplug
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/mqtt"
+	"github.com/thinkontrolsy/goplc/poll"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	spb "github.com/thinkontrolsy/goplc/sparkplug/sparkplug_b"
+)
+
+const (
+	Namespace = "spBv1.0"
+
+	NBIRTH = "NBIRTH"
+	NDEATH = "NDEATH"
+	NDATA  = "NDATA"
+	NCMD   = "NCMD"
+	DBIRTH = "DBIRTH"
+	DDEATH = "DDEATH"
+	DDATA  = "DDATA"
+	DCMD   = "DCMD"
+
+	MetricBdSeq   = "bdSeq"
+	MetricRebirth = "Node Control/Rebirth"
+
+	publishTimeout = 5 * time.Second
+
+	// a lost connection to the broker is made again after
+	// minReconnectInterval, doubling up to maxReconnectInterval
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+var errUnknownDevice = errors.New("unknown device")
+
+type Config struct {
+	Broker     string `json:"broker"`
+	ClientID   string `json:"client_id"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	GroupID    string `json:"group_id"`
+	EdgeNodeID string `json:"edge_node_id"`
+}
+
+// Topic returns the Sparkplug topic of a message type, for the node itself
+// when deviceID is empty.
+func (c Config) Topic(msgType, deviceID string) string {
+	topic := Namespace + "/" + c.GroupID + "/" + msgType + "/" + c.EdgeNodeID
+	if deviceID != "" {
+		topic += "/" + deviceID
+	}
+	return topic
+}
+
+type device struct {
+	plc    config.Plc
+	online bool
+	tags   []*pb.Tag
+	times  []time.Time
+}
+
+type Node struct {
+	config Config
+	server mqtt.PlcRW
+
+	ctx context.Context
+
+	mu sync.Mutex
+	// client is the connection to the broker, bdSeq its birth and death
+	// sequence number and will the NDEATH it leaves
+	client    paho.Client
+	bdSeq     uint64
+	will      []byte
+	nextBdSeq uint64
+	seq       uint64
+	devices   []*device
+}
+
+func NewNode(c Config, plcs config.Plcs, server mqtt.PlcRW) *Node {
+	n := &Node{config: c, server: server}
+	for _, plc := range plcs {
+		n.devices = append(n.devices, &device{
+			plc:   plc,
+			tags:  plc.PbTags(),
+			times: make([]time.Time, len(plc.Tags)),
+		})
+	}
+	return n
+}
+
+// Run connects to the broker, births the node and polls every device until
+// ctx is done, then publishes the death certificates and disconnects. A
+// lost connection is made again, with the next bdSeq.
+func (n *Node) Run(ctx context.Context) error {
+	n.ctx = ctx
+	lost := make(chan struct{}, 1)
+	if err := n.connect(lost); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range n.devices {
+		wg.Add(1)
+		go func(d *device) {
+			defer wg.Done()
+			g := poll.Group{Plc: d.plc.Pb(), Tags: d.plc.PbTags(), Interval: d.plc.GetInterval()}
+			poll.Watch(ctx, n.server, g, func(samples []poll.Sample) {
+				n.update(d, samples)
+			})
+		}(d)
+	}
+	n.reconnect(ctx, lost)
+	wg.Wait()
+
+	n.mu.Lock()
+	for _, d := range n.devices {
+		if d.online {
+			d.online = false
+			n.publish(DDEATH, d.plc.GetName(), nil)
+		}
+	}
+	client, will := n.client, n.will
+	n.mu.Unlock()
+	if client.IsConnected() {
+		if token := client.Publish(n.config.Topic(NDEATH, ""), 1, false, will); !token.WaitTimeout(publishTimeout) {
+			log.Printf("sparkplug: publish %s: timeout", NDEATH)
+		}
+	}
+	client.Disconnect(250)
+	return nil
+}
+
+// connect makes a new connection to the broker. Every connection has a
+// bdSeq of its own, one more than the last, in the NDEATH it leaves as its
+// will and in the NBIRTH published once it is up; so paho does not
+// reconnect by itself with the old will, lost hears of a lost connection.
+func (n *Node) connect(lost chan<- struct{}) error {
+	n.mu.Lock()
+	bdSeq := n.nextBdSeq
+	n.nextBdSeq = (bdSeq + 1) % 256
+	will, err := proto.Marshal(&spb.Payload{
+		Timestamp: newUint64(millis(time.Now())),
+		Metrics:   []*spb.Payload_Metric{bdSeqMetric(bdSeq)},
+	})
+	if err != nil {
+		n.mu.Unlock()
+		return err
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(n.config.Broker).
+		SetClientID(n.config.ClientID).
+		SetUsername(n.config.Username).
+		SetPassword(n.config.Password).
+		SetAutoReconnect(false).
+		SetBinaryWill(n.config.Topic(NDEATH, ""), will, 1, false).
+		SetOnConnectHandler(n.onConnect).
+		SetConnectionLostHandler(func(client paho.Client, err error) {
+			log.Printf("sparkplug: connection lost: %v", err)
+			select {
+			case lost <- struct{}{}:
+			default:
+			}
+		})
+	client := paho.NewClient(opts)
+	n.client, n.bdSeq, n.will = client, bdSeq, will
+	n.mu.Unlock()
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+// reconnect connects again every time the connection is lost, waiting
+// longer after every failed attempt, until ctx is done.
+func (n *Node) reconnect(ctx context.Context, lost chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-lost:
+		}
+		wait := minReconnectInterval
+		for {
+			err := n.connect(lost)
+			if err == nil {
+				break
+			}
+			log.Printf("sparkplug: reconnect: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+			if wait *= 2; wait > maxReconnectInterval {
+				wait = maxReconnectInterval
+			}
+		}
+	}
+}
+
+func (n *Node) onConnect(client paho.Client) {
+	filters := map[string]byte{
+		n.config.Topic(NCMD, ""):  0,
+		n.config.Topic(DCMD, "+"): 0,
+	}
+	if token := client.SubscribeMultiple(filters, n.onCommand); token.Wait() && token.Error() != nil {
+		log.Printf("sparkplug: subscribe: %v", token.Error())
+	}
+	n.birth()
+}
+
+func bdSeqMetric(bdSeq uint64) *spb.Payload_Metric {
+	return &spb.Payload_Metric{
+		Name:     proto.String(MetricBdSeq),
+		Datatype: newUint32(uint32(spb.DataType_Int64)),
+		Value:    &spb.Payload_Metric_LongValue{LongValue: bdSeq},
+	}
+}
+
+// birth publishes NBIRTH followed by DBIRTH for every online device.
+func (n *Node) birth() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.seq = 0
+	n.publish(NBIRTH, "", []*spb.Payload_Metric{
+		bdSeqMetric(n.bdSeq),
+		{
+			Name:     proto.String(MetricRebirth),
+			Datatype: newUint32(uint32(spb.DataType_Boolean)),
+			Value:    &spb.Payload_Metric_BooleanValue{BooleanValue: false},
+		},
+	})
+	for _, d := range n.devices {
+		if d.online {
+			n.publish(DBIRTH, d.plc.GetName(), n.metrics(d, nil))
+		}
+	}
+}
+
+// metrics converts the cached tags of d at indexes to metrics, every tag
+// when indexes is nil.
+func (n *Node) metrics(d *device, indexes []int) []*spb.Payload_Metric {
+	if indexes == nil {
+		for i := range d.tags {
+			indexes = append(indexes, i)
+		}
+	}
+	var metrics []*spb.Payload_Metric
+	for _, i := range indexes {
+		ts := d.times[i]
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		m, err := metric(d.plc.Tags[i].GetName(), d.tags[i], ts)
+		if err != nil {
+			log.Printf("sparkplug: %s: %v", d.tags[i].GetAddress(), err)
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// update handles a poll result: a failed read kills the device, the first
+// good read after that births it again and later changes become DDATA.
+func (n *Node) update(d *device, samples []poll.Sample) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var changed []int
+	dead := false
+	for _, s := range samples {
+		if s.Quality == poll.Bad {
+			dead = true
+			continue
+		}
+		d.tags[s.Index] = s.Tag
+		d.times[s.Index] = s.Time
+		changed = append(changed, s.Index)
+	}
+	switch {
+	case dead:
+		if d.online {
+			d.online = false
+			n.publish(DDEATH, d.plc.GetName(), nil)
+		}
+	case !d.online:
+		d.online = true
+		n.publish(DBIRTH, d.plc.GetName(), n.metrics(d, nil))
+	case len(changed) > 0:
+		n.publish(DDATA, d.plc.GetName(), n.metrics(d, changed))
+	}
+}
+
+// publish sends a payload with the next sequence number. n.mu must be held
+// so that sequence numbers go out in order.
+func (n *Node) publish(msgType, deviceID string, metrics []*spb.Payload_Metric) {
+	if n.client == nil || !n.client.IsConnected() {
+		return
+	}
+	payload := &spb.Payload{
+		Timestamp: newUint64(millis(time.Now())),
+		Metrics:   metrics,
+		Seq:       newUint64(n.seq),
+	}
+	n.seq = (n.seq + 1) % 256
+	data, err := proto.Marshal(payload)
+	if err != nil {
+		log.Printf("sparkplug: %s: %v", msgType, err)
+		return
+	}
+	topic := n.config.Topic(msgType, deviceID)
+	token := n.client.Publish(topic, 0, false, data)
+	if !token.WaitTimeout(publishTimeout) {
+		log.Printf("sparkplug: publish %s: timeout", topic)
+	} else if token.Error() != nil {
+		log.Printf("sparkplug: publish %s: %v", topic, token.Error())
+	}
+}
+
+func (n *Node) onCommand(client paho.Client, msg paho.Message) {
+	var payload spb.Payload
+	if err := proto.Unmarshal(msg.Payload(), &payload); err != nil {
+		log.Printf("sparkplug: %s: %v", msg.Topic(), err)
+		return
+	}
+	levels := strings.Split(msg.Topic(), "/")
+	if len(levels) < 4 {
+		return
+	}
+	switch levels[2] {
+	case NCMD:
+		for _, m := range payload.GetMetrics() {
+			if m.GetName() == MetricRebirth && m.GetBooleanValue() {
+				go n.birth()
+			}
+		}
+	case DCMD:
+		if len(levels) != 5 {
+			return
+		}
+		go func() {
+			if err := n.write(levels[4], payload.GetMetrics()); err != nil {
+				log.Printf("sparkplug: %s: %v", msg.Topic(), err)
+			}
+		}()
+	}
+}
+
+// write writes DCMD metrics to the writable tags of the same name.
+func (n *Node) write(deviceID string, metrics []*spb.Payload_Metric) error {
+	var d *device
+	for _, dev := range n.devices {
+		if dev.plc.GetName() == deviceID {
+			d = dev
+		}
+	}
+	if d == nil {
+		return errUnknownDevice
+	}
+	var tags []*pb.Tag
+	for _, m := range metrics {
+		t, ok := d.plc.Tag(m.GetName())
+		if !ok || !t.Writable {
+			log.Printf("sparkplug: %s: metric %q is not writable", deviceID, m.GetName())
+			continue
+		}
+		tag := t.Pb()
+		if err := setMetricValue(tag, m); err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	ctx := n.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, err := n.server.WriteTags(ctx, &pb.RWReq{Plc: d.plc.Pb(), Tags: tags})
+	return err
+}
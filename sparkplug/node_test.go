@@ -0,0 +1,205 @@
+package sparkplug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/golang/protobuf/proto"
+	ptypes "github.com/golang/protobuf/ptypes"
+
+	"github.com/thinkontrolsy/goplc/config"
+	"github.com/thinkontrolsy/goplc/internal/mqtttest"
+	"github.com/thinkontrolsy/goplc/internal/plctest"
+	pb "github.com/thinkontrolsy/goplc/s7/plc_api"
+	spb "github.com/thinkontrolsy/goplc/sparkplug/sparkplug_b"
+)
+
+func TestDataTypes(t *testing.T) {
+	for dt := range pb.DT {
+		if _, ok := DataTypes[dt]; !ok {
+			t.Errorf("%s has no Sparkplug datatype", dt)
+		}
+	}
+	expected := map[string]spb.DataType{
+		"Bool":       spb.DataType_Boolean,
+		"Byte":       spb.DataType_UInt8,
+		"Word":       spb.DataType_UInt16,
+		"SInt":       spb.DataType_Int8,
+		"Int":        spb.DataType_Int16,
+		"UDInt":      spb.DataType_UInt32,
+		"LInt":       spb.DataType_Int64,
+		"ULInt":      spb.DataType_UInt64,
+		"Real":       spb.DataType_Float,
+		"LReal":      spb.DataType_Double,
+		"DTL":        spb.DataType_DateTime,
+		"Time":       spb.DataType_Int32,
+		"LTime":      spb.DataType_Int64,
+		"String":     spb.DataType_String,
+		"String[12]": spb.DataType_String,
+	}
+	for dt, want := range expected {
+		if got, err := DataType(dt); err != nil || got != want {
+			t.Errorf("%s: got %v %v, want %v", dt, got, err, want)
+		}
+	}
+	if _, err := DataType("Float"); err == nil {
+		t.Error("unknown datatype accepted")
+	}
+}
+
+func TestMetricRoundTrip(t *testing.T) {
+	ts, _ := ptypes.TimestampProto(time.Date(2020, 3, 16, 7, 4, 34, 0, time.UTC))
+	tags := []*pb.Tag{
+		{Address: "DB2P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: -2345}},
+		{Address: "DB2P2", Dt: "USInt", Value: &pb.Tag_ValueInteger{ValueInteger: 200}},
+		{Address: "DB2P4", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+		{Address: "DB2P8", Dt: "LInt", Value: &pb.Tag_ValueInteger{ValueInteger: -234123}},
+		{Address: "DB2P16", Dt: "Word", Value: &pb.Tag_ValueBytes{ValueBytes: []byte{0x12, 0x34}}},
+		{Address: "DB2P18", Dt: "DTL", Value: &pb.Tag_ValueTimestamp{ValueTimestamp: ts}},
+		{Address: "DB2P30", Dt: "Time", Value: &pb.Tag_ValueDuration{ValueDuration: ptypes.DurationProto(-1500 * time.Millisecond)}},
+		{Address: "DB2P34.1", Dt: "Bool", Value: &pb.Tag_ValueBool{ValueBool: true}},
+		{Address: "DB2P36", Dt: "String[12]", Value: &pb.Tag_ValueString{ValueString: "abc"}},
+	}
+	for _, tag := range tags {
+		m, err := metric(tag.GetAddress(), tag, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := &pb.Tag{Address: tag.GetAddress(), Dt: tag.GetDt()}
+		if err := setMetricValue(got, m); err != nil {
+			t.Fatalf("%s: %v", tag.GetAddress(), err)
+		}
+		if !proto.Equal(got, tag) {
+			t.Errorf("%s: got %v, want %v", tag.GetAddress(), got, tag)
+		}
+	}
+}
+
+type message struct {
+	topic   string
+	payload spb.Payload
+}
+
+func TestNode(t *testing.T) {
+	broker := mqtttest.NewBroker(t)
+	defer broker.Close()
+
+	plc := plctest.NewMemoryPlc(
+		&pb.Tag{Address: "DB2P0", Dt: "Int", Value: &pb.Tag_ValueInteger{ValueInteger: 42}},
+		&pb.Tag{Address: "DB2P4", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 12.5}},
+	)
+	plcs := config.Plcs{{
+		Name:     "line1",
+		Host:     "127.0.0.1",
+		Interval: config.Duration(20 * time.Millisecond),
+		Tags: []config.Tag{
+			{Name: "speed", Address: "DB2P0", Dt: "Int", Writable: true},
+			{Name: "temperature", Address: "DB2P4", Dt: "Real"},
+		},
+	}}
+	c := Config{Broker: broker.URL(), ClientID: "edge", GroupID: "plant", EdgeNodeID: "goplc"}
+
+	received := make(chan message, 64)
+	host := paho.NewClient(paho.NewClientOptions().AddBroker(broker.URL()).SetClientID("host"))
+	if token := host.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer host.Disconnect(0)
+	host.Subscribe("spBv1.0/plant/+/goplc/#", 0, func(c paho.Client, m paho.Message) {
+		var p spb.Payload
+		if err := proto.Unmarshal(m.Payload(), &p); err != nil {
+			t.Error(err)
+		}
+		received <- message{m.Topic(), p}
+	}).Wait()
+
+	node := NewNode(c, plcs, plc)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- node.Run(ctx) }()
+
+	expect := func(topic string) spb.Payload {
+		for {
+			select {
+			case m := <-received:
+				if m.topic == topic {
+					return m.payload
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout waiting for %s", topic)
+			}
+		}
+	}
+	metrics := func(p spb.Payload) map[string]*spb.Payload_Metric {
+		m := make(map[string]*spb.Payload_Metric)
+		for _, metric := range p.GetMetrics() {
+			m[metric.GetName()] = metric
+		}
+		return m
+	}
+
+	nbirth := expect(c.Topic(NBIRTH, ""))
+	if nbirth.GetSeq() != 0 || metrics(nbirth)[MetricBdSeq] == nil {
+		t.Fatalf("NBIRTH: %v", nbirth)
+	}
+	dbirth := metrics(expect(c.Topic(DBIRTH, "line1")))
+	if m := dbirth["speed"]; m.GetDatatype() != uint32(spb.DataType_Int16) || m.GetIntValue() != 42 {
+		t.Fatalf("DBIRTH speed: %v", m)
+	}
+	if m := dbirth["temperature"]; m.GetDatatype() != uint32(spb.DataType_Float) || m.GetFloatValue() != 12.5 {
+		t.Fatalf("DBIRTH temperature: %v", m)
+	}
+
+	plc.Set(&pb.Tag{Address: "DB2P4", Dt: "Real", Value: &pb.Tag_ValueDouble{ValueDouble: 13}})
+	ddata := metrics(expect(c.Topic(DDATA, "line1")))
+	if len(ddata) != 1 || ddata["temperature"].GetFloatValue() != 13 {
+		t.Fatalf("DDATA: %v", ddata)
+	}
+
+	cmd, _ := proto.Marshal(&spb.Payload{Metrics: []*spb.Payload_Metric{
+		{Name: proto.String("speed"), Datatype: newUint32(uint32(spb.DataType_Int16)), Value: &spb.Payload_Metric_IntValue{IntValue: 7}},
+		{Name: proto.String("temperature"), Datatype: newUint32(uint32(spb.DataType_Float)), Value: &spb.Payload_Metric_FloatValue{FloatValue: 99}},
+	}})
+	host.Publish(c.Topic(DCMD, "line1"), 0, false, cmd).Wait()
+	ddata = metrics(expect(c.Topic(DDATA, "line1")))
+	if ddata["speed"].GetIntValue() != 7 {
+		t.Fatalf("DDATA after DCMD: %v", ddata)
+	}
+	if v := plc.Get("DB2P4").GetValueDouble(); v != 13 {
+		t.Fatalf("read only metric written: %v", v)
+	}
+
+	plc.SetOffline(true)
+	expect(c.Topic(DDEATH, "line1"))
+	plc.SetOffline(false)
+	expect(c.Topic(DBIRTH, "line1"))
+
+	rebirth, _ := proto.Marshal(&spb.Payload{Metrics: []*spb.Payload_Metric{
+		{Name: proto.String(MetricRebirth), Datatype: newUint32(uint32(spb.DataType_Boolean)), Value: &spb.Payload_Metric_BooleanValue{BooleanValue: true}},
+	}})
+	host.Publish(c.Topic(NCMD, ""), 0, false, rebirth).Wait()
+	if p := expect(c.Topic(NBIRTH, "")); p.GetSeq() != 0 {
+		t.Fatalf("rebirth seq: %d", p.GetSeq())
+	}
+	expect(c.Topic(DBIRTH, "line1"))
+
+	// a lost connection leaves its NDEATH; the next one has the next bdSeq
+	bdSeq := metrics(nbirth)[MetricBdSeq].GetLongValue()
+	broker.Drop("edge")
+	if m := metrics(expect(c.Topic(NDEATH, "")))[MetricBdSeq]; m.GetLongValue() != bdSeq {
+		t.Fatalf("will bdSeq %v, NBIRTH %d", m, bdSeq)
+	}
+	if m := metrics(expect(c.Topic(NBIRTH, "")))[MetricBdSeq]; m.GetLongValue() != bdSeq+1 {
+		t.Fatalf("NBIRTH bdSeq after reconnecting %v, want %d", m, bdSeq+1)
+	}
+	expect(c.Topic(DBIRTH, "line1"))
+
+	cancel()
+	<-done
+	expect(c.Topic(DDEATH, "line1"))
+	if m := metrics(expect(c.Topic(NDEATH, "")))[MetricBdSeq]; m.GetLongValue() != bdSeq+1 {
+		t.Fatalf("NDEATH bdSeq %v, want %d", m, bdSeq+1)
+	}
+}
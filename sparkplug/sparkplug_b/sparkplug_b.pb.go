@@ -0,0 +1,445 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: sparkplug_b.proto
+
+package sparkplug_b
+
+import (
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type DataType int32
+
+const (
+	DataType_Unknown  DataType = 0
+	DataType_Int8     DataType = 1
+	DataType_Int16    DataType = 2
+	DataType_Int32    DataType = 3
+	DataType_Int64    DataType = 4
+	DataType_UInt8    DataType = 5
+	DataType_UInt16   DataType = 6
+	DataType_UInt32   DataType = 7
+	DataType_UInt64   DataType = 8
+	DataType_Float    DataType = 9
+	DataType_Double   DataType = 10
+	DataType_Boolean  DataType = 11
+	DataType_String   DataType = 12
+	DataType_DateTime DataType = 13
+	DataType_Text     DataType = 14
+	DataType_UUID     DataType = 15
+	DataType_DataSet  DataType = 16
+	DataType_Bytes    DataType = 17
+	DataType_File     DataType = 18
+	DataType_Template DataType = 19
+)
+
+var DataType_name = map[int32]string{
+	0:  "Unknown",
+	1:  "Int8",
+	2:  "Int16",
+	3:  "Int32",
+	4:  "Int64",
+	5:  "UInt8",
+	6:  "UInt16",
+	7:  "UInt32",
+	8:  "UInt64",
+	9:  "Float",
+	10: "Double",
+	11: "Boolean",
+	12: "String",
+	13: "DateTime",
+	14: "Text",
+	15: "UUID",
+	16: "DataSet",
+	17: "Bytes",
+	18: "File",
+	19: "Template",
+}
+
+var DataType_value = map[string]int32{
+	"Unknown":  0,
+	"Int8":     1,
+	"Int16":    2,
+	"Int32":    3,
+	"Int64":    4,
+	"UInt8":    5,
+	"UInt16":   6,
+	"UInt32":   7,
+	"UInt64":   8,
+	"Float":    9,
+	"Double":   10,
+	"Boolean":  11,
+	"String":   12,
+	"DateTime": 13,
+	"Text":     14,
+	"UUID":     15,
+	"DataSet":  16,
+	"Bytes":    17,
+	"File":     18,
+	"Template": 19,
+}
+
+func (x DataType) Enum() *DataType {
+	p := new(DataType)
+	*p = x
+	return p
+}
+
+func (x DataType) String() string {
+	return proto.EnumName(DataType_name, int32(x))
+}
+
+func (x *DataType) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(DataType_value, data, "DataType")
+	if err != nil {
+		return err
+	}
+	*x = DataType(value)
+	return nil
+}
+
+func (DataType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_a8b2a114189166f7, []int{0}
+}
+
+type Payload struct {
+	Timestamp            *uint64           `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Metrics              []*Payload_Metric `protobuf:"bytes,2,rep,name=metrics" json:"metrics,omitempty"`
+	Seq                  *uint64           `protobuf:"varint,3,opt,name=seq" json:"seq,omitempty"`
+	Uuid                 *string           `protobuf:"bytes,4,opt,name=uuid" json:"uuid,omitempty"`
+	Body                 []byte            `protobuf:"bytes,5,opt,name=body" json:"body,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *Payload) Reset()         { *m = Payload{} }
+func (m *Payload) String() string { return proto.CompactTextString(m) }
+func (*Payload) ProtoMessage()    {}
+func (*Payload) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a8b2a114189166f7, []int{0}
+}
+
+func (m *Payload) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Payload.Unmarshal(m, b)
+}
+func (m *Payload) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Payload.Marshal(b, m, deterministic)
+}
+func (m *Payload) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Payload.Merge(m, src)
+}
+func (m *Payload) XXX_Size() int {
+	return xxx_messageInfo_Payload.Size(m)
+}
+func (m *Payload) XXX_DiscardUnknown() {
+	xxx_messageInfo_Payload.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Payload proto.InternalMessageInfo
+
+func (m *Payload) GetTimestamp() uint64 {
+	if m != nil && m.Timestamp != nil {
+		return *m.Timestamp
+	}
+	return 0
+}
+
+func (m *Payload) GetMetrics() []*Payload_Metric {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+func (m *Payload) GetSeq() uint64 {
+	if m != nil && m.Seq != nil {
+		return *m.Seq
+	}
+	return 0
+}
+
+func (m *Payload) GetUuid() string {
+	if m != nil && m.Uuid != nil {
+		return *m.Uuid
+	}
+	return ""
+}
+
+func (m *Payload) GetBody() []byte {
+	if m != nil {
+		return m.Body
+	}
+	return nil
+}
+
+type Payload_Metric struct {
+	Name         *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Alias        *uint64 `protobuf:"varint,2,opt,name=alias" json:"alias,omitempty"`
+	Timestamp    *uint64 `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Datatype     *uint32 `protobuf:"varint,4,opt,name=datatype" json:"datatype,omitempty"`
+	IsHistorical *bool   `protobuf:"varint,5,opt,name=is_historical,json=isHistorical" json:"is_historical,omitempty"`
+	IsTransient  *bool   `protobuf:"varint,6,opt,name=is_transient,json=isTransient" json:"is_transient,omitempty"`
+	IsNull       *bool   `protobuf:"varint,7,opt,name=is_null,json=isNull" json:"is_null,omitempty"`
+	// Types that are valid to be assigned to Value:
+	//	*Payload_Metric_IntValue
+	//	*Payload_Metric_LongValue
+	//	*Payload_Metric_FloatValue
+	//	*Payload_Metric_DoubleValue
+	//	*Payload_Metric_BooleanValue
+	//	*Payload_Metric_StringValue
+	//	*Payload_Metric_BytesValue
+	Value                isPayload_Metric_Value `protobuf_oneof:"value"`
+	XXX_NoUnkeyedLiteral struct{}               `json:"-"`
+	XXX_unrecognized     []byte                 `json:"-"`
+	XXX_sizecache        int32                  `json:"-"`
+}
+
+func (m *Payload_Metric) Reset()         { *m = Payload_Metric{} }
+func (m *Payload_Metric) String() string { return proto.CompactTextString(m) }
+func (*Payload_Metric) ProtoMessage()    {}
+func (*Payload_Metric) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a8b2a114189166f7, []int{0, 0}
+}
+
+func (m *Payload_Metric) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Payload_Metric.Unmarshal(m, b)
+}
+func (m *Payload_Metric) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Payload_Metric.Marshal(b, m, deterministic)
+}
+func (m *Payload_Metric) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Payload_Metric.Merge(m, src)
+}
+func (m *Payload_Metric) XXX_Size() int {
+	return xxx_messageInfo_Payload_Metric.Size(m)
+}
+func (m *Payload_Metric) XXX_DiscardUnknown() {
+	xxx_messageInfo_Payload_Metric.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Payload_Metric proto.InternalMessageInfo
+
+func (m *Payload_Metric) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Payload_Metric) GetAlias() uint64 {
+	if m != nil && m.Alias != nil {
+		return *m.Alias
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetTimestamp() uint64 {
+	if m != nil && m.Timestamp != nil {
+		return *m.Timestamp
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetDatatype() uint32 {
+	if m != nil && m.Datatype != nil {
+		return *m.Datatype
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetIsHistorical() bool {
+	if m != nil && m.IsHistorical != nil {
+		return *m.IsHistorical
+	}
+	return false
+}
+
+func (m *Payload_Metric) GetIsTransient() bool {
+	if m != nil && m.IsTransient != nil {
+		return *m.IsTransient
+	}
+	return false
+}
+
+func (m *Payload_Metric) GetIsNull() bool {
+	if m != nil && m.IsNull != nil {
+		return *m.IsNull
+	}
+	return false
+}
+
+type isPayload_Metric_Value interface {
+	isPayload_Metric_Value()
+}
+
+type Payload_Metric_IntValue struct {
+	IntValue uint32 `protobuf:"varint,10,opt,name=int_value,json=intValue,oneof"`
+}
+
+type Payload_Metric_LongValue struct {
+	LongValue uint64 `protobuf:"varint,11,opt,name=long_value,json=longValue,oneof"`
+}
+
+type Payload_Metric_FloatValue struct {
+	FloatValue float32 `protobuf:"fixed32,12,opt,name=float_value,json=floatValue,oneof"`
+}
+
+type Payload_Metric_DoubleValue struct {
+	DoubleValue float64 `protobuf:"fixed64,13,opt,name=double_value,json=doubleValue,oneof"`
+}
+
+type Payload_Metric_BooleanValue struct {
+	BooleanValue bool `protobuf:"varint,14,opt,name=boolean_value,json=booleanValue,oneof"`
+}
+
+type Payload_Metric_StringValue struct {
+	StringValue string `protobuf:"bytes,15,opt,name=string_value,json=stringValue,oneof"`
+}
+
+type Payload_Metric_BytesValue struct {
+	BytesValue []byte `protobuf:"bytes,16,opt,name=bytes_value,json=bytesValue,oneof"`
+}
+
+func (*Payload_Metric_IntValue) isPayload_Metric_Value() {}
+
+func (*Payload_Metric_LongValue) isPayload_Metric_Value() {}
+
+func (*Payload_Metric_FloatValue) isPayload_Metric_Value() {}
+
+func (*Payload_Metric_DoubleValue) isPayload_Metric_Value() {}
+
+func (*Payload_Metric_BooleanValue) isPayload_Metric_Value() {}
+
+func (*Payload_Metric_StringValue) isPayload_Metric_Value() {}
+
+func (*Payload_Metric_BytesValue) isPayload_Metric_Value() {}
+
+func (m *Payload_Metric) GetValue() isPayload_Metric_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *Payload_Metric) GetIntValue() uint32 {
+	if x, ok := m.GetValue().(*Payload_Metric_IntValue); ok {
+		return x.IntValue
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetLongValue() uint64 {
+	if x, ok := m.GetValue().(*Payload_Metric_LongValue); ok {
+		return x.LongValue
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetFloatValue() float32 {
+	if x, ok := m.GetValue().(*Payload_Metric_FloatValue); ok {
+		return x.FloatValue
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetDoubleValue() float64 {
+	if x, ok := m.GetValue().(*Payload_Metric_DoubleValue); ok {
+		return x.DoubleValue
+	}
+	return 0
+}
+
+func (m *Payload_Metric) GetBooleanValue() bool {
+	if x, ok := m.GetValue().(*Payload_Metric_BooleanValue); ok {
+		return x.BooleanValue
+	}
+	return false
+}
+
+func (m *Payload_Metric) GetStringValue() string {
+	if x, ok := m.GetValue().(*Payload_Metric_StringValue); ok {
+		return x.StringValue
+	}
+	return ""
+}
+
+func (m *Payload_Metric) GetBytesValue() []byte {
+	if x, ok := m.GetValue().(*Payload_Metric_BytesValue); ok {
+		return x.BytesValue
+	}
+	return nil
+}
+
+// XXX_OneofWrappers is for the internal use of the proto package.
+func (*Payload_Metric) XXX_OneofWrappers() []interface{} {
+	return []interface{}{
+		(*Payload_Metric_IntValue)(nil),
+		(*Payload_Metric_LongValue)(nil),
+		(*Payload_Metric_FloatValue)(nil),
+		(*Payload_Metric_DoubleValue)(nil),
+		(*Payload_Metric_BooleanValue)(nil),
+		(*Payload_Metric_StringValue)(nil),
+		(*Payload_Metric_BytesValue)(nil),
+	}
+}
+
+func init() {
+	proto.RegisterEnum("org.eclipse.tahu.protobuf.DataType", DataType_name, DataType_value)
+	proto.RegisterType((*Payload)(nil), "org.eclipse.tahu.protobuf.Payload")
+	proto.RegisterType((*Payload_Metric)(nil), "org.eclipse.tahu.protobuf.Payload.Metric")
+}
+
+func init() { proto.RegisterFile("sparkplug_b.proto", fileDescriptor_a8b2a114189166f7) }
+
+var fileDescriptor_a8b2a114189166f7 = []byte{
+	// 563 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x53, 0x5d, 0x6f, 0xd3, 0x30,
+	0x14, 0x6d, 0xd6, 0x8f, 0x34, 0x37, 0xe9, 0xe6, 0x19, 0x24, 0xc2, 0x04, 0xa2, 0x63, 0x42, 0x2a,
+	0x3c, 0x54, 0x62, 0x9b, 0x26, 0x9e, 0xcb, 0x34, 0x65, 0x0f, 0x20, 0xe4, 0xb5, 0x3c, 0xf0, 0x52,
+	0x39, 0xab, 0xb7, 0x59, 0x73, 0xec, 0x10, 0x3b, 0x40, 0x7f, 0x24, 0xbf, 0x06, 0x89, 0x67, 0x74,
+	0x9d, 0xb4, 0x7c, 0x48, 0xbc, 0x1d, 0x9f, 0x7b, 0x7c, 0x7c, 0xce, 0x8d, 0x02, 0xfb, 0xb6, 0xe4,
+	0xd5, 0x7d, 0xa9, 0xea, 0xdb, 0x65, 0x3e, 0x2d, 0x2b, 0xe3, 0x0c, 0x7d, 0x6c, 0xaa, 0xdb, 0xa9,
+	0xb8, 0x56, 0xb2, 0xb4, 0x62, 0xea, 0xf8, 0x5d, 0xdd, 0xf0, 0x79, 0x7d, 0xf3, 0xfc, 0x67, 0x0f,
+	0xc2, 0x0f, 0x7c, 0xad, 0x0c, 0x5f, 0xd1, 0x27, 0x10, 0x39, 0x59, 0x08, 0xeb, 0x78, 0x51, 0xa6,
+	0xc1, 0x38, 0x98, 0xf4, 0xd8, 0x6f, 0x82, 0xbe, 0x85, 0xb0, 0x10, 0xae, 0x92, 0xd7, 0x36, 0xdd,
+	0x19, 0x77, 0x27, 0xf1, 0xf1, 0xcb, 0xe9, 0x7f, 0x6d, 0xa7, 0xad, 0xe5, 0xf4, 0x9d, 0xbf, 0xc1,
+	0x36, 0x37, 0x29, 0x81, 0xae, 0x15, 0x9f, 0xd3, 0xae, 0x37, 0x47, 0x48, 0x29, 0xf4, 0xea, 0x5a,
+	0xae, 0xd2, 0xde, 0x38, 0x98, 0x44, 0xcc, 0x63, 0xe4, 0x72, 0xb3, 0x5a, 0xa7, 0xfd, 0x71, 0x30,
+	0x49, 0x98, 0xc7, 0x07, 0xdf, 0xbb, 0x30, 0x68, 0xdc, 0x70, 0xac, 0x79, 0x21, 0x7c, 0xc4, 0x88,
+	0x79, 0x4c, 0x1f, 0x42, 0x9f, 0x2b, 0xc9, 0x31, 0x1b, 0x5a, 0x37, 0x87, 0xbf, 0x1b, 0x75, 0xff,
+	0x6d, 0x74, 0x00, 0xc3, 0x15, 0x77, 0xdc, 0xad, 0x4b, 0xe1, 0x9f, 0x1f, 0xb1, 0xed, 0x99, 0x1e,
+	0xc1, 0x48, 0xda, 0xe5, 0x9d, 0xb4, 0xce, 0x54, 0xf2, 0x9a, 0x2b, 0x9f, 0x65, 0xc8, 0x12, 0x69,
+	0xb3, 0x2d, 0x47, 0x0f, 0x21, 0x91, 0x76, 0xe9, 0x2a, 0xae, 0xad, 0x14, 0xda, 0xa5, 0x03, 0xaf,
+	0x89, 0xa5, 0x9d, 0x6f, 0x28, 0xfa, 0x08, 0x42, 0x69, 0x97, 0xba, 0x56, 0x2a, 0x0d, 0xfd, 0x74,
+	0x20, 0xed, 0xfb, 0x5a, 0x29, 0xfa, 0x14, 0x22, 0xa9, 0xdd, 0xf2, 0x0b, 0x57, 0xb5, 0x48, 0x01,
+	0x5f, 0xcf, 0x3a, 0x6c, 0x28, 0xb5, 0xfb, 0x88, 0x0c, 0x7d, 0x06, 0xa0, 0x8c, 0xbe, 0x6d, 0xe7,
+	0x31, 0x46, 0xcf, 0x3a, 0x2c, 0x42, 0xae, 0x11, 0x1c, 0x42, 0x7c, 0xa3, 0x0c, 0xdf, 0x38, 0x24,
+	0xe3, 0x60, 0xb2, 0x93, 0x75, 0x18, 0x78, 0xb2, 0x91, 0x1c, 0x41, 0xb2, 0x32, 0x75, 0xae, 0x44,
+	0xab, 0x19, 0x8d, 0x83, 0x49, 0x90, 0x75, 0x58, 0xdc, 0xb0, 0x8d, 0xe8, 0x05, 0x8c, 0x72, 0x63,
+	0x94, 0xe0, 0xba, 0x55, 0xed, 0x62, 0xcc, 0xac, 0xc3, 0x92, 0x96, 0xde, 0x7a, 0x59, 0x57, 0xc9,
+	0x6d, 0xa2, 0x3d, 0xdc, 0x3d, 0x7a, 0x35, 0xec, 0x36, 0x53, 0xbe, 0x76, 0xc2, 0xb6, 0x1a, 0x82,
+	0x9f, 0x0f, 0x33, 0x79, 0xd2, 0x4b, 0x66, 0x21, 0xf4, 0xfd, 0xf0, 0xd5, 0x8f, 0x00, 0x86, 0xe7,
+	0xdc, 0xf1, 0x39, 0x6e, 0x3b, 0x86, 0x70, 0xa1, 0xef, 0xb5, 0xf9, 0xaa, 0x49, 0x87, 0x0e, 0xa1,
+	0x77, 0xa9, 0xdd, 0x1b, 0x12, 0xd0, 0x08, 0xfa, 0x97, 0xda, 0xbd, 0x3e, 0x23, 0x3b, 0x2d, 0x3c,
+	0x39, 0x26, 0xdd, 0x16, 0x9e, 0x9d, 0x92, 0x1e, 0xc2, 0x85, 0xd7, 0xf6, 0x29, 0xc0, 0x60, 0xd1,
+	0x88, 0x07, 0x1b, 0x7c, 0x72, 0x4c, 0xc2, 0x0d, 0x3e, 0x3b, 0x25, 0x43, 0x94, 0x5f, 0xe0, 0x7a,
+	0x48, 0x84, 0xf4, 0xb9, 0xdf, 0x02, 0x01, 0x7c, 0x7d, 0xd6, 0x74, 0x25, 0x31, 0x0e, 0xae, 0x7c,
+	0x25, 0x92, 0xd0, 0xc4, 0x47, 0x14, 0x73, 0x59, 0x08, 0x32, 0xc2, 0x5c, 0x73, 0xf1, 0xcd, 0x91,
+	0x5d, 0x44, 0x8b, 0xc5, 0xe5, 0x39, 0xd9, 0xc3, 0xab, 0x58, 0xe2, 0x4a, 0x38, 0x42, 0xd0, 0x7e,
+	0x86, 0x4d, 0xc9, 0x3e, 0x2a, 0x2e, 0xa4, 0x12, 0x84, 0xa2, 0xc7, 0x5c, 0x14, 0xa5, 0xe2, 0x4e,
+	0x90, 0x07, 0xb3, 0xd1, 0xa7, 0xf8, 0x8f, 0xdf, 0xf3, 0xd7, 0x00, 0x9c, 0xfe, 0xfa, 0x23, 0xac,
+	0x03, 0x00, 0x00,
+}
@@ -0,0 +1,324 @@
+package web
+
+const indexHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>goplc</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<header>
+  <h1>goplc</h1>
+  <span id="caller"></span>
+</header>
+
+<section>
+  <h2>PLC</h2>
+  <form id="plc">
+    <label>Host <input name="host" required placeholder="192.168.0.1"></label>
+    <label>Rack <input name="rack" type="number" min="0" value="0"></label>
+    <label>Slot <input name="slot" type="number" min="0" value="1"></label>
+    <label>Port <input name="port" type="number" min="0" placeholder="102"></label>
+    <label>Password ref <input name="password_ref" placeholder="none"></label>
+    <button>Connect</button>
+  </form>
+  <table id="info" hidden>
+    <tr><th>Module type</th><td data-field="module_type_name"></td></tr>
+    <tr><th>Serial number</th><td data-field="serial_number"></td></tr>
+    <tr><th>Station</th><td data-field="as_name"></td></tr>
+    <tr><th>Module name</th><td data-field="module_name"></td></tr>
+    <tr><th>Copyright</th><td data-field="copyright"></td></tr>
+    <tr><th>Order code</th><td data-field="order_code"></td></tr>
+    <tr><th>Firmware</th><td data-field="firmware_version"></td></tr>
+    <tr><th>Family</th><td data-field="cpu_family"></td></tr>
+    <tr><th>Protection level</th><td data-field="protection_level"></td></tr>
+    <tr><th>Mode selector</th><td data-field="mode_selector"></td></tr>
+  </table>
+</section>
+
+<section>
+  <h2>Watch table</h2>
+  <form id="add">
+    <input name="tag" required placeholder="DB2P0:Int" pattern="[^:\s]+:[^:\s]+">
+    <label>Rate <select name="rate">
+      <option value="250">250 ms</option>
+      <option value="1000" selected>1 s</option>
+      <option value="5000">5 s</option>
+    </select></label>
+    <button>Add</button>
+  </form>
+  <table id="watch">
+    <thead><tr><th>Address</th><th>Type</th><th>Value</th><th>Quality</th><th>Time</th><th class="write">Write</th><th></th></tr></thead>
+    <tbody></tbody>
+  </table>
+</section>
+
+<p id="status" role="status"></p>
+<script src="app.js"></script>
+</body>
+</html>
+`
+
+const appJS = `"use strict";
+
+const state = { plc: null, rows: [], role: "viewer", ws: null, sub: 0, rate: 1000 };
+const $ = (sel) => document.querySelector(sel);
+
+function status(text, error) {
+  const el = $("#status");
+  el.textContent = text || "";
+  el.className = error ? "error" : "";
+}
+
+async function request(method, url, body) {
+  const resp = await fetch(url, {
+    method,
+    headers: body ? { "Content-Type": "application/json" } : {},
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  const data = await resp.json();
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+async function loadCaller() {
+  try {
+    const me = await request("GET", "../me");
+    state.role = me.role;
+    $("#caller").textContent = (me.name ? me.name + " · " : "") + me.role;
+  } catch (e) {
+    status(e.message, true);
+  }
+  document.body.classList.toggle("can-write", state.role !== "viewer");
+}
+
+async function connect(ev) {
+  ev.preventDefault();
+  const f = new FormData(ev.target);
+  state.plc = {
+    host: f.get("host"),
+    rack: Number(f.get("rack") || 0),
+    slot: Number(f.get("slot") || 0),
+    port: Number(f.get("port") || 0),
+    password_ref: f.get("password_ref") || undefined,
+  };
+  const q = new URLSearchParams({ rack: state.plc.rack, slot: state.plc.slot });
+  if (state.plc.port) {
+    q.set("port", state.plc.port);
+  }
+  if (state.plc.password_ref) {
+    q.set("password_ref", state.plc.password_ref);
+  }
+  status("Connecting…");
+  try {
+    const info = await request("GET", "../plcs/" + encodeURIComponent(state.plc.host) + "/info?" + q);
+    for (const td of document.querySelectorAll("#info [data-field]")) {
+      td.textContent = info[td.dataset.field] || "";
+    }
+    $("#info").hidden = false;
+    status("");
+  } catch (e) {
+    $("#info").hidden = true;
+    status(e.message, true);
+  }
+  subscribe();
+}
+
+function addRow(ev) {
+  ev.preventDefault();
+  const f = new FormData(ev.target);
+  const [address, dt] = f.get("tag").trim().split(":");
+  state.rate = Number(f.get("rate"));
+  if (!state.rows.some((r) => r.address === address && r.dt === dt)) {
+    state.rows.push({ address, dt });
+  }
+  ev.target.tag.value = "";
+  render();
+  subscribe();
+}
+
+function removeRow(i) {
+  state.rows.splice(i, 1);
+  render();
+  subscribe();
+}
+
+function render() {
+  const body = $("#watch tbody");
+  body.textContent = "";
+  state.rows.forEach((row, i) => {
+    const tr = document.createElement("tr");
+    row.tr = tr;
+    for (const text of [row.address, row.dt]) {
+      const td = document.createElement("td");
+      td.textContent = text;
+      tr.appendChild(td);
+    }
+    for (const cls of ["value", "quality", "time"]) {
+      const td = document.createElement("td");
+      td.className = cls;
+      tr.appendChild(td);
+    }
+    const write = document.createElement("td");
+    write.className = "write";
+    const form = document.createElement("form");
+    const input = document.createElement("input");
+    input.name = "value";
+    input.required = true;
+    const button = document.createElement("button");
+    button.textContent = "Write";
+    form.append(input, button);
+    form.addEventListener("submit", (ev) => writeRow(ev, row));
+    write.appendChild(form);
+    tr.appendChild(write);
+    const remove = document.createElement("td");
+    const x = document.createElement("button");
+    x.textContent = "✕";
+    x.title = "Remove";
+    x.addEventListener("click", () => removeRow(i));
+    remove.appendChild(x);
+    tr.appendChild(remove);
+    body.appendChild(tr);
+    if (row.last) {
+      show(row, row.last);
+    }
+  });
+}
+
+function show(row, v) {
+  row.last = v;
+  const tr = row.tr;
+  tr.querySelector(".value").textContent = v.value === undefined ? "" : JSON.stringify(v.value);
+  tr.querySelector(".quality").textContent = v.err ? v.quality + ": " + v.err : v.quality;
+  tr.querySelector(".time").textContent = new Date(v.time).toLocaleTimeString();
+  tr.classList.toggle("bad", v.quality !== "good");
+}
+
+// parseValue turns the text of a write field into the JSON value for dt.
+function parseValue(dt, text) {
+  if (dt === "Bool") {
+    return /^(1|true|on)$/i.test(text);
+  }
+  if (/^(Char|String|Date|DTL|Date_And_Time|LDT|Time_Of_Day|LTime_Of_Day|Time|LTime|S5Time)/.test(dt)) {
+    return text;
+  }
+  const n = Number(text);
+  if (text.trim() === "" || Number.isNaN(n)) {
+    throw new Error(text + " is not a number");
+  }
+  return n;
+}
+
+async function writeRow(ev, row) {
+  ev.preventDefault();
+  try {
+    const value = parseValue(row.dt, ev.target.value.value);
+    await request("POST", "../write", { plc: state.plc, tags: [{ address: row.address, dt: row.dt, value }] });
+    status("Wrote " + row.address);
+    ev.target.value.value = "";
+  } catch (e) {
+    status(e.message, true);
+  }
+}
+
+function feed() {
+  if (state.ws && state.ws.readyState <= WebSocket.OPEN) {
+    return state.ws;
+  }
+  const url = new URL("../feed", location.href);
+  url.protocol = url.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(url);
+  ws.addEventListener("open", () => subscribe());
+  ws.addEventListener("message", (ev) => receive(JSON.parse(ev.data)));
+  ws.addEventListener("close", () => {
+    if (state.ws === ws) {
+      state.ws = null;
+      status("Live feed closed, reconnecting…", true);
+      setTimeout(feed, 2000);
+    }
+  });
+  state.ws = ws;
+  return ws;
+}
+
+// subscribe replaces the subscription with one for the current rows.
+function subscribe() {
+  const ws = feed();
+  if (ws.readyState !== WebSocket.OPEN) {
+    return;
+  }
+  if (state.sub) {
+    ws.send(JSON.stringify({ type: "unsubscribe", id: String(state.sub) }));
+  }
+  state.sub++;
+  if (!state.plc || state.rows.length === 0) {
+    return;
+  }
+  ws.send(JSON.stringify({
+    type: "subscribe",
+    id: String(state.sub),
+    plc: state.plc,
+    tags: state.rows.map((r) => ({ address: r.address, dt: r.dt })),
+    interval_ms: state.rate,
+  }));
+}
+
+function receive(m) {
+  if (m.id !== undefined && m.id !== String(state.sub)) {
+    return;
+  }
+  switch (m.type) {
+  case "values":
+    for (const v of m.values) {
+      if (state.rows[v.index]) {
+        show(state.rows[v.index], v);
+      }
+    }
+    break;
+  case "error":
+    status(m.error, true);
+    break;
+  case "subscribed":
+    status("");
+    break;
+  }
+}
+
+$("#plc").addEventListener("submit", connect);
+$("#add").addEventListener("submit", addRow);
+loadCaller();
+feed();
+`
+
+const styleCSS = `body {
+  font-family: system-ui, sans-serif;
+  margin: 0 auto;
+  max-width: 60rem;
+  padding: 0 1rem 2rem;
+  color: #222;
+}
+header {
+  display: flex;
+  align-items: baseline;
+  justify-content: space-between;
+  border-bottom: 1px solid #ccc;
+}
+h1 { font-size: 1.4rem; }
+h2 { font-size: 1.1rem; margin-top: 1.5rem; }
+form { display: flex; flex-wrap: wrap; gap: .5rem; align-items: center; margin: .5rem 0; }
+input[type=number] { width: 4rem; }
+table { border-collapse: collapse; width: 100%; margin-top: .5rem; }
+th, td { text-align: left; padding: .25rem .5rem; border-bottom: 1px solid #eee; }
+td.value { font-family: ui-monospace, monospace; }
+tr.bad td { color: #a00; }
+td form { margin: 0; }
+td input { width: 8rem; }
+.write { display: none; }
+body.can-write .write { display: table-cell; }
+#caller { color: #666; }
+#status.error { color: #a00; }
+`
@@ -0,0 +1,50 @@
+// Package web is a small browser interface to goplc, for looking at and
+// changing a few values without engineering software. It talks to the
+// HTTP/JSON gateway and the live feed of package rest, which must be served
+// by the same server.
+package web
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+type asset struct {
+	contentType string
+	body        string
+}
+
+var assets = map[string]asset{
+	"/index.html": {"text/html; charset=utf-8", indexHTML},
+	"/app.js":     {"application/javascript; charset=utf-8", appJS},
+	"/style.css":  {"text/css; charset=utf-8", styleCSS},
+}
+
+// built is the modification time of every asset.
+var built = time.Now()
+
+// Handler serves the interface. Mount it below a prefix with
+// http.StripPrefix, for example at /ui/.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := path.Clean("/" + r.URL.Path)
+		if name == "/" {
+			name = "/index.html"
+		}
+		a, ok := assets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", a.contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, built, strings.NewReader(a.body))
+	})
+}
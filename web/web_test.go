@@ -0,0 +1,45 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(http.StripPrefix("/ui", Handler()))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		path, contentType, contains string
+		code                        int
+	}{
+		{"/ui/", "text/html; charset=utf-8", `<script src="app.js">`, http.StatusOK},
+		{"/ui/app.js", "application/javascript; charset=utf-8", `"../feed"`, http.StatusOK},
+		{"/ui/style.css", "text/css; charset=utf-8", "can-write", http.StatusOK},
+		{"/ui/../main.go", "", "", http.StatusNotFound},
+		{"/ui/missing", "", "", http.StatusNotFound},
+	} {
+		resp, err := http.Get(srv.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s: status %d, want %d", c.path, resp.StatusCode, c.code)
+			continue
+		}
+		if c.code != http.StatusOK {
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != c.contentType {
+			t.Errorf("%s: content type %q, want %q", c.path, ct, c.contentType)
+		}
+		if !strings.Contains(string(body), c.contains) {
+			t.Errorf("%s lacks %q", c.path, c.contains)
+		}
+	}
+}